@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"github.com/ksensehq/eventnative/schema"
 	_ "github.com/lib/pq"
+	"log"
 )
 
 const (
 	copyTemplate = `copy "%s"."%s"
 					from 's3://%s/%s'
-    				ACCESS_KEY_ID '%s'
-    				SECRET_ACCESS_KEY '%s'
+    				%s
     				region '%s'
-    				json 'auto'`
+    				json 'auto'
+    				timeformat 'auto'`
+	accessKeysCredentialsTemplate = `ACCESS_KEY_ID '%s'
+    				SECRET_ACCESS_KEY '%s'`
+	iamRoleCredentialsTemplate = `IAM_ROLE '%s'`
 )
 
 //AwsRedshift adapter for creating,patching (schema or table), copying data from s3 to redshift
@@ -48,8 +52,13 @@ func (ar *AwsRedshift) OpenTx() (*Transaction, error) {
 }
 
 //Copy transfer data from s3 to redshift by passing COPY request to redshift in provided wrapped transaction
+//Authorize with IAM role if it is configured otherwise with s3 access keys
 func (ar *AwsRedshift) Copy(wrappedTx *Transaction, fileKey, tableName string) error {
-	statement := fmt.Sprintf(copyTemplate, ar.dataSourceProxy.config.Schema, tableName, ar.s3Config.Bucket, fileKey, ar.s3Config.AccessKeyID, ar.s3Config.SecretKey, ar.s3Config.Region)
+	credentials := fmt.Sprintf(accessKeysCredentialsTemplate, ar.s3Config.AccessKeyID, ar.s3Config.SecretKey)
+	if ar.s3Config.IamRole != "" {
+		credentials = fmt.Sprintf(iamRoleCredentialsTemplate, ar.s3Config.IamRole)
+	}
+	statement := fmt.Sprintf(copyTemplate, ar.dataSourceProxy.config.Schema, tableName, ar.s3Config.Bucket, fileKey, credentials, ar.s3Config.Region)
 	_, err := wrappedTx.tx.ExecContext(ar.dataSourceProxy.ctx, statement)
 
 	return err
@@ -66,7 +75,13 @@ func (ar *AwsRedshift) CreateDbSchema(dbSchemaName string) error {
 }
 
 //PatchTableSchema add new columns(from provided schema.Table) to existing table
+//Redshift doesn't support changing column types so widened columns are skipped
 func (ar *AwsRedshift) PatchTableSchema(patchSchema *schema.Table) error {
+	if len(patchSchema.WidenedColumns) > 0 {
+		log.Printf("Warn: Redshift doesn't support column type widening. Table %s columns %s types won't be changed", patchSchema.Name, patchSchema.WidenedColumns.Header())
+		patchSchema = &schema.Table{Name: patchSchema.Name, Columns: patchSchema.Columns}
+	}
+
 	wrappedTx, err := ar.OpenTx()
 	if err != nil {
 		return err
@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"net/http"
+	"strings"
 )
 
 type AwsS3 struct {
@@ -21,6 +22,22 @@ type S3Config struct {
 	SecretKey   string `mapstructure:"secret_access_key"`
 	Bucket      string `mapstructure:"bucket"`
 	Region      string `mapstructure:"region"`
+	//optional objects key prefix e.g. 'events/redshift'
+	Folder string `mapstructure:"folder"`
+	//optional IAM role ARN for Redshift COPY authorization instead of access keys
+	IamRole string `mapstructure:"iam_role"`
+}
+
+//S3SinkConfig dto for deserialized s3 destination parameters (raw events archive)
+//Connection parameters, bucket and folder are in S3Config
+type S3SinkConfig struct {
+	//object key template (text/template) with .Year .Month .Day .Hour fields of upload time (UTC) and uuid function
+	KeyTemplate string `mapstructure:"key_template"`
+	//compress objects with gzip
+	Gzip bool `mapstructure:"gzip"`
+
+	//batch_size - max events in one object, flush_interval_ms - max time window of one object
+	StreamingConfig `mapstructure:",squash"`
 }
 
 func (s3c *S3Config) Validate() error {
@@ -43,6 +60,16 @@ func (s3c *S3Config) Validate() error {
 	return nil
 }
 
+//Key return object key with configured folder prefix
+func (s3c *S3Config) Key(fileName string) string {
+	folder := strings.Trim(s3c.Folder, "/")
+	if folder == "" {
+		return fileName
+	}
+
+	return folder + "/" + fileName
+}
+
 func NewAwsS3(s3Config *S3Config) (*AwsS3, error) {
 	awsConfig := aws.NewConfig().
 		WithCredentials(credentials.NewStaticCredentials(s3Config.AccessKeyID, s3Config.SecretKey, "")).
@@ -52,12 +79,12 @@ func NewAwsS3(s3Config *S3Config) (*AwsS3, error) {
 	return &AwsS3{client: s3.New(s3Session, awsConfig), config: s3Config}, nil
 }
 
-//Create named file on aws s3 with payload
+//Create named file on aws s3 with payload (under configured folder)
 func (a *AwsS3) UploadBytes(fileName string, fileBytes []byte) error {
 	fileType := http.DetectContentType(fileBytes)
 	params := &s3.PutObjectInput{
 		Bucket:      aws.String(a.config.Bucket),
-		Key:         aws.String(fileName),
+		Key:         aws.String(a.config.Key(fileName)),
 		Body:        bytes.NewReader(fileBytes),
 		ContentType: aws.String(fileType),
 	}
@@ -68,8 +95,9 @@ func (a *AwsS3) UploadBytes(fileName string, fileBytes []byte) error {
 	return nil
 }
 
-//Return aws s3 bucket file names filtered by prefix
+//Return aws s3 bucket file keys (with configured folder) filtered by prefix
 func (a *AwsS3) ListBucket(prefix string) ([]string, error) {
+	prefix = a.config.Key(prefix)
 	input := &s3.ListObjectsV2Input{Bucket: &a.config.Bucket, Prefix: &prefix}
 	var files []string
 	for {
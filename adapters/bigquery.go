@@ -3,7 +3,11 @@ package adapters
 import (
 	"cloud.google.com/go/bigquery"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"github.com/ksensehq/eventnative/events"
 	"github.com/ksensehq/eventnative/schema"
 	"google.golang.org/api/googleapi"
 	"log"
@@ -11,13 +15,22 @@ import (
 	"strings"
 )
 
+//flatten default event id path (/eventn_ctx/event_id) which is used as streaming insert id
+const eventIdColumn = "eventn_ctx_event_id"
+
 var (
 	SchemaToBigQuery = map[schema.DataType]bigquery.FieldType{
-		schema.STRING: bigquery.StringFieldType,
+		schema.STRING:    bigquery.StringFieldType,
+		schema.INT64:     bigquery.IntegerFieldType,
+		schema.FLOAT64:   bigquery.FloatFieldType,
+		schema.TIMESTAMP: bigquery.TimestampFieldType,
 	}
 
 	BigQueryToSchema = map[bigquery.FieldType]schema.DataType{
-		bigquery.StringFieldType: schema.STRING,
+		bigquery.StringFieldType:    schema.STRING,
+		bigquery.IntegerFieldType:   schema.INT64,
+		bigquery.FloatFieldType:     schema.FLOAT64,
+		bigquery.TimestampFieldType: schema.TIMESTAMP,
 	}
 )
 
@@ -104,16 +117,17 @@ func (bq *BigQuery) CreateTable(tableSchema *schema.Table) error {
 	}
 
 	bqSchema := bigquery.Schema{}
-	for columnName, column := range tableSchema.Columns {
-		mappedType, ok := SchemaToBigQuery[column.Type]
-		if !ok {
-			log.Println("Unknown BigQuery schema type:", column.Type)
-			mappedType = SchemaToBigQuery[schema.STRING]
-		}
-		bqSchema = append(bqSchema, &bigquery.FieldSchema{Name: columnName, Type: mappedType})
+	for _, columnName := range tableSchema.Columns.SortedNames() {
+		bqSchema = append(bqSchema, &bigquery.FieldSchema{Name: columnName, Type: bq.columnType(tableSchema.Columns[columnName])})
 	}
 
-	if err := bqTable.Create(bq.ctx, &bigquery.TableMetadata{Name: tableSchema.Name, Schema: bqSchema}); err != nil {
+	tableMetadata := &bigquery.TableMetadata{Name: tableSchema.Name, Schema: bqSchema}
+	if bq.config.PartitionByIngestionTime {
+		//without field BigQuery partitions table daily by ingestion time
+		tableMetadata.TimePartitioning = &bigquery.TimePartitioning{}
+	}
+
+	if err := bqTable.Create(bq.ctx, tableMetadata); err != nil {
 		return fmt.Errorf("Error creating [%s] BigQuery table %v", tableSchema.Name, err)
 	}
 
@@ -144,13 +158,8 @@ func (bq *BigQuery) PatchTableSchema(patchSchema *schema.Table) error {
 		return fmt.Errorf("Error getting table %s metadata: %v", patchSchema.Name, err)
 	}
 
-	for columnName, column := range patchSchema.Columns {
-		mappedColumnType, ok := SchemaToBigQuery[column.Type]
-		if !ok {
-			log.Println("Unknown BigQuery schema type:", column.Type.String())
-			mappedColumnType = SchemaToBigQuery[schema.STRING]
-		}
-		metadata.Schema = append(metadata.Schema, &bigquery.FieldSchema{Name: columnName, Type: mappedColumnType})
+	for _, columnName := range patchSchema.Columns.SortedNames() {
+		metadata.Schema = append(metadata.Schema, &bigquery.FieldSchema{Name: columnName, Type: bq.columnType(patchSchema.Columns[columnName])})
 	}
 
 	updateReq := bigquery.TableMetadataToUpdate{Schema: metadata.Schema}
@@ -165,6 +174,52 @@ func (bq *BigQuery) PatchTableSchema(patchSchema *schema.Table) error {
 	return nil
 }
 
+//Insert provided objects to google BigQuery table via streaming insert api as one request
+//Invalid rows are skipped and the others are inserted: *PartialInsertError with indexes of failed rows is returned
+//so only they are retried. Retried rows have the same insert id for BigQuery best-effort deduplication
+func (bq *BigQuery) Insert(table *schema.Table, objects []events.Fact) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	items := make([]*bqItem, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, &bqItem{values: object})
+	}
+
+	inserter := bq.client.Dataset(bq.config.Dataset).Table(table.Name).Inserter()
+	inserter.SkipInvalidRows = true
+	if err := inserter.Put(bq.ctx, items); err != nil {
+		if multiErr, ok := err.(bigquery.PutMultiError); ok {
+			return rowsInsertionError(table.Name, len(objects), multiErr)
+		}
+		return fmt.Errorf("Error inserting %d objects to BigQuery table %s: %v", len(objects), table.Name, err)
+	}
+
+	return nil
+}
+
+//Return *PartialInsertError with indexes of rows from bigquery.PutMultiError
+func rowsInsertionError(tableName string, total int, multiErr bigquery.PutMultiError) error {
+	partialErr := &PartialInsertError{Total: total}
+	failed := map[int]bool{}
+	for _, rowErr := range multiErr {
+		if rowErr.RowIndex < 0 || rowErr.RowIndex >= total || failed[rowErr.RowIndex] {
+			continue
+		}
+		failed[rowErr.RowIndex] = true
+		partialErr.Failed = append(partialErr.Failed, rowErr.RowIndex)
+		if partialErr.Err == nil {
+			partialErr.Err = fmt.Errorf("Error inserting row %d to BigQuery table %s: %v", rowErr.RowIndex, tableName, rowErr.Errors)
+		}
+	}
+	if len(partialErr.Failed) == 0 {
+		return fmt.Errorf("Error inserting %d objects to BigQuery table %s: %v", total, tableName, multiErr)
+	}
+
+	return partialErr
+}
+
 func (bq *BigQuery) Close() error {
 	if err := bq.client.Close(); err != nil {
 		return fmt.Errorf("Error closing BigQuery client: %v", err)
@@ -178,3 +233,49 @@ func isNotFoundErr(err error) bool {
 	e, ok := err.(*googleapi.Error)
 	return ok && e.Code == http.StatusNotFound
 }
+
+//Return explicitly configured column type (e.g. INTEGER) or mapped from schema.DataType
+func (bq *BigQuery) columnType(column schema.Column) bigquery.FieldType {
+	if column.SqlType != "" {
+		return bigquery.FieldType(strings.ToUpper(column.SqlType))
+	}
+
+	mappedType, ok := SchemaToBigQuery[column.Type]
+	if !ok {
+		log.Println("Unknown BigQuery schema type:", column.Type.String())
+		mappedType = SchemaToBigQuery[schema.STRING]
+	}
+
+	return mappedType
+}
+
+//bqItem is a bigquery.ValueSaver of one object for streaming insert
+type bqItem struct {
+	values map[string]interface{}
+}
+
+//Save return object values and insert id for best-effort deduplication: event id or hash of the values if the object
+//doesn't have event id
+func (bqi *bqItem) Save() (row map[string]bigquery.Value, insertID string, err error) {
+	row = make(map[string]bigquery.Value, len(bqi.values))
+	for k, v := range bqi.values {
+		row[k] = v
+	}
+
+	return row, bqi.insertID(), nil
+}
+
+func (bqi *bqItem) insertID() string {
+	if eventId, ok := bqi.values[eventIdColumn].(string); ok && eventId != "" {
+		return eventId
+	}
+
+	//json keys are sorted so the same values have the same hash
+	b, err := json.Marshal(bqi.values)
+	if err != nil {
+		//empty id is generated by BigQuery client
+		return ""
+	}
+	hash := sha1.Sum(b)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,43 @@
+package adapters
+
+import (
+	"cloud.google.com/go/bigquery"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBigQueryInsertID(t *testing.T) {
+	withId := &bqItem{values: map[string]interface{}{eventIdColumn: "event_1", "field": "value"}}
+	_, insertID, err := withId.Save()
+	require.NoError(t, err)
+	require.Equal(t, "event_1", insertID)
+
+	//the same values have the same id
+	first := &bqItem{values: map[string]interface{}{"a": 1, "b": "value"}}
+	second := &bqItem{values: map[string]interface{}{"b": "value", "a": 1}}
+	other := &bqItem{values: map[string]interface{}{"a": 2, "b": "value"}}
+	require.NotEmpty(t, first.insertID())
+	require.Equal(t, first.insertID(), second.insertID())
+	require.NotEqual(t, first.insertID(), other.insertID())
+}
+
+func TestBigQueryRowsInsertionError(t *testing.T) {
+	multiErr := bigquery.PutMultiError{
+		bigquery.RowInsertionError{RowIndex: 1, Errors: bigquery.MultiError{errors.New("no such field")}},
+		bigquery.RowInsertionError{RowIndex: 3, Errors: bigquery.MultiError{errors.New("invalid value")}},
+		bigquery.RowInsertionError{RowIndex: 3, Errors: bigquery.MultiError{errors.New("invalid value")}},
+	}
+
+	err := rowsInsertionError("events", 4, multiErr)
+	partialErr, ok := err.(*PartialInsertError)
+	require.True(t, ok)
+	require.Equal(t, []int{1, 3}, partialErr.Failed)
+	require.Equal(t, 4, partialErr.Total)
+	require.Error(t, partialErr.Err)
+
+	//row indexes are unknown
+	err = rowsInsertionError("events", 4, bigquery.PutMultiError{bigquery.RowInsertionError{RowIndex: 10}})
+	_, ok = err.(*PartialInsertError)
+	require.False(t, ok)
+}
@@ -0,0 +1,277 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "github.com/ClickHouse/clickhouse-go"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/ksensehq/eventnative/timestamp"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	chTableSchemaQuery            = `SELECT name, type FROM system.columns WHERE database = ? AND table = ?`
+	chCreateDbIfNotExistsTemplate = `CREATE DATABASE IF NOT EXISTS "%s"`
+	chAddColumnTemplate           = `ALTER TABLE "%s"."%s" ADD COLUMN "%s" %s`
+	chCreateTableTemplate         = `CREATE TABLE "%s"."%s" (%s) ENGINE = MergeTree() PARTITION BY %s ORDER BY %s`
+	chInsertTemplate              = `INSERT INTO "%s"."%s" (%s) VALUES (%s)`
+
+	//timestamp.Key column is used in partition and order by keys so it can't be Nullable
+	chTimestampColumnType = "DateTime"
+	defaultChPartitionBy  = "toYYYYMMDD(" + timestamp.Key + ")"
+	defaultChOrderBy      = "(" + timestamp.Key + ")"
+)
+
+var (
+	schemaToClickHouse = map[schema.DataType]string{
+		schema.STRING:    "Nullable(String)",
+		schema.INT64:     "Nullable(Int64)",
+		schema.FLOAT64:   "Nullable(Float64)",
+		schema.TIMESTAMP: "Nullable(DateTime)",
+	}
+
+	clickHouseToSchema = map[string]schema.DataType{
+		"Nullable(String)":    schema.STRING,
+		"String":              schema.STRING,
+		chTimestampColumnType: schema.STRING,
+		"Nullable(Int64)":     schema.INT64,
+		"Int64":               schema.INT64,
+		"Nullable(Float64)":   schema.FLOAT64,
+		"Float64":             schema.FLOAT64,
+		"Nullable(DateTime)":  schema.TIMESTAMP,
+	}
+)
+
+//ClickHouseConfig dto for deserialized clickhouse config
+type ClickHouseConfig struct {
+	Dsn         string `mapstructure:"dsn"`
+	Db          string `mapstructure:"db"`
+	PartitionBy string `mapstructure:"partition_by"`
+	OrderBy     string `mapstructure:"order_by"`
+
+	StreamingConfig `mapstructure:",squash"`
+}
+
+//Validate required fields in ClickHouseConfig
+func (chc *ClickHouseConfig) Validate() error {
+	if chc == nil {
+		return errors.New("ClickHouse config is required")
+	}
+	if chc.Dsn == "" {
+		return errors.New("ClickHouse dsn is required parameter")
+	}
+	if chc.Db == "" {
+		return errors.New("ClickHouse db is required parameter")
+	}
+
+	return nil
+}
+
+//ClickHouse is adapter for creating,patching (database or table), inserting data to clickhouse
+//Tables are created with MergeTree engine partitioned by timestamp.Key column
+type ClickHouse struct {
+	ctx        context.Context
+	config     *ClickHouseConfig
+	dataSource *sql.DB
+}
+
+//NewClickHouse return configured ClickHouse adapter instance
+func NewClickHouse(ctx context.Context, config *ClickHouseConfig) (*ClickHouse, error) {
+	dataSource, err := sql.Open("clickhouse", config.Dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := dataSource.Ping(); err != nil {
+		dataSource.Close()
+		return nil, err
+	}
+
+	//defaults are applied to a copy: caller's config isn't modified
+	chConfig := *config
+	if chConfig.PartitionBy == "" {
+		chConfig.PartitionBy = defaultChPartitionBy
+	}
+	if chConfig.OrderBy == "" {
+		chConfig.OrderBy = defaultChOrderBy
+	}
+
+	return &ClickHouse{ctx: ctx, config: &chConfig, dataSource: dataSource}, nil
+}
+
+func (ClickHouse) Name() string {
+	return "ClickHouse"
+}
+
+//CreateDB create database instance if doesn't exist
+func (ch *ClickHouse) CreateDB(dbName string) error {
+	if _, err := ch.dataSource.ExecContext(ch.ctx, fmt.Sprintf(chCreateDbIfNotExistsTemplate, dbName)); err != nil {
+		return fmt.Errorf("Error creating [%s] db: %v", dbName, err)
+	}
+
+	return nil
+}
+
+//GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
+func (ch *ClickHouse) GetTableSchema(tableName string) (*schema.Table, error) {
+	table := &schema.Table{Name: tableName, Columns: schema.Columns{}}
+	rows, err := ch.dataSource.QueryContext(ch.ctx, chTableSchemaQuery, ch.config.Db, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying table [%s] schema: %v", tableName, err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, columnClickHouseType string
+		if err := rows.Scan(&columnName, &columnClickHouseType); err != nil {
+			return nil, fmt.Errorf("Error scanning result: %v", err)
+		}
+		mappedType, ok := clickHouseToSchema[columnClickHouseType]
+		if !ok {
+			log.Println("Unknown clickhouse column type:", columnClickHouseType)
+			mappedType = schema.STRING
+		}
+		table.Columns[columnName] = schema.Column{Type: mappedType}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return table, nil
+}
+
+//CreateTable create MergeTree table with name,columns provided in schema.Table representation
+//partitioned and ordered by configured expressions (timestamp.Key column by default)
+//timestamp.Key column goes first, other columns are sorted by name
+func (ch *ClickHouse) CreateTable(tableSchema *schema.Table) error {
+	columnsDDL := []string{fmt.Sprintf(`"%s" %s`, timestamp.Key, chTimestampColumnType)}
+	for _, columnName := range tableSchema.Columns.SortedNames() {
+		if columnName == timestamp.Key {
+			continue
+		}
+		columnsDDL = append(columnsDDL, fmt.Sprintf(`"%s" %s`, columnName, ch.columnType(tableSchema.Columns[columnName])))
+	}
+
+	statement := fmt.Sprintf(chCreateTableTemplate, ch.config.Db, tableSchema.Name, strings.Join(columnsDDL, ","), ch.config.PartitionBy, ch.config.OrderBy)
+	if _, err := ch.dataSource.ExecContext(ch.ctx, statement); err != nil {
+		return fmt.Errorf("Error creating [%s] table: %v", tableSchema.Name, err)
+	}
+
+	return nil
+}
+
+//PatchTableSchema add new columns(from provided schema.Table) to existing table
+func (ch *ClickHouse) PatchTableSchema(patchSchema *schema.Table) error {
+	for _, columnName := range patchSchema.Columns.SortedNames() {
+		mappedColumnType := ch.columnType(patchSchema.Columns[columnName])
+		statement := fmt.Sprintf(chAddColumnTemplate, ch.config.Db, patchSchema.Name, columnName, mappedColumnType)
+		if _, err := ch.dataSource.ExecContext(ch.ctx, statement); err != nil {
+			return fmt.Errorf("Error patching %s table with '%s' - %s column schema: %v", patchSchema.Name, columnName, mappedColumnType, err)
+		}
+	}
+
+	return nil
+}
+
+//BulkInsert insert provided objects in clickhouse as one block
+//(clickhouse driver sends all prepared statement executions in one transaction as one block)
+//Objects may have different keys: header is a union of all keys, missing values are inserted as NULL
+func (ch *ClickHouse) BulkInsert(table *schema.Table, objects []events.Fact) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	columnsSet := map[string]bool{}
+	for _, object := range objects {
+		for name := range object {
+			columnsSet[name] = true
+		}
+	}
+	var columns, placeholders []string
+	for name := range columnsSet {
+		columns = append(columns, name)
+		placeholders = append(placeholders, "?")
+	}
+	sort.Strings(columns)
+	header := `"` + strings.Join(columns, `","`) + `"`
+
+	tx, err := ch.dataSource.BeginTx(ch.ctx, nil)
+	if err != nil {
+		return err
+	}
+	wrappedTx := &Transaction{tx: tx, dbType: ch.Name()}
+
+	insertStmt, err := wrappedTx.tx.PrepareContext(ch.ctx, fmt.Sprintf(chInsertTemplate, ch.config.Db, table.Name, header, strings.Join(placeholders, ",")))
+	if err != nil {
+		wrappedTx.Rollback()
+		return fmt.Errorf("Error preparing insert table %s statement: %v", table.Name, err)
+	}
+	defer insertStmt.Close()
+
+	for _, object := range objects {
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			value := object[column]
+			if column == timestamp.Key {
+				value, err = toDateTime(value)
+				if err != nil {
+					wrappedTx.Rollback()
+					return err
+				}
+			}
+			values[i] = value
+		}
+
+		if _, err := insertStmt.ExecContext(ch.ctx, values...); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error inserting in %s table with statement: %s values: %v: %v", table.Name, header, values, err)
+		}
+	}
+
+	return wrappedTx.tx.Commit()
+}
+
+//Close underlying sql.DB
+func (ch *ClickHouse) Close() error {
+	if err := ch.dataSource.Close(); err != nil {
+		return fmt.Errorf("Error closing datasource: %v", err)
+	}
+
+	return nil
+}
+
+//Return explicitly configured column sql type or mapped from schema.DataType
+func (ch *ClickHouse) columnType(column schema.Column) string {
+	if column.SqlType != "" {
+		return column.SqlType
+	}
+
+	mappedType, ok := schemaToClickHouse[column.Type]
+	if !ok {
+		log.Println("Unknown clickhouse schema type:", column.Type.String())
+		mappedType = schemaToClickHouse[schema.STRING]
+	}
+
+	return mappedType
+}
+
+//Return time.Time from timestamp.Key value (it is time.Time after table name extracting or string in timestamp.Layout)
+func toDateTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		t, err := time.Parse(timestamp.Layout, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Malformed %s value [%s]: %v", timestamp.Key, v, err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, fmt.Errorf("Malformed %s value [%v]: must be string or time", timestamp.Key, value)
+	}
+}
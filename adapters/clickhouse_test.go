@@ -0,0 +1,188 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/ksensehq/eventnative/timestamp"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newTestClickHouse(recordingDrv *recordingDriver, config *ClickHouseConfig) *ClickHouse {
+	return &ClickHouse{ctx: context.Background(), config: config, dataSource: sql.OpenDB(recordingDrv)}
+}
+
+func TestClickHouseConfigValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *ClickHouseConfig
+		expectedError string
+	}{
+		{
+			"Nil config",
+			nil,
+			"ClickHouse config is required",
+		},
+		{
+			"Without dsn",
+			&ClickHouseConfig{Db: "default"},
+			"ClickHouse dsn is required parameter",
+		},
+		{
+			"Without db",
+			&ClickHouseConfig{Dsn: "tcp://localhost:9000"},
+			"ClickHouse db is required parameter",
+		},
+		{
+			"Valid",
+			&ClickHouseConfig{Dsn: "tcp://localhost:9000", Db: "default"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestClickHouseCreateTable(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *ClickHouseConfig
+		expectedQuery string
+	}{
+		{
+			"Default partition and order",
+			&ClickHouseConfig{Db: "events_db", PartitionBy: defaultChPartitionBy, OrderBy: defaultChOrderBy},
+			`CREATE TABLE "events_db"."events" ("_timestamp" DateTime,"count" Nullable(Int64),"products" Array(String),"user" Nullable(String)) ` +
+				`ENGINE = MergeTree() PARTITION BY toYYYYMMDD(_timestamp) ORDER BY (_timestamp)`,
+		},
+		{
+			"Configured partition and order",
+			&ClickHouseConfig{Db: "events_db", PartitionBy: "toYYYYMM(_timestamp)", OrderBy: "(user, _timestamp)"},
+			`CREATE TABLE "events_db"."events" ("_timestamp" DateTime,"count" Nullable(Int64),"products" Array(String),"user" Nullable(String)) ` +
+				`ENGINE = MergeTree() PARTITION BY toYYYYMM(_timestamp) ORDER BY (user, _timestamp)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordingDrv := &recordingDriver{}
+			ch := newTestClickHouse(recordingDrv, tt.config)
+			defer ch.Close()
+
+			//timestamp.Key column goes first and isn't Nullable regardless of its type
+			table := &schema.Table{Name: "events", Columns: schema.Columns{
+				"user":        schema.Column{Type: schema.STRING},
+				timestamp.Key: schema.Column{Type: schema.TIMESTAMP},
+				"count":       schema.Column{Type: schema.INT64},
+				"products":    schema.Column{Type: schema.STRING, SqlType: "Array(String)"},
+			}}
+			require.NoError(t, ch.CreateTable(table))
+			require.Equal(t, []string{tt.expectedQuery}, recordingDrv.queries)
+		})
+	}
+}
+
+func TestClickHousePatchTableSchema(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	ch := newTestClickHouse(recordingDrv, &ClickHouseConfig{Db: "events_db"})
+	defer ch.Close()
+
+	patch := &schema.Table{Name: "events", Columns: schema.Columns{
+		"price":      schema.Column{Type: schema.FLOAT64},
+		"created_at": schema.Column{Type: schema.TIMESTAMP},
+	}}
+	require.NoError(t, ch.PatchTableSchema(patch))
+	require.Equal(t, []string{
+		`ALTER TABLE "events_db"."events" ADD COLUMN "created_at" Nullable(DateTime)`,
+		`ALTER TABLE "events_db"."events" ADD COLUMN "price" Nullable(Float64)`,
+	}, recordingDrv.queries)
+}
+
+func TestClickHouseBulkInsert(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	ch := newTestClickHouse(recordingDrv, &ClickHouseConfig{Db: "events_db"})
+	defer ch.Close()
+
+	eventTime := time.Date(2020, 10, 1, 12, 30, 0, 0, time.UTC)
+	table := &schema.Table{Name: "events"}
+	require.NoError(t, ch.BulkInsert(table, []events.Fact{
+		{"user": "user_1", timestamp.Key: eventTime},
+		{"count": int64(2), timestamp.Key: eventTime.Format(timestamp.Layout)},
+	}))
+
+	//one statement with union of all keys, missing values are NULL
+	require.Equal(t, []string{`INSERT INTO "events_db"."events" ("_timestamp","count","user") VALUES (?,?,?)`}, recordingDrv.queries)
+	require.Equal(t, [][]driver.Value{
+		{eventTime, nil, "user_1"},
+		{eventTime, int64(2), nil},
+	}, recordingDrv.args)
+
+	//nothing is executed without objects
+	require.NoError(t, ch.BulkInsert(table, nil))
+	require.Len(t, recordingDrv.queries, 1)
+
+	//malformed timestamp rolls back the whole block
+	err := ch.BulkInsert(table, []events.Fact{{"user": "user_1", timestamp.Key: "yesterday"}})
+	require.Error(t, err)
+	require.Len(t, recordingDrv.args, 2)
+}
+
+func TestToDateTime(t *testing.T) {
+	eventTime := time.Date(2020, 10, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		value         interface{}
+		expected      time.Time
+		expectedError string
+	}{
+		{
+			"Time",
+			eventTime,
+			eventTime,
+			"",
+		},
+		{
+			"String in timestamp layout",
+			eventTime.Format(timestamp.Layout),
+			eventTime,
+			"",
+		},
+		{
+			"Malformed string",
+			"yesterday",
+			time.Time{},
+			"Malformed _timestamp value [yesterday]",
+		},
+		{
+			"Number",
+			1601555400,
+			time.Time{},
+			"Malformed _timestamp value [1601555400]: must be string or time",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := toDateTime(tt.value)
+			if tt.expectedError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, tt.expected.Equal(actual))
+		})
+	}
+}
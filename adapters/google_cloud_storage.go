@@ -17,11 +17,24 @@ type GoogleCloudStorage struct {
 	ctx    context.Context
 }
 
+const (
+	//GoogleBatchMode files are uploaded to google cloud storage and loaded to BigQuery
+	GoogleBatchMode = "batch"
+	//GoogleStreamMode events are inserted to BigQuery via streaming insert api
+	GoogleStreamMode = "stream"
+)
+
 type GoogleConfig struct {
 	Bucket  string      `mapstructure:"gcs_bucket"`
 	Project string      `mapstructure:"bq_project"`
 	Dataset string      `mapstructure:"bq_dataset"`
 	KeyFile interface{} `mapstructure:"key_file"`
+	//batch (default) or stream
+	Mode string `mapstructure:"mode"`
+	//create tables partitioned by ingestion time (daily)
+	PartitionByIngestionTime bool `mapstructure:"partition_by_ingestion_time"`
+
+	StreamingConfig `mapstructure:",squash"`
 
 	//will be set on validation
 	credentials option.ClientOption
@@ -31,8 +44,14 @@ func (gc *GoogleConfig) Validate() error {
 	if gc == nil {
 		return errors.New("Google config is required")
 	}
-	if gc.Bucket == "" {
-		return errors.New("Google cloud storage bucket(gcs_bucket) is required parameter")
+	switch gc.Mode {
+	case "", GoogleBatchMode:
+		if gc.Bucket == "" {
+			return errors.New("Google cloud storage bucket(gcs_bucket) is required parameter")
+		}
+	case GoogleStreamMode:
+	default:
+		return fmt.Errorf("Unsupported BigQuery mode: %s. Supported: %s, %s", gc.Mode, GoogleBatchMode, GoogleStreamMode)
 	}
 	if gc.Project == "" {
 		return errors.New("BigQuery project(bq_project) is required parameter")
@@ -66,6 +85,11 @@ func (gc *GoogleConfig) Validate() error {
 	return nil
 }
 
+//StreamMode return true if events should be inserted to BigQuery via streaming api
+func (gc *GoogleConfig) StreamMode() bool {
+	return gc.Mode == GoogleStreamMode
+}
+
 func NewGoogleCloudStorage(ctx context.Context, config *GoogleConfig) (*GoogleCloudStorage, error) {
 	client, err := storage.NewClient(ctx, config.credentials)
 	if err != nil {
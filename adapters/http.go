@@ -0,0 +1,184 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const maxHTTPErrorBodyLength = 512
+
+//HTTPConfig dto for deserialized http destination config
+type HTTPConfig struct {
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+	//send batch_size events in one request as json array. One request per event by default
+	Batch     bool `mapstructure:"batch"`
+	TimeoutMs int  `mapstructure:"timeout_ms"`
+	//max count of retries on 5xx, 429 responses and network errors
+	MaxRetries int `mapstructure:"max_retries"`
+	//max requests per second (including retries). 0 - unlimited
+	RateLimit float64 `mapstructure:"rate_limit"`
+
+	StreamingConfig `mapstructure:",squash"`
+}
+
+//Validate required fields in HTTPConfig
+func (hc *HTTPConfig) Validate() error {
+	if hc == nil {
+		return errors.New("HTTP config is required")
+	}
+	if hc.URL == "" {
+		return errors.New("HTTP url is required parameter")
+	}
+	if hc.RateLimit < 0 {
+		return errors.New("HTTP rate_limit must be positive")
+	}
+
+	return nil
+}
+
+//HTTPError is non 2xx response
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	//parsed Retry-After header value. 0 if header doesn't exist
+	RetryAfter time.Duration
+}
+
+func (he *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP response code: %d body: %s", he.StatusCode, he.Body)
+}
+
+//Retryable return true if request may succeed after retry (5xx and 429 responses)
+func (he *HTTPError) Retryable() bool {
+	return he.StatusCode >= http.StatusInternalServerError || he.StatusCode == http.StatusTooManyRequests
+}
+
+//HTTP is adapter for sending json payloads with POST requests to configured url
+//with custom headers and rate limit
+type HTTP struct {
+	ctx     context.Context
+	config  *HTTPConfig
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+//NewHTTP return configured HTTP adapter instance
+func NewHTTP(ctx context.Context, config *HTTPConfig) *HTTP {
+	return &HTTP{
+		ctx:     ctx,
+		config:  config,
+		client:  &http.Client{Timeout: time.Duration(config.TimeoutMs) * time.Millisecond},
+		limiter: newRateLimiter(config.RateLimit),
+	}
+}
+
+func (HTTP) Name() string {
+	return "HTTP"
+}
+
+//Send POST request with json payload (waiting for rate limiter if it is configured)
+//Return *HTTPError on non 2xx response and client error on network failures and timeouts
+func (h *HTTP) Send(payload []byte) error {
+	if err := h.limiter.wait(h.ctx); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(h.ctx, http.MethodPost, h.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("Error creating HTTP request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range h.config.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		//read body for connection reusing
+		io.Copy(ioutil.Discard, response.Body)
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxHTTPErrorBodyLength))
+	return &HTTPError{StatusCode: response.StatusCode, Body: string(body), RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"))}
+}
+
+//Close idle connections
+func (h *HTTP) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
+
+//Return Retry-After header value (seconds or http date) as duration or 0 if it is empty or malformed
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+//rateLimiter allows not more than rate calls per second with equal intervals
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+//Return limiter with rate calls per second. 0 - unlimited
+func newRateLimiter(rate float64) *rateLimiter {
+	limiter := &rateLimiter{}
+	if rate > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	return limiter
+}
+
+//Block until next call is allowed or context is done
+func (rl *rateLimiter) wait(ctx context.Context) error {
+	if rl.interval == 0 {
+		return nil
+	}
+
+	rl.mutex.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	delay := rl.next.Sub(now)
+	rl.next = rl.next.Add(rl.interval)
+	rl.mutex.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
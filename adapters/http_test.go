@@ -0,0 +1,86 @@
+package adapters
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPSend(t *testing.T) {
+	tests := []struct {
+		name               string
+		statusCode         int
+		retryAfter         string
+		expectedErr        bool
+		expectedRetryable  bool
+		expectedRetryAfter time.Duration
+	}{
+		{"Success", http.StatusOK, "", false, false, 0},
+		{"Bad request", http.StatusBadRequest, "", true, false, 0},
+		{"Server error", http.StatusBadGateway, "", true, true, 0},
+		{"Too many requests", http.StatusTooManyRequests, "3", true, true, 3 * time.Second},
+		{"Malformed retry after", http.StatusTooManyRequests, "soon", true, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "value", r.Header.Get("X-Custom"))
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			adapter := NewHTTP(context.Background(), &HTTPConfig{URL: server.URL, Headers: map[string]string{"X-Custom": "value"}, TimeoutMs: 1000})
+			err := adapter.Send([]byte(`{"key":"value"}`))
+			if !tt.expectedErr {
+				require.NoError(t, err)
+				return
+			}
+
+			httpErr, ok := err.(*HTTPError)
+			require.True(t, ok, "Error must be HTTPError: %v", err)
+			require.Equal(t, tt.statusCode, httpErr.StatusCode)
+			require.Equal(t, tt.expectedRetryable, httpErr.Retryable())
+			require.Equal(t, tt.expectedRetryAfter, httpErr.RetryAfter)
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		require.NoError(t, limiter.wait(context.Background()))
+	}
+	require.True(t, time.Since(start) >= 100*time.Millisecond, "10 intervals of 10ms must elapse")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{"Empty", "", 0},
+		{"Seconds", "120", 2 * time.Minute},
+		{"Zero seconds", "0", 0},
+		{"Negative seconds", "-5", 0},
+		{"Past http date", "Wed, 21 Oct 2015 07:28:00 GMT", 0},
+		{"Malformed", "in a minute", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, parseRetryAfter(tt.value))
+		})
+	}
+
+	//future http date is a duration until it
+	delay := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	require.True(t, delay > 59*time.Minute && delay <= time.Hour, "Unexpected delay: %v", delay)
+}
@@ -0,0 +1,198 @@
+package adapters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"github.com/ksensehq/eventnative/events"
+	"io/ioutil"
+)
+
+var kafkaRequiredAcks = map[string]sarama.RequiredAcks{
+	"none":   sarama.NoResponse,
+	"leader": sarama.WaitForLocal,
+	"all":    sarama.WaitForAll,
+}
+
+//KafkaConfig dto for deserialized kafka config
+type KafkaConfig struct {
+	Brokers []string `mapstructure:"brokers"`
+	Topic   string   `mapstructure:"topic"`
+	//field path in event (e.g. /user_id) which value is used as a message key (partition key)
+	//messages without key are distributed between partitions randomly
+	KeyField string `mapstructure:"key_field"`
+	//none, leader (default) or all
+	RequiredAcks string           `mapstructure:"required_acks"`
+	SASL         *KafkaSASLConfig `mapstructure:"sasl"`
+	TLS          *KafkaTLSConfig  `mapstructure:"tls"`
+
+	StreamingConfig `mapstructure:",squash"`
+}
+
+//KafkaSASLConfig dto for SASL/PLAIN authentication
+type KafkaSASLConfig struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+//KafkaTLSConfig dto for TLS connection. Certificate files are optional: system CA pool is used without ca_file
+type KafkaTLSConfig struct {
+	CaFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+//Validate required fields in KafkaConfig
+func (kc *KafkaConfig) Validate() error {
+	if kc == nil {
+		return errors.New("Kafka config is required")
+	}
+	if len(kc.Brokers) == 0 {
+		return errors.New("Kafka brokers is required parameter")
+	}
+	if kc.Topic == "" {
+		return errors.New("Kafka topic is required parameter")
+	}
+	if _, ok := kafkaRequiredAcks[kc.RequiredAcks]; kc.RequiredAcks != "" && !ok {
+		return fmt.Errorf("Unsupported kafka required_acks: %s. Supported: none, leader, all", kc.RequiredAcks)
+	}
+	if kc.SASL != nil && kc.SASL.Username == "" {
+		return errors.New("Kafka sasl username is required parameter")
+	}
+	if kc.TLS != nil && (kc.TLS.CertFile == "") != (kc.TLS.KeyFile == "") {
+		return errors.New("Kafka tls cert_file and key_file must be provided together")
+	}
+
+	return nil
+}
+
+//Kafka is adapter for producing events to kafka topic with sarama async producer
+type Kafka struct {
+	config   *KafkaConfig
+	producer sarama.AsyncProducer
+}
+
+//NewKafka return configured Kafka adapter instance
+func NewKafka(config *KafkaConfig) (*Kafka, error) {
+	producerConfig := sarama.NewConfig()
+	producerConfig.Producer.Return.Successes = true
+	producerConfig.Producer.Return.Errors = true
+	if config.RequiredAcks != "" {
+		producerConfig.Producer.RequiredAcks = kafkaRequiredAcks[config.RequiredAcks]
+	}
+
+	if config.SASL != nil {
+		producerConfig.Net.SASL.Enable = true
+		producerConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		producerConfig.Net.SASL.User = config.SASL.Username
+		producerConfig.Net.SASL.Password = config.SASL.Password
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := kafkaTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		producerConfig.Net.TLS.Enable = true
+		producerConfig.Net.TLS.Config = tlsConfig
+	}
+
+	producer, err := sarama.NewAsyncProducer(config.Brokers, producerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating kafka producer: %v", err)
+	}
+
+	return &Kafka{config: config, producer: producer}, nil
+}
+
+func kafkaTLSConfig(config *KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CaFile != "" {
+		caCert, err := ioutil.ReadFile(config.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading kafka tls ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("Error parsing kafka tls ca_file %s: no PEM certificates", config.CaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading kafka tls certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (Kafka) Name() string {
+	return "Kafka"
+}
+
+//Produce send all facts as json messages to configured topic and wait for acks of all of them
+//Return error if at least one message hasn't been produced
+//(the whole batch will be produced again so downstream consumers should tolerate duplicates)
+func (k *Kafka) Produce(facts []events.Fact) error {
+	messages := make([]*sarama.ProducerMessage, 0, len(facts))
+	for _, fact := range facts {
+		b, err := json.Marshal(fact)
+		if err != nil {
+			return fmt.Errorf("Error marshaling event to json: %v", err)
+		}
+
+		message := &sarama.ProducerMessage{Topic: k.config.Topic, Value: sarama.ByteEncoder(b)}
+		if k.config.KeyField != "" {
+			if key := fact.Get(k.config.KeyField); key != nil {
+				message.Key = sarama.StringEncoder(fmt.Sprint(key))
+			}
+		}
+		messages = append(messages, message)
+	}
+
+	//acks are read while sending because producer channels are bounded
+	sent, acked, failed := 0, 0, 0
+	var lastErr error
+	for acked < len(messages) {
+		//nil channel blocks forever: only acks are read when all messages have been sent
+		var input chan<- *sarama.ProducerMessage
+		var next *sarama.ProducerMessage
+		if sent < len(messages) {
+			input = k.producer.Input()
+			next = messages[sent]
+		}
+
+		select {
+		case input <- next:
+			sent++
+		case <-k.producer.Successes():
+			acked++
+		case producerErr := <-k.producer.Errors():
+			acked++
+			failed++
+			lastErr = producerErr.Err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Error producing %d of %d messages to kafka topic %s: %v", failed, len(messages), k.config.Topic, lastErr)
+	}
+
+	return nil
+}
+
+//Close flush buffered messages and close producer
+func (k *Kafka) Close() error {
+	if err := k.producer.Close(); err != nil {
+		return fmt.Errorf("Error closing kafka producer: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,264 @@
+package adapters
+
+import (
+	"errors"
+	"github.com/Shopify/sarama"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeAsyncProducer is a sarama.AsyncProducer with unbuffered channels (like a full producer buffer):
+//every message is acked only after the previous ack has been read
+type fakeAsyncProducer struct {
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+	//message value -> error
+	failures map[string]error
+
+	mutex    sync.Mutex
+	produced []*sarama.ProducerMessage
+	done     chan struct{}
+}
+
+func newFakeAsyncProducer(failures map[string]error) *fakeAsyncProducer {
+	fap := &fakeAsyncProducer{
+		input:     make(chan *sarama.ProducerMessage),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+		failures:  failures,
+		done:      make(chan struct{}),
+	}
+	go fap.run()
+
+	return fap
+}
+
+func (fap *fakeAsyncProducer) run() {
+	defer close(fap.done)
+	for message := range fap.input {
+		value, _ := message.Value.Encode()
+		if err, ok := fap.failures[string(value)]; ok {
+			fap.errors <- &sarama.ProducerError{Msg: message, Err: err}
+			continue
+		}
+
+		fap.mutex.Lock()
+		fap.produced = append(fap.produced, message)
+		fap.mutex.Unlock()
+		fap.successes <- message
+	}
+}
+
+func (fap *fakeAsyncProducer) AsyncClose() {
+	close(fap.input)
+}
+
+func (fap *fakeAsyncProducer) Close() error {
+	close(fap.input)
+	<-fap.done
+	return nil
+}
+
+func (fap *fakeAsyncProducer) Input() chan<- *sarama.ProducerMessage {
+	return fap.input
+}
+
+func (fap *fakeAsyncProducer) Successes() <-chan *sarama.ProducerMessage {
+	return fap.successes
+}
+
+func (fap *fakeAsyncProducer) Errors() <-chan *sarama.ProducerError {
+	return fap.errors
+}
+
+//Produce with timeout: a broken acks accounting blocks forever
+func produceWithTimeout(t *testing.T, k *Kafka, facts []events.Fact) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- k.Produce(facts)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Produce hasn't returned: acks aren't read")
+		return nil
+	}
+}
+
+func TestKafkaConfigValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *KafkaConfig
+		expectedError string
+	}{
+		{
+			"Nil config",
+			nil,
+			"Kafka config is required",
+		},
+		{
+			"Without brokers",
+			&KafkaConfig{Topic: "events"},
+			"Kafka brokers is required parameter",
+		},
+		{
+			"Without topic",
+			&KafkaConfig{Brokers: []string{"localhost:9092"}},
+			"Kafka topic is required parameter",
+		},
+		{
+			"Unsupported required acks",
+			&KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", RequiredAcks: "some"},
+			"Unsupported kafka required_acks: some. Supported: none, leader, all",
+		},
+		{
+			"SASL without username",
+			&KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", SASL: &KafkaSASLConfig{Password: "secret"}},
+			"Kafka sasl username is required parameter",
+		},
+		{
+			"TLS cert without key",
+			&KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", TLS: &KafkaTLSConfig{CertFile: "client.crt"}},
+			"Kafka tls cert_file and key_file must be provided together",
+		},
+		{
+			"Valid",
+			&KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "events", RequiredAcks: "all",
+				SASL: &KafkaSASLConfig{Username: "user"}, TLS: &KafkaTLSConfig{InsecureSkipVerify: true}},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestKafkaProduce(t *testing.T) {
+	tests := []struct {
+		name             string
+		facts            []events.Fact
+		failures         map[string]error
+		expectedProduced int
+		expectedError    string
+	}{
+		{
+			"All messages are acked",
+			[]events.Fact{{"id": 1}, {"id": 2}, {"id": 3}},
+			nil,
+			3,
+			"",
+		},
+		{
+			"Failed message fails the batch after all acks",
+			[]events.Fact{{"id": 1}, {"id": 2}, {"id": 3}},
+			map[string]error{`{"id":2}`: errors.New("message too large")},
+			2,
+			"Error producing 1 of 3 messages to kafka topic events: message too large",
+		},
+		{
+			"All messages failed",
+			[]events.Fact{{"id": 1}, {"id": 2}},
+			map[string]error{`{"id":1}`: errors.New("leader not available"), `{"id":2}`: errors.New("leader not available")},
+			0,
+			"Error producing 2 of 2 messages to kafka topic events: leader not available",
+		},
+		{
+			"Empty batch",
+			nil,
+			nil,
+			0,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := newFakeAsyncProducer(tt.failures)
+			k := &Kafka{config: &KafkaConfig{Topic: "events"}, producer: producer}
+			defer k.Close()
+
+			err := produceWithTimeout(t, k, tt.facts)
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, producer.produced, tt.expectedProduced)
+		})
+	}
+}
+
+//batch is much bigger than producer buffers: acks are read while messages are sent
+func TestKafkaProduceBigBatch(t *testing.T) {
+	producer := newFakeAsyncProducer(map[string]error{`{"id":500}`: errors.New("message too large")})
+	k := &Kafka{config: &KafkaConfig{Topic: "events"}, producer: producer}
+	defer k.Close()
+
+	var facts []events.Fact
+	for i := 0; i < 1000; i++ {
+		facts = append(facts, events.Fact{"id": i})
+	}
+	require.EqualError(t, produceWithTimeout(t, k, facts), "Error producing 1 of 1000 messages to kafka topic events: message too large")
+	require.Len(t, producer.produced, 999)
+}
+
+func TestKafkaProduceKeys(t *testing.T) {
+	producer := newFakeAsyncProducer(nil)
+	k := &Kafka{config: &KafkaConfig{Topic: "events", KeyField: "/eventn_ctx/user_id"}, producer: producer}
+	defer k.Close()
+
+	require.NoError(t, produceWithTimeout(t, k, []events.Fact{
+		{"eventn_ctx": map[string]interface{}{"user_id": "user_1"}},
+		{"eventn_ctx": map[string]interface{}{"user_id": 42}},
+		{"event_type": "pageview"},
+	}))
+
+	require.Len(t, producer.produced, 3)
+	require.Equal(t, "events", producer.produced[0].Topic)
+	require.Equal(t, sarama.StringEncoder("user_1"), producer.produced[0].Key)
+	require.Equal(t, sarama.StringEncoder("42"), producer.produced[1].Key)
+	require.Nil(t, producer.produced[2].Key, "Message without key must be distributed randomly")
+	value, err := producer.produced[2].Value.Encode()
+	require.NoError(t, err)
+	require.Equal(t, `{"event_type":"pageview"}`, string(value))
+}
+
+func TestKafkaTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kafka_tls_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	notPEM := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(notPEM, []byte("not a certificate"), 0644))
+
+	tlsConfig, err := kafkaTLSConfig(&KafkaTLSConfig{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	require.True(t, tlsConfig.InsecureSkipVerify)
+	require.Nil(t, tlsConfig.RootCAs, "System CA pool must be used without ca_file")
+
+	_, err = kafkaTLSConfig(&KafkaTLSConfig{CaFile: filepath.Join(dir, "not_existing.crt")})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Error reading kafka tls ca_file")
+
+	_, err = kafkaTLSConfig(&KafkaTLSConfig{CaFile: notPEM})
+	require.EqualError(t, err, "Error parsing kafka tls ca_file "+notPEM+": no PEM certificates")
+
+	_, err = kafkaTLSConfig(&KafkaTLSConfig{CertFile: notPEM, KeyFile: notPEM})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Error loading kafka tls certificate")
+}
@@ -0,0 +1,324 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	mySQLTableSchemaQuery        = `SELECT column_name, column_type FROM information_schema.columns WHERE table_schema = ? AND table_name = ?`
+	mySQLIndexExistsQuery        = `SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = ? AND table_name = ? AND index_name = ?`
+	mySQLCreateDbIfNotExists     = "CREATE DATABASE IF NOT EXISTS `%s`"
+	mySQLCreateUniqueIndex       = "CREATE UNIQUE INDEX `%s` ON `%s`.`%s` (`%s`)"
+	mySQLBulkInsertTemplate      = "INSERT INTO %s.%s (%s) VALUES %s"
+	mySQLOnDuplicateKeyTemplate  = " ON DUPLICATE KEY UPDATE %s = %s"
+	mySQLDedupKeyColumnType      = "varchar(255)"
+	mySQLMaxPlaceholdersPerQuery = 65535
+)
+
+var (
+	schemaToMySQL = map[schema.DataType]string{
+		schema.STRING:    "text",
+		schema.INT64:     "bigint",
+		schema.FLOAT64:   "double",
+		schema.TIMESTAMP: "datetime(6)",
+	}
+
+	mySQLToSchema = map[string]schema.DataType{
+		"text":       schema.STRING,
+		"mediumtext": schema.STRING,
+		"longtext":   schema.STRING,
+		"varchar":    schema.STRING,
+		"json":       schema.STRING,
+		"int":        schema.INT64,
+		"bigint":     schema.INT64,
+		"float":      schema.FLOAT64,
+		"double":     schema.FLOAT64,
+		"decimal":    schema.FLOAT64,
+		"datetime":   schema.TIMESTAMP,
+	}
+)
+
+//MySQLConfig dto for deserialized mysql config
+type MySQLConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Db       string `mapstructure:"db"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	//additional driver parameters e.g. tls: skip-verify
+	Parameters map[string]string `mapstructure:"parameters"`
+	//flatten column name (e.g. eventn_ctx_event_id) with unique index. Rows with already existing values are skipped on insert
+	DedupKey string `mapstructure:"dedup_key"`
+
+	StreamingConfig `mapstructure:",squash"`
+}
+
+//Validate required fields in MySQLConfig
+func (mc *MySQLConfig) Validate() error {
+	if mc == nil {
+		return errors.New("MySQL config is required")
+	}
+	if mc.Host == "" {
+		return errors.New("MySQL host is required parameter")
+	}
+	if mc.Db == "" {
+		return errors.New("MySQL db is required parameter")
+	}
+	if mc.Username == "" {
+		return errors.New("MySQL username is required parameter")
+	}
+
+	return nil
+}
+
+//MySQLDialect is a SQLDialect with backtick quoted identifiers and MySQL column types
+type MySQLDialect struct{}
+
+func (MySQLDialect) QuoteIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+func (MySQLDialect) ColumnType(column schema.Column) string {
+	if column.SqlType != "" {
+		return column.SqlType
+	}
+
+	mappedType, ok := schemaToMySQL[column.Type]
+	if !ok {
+		log.Println("Unknown mysql schema type:", column.Type.String())
+		mappedType = schemaToMySQL[schema.STRING]
+	}
+
+	return mappedType
+}
+
+//CreateTableDDL return CREATE TABLE statement. Columns are sorted by name
+func (d MySQLDialect) CreateTableDDL(dbSchema string, table *schema.Table) string {
+	var columnsDDL []string
+	for _, columnName := range table.Columns.SortedNames() {
+		columnsDDL = append(columnsDDL, d.QuoteIdentifier(columnName)+" "+d.ColumnType(table.Columns[columnName]))
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s.%s (%s)", d.QuoteIdentifier(dbSchema), d.QuoteIdentifier(table.Name), strings.Join(columnsDDL, ","))
+}
+
+func (d MySQLDialect) AlterAddColumnDDL(dbSchema, tableName, columnName string, column schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s %s", d.QuoteIdentifier(dbSchema), d.QuoteIdentifier(tableName),
+		d.QuoteIdentifier(columnName), d.ColumnType(column))
+}
+
+//MySQL is adapter for creating,patching (database or table), inserting data to mysql
+//Nested objects and arrays are stored as json strings: use json type in column_types for them
+//Tables are created and patched with SQLAdapter and MySQLDialect
+type MySQL struct {
+	*SQLAdapter
+
+	config *MySQLConfig
+}
+
+//NewMySQL return configured MySQL adapter instance
+func NewMySQL(ctx context.Context, config *MySQLConfig) (*MySQL, error) {
+	driverConfig := mysql.NewConfig()
+	driverConfig.Net = "tcp"
+	driverConfig.Addr = config.Host + ":" + strconv.Itoa(config.Port)
+	driverConfig.User = config.Username
+	driverConfig.Passwd = config.Password
+	driverConfig.Params = config.Parameters
+
+	//database may not exist yet so connection is opened without it (all statements use qualified table names)
+	dataSource, err := sql.Open("mysql", driverConfig.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	if err := dataSource.Ping(); err != nil {
+		dataSource.Close()
+		return nil, err
+	}
+
+	return &MySQL{SQLAdapter: NewSQLAdapter(ctx, dataSource, MySQLDialect{}, "MySQL"), config: config}, nil
+}
+
+func (MySQL) Name() string {
+	return "MySQL"
+}
+
+//CreateDB create database instance if doesn't exist
+func (m *MySQL) CreateDB(dbName string) error {
+	if _, err := m.dataSource.ExecContext(m.ctx, fmt.Sprintf(mySQLCreateDbIfNotExists, dbName)); err != nil {
+		return fmt.Errorf("Error creating [%s] db: %v", dbName, err)
+	}
+
+	return nil
+}
+
+//GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
+func (m *MySQL) GetTableSchema(tableName string) (*schema.Table, error) {
+	table := &schema.Table{Name: tableName, Columns: schema.Columns{}}
+	rows, err := m.dataSource.QueryContext(m.ctx, mySQLTableSchemaQuery, m.config.Db, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying table [%s] schema: %v", tableName, err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, columnMySQLType string
+		if err := rows.Scan(&columnName, &columnMySQLType); err != nil {
+			return nil, fmt.Errorf("Error scanning result: %v", err)
+		}
+		//column_type contains length e.g. bigint(20) or varchar(255)
+		mappedType, ok := mySQLToSchema[strings.Split(strings.ToLower(columnMySQLType), "(")[0]]
+		if !ok {
+			log.Println("Unknown mysql column type:", columnMySQLType)
+			mappedType = schema.STRING
+		}
+		table.Columns[columnName] = schema.Column{Type: mappedType}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return table, nil
+}
+
+//CreateTable create database table with name,columns provided in schema.Table representation
+//and unique index on dedup key column if it is configured
+func (m *MySQL) CreateTable(tableSchema *schema.Table) error {
+	//dedup key column must exist for unique index even if objects don't have it yet
+	//text columns can't be used in unique index without prefix length so varchar is used
+	dedupKey := m.config.DedupKey
+	if dedupKey != "" {
+		tableSchema.Columns[dedupKey] = schema.Column{Type: schema.STRING, SqlType: mySQLDedupKeyColumnType}
+	}
+
+	wrappedTx, err := m.OpenTx()
+	if err != nil {
+		return err
+	}
+	if err := m.createTable(wrappedTx, m.config.Db, tableSchema); err != nil {
+		return err
+	}
+	if err := wrappedTx.tx.Commit(); err != nil {
+		return err
+	}
+
+	return m.EnsureDedupKey(tableSchema)
+}
+
+//EnsureDedupKey create unique index on dedup key column if it is configured and index doesn't exist
+func (m *MySQL) EnsureDedupKey(table *schema.Table) error {
+	dedupKey := m.config.DedupKey
+	if dedupKey == "" {
+		return nil
+	}
+
+	indexName := table.Name + "_" + dedupKey + "_key"
+	var count int
+	if err := m.dataSource.QueryRowContext(m.ctx, mySQLIndexExistsQuery, m.config.Db, table.Name, indexName).Scan(&count); err != nil {
+		return fmt.Errorf("Error querying table %s unique index on %s: %v", table.Name, dedupKey, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if _, err := m.dataSource.ExecContext(m.ctx, fmt.Sprintf(mySQLCreateUniqueIndex, indexName, m.config.Db, table.Name, dedupKey)); err != nil {
+		return fmt.Errorf("Error creating unique index on table %s column %s: %v", table.Name, dedupKey, err)
+	}
+
+	return nil
+}
+
+//PatchTableSchema add new columns(from provided schema.Table) to existing table
+func (m *MySQL) PatchTableSchema(patchSchema *schema.Table) error {
+	wrappedTx, err := m.OpenTx()
+	if err != nil {
+		return err
+	}
+	if err := m.addColumns(wrappedTx, m.config.Db, patchSchema); err != nil {
+		return err
+	}
+
+	return wrappedTx.tx.Commit()
+}
+
+//BulkInsert insert provided objects in mysql with multi-row INSERT statements in one transaction
+//Objects may have different keys: header is a union of all keys, missing values are inserted as NULL
+//Rows with already existing dedup key values are skipped (ON DUPLICATE KEY UPDATE without changes)
+func (m *MySQL) BulkInsert(table *schema.Table, objects []events.Fact) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	columnsSet := map[string]bool{}
+	for _, object := range objects {
+		for name := range object {
+			columnsSet[name] = true
+		}
+	}
+	var columns, quotedColumns []string
+	for name := range columnsSet {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	for _, name := range columns {
+		quotedColumns = append(quotedColumns, m.dialect.QuoteIdentifier(name))
+	}
+	header := strings.Join(quotedColumns, ",")
+	rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+
+	onDuplicateKey := ""
+	if m.config.DedupKey != "" {
+		quotedDedupKey := m.dialect.QuoteIdentifier(m.config.DedupKey)
+		onDuplicateKey = fmt.Sprintf(mySQLOnDuplicateKeyTemplate, quotedDedupKey, quotedDedupKey)
+	}
+
+	rowsPerStatement := mySQLMaxPlaceholdersPerQuery / len(columns)
+
+	wrappedTx, err := m.OpenTx()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(objects); start += rowsPerStatement {
+		end := start + rowsPerStatement
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		var rows []string
+		var values []interface{}
+		for _, object := range objects[start:end] {
+			for _, column := range columns {
+				values = append(values, object[column])
+			}
+			rows = append(rows, rowPlaceholders)
+		}
+
+		statement := fmt.Sprintf(mySQLBulkInsertTemplate, m.dialect.QuoteIdentifier(m.config.Db), m.dialect.QuoteIdentifier(table.Name),
+			header, strings.Join(rows, ",")) + onDuplicateKey
+		if _, err := wrappedTx.tx.ExecContext(m.ctx, statement, values...); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error bulk inserting %d objects in %s table with statement: %s: %v", end-start, table.Name, header, err)
+		}
+	}
+
+	return wrappedTx.tx.Commit()
+}
+
+//Close underlying sql.DB
+func (m *MySQL) Close() error {
+	if err := m.dataSource.Close(); err != nil {
+		return fmt.Errorf("Error closing datasource: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package adapters
+
+import (
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMySQLDialect(t *testing.T) {
+	dialect := MySQLDialect{}
+	table := &schema.Table{Name: "events", Columns: schema.Columns{
+		"order":    schema.Column{Type: schema.STRING},
+		"count":    schema.Column{Type: schema.INT64},
+		"products": schema.Column{Type: schema.STRING, SqlType: "json"},
+	}}
+
+	require.Equal(t, "`odd``name`", dialect.QuoteIdentifier("odd`name"))
+	require.Equal(t, "datetime(6)", dialect.ColumnType(schema.Column{Type: schema.TIMESTAMP}))
+	require.Equal(t, "CREATE TABLE `my_db`.`events` (`count` bigint,`order` text,`products` json)", dialect.CreateTableDDL("my_db", table))
+	require.Equal(t, "ALTER TABLE `my_db`.`events` ADD COLUMN `price` double",
+		dialect.AlterAddColumnDDL("my_db", "events", "price", schema.Column{Type: schema.FLOAT64}))
+}
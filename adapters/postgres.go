@@ -3,17 +3,26 @@ package adapters
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ksensehq/eventnative/events"
 	"github.com/ksensehq/eventnative/schema"
 	_ "github.com/lib/pq"
 	"log"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
 const (
 	connectTimeoutSeconds = 600 //TODO make it configurable
+	//postgres protocol limit of bind parameters in one statement
+	maxPlaceholdersPerStatement = 65535
 
 	tableNamesQuery  = `SELECT table_name FROM information_schema.tables WHERE table_schema=$1`
 	tableSchemaQuery = `SELECT 
@@ -24,23 +33,64 @@ const (
          					LEFT JOIN pg_attrdef pg_attrdef ON pg_attrdef.adrelid = pg_class.oid AND pg_attrdef.adnum = pg_attribute.attnum
          					LEFT JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
          					LEFT JOIN pg_constraint ON pg_constraint.conrelid = pg_class.oid AND pg_attribute.attnum = ANY (pg_constraint.conkey)
-						WHERE pg_class.relkind = 'r'::char
+						WHERE pg_class.relkind IN ('r'::char, 'p'::char)
   							AND  pg_namespace.nspname = $1
   							AND pg_class.relname = $2
   							AND pg_attribute.attnum > 0`
-	createDbSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS "%s"`
-	addColumnTemplate                 = `ALTER TABLE "%s"."%s" ADD COLUMN %s %s`
-	createTableTemplate               = `CREATE TABLE "%s"."%s" (%s)`
-	insertTemplate                    = `INSERT INTO "%s"."%s" (%s) VALUES (%s)`
+	createDbSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS %s`
+	alterColumnTypeTemplate           = `ALTER TABLE %s.%s ALTER COLUMN %s TYPE %s USING %s::%s`
+	insertTemplate                    = `INSERT INTO %s.%s (%s) VALUES (%s)`
+	bulkInsertTemplate                = `INSERT INTO %s.%s (%s) VALUES %s`
+	onConflictDoNothingTemplate       = ` ON CONFLICT (%s) DO NOTHING`
+	onConflictDoUpdateTemplate        = ` ON CONFLICT (%s) DO UPDATE SET %s`
+	createUniqueIndexTemplate         = `CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s.%s (%s)`
+	createIndexTemplate               = `CREATE INDEX %sIF NOT EXISTS %s ON %s.%s (%s)`
+	dropIndexConcurrentlyTemplate     = `DROP INDEX CONCURRENTLY IF EXISTS %s.%s`
+	partitionByRangeTemplate          = ` PARTITION BY RANGE (%s)`
+	createPartitionTemplate           = `CREATE TABLE IF NOT EXISTS %s.%s PARTITION OF %s.%s FOR VALUES FROM ('%s') TO ('%s')`
+	commentOnColumnTemplate           = `COMMENT ON COLUMN %s.%s.%s IS '%s'`
+	isPartitionedQuery                = `SELECT pg_class.relkind = 'p'::char FROM pg_class JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace
+						WHERE pg_namespace.nspname = $1 AND pg_class.relname = $2`
+	serverVersionNumQuery = `SHOW server_version_num`
+	partitionBoundLayout  = "2006-01-02"
+	//longer identifiers are truncated by Postgres silently
+	maxIdentifierLength = 63
+
+	//StreamingInsertMode insert objects one by one (one statement per row) in one transaction
+	StreamingInsertMode = "streaming"
+	//BatchInsertMode insert objects with multi-row INSERT statements (default)
+	BatchInsertMode = "batch"
+	//CopyInsertMode load objects with COPY FROM STDIN. The fastest one but doesn't support ON CONFLICT (dedup_key)
+	CopyInsertMode = "copy"
 )
 
 var (
+	dbSchemaNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
 	schemaToPostgres = map[schema.DataType]string{
-		schema.STRING: "character varying(512)",
+		schema.STRING:    "character varying(512)",
+		schema.INT64:     "bigint",
+		schema.FLOAT64:   "double precision",
+		schema.TIMESTAMP: "timestamp with time zone",
 	}
 
 	postgresToSchema = map[string]schema.DataType{
-		"character varying(512)": schema.STRING,
+		"character varying(512)":      schema.STRING,
+		"text":                        schema.STRING,
+		"smallint":                    schema.INT64,
+		"integer":                     schema.INT64,
+		"bigint":                      schema.INT64,
+		"real":                        schema.FLOAT64,
+		"double precision":            schema.FLOAT64,
+		"numeric":                     schema.FLOAT64,
+		"jsonb":                       schema.STRING,
+		"text[]":                      schema.STRING,
+		"bigint[]":                    schema.STRING,
+		"double precision[]":          schema.STRING,
+		"boolean[]":                   schema.STRING,
+		"date":                        schema.STRING,
+		"timestamp with time zone":    schema.TIMESTAMP,
+		"timestamp without time zone": schema.TIMESTAMP,
 	}
 )
 
@@ -52,6 +102,74 @@ type DataSourceConfig struct {
 	Schema   string `mapstructure:"schema"`
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
+	//TLS settings: sslmode (disable, require, verify-ca, verify-full) and certificate files paths
+	//driver default (require) is used if sslmode isn't set
+	SSLMode     string `mapstructure:"ssl_mode"`
+	SSLRootCert string `mapstructure:"ssl_root_cert"`
+	SSLCert     string `mapstructure:"ssl_cert"`
+	SSLKey      string `mapstructure:"ssl_key"`
+	//used only in Postgres destination: flatten column name (e.g. eventn_ctx_event_id) with unique index
+	//rows with already existing values are skipped on insert
+	DedupKey string `mapstructure:"dedup_key"`
+	//used only in Postgres destination: table name (lowercase, with table_prefix and table_suffix) -> key columns
+	//with unique index. Rows with already existing keys are updated with new values (the latest event wins)
+	UpsertKeys map[string][]string `mapstructure:"upsert_keys"`
+	//used only in Postgres destination: table name (lowercase, with table_prefix and table_suffix) -> columns of secondary
+	//indexes (one list per index). Indexes are created in background after the table is created or read (see CreateIndex)
+	Indexes map[string][][]string `mapstructure:"indexes"`
+	//used only in Postgres destination: added to all table names e.g. staging_ for isolation of environments in one database
+	TablePrefix string `mapstructure:"table_prefix"`
+	TableSuffix string `mapstructure:"table_suffix"`
+	//connection pool settings. database/sql defaults are used if not set (unlimited open connections, 2 idle ones)
+	MaxOpenConns       int `mapstructure:"max_open_conns"`
+	MaxIdleConns       int `mapstructure:"max_idle_conns"`
+	ConnMaxLifetimeSec int `mapstructure:"conn_max_lifetime_sec"`
+	//used only in Postgres destination: how often cached tables schemas are re-read to detect outer changes. 0 - never
+	SchemaRefreshIntervalSec int `mapstructure:"schema_refresh_interval_sec"`
+	//used only in Postgres destination: count of goroutines which read queue and insert batches concurrently
+	//(with connections from the pool). Events order isn't kept if it is greater than 1
+	DrainWorkers int `mapstructure:"drain_workers"`
+	//used only in Postgres destination: streaming, batch (default) or copy (see BulkInsert)
+	InsertMode string `mapstructure:"insert_mode"`
+	//used only in Postgres destination: rows older than this count of days are deleted periodically
+	//(old partitions are dropped if partitioning is configured). 0 - retention is disabled
+	RetentionDays int `mapstructure:"retention_days"`
+	//timestamp column which is compared with retention window. _timestamp by default
+	RetentionColumn string `mapstructure:"retention_column"`
+	//max count of rows deleted with one statement. 10000 by default
+	RetentionBatchSize int `mapstructure:"retention_batch_size"`
+	//how often retention job is run. 3600 by default
+	RetentionIntervalSec int `mapstructure:"retention_interval_sec"`
+	//used only in Postgres destination: max duration of one insert or DDL statement. Statements are canceled
+	//on timeout and failed events are re-enqueued. 0 - unlimited (default)
+	StatementTimeoutMs int `mapstructure:"statement_timeout_ms"`
+	//used only in Postgres destination: text/template of comments of created and patched columns
+	//(see ColumnCommentData). Columns aren't commented if not set
+	ColumnCommentTemplate string `mapstructure:"column_comment_template"`
+	//used only in Postgres destination: columns which haven't been received in this count of the latest events
+	//of the table are reported as unused (see storages.Postgres.UnusedColumns). 0 - isn't tracked
+	UnusedColumnsWindow int `mapstructure:"unused_columns_window"`
+	//used only in Postgres destination: flatten column name (e.g. eventn_ctx_tenant_id) which lowercase value is
+	//a db schema of the row. Db schemas are created on first use. Rows with missing, invalid (see IsValidDbSchemaName)
+	//or not allowed values are stored in schema. All rows are stored in schema if not set
+	SchemaColumn string `mapstructure:"schema_column"`
+	//used only with schema_column: the only db schemas which may be selected. All valid names if empty
+	AllowedSchemas []string `mapstructure:"allowed_schemas"`
+
+	//used only in Postgres destination: rows of one batch are inserted in chunks of this count with savepoint before
+	//every chunk. Failed chunk is rolled back to its savepoint and retried row by row: only bad rows are re-enqueued,
+	//other rows are committed. batch and streaming insert modes only. 0 - the whole batch fails on one bad row (default)
+	SavepointRows int `mapstructure:"savepoint_rows"`
+	//used only in Postgres destination: DDL statements (e.g. automatic CREATE TABLE, ALTER TABLE) are written to the log
+	//before execution for audit, DML statements (without values) are written at debug level (see SQLHooks)
+	AuditStatements bool `mapstructure:"audit_statements"`
+	//used only in Postgres destination: tables aren't patched automatically. Objects with new columns (or values which
+	//don't fit column types) are put to the dead-letter queue (schema must be changed with migrations). Tables are still
+	//created automatically. false - new columns are added and types are widened (default)
+	StrictSchema bool `mapstructure:"strict_schema"`
+
+	//used only in streaming (Postgres) destination
+	StreamingConfig `mapstructure:",squash"`
 }
 
 //Validate required fields in DataSourceConfig
@@ -68,45 +186,222 @@ func (dsc *DataSourceConfig) Validate() error {
 	if dsc.Username == "" {
 		return errors.New("Datasource username is required parameter")
 	}
+	if dsc.DrainWorkers < 0 {
+		return errors.New("Datasource drain_workers must be positive")
+	}
+	if dsc.DedupKey != "" && len(dsc.UpsertKeys) > 0 {
+		return errors.New("Datasource dedup_key and upsert_keys can't be used together")
+	}
+	for table, key := range dsc.UpsertKeys {
+		if len(key) == 0 {
+			return fmt.Errorf("Datasource upsert_keys of %s table must contain at least one column", table)
+		}
+	}
+	for table, indexes := range dsc.Indexes {
+		for _, columns := range indexes {
+			if len(columns) == 0 {
+				return fmt.Errorf("Datasource indexes of %s table must contain at least one column", table)
+			}
+		}
+	}
+	if dsc.RetentionDays < 0 || dsc.RetentionBatchSize < 0 || dsc.RetentionIntervalSec < 0 {
+		return errors.New("Datasource retention_days, retention_batch_size and retention_interval_sec must be positive")
+	}
+	if dsc.StatementTimeoutMs < 0 {
+		return errors.New("Datasource statement_timeout_ms must be positive")
+	}
+	if dsc.SavepointRows < 0 {
+		return errors.New("Datasource savepoint_rows must be positive")
+	}
+	if dsc.UnusedColumnsWindow < 0 {
+		return errors.New("Datasource unused_columns_window must be positive")
+	}
+	if _, err := parseColumnCommentTemplate(dsc.ColumnCommentTemplate); err != nil {
+		return err
+	}
+	for _, dbSchema := range dsc.AllowedSchemas {
+		if !IsValidDbSchemaName(strings.ToLower(dbSchema)) {
+			return fmt.Errorf("Datasource allowed_schemas contains invalid schema name: %s", dbSchema)
+		}
+	}
+	switch dsc.SSLMode {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("Unsupported datasource ssl_mode: %s. Supported: disable, require, verify-ca, verify-full", dsc.SSLMode)
+	}
+	switch dsc.InsertMode {
+	case "", StreamingInsertMode, BatchInsertMode:
+	case CopyInsertMode:
+		if dsc.DedupKey != "" || len(dsc.UpsertKeys) > 0 {
+			return errors.New("Datasource insert_mode copy can't be used with dedup_key and upsert_keys: COPY doesn't support ON CONFLICT")
+		}
+		if dsc.SavepointRows > 0 {
+			return errors.New("Datasource insert_mode copy can't be used with savepoint_rows: COPY is one statement")
+		}
+	default:
+		return fmt.Errorf("Unsupported datasource insert_mode: %s. Supported: %s, %s, %s", dsc.InsertMode, StreamingInsertMode, BatchInsertMode, CopyInsertMode)
+	}
 
 	return nil
 }
 
+//IsValidDbSchemaName return true if name may be used as db schema selected by event field (see DataSourceConfig.SchemaColumn):
+//lowercase letters, digits and underscores not longer than Postgres identifier limit. Reserved pg_ prefix isn't allowed
+func IsValidDbSchemaName(name string) bool {
+	return dbSchemaNameRegexp.MatchString(name) && !strings.HasPrefix(name, "pg_")
+}
+
+//PostgresDialect is a SQLDialect with double quoted identifiers and Postgres column types
+//(also is used for Redshift)
+type PostgresDialect struct{}
+
+func (PostgresDialect) QuoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (PostgresDialect) ColumnType(column schema.Column) string {
+	if column.SqlType != "" {
+		return column.SqlType
+	}
+
+	mappedType, ok := schemaToPostgres[column.Type]
+	if !ok {
+		log.Println("Unknown postgres schema type:", column.Type.String())
+		mappedType = schemaToPostgres[schema.STRING]
+	}
+
+	return mappedType
+}
+
+//CreateTableDDL return CREATE TABLE statement. Columns are sorted by name
+//Column names are quoted because they can be reserved words (e.g. user, order)
+func (d PostgresDialect) CreateTableDDL(dbSchema string, table *schema.Table) string {
+	var columnsDDL []string
+	for _, columnName := range table.Columns.SortedNames() {
+		columnsDDL = append(columnsDDL, d.QuoteIdentifier(columnName)+" "+d.ColumnType(table.Columns[columnName]))
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s.%s (%s)", d.QuoteIdentifier(dbSchema), d.QuoteIdentifier(table.Name), strings.Join(columnsDDL, ","))
+}
+
+func (d PostgresDialect) AlterAddColumnDDL(dbSchema, tableName, columnName string, column schema.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s.%s ADD COLUMN %s %s", d.QuoteIdentifier(dbSchema), d.QuoteIdentifier(tableName),
+		d.QuoteIdentifier(columnName), d.ColumnType(column))
+}
+
+//TableNotFoundError is an insert error of table (or its db schema) which doesn't exist in postgres
+//e.g. it has been dropped outside after its schema had been cached
+type TableNotFoundError struct {
+	err error
+}
+
+func (tnfe *TableNotFoundError) Error() string {
+	return tnfe.err.Error()
+}
+
+//IsTableNotFoundError return true if err is (or wraps) TableNotFoundError
+func IsTableNotFoundError(err error) bool {
+	var tnfe *TableNotFoundError
+	return errors.As(err, &tnfe)
+}
+
+//Return wrapped as TableNotFoundError if statement error err is SQLSTATE 42P01 (undefined_table) or 3F000 (invalid_schema_name)
+func withTableNotFound(err, wrapped error) error {
+	var sqlStateErr interface{ SQLState() string }
+	if errors.As(err, &sqlStateErr) && (sqlStateErr.SQLState() == "42P01" || sqlStateErr.SQLState() == "3F000") {
+		return &TableNotFoundError{err: wrapped}
+	}
+
+	return wrapped
+}
+
 //Postgres is adapter for creating,patching (schema or table), inserting data to postgres
+//Tables are created and patched with SQLAdapter and PostgresDialect
+//Connection errors of inserts mark adapter unhealthy and start reconnecting with backoff (see Health)
 type Postgres struct {
-	ctx        context.Context
-	config     *DataSourceConfig
-	dataSource *sql.DB
+	*SQLAdapter
+
+	config *DataSourceConfig
+	//nil if column comments aren't configured
+	columnComment *template.Template
+
+	//guards connErr, reconnecting and closed
+	connMutex sync.Mutex
+	//the last connection error. nil - connection is alive
+	connErr      error
+	reconnecting bool
+	closed       bool
+	//reconnect delays. defaultReconnectBackoffBase and defaultReconnectBackoffMax if not set
+	reconnectBackoffBase time.Duration
+	reconnectBackoffMax  time.Duration
 }
 
 //NewPostgres return configured Postgres adapter instance
 func NewPostgres(ctx context.Context, config *DataSourceConfig) (*Postgres, error) {
 	connectionString := fmt.Sprintf("host=%s port=%d dbname=%s connect_timeout=%d  user=%s password=%s",
-		config.Host, config.Port, config.Db, connectTimeoutSeconds, config.Username, config.Password)
+		connectionValue(config.Host), config.Port, connectionValue(config.Db), connectTimeoutSeconds,
+		connectionValue(config.Username), connectionValue(config.Password))
+	connectionString += sslParameters(config)
 	dataSource, err := sql.Open("postgres", connectionString)
 
 	if err != nil {
 		return nil, err
 	}
+	configurePool(dataSource, config)
 	if err := dataSource.Ping(); err != nil {
 		return nil, err
 	}
 
-	return &Postgres{ctx: ctx, config: config, dataSource: dataSource}, nil
+	columnComment, err := parseColumnCommentTemplate(config.ColumnCommentTemplate)
+	if err != nil {
+		dataSource.Close()
+		return nil, err
+	}
+
+	sqlAdapter := NewSQLAdapter(ctx, dataSource, PostgresDialect{}, "Postgres")
+	sqlAdapter.statementTimeout = time.Duration(config.StatementTimeoutMs) * time.Millisecond
+
+	return &Postgres{SQLAdapter: sqlAdapter, config: config, columnComment: columnComment}, nil
 }
 
-func (Postgres) Name() string {
-	return "Postgres"
+//Return libpq ssl connection string parameters which are set in config
+func sslParameters(config *DataSourceConfig) string {
+	parameters := ""
+	for _, kv := range [][2]string{
+		{"sslmode", config.SSLMode},
+		{"sslrootcert", config.SSLRootCert},
+		{"sslcert", config.SSLCert},
+		{"sslkey", config.SSLKey},
+	} {
+		if kv[1] != "" {
+			parameters += fmt.Sprintf(" %s=%s", kv[0], connectionValue(kv[1]))
+		}
+	}
+
+	return parameters
 }
 
-//OpenTx open underline sql transaction and return wrapped instance
-func (p *Postgres) OpenTx() (*Transaction, error) {
-	tx, err := p.dataSource.BeginTx(p.ctx, nil)
-	if err != nil {
-		return nil, err
+//Return libpq connection string value in single quotes with escaped single quotes and backslashes
+//so values with spaces (e.g. certificate paths or passwords) are kept as is
+func connectionValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+//Apply connection pool settings to sql.DB. All inserts and schema changes acquire connections from this pool
+func configurePool(dataSource *sql.DB, config *DataSourceConfig) {
+	if config.MaxOpenConns > 0 {
+		dataSource.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		dataSource.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetimeSec > 0 {
+		dataSource.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetimeSec) * time.Second)
 	}
+}
 
-	return &Transaction{tx: tx, dbType: p.Name()}, nil
+func (*Postgres) Name() string {
+	return "Postgres"
 }
 
 //CreateDbSchema create database schema instance if doesn't exist
@@ -120,6 +415,7 @@ func (p *Postgres) CreateDbSchema(dbSchemaName string) error {
 }
 
 //CreateTable create database table with name,columns provided in schema.Table representation
+//and unique constraint on dedup key column if it is configured
 func (p *Postgres) CreateTable(tableSchema *schema.Table) error {
 	wrappedTx, err := p.OpenTx()
 	if err != nil {
@@ -129,6 +425,56 @@ func (p *Postgres) CreateTable(tableSchema *schema.Table) error {
 	return p.createTableInTransaction(wrappedTx, tableSchema)
 }
 
+//CreatePartitionedTable create database table (like CreateTable) partitioned by range of partitionColumn
+//Partitions must be created with CreatePartition before inserting. Declarative partitioning requires Postgres 10+
+func (p *Postgres) CreatePartitionedTable(tableSchema *schema.Table, partitionColumn string) error {
+	wrappedTx, err := p.OpenTx()
+	if err != nil {
+		return err
+	}
+
+	statement := p.dialect.CreateTableDDL(p.dbSchema(tableSchema.Schema), tableSchema) + fmt.Sprintf(partitionByRangeTemplate, p.dialect.QuoteIdentifier(partitionColumn))
+	if _, err := p.exec(wrappedTx, statement); err != nil {
+		wrappedTx.Rollback()
+		return fmt.Errorf("Error creating [%s] partitioned table: %v", tableSchema.Name, err)
+	}
+
+	return wrappedTx.tx.Commit()
+}
+
+//CreatePartition create partition of partitioned table for values range [from, to) if doesn't exist
+//Partition is created in the db schema of the table (default one if dbSchema is empty)
+func (p *Postgres) CreatePartition(dbSchema, tableName, partitionName string, from, to time.Time) error {
+	quotedSchema := p.dialect.QuoteIdentifier(p.dbSchema(dbSchema))
+	statement := fmt.Sprintf(createPartitionTemplate, quotedSchema, p.dialect.QuoteIdentifier(partitionName), quotedSchema,
+		p.dialect.QuoteIdentifier(tableName), from.Format(partitionBoundLayout), to.Format(partitionBoundLayout))
+	if _, err := p.execDataSource(p.ctx, statement); err != nil {
+		return fmt.Errorf("Error creating partition %s of %s table: %v", partitionName, tableName, err)
+	}
+
+	return nil
+}
+
+//IsPartitioned return true if table of db schema (default one if empty) is partitioned (declarative partitioning)
+func (p *Postgres) IsPartitioned(dbSchema, tableName string) (bool, error) {
+	var partitioned bool
+	if err := p.dataSource.QueryRowContext(p.ctx, isPartitionedQuery, p.dbSchema(dbSchema), tableName).Scan(&partitioned); err != nil {
+		return false, fmt.Errorf("Error querying table %s partitioning: %v", tableName, err)
+	}
+
+	return partitioned, nil
+}
+
+//ServerVersionNum return postgres server version number e.g. 120004
+func (p *Postgres) ServerVersionNum() (int, error) {
+	var versionNum string
+	if err := p.dataSource.QueryRowContext(p.ctx, serverVersionNumQuery).Scan(&versionNum); err != nil {
+		return 0, fmt.Errorf("Error querying server version: %v", err)
+	}
+
+	return strconv.Atoi(versionNum)
+}
+
 //PatchTableSchema add new columns(from provided schema.Table) to existing table
 func (p *Postgres) PatchTableSchema(patchSchema *schema.Table) error {
 	wrappedTx, err := p.OpenTx()
@@ -140,7 +486,7 @@ func (p *Postgres) PatchTableSchema(patchSchema *schema.Table) error {
 }
 
 func (p *Postgres) createDbSchemaInTransaction(wrappedTx *Transaction, dbSchemaName string) error {
-	createStmt, err := wrappedTx.tx.PrepareContext(p.ctx, fmt.Sprintf(createDbSchemaIfNotExistsTemplate, dbSchemaName))
+	createStmt, err := p.prepare(p.ctx, wrappedTx, fmt.Sprintf(createDbSchemaIfNotExistsTemplate, p.dialect.QuoteIdentifier(dbSchemaName)))
 	if err != nil {
 		wrappedTx.Rollback()
 		return fmt.Errorf("Error preparing create db schema %s statement: %v", dbSchemaName, err)
@@ -155,10 +501,16 @@ func (p *Postgres) createDbSchemaInTransaction(wrappedTx *Transaction, dbSchemaN
 	return wrappedTx.tx.Commit()
 }
 
-//GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
+//GetTableSchema return table (name,columns with name and types) of the default db schema representation wrapped in schema.Table struct
 func (p *Postgres) GetTableSchema(tableName string) (*schema.Table, error) {
-	table := &schema.Table{Name: tableName, Columns: schema.Columns{}}
-	rows, err := p.dataSource.QueryContext(p.ctx, tableSchemaQuery, p.config.Schema, tableName)
+	return p.GetDbSchemaTable("", tableName)
+}
+
+//GetDbSchemaTable return table of provided db schema (default one if empty) like GetTableSchema
+//Result schema.Table has provided db schema
+func (p *Postgres) GetDbSchemaTable(dbSchema, tableName string) (*schema.Table, error) {
+	table := &schema.Table{Name: tableName, Schema: dbSchema, Columns: schema.Columns{}}
+	rows, err := p.dataSource.QueryContext(p.ctx, tableSchemaQuery, p.dbSchema(dbSchema), tableName)
 	if err != nil {
 		return nil, fmt.Errorf("Error querying table [%s] schema: %v", tableName, err)
 	}
@@ -170,6 +522,10 @@ func (p *Postgres) GetTableSchema(tableName string) (*schema.Table, error) {
 			return nil, fmt.Errorf("Error scanning result: %v", err)
 		}
 		mappedType, ok := postgresToSchema[columnPostgresType]
+		//numeric with precision and scale e.g. numeric(18,2) (see schema.DecimalValue)
+		if !ok && strings.HasPrefix(columnPostgresType, "numeric(") {
+			mappedType, ok = schema.FLOAT64, true
+		}
 		if !ok {
 			log.Println("Unknown postgres column type:", columnPostgresType)
 			mappedType = schema.STRING
@@ -184,61 +540,70 @@ func (p *Postgres) GetTableSchema(tableName string) (*schema.Table, error) {
 }
 
 func (p *Postgres) createTableInTransaction(wrappedTx *Transaction, tableSchema *schema.Table) error {
-	var columnsDDL []string
-	for columnName, column := range tableSchema.Columns {
-		mappedType, ok := schemaToPostgres[column.Type]
-		if !ok {
-			log.Println("Unknown postgres schema type:", column.Type)
-			mappedType = schemaToPostgres[schema.STRING]
+	//unique key columns must exist for unique index even if objects don't have them yet
+	uniqueKey := p.uniqueKey(tableSchema.Name)
+	for _, column := range uniqueKey {
+		if _, ok := tableSchema.Columns[column]; !ok {
+			tableSchema.Columns[column] = schema.Column{Type: schema.STRING}
 		}
-		columnsDDL = append(columnsDDL, fmt.Sprintf(`%s %s`, columnName, mappedType))
 	}
 
-	createStmt, err := wrappedTx.tx.PrepareContext(p.ctx, fmt.Sprintf(createTableTemplate, p.config.Schema, tableSchema.Name, strings.Join(columnsDDL, ",")))
-	if err != nil {
-		wrappedTx.Rollback()
-		return fmt.Errorf("Error preparing create table %s statement: %v", tableSchema.Name, err)
+	if err := p.createTable(wrappedTx, p.dbSchema(tableSchema.Schema), tableSchema); err != nil {
+		return err
 	}
 
-	_, err = createStmt.ExecContext(p.ctx)
+	//unique index in the same transaction (it is used by ON CONFLICT clause as well as unique constraint)
+	if len(uniqueKey) > 0 {
+		if _, err := p.exec(wrappedTx, p.uniqueIndexStatement(tableSchema.Schema, tableSchema.Name, uniqueKey)); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error creating %s table unique index on %v: %v", tableSchema.Name, uniqueKey, err)
+		}
+	}
 
-	if err != nil {
-		wrappedTx.Rollback()
-		return fmt.Errorf("Error creating [%s] table: %v", tableSchema.Name, err)
+	if err := p.commentColumns(wrappedTx, tableSchema.Schema, tableSchema.Name, tableSchema.Columns); err != nil {
+		return err
 	}
+
 	return wrappedTx.tx.Commit()
 }
 
 func (p *Postgres) patchTableSchemaInTransaction(wrappedTx *Transaction, patchSchema *schema.Table) error {
-	for columnName, column := range patchSchema.Columns {
-		mappedColumnType, ok := schemaToPostgres[column.Type]
-		if !ok {
-			log.Println("Unknown postgres schema type:", column.Type.String())
-			mappedColumnType = schemaToPostgres[schema.STRING]
-		}
-		alterStmt, err := wrappedTx.tx.PrepareContext(p.ctx, fmt.Sprintf(addColumnTemplate, p.config.Schema, patchSchema.Name, columnName, mappedColumnType))
-		if err != nil {
-			wrappedTx.Rollback()
-			return fmt.Errorf("Error preparing patching table %s schema statement: %v", patchSchema.Name, err)
-		}
+	dbSchema := p.dbSchema(patchSchema.Schema)
+	if err := p.addColumns(wrappedTx, dbSchema, patchSchema); err != nil {
+		return err
+	}
 
-		_, err = alterStmt.ExecContext(p.ctx)
-		if err != nil {
+	//widen types of existing columns (e.g. bigint -> double precision) in the same transaction
+	for _, columnName := range patchSchema.WidenedColumns.SortedNames() {
+		column := patchSchema.WidenedColumns[columnName]
+		mappedColumnType := p.dialect.ColumnType(column)
+		quotedColumn := p.dialect.QuoteIdentifier(columnName)
+		statement := fmt.Sprintf(alterColumnTypeTemplate, p.dialect.QuoteIdentifier(dbSchema), p.dialect.QuoteIdentifier(patchSchema.Name),
+			quotedColumn, mappedColumnType, quotedColumn, mappedColumnType)
+		if _, err := p.exec(wrappedTx, statement); err != nil {
 			wrappedTx.Rollback()
-			return fmt.Errorf("Error patching %s table with '%s' - %s column schema: %v", patchSchema.Name, columnName, mappedColumnType, err)
+			return fmt.Errorf("Error widening %s table '%s' column type to %s: %v", patchSchema.Name, columnName, mappedColumnType, err)
 		}
 	}
 
+	//widened columns are commented one more time with new type
+	if err := p.commentColumns(wrappedTx, patchSchema.Schema, patchSchema.Name, patchSchema.Columns); err != nil {
+		return err
+	}
+	if err := p.commentColumns(wrappedTx, patchSchema.Schema, patchSchema.Name, patchSchema.WidenedColumns); err != nil {
+		return err
+	}
+
 	return wrappedTx.tx.Commit()
 }
 
-//Insert provided object in postgres
+//Insert provided object in postgres (schema.JSONValue values are marshaled to json)
 func (p *Postgres) Insert(schema *schema.Table, valuesMap map[string]interface{}) error {
 	var header, placeholders string
 	var values []interface{}
 	i := 1
 	for name, value := range valuesMap {
-		header += name + ","
+		header += p.dialect.QuoteIdentifier(name) + ","
 		//$1, $2, $3, etc
 		placeholders += "$" + strconv.Itoa(i) + ","
 		values = append(values, value)
@@ -253,19 +618,193 @@ func (p *Postgres) Insert(schema *schema.Table, valuesMap map[string]interface{}
 		return err
 	}
 
-	insertStmt, err := wrappedTx.tx.PrepareContext(p.ctx, fmt.Sprintf(insertTemplate, p.config.Schema, schema.Name, header, placeholders))
+	var columns []string
+	for name := range valuesMap {
+		columns = append(columns, name)
+	}
+	ctx, cancel := p.statementContext()
+	defer cancel()
+	insertStmt, err := p.prepare(ctx, wrappedTx, fmt.Sprintf(insertTemplate, p.dialect.QuoteIdentifier(p.dbSchema(schema.Schema)), p.dialect.QuoteIdentifier(schema.Name), header, placeholders)+p.onConflictClause(schema.Name, columns))
 	if err != nil {
+		p.checkConnection(err)
 		wrappedTx.Rollback()
-		return fmt.Errorf("Error preparing insert table %s statement: %v", schema.Name, err)
+		return withTableNotFound(err, fmt.Errorf("Error preparing insert table %s statement: %v", schema.Name, err))
 	}
 
-	_, err = insertStmt.ExecContext(p.ctx, values...)
+	_, err = insertStmt.ExecContext(ctx, values...)
 	if err != nil {
+		p.checkConnection(err)
 		wrappedTx.Rollback()
-		return fmt.Errorf("Error inserting in %s table with statement: %s values: %v: %v", schema.Name, header, values, err)
+		return withTableNotFound(err, fmt.Errorf("Error inserting in %s table with statement: %s values: %v: %v", schema.Name, header, values, err))
 	}
 
-	return wrappedTx.tx.Commit()
+	return p.checkConnection(wrappedTx.tx.Commit())
+}
+
+//BulkInsert insert provided objects in postgres in one transaction according to configured insert mode:
+//multi-row INSERT statements (batch, default), one INSERT statement per object (streaming) or COPY FROM STDIN (copy)
+//Objects may have different keys: header is a union of all keys, missing values are inserted as NULL
+//schema.JSONValue values (not flattened subtrees) are marshaled to json for jsonb columns (see schema.JSONValue.Value())
+//If upsert key is configured for the table only the last object of every key is inserted (or updated)
+//If savepoint_rows is configured bad rows don't fail the whole transaction: other rows are committed and
+//*PartialInsertError with indexes of not inserted objects is returned (see savepointInsert)
+func (p *Postgres) BulkInsert(table *schema.Table, objects []events.Fact) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	total := len(objects)
+	//indexes of inserted objects in provided objects. nil - all objects are inserted
+	var kept []int
+	if upsertKey := p.upsertKey(table.Name); len(upsertKey) > 0 {
+		//ON CONFLICT DO UPDATE can't update one row twice in one statement
+		objects, kept = lastByKey(objects, upsertKey)
+	}
+
+	columnsSet := map[string]bool{}
+	for _, object := range objects {
+		for name := range object {
+			columnsSet[name] = true
+		}
+	}
+	var columns []string
+	for name := range columnsSet {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	wrappedTx, err := p.OpenTx()
+	if err != nil {
+		return p.checkConnection(err)
+	}
+
+	var insert func(objects []events.Fact) error
+	switch p.config.InsertMode {
+	case StreamingInsertMode:
+		insert = func(objects []events.Fact) error { return p.rowsInsert(wrappedTx, table, columns, objects) }
+	case CopyInsertMode:
+		insert = func(objects []events.Fact) error { return p.copyInsert(wrappedTx, table, columns, objects) }
+	default:
+		insert = func(objects []events.Fact) error { return p.multiRowInsert(wrappedTx, table, columns, objects) }
+	}
+
+	if p.config.SavepointRows == 0 {
+		if err := insert(objects); err != nil {
+			wrappedTx.Rollback()
+			//connection errors have been checked before wrapping
+			return err
+		}
+		return p.checkConnection(wrappedTx.tx.Commit())
+	}
+
+	failed, rowErr, err := p.savepointInsert(wrappedTx, objects, insert)
+	if err != nil {
+		wrappedTx.Rollback()
+		return err
+	}
+	if err := p.checkConnection(wrappedTx.tx.Commit()); err != nil {
+		return err
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if kept != nil {
+		for i, index := range failed {
+			failed[i] = kept[index]
+		}
+	}
+
+	return &PartialInsertError{Failed: failed, Total: total, Err: rowErr}
+}
+
+//Return comma separated quoted column names
+func (p *Postgres) header(columns []string) string {
+	var quotedColumns []string
+	for _, name := range columns {
+		quotedColumns = append(quotedColumns, p.dialect.QuoteIdentifier(name))
+	}
+
+	return strings.Join(quotedColumns, ",")
+}
+
+//multiRowInsert insert objects with multi-row INSERT statements in provided transaction without commit
+//Objects are split into several statements if the postgres bind parameters limit is exceeded
+//Transaction isn't rolled back on error (see BulkInsert)
+func (p *Postgres) multiRowInsert(wrappedTx *Transaction, table *schema.Table, columns []string, objects []events.Fact) error {
+	header := p.header(columns)
+	rowsPerStatement := maxPlaceholdersPerStatement / len(columns)
+
+	for start := 0; start < len(objects); start += rowsPerStatement {
+		end := start + rowsPerStatement
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		var rows []string
+		var values []interface{}
+		i := 1
+		for _, object := range objects[start:end] {
+			var placeholders []string
+			for _, column := range columns {
+				//$1, $2, $3, etc
+				placeholders = append(placeholders, "$"+strconv.Itoa(i))
+				values = append(values, object[column])
+				i++
+			}
+			rows = append(rows, "("+strings.Join(placeholders, ",")+")")
+		}
+
+		ctx, cancel := p.statementContext()
+		insertStmt, err := p.prepare(ctx, wrappedTx, fmt.Sprintf(bulkInsertTemplate, p.dialect.QuoteIdentifier(p.dbSchema(table.Schema)), p.dialect.QuoteIdentifier(table.Name), header, strings.Join(rows, ","))+p.onConflictClause(table.Name, columns))
+		if err != nil {
+			cancel()
+			p.checkConnection(err)
+			return withTableNotFound(err, fmt.Errorf("Error preparing bulk insert table %s statement: %v", table.Name, err))
+		}
+
+		_, err = insertStmt.ExecContext(ctx, values...)
+		cancel()
+		if err != nil {
+			p.checkConnection(err)
+			return withTableNotFound(err, fmt.Errorf("Error bulk inserting %d objects in %s table with statement: %s: %v", end-start, table.Name, header, err))
+		}
+	}
+
+	return nil
+}
+
+//rowsInsert insert objects one by one with one prepared INSERT statement in provided transaction without commit
+//Transaction isn't rolled back on error (see BulkInsert)
+func (p *Postgres) rowsInsert(wrappedTx *Transaction, table *schema.Table, columns []string, objects []events.Fact) error {
+	header := p.header(columns)
+	var placeholders []string
+	for i := range columns {
+		placeholders = append(placeholders, "$"+strconv.Itoa(i+1))
+	}
+
+	prepareCtx, cancel := p.statementContext()
+	insertStmt, err := p.prepare(prepareCtx, wrappedTx, fmt.Sprintf(insertTemplate, p.dialect.QuoteIdentifier(p.dbSchema(table.Schema)), p.dialect.QuoteIdentifier(table.Name), header, strings.Join(placeholders, ","))+p.onConflictClause(table.Name, columns))
+	cancel()
+	if err != nil {
+		p.checkConnection(err)
+		return withTableNotFound(err, fmt.Errorf("Error preparing insert table %s statement: %v", table.Name, err))
+	}
+	defer insertStmt.Close()
+
+	for _, object := range objects {
+		values := make([]interface{}, 0, len(columns))
+		for _, column := range columns {
+			values = append(values, object[column])
+		}
+		ctx, cancel := p.statementContext()
+		_, err := insertStmt.ExecContext(ctx, values...)
+		cancel()
+		if err != nil {
+			p.checkConnection(err)
+			return withTableNotFound(err, fmt.Errorf("Error inserting in %s table with statement: %s values: %v: %v", table.Name, header, values, err))
+		}
+	}
+
+	return nil
 }
 
 //TablesList return slice of postgres table names
@@ -291,8 +830,12 @@ func (p *Postgres) TablesList() ([]string, error) {
 	return tableNames, nil
 }
 
-//Close underlying sql.DB
+//Close underlying sql.DB and stop reconnecting
 func (p *Postgres) Close() error {
+	p.connMutex.Lock()
+	p.closed = true
+	p.connMutex.Unlock()
+
 	if err := p.dataSource.Close(); err != nil {
 		return fmt.Errorf("Error closing datasource: %v", err)
 	}
@@ -300,29 +843,174 @@ func (p *Postgres) Close() error {
 	return nil
 }
 
-//Transaction is sql transaction wrapper. Used for handling and log errors with db type (postgres or redshift)
-//on Commit() and Rollback() calls
-type Transaction struct {
-	dbType string
-	tx     *sql.Tx
+func removeLastComma(str string) string {
+	if last := len(str) - 1; last >= 0 && str[last] == ',' {
+		str = str[:last]
+	}
+
+	return str
 }
 
-func (t *Transaction) Commit() {
-	if err := t.tx.Commit(); err != nil {
-		log.Printf("System error: unable to commit %s transaction: %v", t.dbType, err)
+//EnsureUniqueKey add dedup key or upsert key columns (if they don't exist) and unique index on them to existing table
+//Do nothing if neither dedup key nor table upsert key is configured
+func (p *Postgres) EnsureUniqueKey(table *schema.Table) error {
+	uniqueKey := p.uniqueKey(table.Name)
+	if len(uniqueKey) == 0 {
+		return nil
+	}
+
+	patch := &schema.Table{Name: table.Name, Schema: table.Schema, Columns: schema.Columns{}}
+	for _, column := range uniqueKey {
+		if _, ok := table.Columns[column]; !ok {
+			patch.Columns[column] = schema.Column{Type: schema.STRING}
+		}
 	}
+	if len(patch.Columns) > 0 {
+		if err := p.PatchTableSchema(patch); err != nil {
+			return err
+		}
+		table.Columns.Merge(patch.Columns)
+	}
+
+	if _, err := p.execDataSource(p.ctx, p.uniqueIndexStatement(table.Schema, table.Name, uniqueKey)); err != nil {
+		return fmt.Errorf("Error creating %s table unique index on %v: %v", table.Name, uniqueKey, err)
+	}
+
+	return nil
 }
 
-func (t *Transaction) Rollback() {
-	if err := t.tx.Rollback(); err != nil {
-		log.Printf("System error: unable to rollback %s transaction: %v", t.dbType, err)
+//Indexes return configured secondary indexes columns of the table or nil
+func (p *Postgres) Indexes(tableName string) [][]string {
+	return p.config.Indexes[strings.ToLower(tableName)]
+}
+
+//CreateIndex create secondary index on columns if it doesn't exist. The statement isn't run in transaction and
+//statement timeout isn't applied: it may take long on big tables. CONCURRENTLY doesn't block inserts but isn't
+//supported on partitioned tables. Invalid index which is left by failed CREATE INDEX CONCURRENTLY is dropped
+//so it is created again on the next call
+func (p *Postgres) CreateIndex(dbSchema, tableName string, columns []string, concurrently bool) error {
+	quotedSchema := p.dialect.QuoteIdentifier(p.dbSchema(dbSchema))
+	quotedIndex := p.dialect.QuoteIdentifier(indexName(tableName, columns, "_idx"))
+	concurrentlyClause := ""
+	if concurrently {
+		concurrentlyClause = "CONCURRENTLY "
+	}
+
+	statement := fmt.Sprintf(createIndexTemplate, concurrentlyClause, quotedIndex, quotedSchema, p.dialect.QuoteIdentifier(tableName), p.header(columns))
+	if _, err := p.execDataSource(p.ctx, statement); err != nil {
+		err = withTableNotFound(err, fmt.Errorf("Error creating %s table index on %v: %v", tableName, columns, err))
+		if concurrently && !IsTableNotFoundError(err) {
+			if _, dropErr := p.execDataSource(p.ctx, fmt.Sprintf(dropIndexConcurrentlyTemplate, quotedSchema, quotedIndex)); dropErr != nil {
+				return fmt.Errorf("%v. Error dropping invalid index %s: %v", err, quotedIndex, dropErr)
+			}
+		}
+		return err
 	}
+
+	return nil
 }
 
-func removeLastComma(str string) string {
-	if last := len(str) - 1; last >= 0 && str[last] == ',' {
-		str = str[:last]
+//Return upsert key columns of the table or nil if upsert isn't configured for it
+func (p *Postgres) upsertKey(tableName string) []string {
+	return p.config.UpsertKeys[strings.ToLower(tableName)]
+}
+
+//Return columns with unique index: table upsert key or dedup key (if configured)
+func (p *Postgres) uniqueKey(tableName string) []string {
+	if upsertKey := p.upsertKey(tableName); len(upsertKey) > 0 {
+		return upsertKey
+	}
+	if p.config.DedupKey != "" {
+		return []string{p.config.DedupKey}
 	}
 
-	return str
+	return nil
+}
+
+func (p *Postgres) uniqueIndexStatement(dbSchema, tableName string, uniqueKey []string) string {
+	return fmt.Sprintf(createUniqueIndexTemplate, p.dialect.QuoteIdentifier(indexName(tableName, uniqueKey, "_key")),
+		p.dialect.QuoteIdentifier(p.dbSchema(dbSchema)), p.dialect.QuoteIdentifier(tableName), p.header(uniqueKey))
+}
+
+//Return <table>_<columns><suffix> index name. Names longer than Postgres identifier limit are truncated with hash suffix:
+//otherwise indexes with the same long prefix would get the same name and IF NOT EXISTS would skip all of them except one
+func indexName(tableName string, columns []string, suffix string) string {
+	return schema.TableNamesConfig{Suffix: suffix, MaxLength: maxIdentifierLength}.TableName(tableName + "_" + strings.Join(columns, "_"))
+}
+
+//Return dbSchema or configured default schema if it is empty
+func (p *Postgres) dbSchema(dbSchema string) string {
+	if dbSchema == "" {
+		return p.config.Schema
+	}
+
+	return dbSchema
+}
+
+//Return ON CONFLICT DO UPDATE clause of all inserted not key columns if table upsert key is configured,
+//ON CONFLICT DO NOTHING clause if dedup key is configured (or all inserted columns are key ones) or ""
+func (p *Postgres) onConflictClause(tableName string, columns []string) string {
+	uniqueKey := p.uniqueKey(tableName)
+	if len(uniqueKey) == 0 {
+		return ""
+	}
+
+	isKey := map[string]bool{}
+	for _, column := range uniqueKey {
+		isKey[column] = true
+	}
+	var updates []string
+	if len(p.upsertKey(tableName)) > 0 {
+		for _, column := range columns {
+			if !isKey[column] {
+				quoted := p.dialect.QuoteIdentifier(column)
+				updates = append(updates, quoted+"=EXCLUDED."+quoted)
+			}
+		}
+	}
+	if len(updates) == 0 {
+		return fmt.Sprintf(onConflictDoNothingTemplate, p.header(uniqueKey))
+	}
+
+	return fmt.Sprintf(onConflictDoUpdateTemplate, p.header(uniqueKey), strings.Join(updates, ","))
+}
+
+//Return the last object of every key (in order of the last objects) and their indexes in objects
+//(nil if all objects are kept). Objects without key values are kept as is: NULL values don't conflict
+func lastByKey(objects []events.Fact, key []string) ([]events.Fact, []int) {
+	lastIndex := map[string]int{}
+	keys := make([]string, len(objects))
+	for i, object := range objects {
+		var values []interface{}
+		notNull := true
+		for _, column := range key {
+			value, ok := object[column]
+			if !ok || value == nil {
+				notNull = false
+				break
+			}
+			values = append(values, value)
+		}
+		if !notNull {
+			continue
+		}
+		if b, err := json.Marshal(values); err == nil {
+			keys[i] = string(b)
+			lastIndex[keys[i]] = i
+		}
+	}
+	if len(lastIndex) == len(objects) {
+		return objects, nil
+	}
+
+	result := make([]events.Fact, 0, len(objects))
+	indexes := make([]int, 0, len(objects))
+	for i, object := range objects {
+		if keys[i] == "" || lastIndex[keys[i]] == i {
+			result = append(result, object)
+			indexes = append(indexes, i)
+		}
+	}
+
+	return result, indexes
 }
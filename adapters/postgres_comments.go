@@ -0,0 +1,61 @@
+package adapters
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ksensehq/eventnative/schema"
+	"strings"
+	"text/template"
+)
+
+//ColumnCommentData is a data of column comment template (see DataSourceConfig.ColumnCommentTemplate)
+//e.g. 'source: {{.SourcePath}} type: {{.Type}}'
+type ColumnCommentData struct {
+	Table  string
+	Column string
+	//source JSON path of column values (e.g. /eventn_ctx/user_agent). Empty for generated columns
+	SourcePath string
+	//Postgres column type e.g. bigint or character varying(512)
+	Type string
+}
+
+//Return parsed column comment template or nil if it isn't configured
+func parseColumnCommentTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("column comment").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing column_comment_template: %v", err)
+	}
+
+	return tmpl, nil
+}
+
+//commentColumns set comments (see ColumnCommentData) on columns (sorted by name) in provided transaction without commit
+//Do nothing if column comment template isn't configured. Rollback transaction on error
+func (p *Postgres) commentColumns(wrappedTx *Transaction, dbSchema, tableName string, columns schema.Columns) error {
+	if p.columnComment == nil {
+		return nil
+	}
+
+	for _, columnName := range columns.SortedNames() {
+		column := columns[columnName]
+		var comment bytes.Buffer
+		data := ColumnCommentData{Table: tableName, Column: columnName, SourcePath: column.SourcePath, Type: p.dialect.ColumnType(column)}
+		if err := p.columnComment.Execute(&comment, data); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error executing column comment template for %s table '%s' column: %v", tableName, columnName, err)
+		}
+
+		statement := fmt.Sprintf(commentOnColumnTemplate, p.dialect.QuoteIdentifier(p.dbSchema(dbSchema)), p.dialect.QuoteIdentifier(tableName),
+			p.dialect.QuoteIdentifier(columnName), strings.ReplaceAll(comment.String(), "'", "''"))
+		if _, err := p.exec(wrappedTx, statement); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error commenting %s table '%s' column: %v", tableName, columnName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package adapters
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/lib/pq"
+)
+
+//copyInsert load objects with COPY FROM STDIN in provided transaction without commit
+//Transaction isn't rolled back on error (see BulkInsert)
+//COPY doesn't support ON CONFLICT so it can't be used with dedup key (see DataSourceConfig.Validate())
+//The whole COPY is one statement: statement timeout limits loading of all objects
+func (p *Postgres) copyInsert(wrappedTx *Transaction, table *schema.Table, columns []string, objects []events.Fact) error {
+	ctx, cancel := p.statementContext()
+	defer cancel()
+
+	copyStmt, err := p.prepare(ctx, wrappedTx, pq.CopyInSchema(p.dbSchema(table.Schema), table.Name, columns...))
+	if err != nil {
+		p.checkConnection(err)
+		return withTableNotFound(err, fmt.Errorf("Error preparing copy to table %s statement: %v", table.Name, err))
+	}
+
+	for _, object := range objects {
+		values := make([]interface{}, 0, len(columns))
+		for _, column := range columns {
+			values = append(values, object[column])
+		}
+		if _, err := copyStmt.ExecContext(ctx, values...); err != nil {
+			p.checkConnection(err)
+			copyStmt.Close()
+			return withTableNotFound(err, fmt.Errorf("Error copying to %s table with columns: %s values: %v: %v", table.Name, p.header(columns), values, err))
+		}
+	}
+
+	//empty Exec flushes buffered data
+	if _, err := copyStmt.ExecContext(ctx); err != nil {
+		p.checkConnection(err)
+		copyStmt.Close()
+		return withTableNotFound(err, fmt.Errorf("Error copying %d objects to %s table: %v", len(objects), table.Name, err))
+	}
+	if err := copyStmt.Close(); err != nil {
+		p.checkConnection(err)
+		return fmt.Errorf("Error closing copy to %s table statement: %v", table.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package adapters
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/ksensehq/eventnative/logging"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultReconnectBackoffBase = time.Second
+	defaultReconnectBackoffMax  = 30 * time.Second
+)
+
+//Return true if err means that database connection is lost (not a statement error):
+//bad connection, network errors and SQLSTATE class 08 (connection exception) or 57P01-57P03 (server shutdown)
+//Statement timeouts and cancellations aren't connection errors
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	//lib/pq and other drivers errors
+	var sqlStateErr interface{ SQLState() string }
+	if errors.As(err, &sqlStateErr) {
+		state := sqlStateErr.SQLState()
+		return strings.HasPrefix(state, "08") || state == "57P01" || state == "57P02" || state == "57P03"
+	}
+
+	return false
+}
+
+//Health return nil if connection is alive (see Ping) or error of the lost connection while reconnecting
+func (p *Postgres) Health() error {
+	p.connMutex.Lock()
+	connErr := p.connErr
+	p.connMutex.Unlock()
+	if connErr != nil {
+		return fmt.Errorf("Postgres connection is lost (reconnecting): %v", connErr)
+	}
+
+	return p.checkConnection(p.Ping())
+}
+
+//Return err as is. Mark connection as lost and start reconnecting in background if err is a connection error
+//Mark connection as restored if err is nil (e.g. the first successful insert after database is back)
+func (p *Postgres) checkConnection(err error) error {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+
+	if err == nil {
+		if p.connErr != nil {
+			p.logger().Info("Postgres connection has been restored")
+			p.connErr = nil
+		}
+		return nil
+	}
+	if !isConnectionError(err) {
+		return err
+	}
+
+	if p.connErr == nil {
+		p.logger().Warn("Postgres connection is lost. Reconnecting", "error", err)
+	}
+	p.connErr = err
+	if !p.reconnecting && !p.closed {
+		p.reconnecting = true
+		go p.reconnect()
+	}
+
+	return err
+}
+
+//Ping database with exponential backoff until connection is restored or adapter is closed
+func (p *Postgres) reconnect() {
+	base, max := p.reconnectBackoffBase, p.reconnectBackoffMax
+	if base <= 0 {
+		base = defaultReconnectBackoffBase
+	}
+	if max <= 0 {
+		max = defaultReconnectBackoffMax
+	}
+
+	delay := base
+	for {
+		select {
+		case <-p.ctx.Done():
+			p.stopReconnecting()
+			return
+		case <-time.After(delay):
+		}
+
+		p.connMutex.Lock()
+		restored, closed := p.connErr == nil, p.closed
+		p.connMutex.Unlock()
+		if restored || closed {
+			p.stopReconnecting()
+			return
+		}
+
+		err := p.Ping()
+		p.connMutex.Lock()
+		if err == nil {
+			if p.connErr != nil {
+				p.logger().Info("Postgres connection has been restored")
+			}
+			p.connErr = nil
+			p.reconnecting = false
+			p.connMutex.Unlock()
+			return
+		}
+		p.connErr = err
+		p.connMutex.Unlock()
+
+		delay *= 2
+		if delay > max {
+			delay = max
+		}
+	}
+}
+
+func (p *Postgres) stopReconnecting() {
+	p.connMutex.Lock()
+	p.reconnecting = false
+	p.connMutex.Unlock()
+}
+
+func (p *Postgres) logger() logging.Logger {
+	return logging.DefaultLogger().With("adapter", "postgres", "host", p.config.Host, "db", p.config.Db)
+}
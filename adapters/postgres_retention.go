@@ -0,0 +1,95 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	//ctid subquery limits count of rows (and duration of locks) per DELETE statement
+	deleteOlderBatchTemplate = `DELETE FROM %s.%s WHERE ctid IN (SELECT ctid FROM %s.%s WHERE %s::timestamptz < $1 LIMIT %d)`
+	partitionBoundsQuery     = `SELECT child.relname, pg_get_expr(child.relpartbound, child.oid) FROM pg_inherits
+						JOIN pg_class parent ON parent.oid = pg_inherits.inhparent
+						JOIN pg_class child ON child.oid = pg_inherits.inhrelid
+						JOIN pg_namespace ON pg_namespace.oid = parent.relnamespace
+						WHERE pg_namespace.nspname = $1 AND parent.relname = $2`
+	dropPartitionTemplate = `DROP TABLE IF EXISTS %s.%s`
+)
+
+//upper bound of range partition: FOR VALUES FROM ('2020-08-01') TO ('2020-09-01')
+var partitionUpperBound = regexp.MustCompile(`TO \('([0-9-]+)'\)`)
+
+//DeleteOlderThan delete rows with timestamp column value before provided time by batches of batchSize rows
+//Every batch is a separate statement (short locks). Return count of deleted rows. Stop when ctx is done
+//Table of the default db schema is used if dbSchema is empty
+func (p *Postgres) DeleteOlderThan(ctx context.Context, dbSchema, tableName, timestampColumn string, before time.Time, batchSize int) (int64, error) {
+	quotedSchema := p.dialect.QuoteIdentifier(p.dbSchema(dbSchema))
+	quotedTable := p.dialect.QuoteIdentifier(tableName)
+	statement := fmt.Sprintf(deleteOlderBatchTemplate, quotedSchema, quotedTable, quotedSchema, quotedTable,
+		p.dialect.QuoteIdentifier(timestampColumn), batchSize)
+
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		result, err := p.execDataSource(ctx, statement, before)
+		if err != nil {
+			return deleted, fmt.Errorf("Error deleting rows older than %s from %s table: %v", before.Format(time.RFC3339), tableName, err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("Error getting count of deleted rows from %s table: %v", tableName, err)
+		}
+		deleted += affected
+		if affected < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
+
+//PartitionsOlderThan return names of tableName partitions which upper bound isn't after provided time
+//(all partition rows are older than it)
+func (p *Postgres) PartitionsOlderThan(dbSchema, tableName string, before time.Time) ([]string, error) {
+	rows, err := p.dataSource.QueryContext(p.ctx, partitionBoundsQuery, p.dbSchema(dbSchema), tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying %s table partitions: %v", tableName, err)
+	}
+	defer rows.Close()
+
+	var partitions []string
+	for rows.Next() {
+		var name, bound string
+		if err := rows.Scan(&name, &bound); err != nil {
+			return nil, fmt.Errorf("Error scanning partition: %v", err)
+		}
+		match := partitionUpperBound.FindStringSubmatch(bound)
+		if match == nil {
+			continue
+		}
+		upper, err := time.Parse(partitionBoundLayout, match[1])
+		if err != nil {
+			continue
+		}
+		if !upper.After(before) {
+			partitions = append(partitions, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return partitions, nil
+}
+
+//DropPartition drop partition table of db schema (default one if empty)
+func (p *Postgres) DropPartition(dbSchema, partitionName string) error {
+	if _, err := p.execDataSource(p.ctx, fmt.Sprintf(dropPartitionTemplate, p.dialect.QuoteIdentifier(p.dbSchema(dbSchema)), p.dialect.QuoteIdentifier(partitionName))); err != nil {
+		return fmt.Errorf("Error dropping partition %s: %v", partitionName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package adapters
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+)
+
+const (
+	savepointName                = "eventnative_batch"
+	createSavepointStatement     = `SAVEPOINT ` + savepointName
+	releaseSavepointStatement    = `RELEASE SAVEPOINT ` + savepointName
+	rollbackToSavepointStatement = `ROLLBACK TO SAVEPOINT ` + savepointName
+)
+
+//PartialInsertError is BulkInsert error when only some objects haven't been inserted (see DataSourceConfig.SavepointRows)
+//or insert error of storage which has rejected some objects (see DataSourceConfig.StrictSchema). Other objects have been committed
+type PartialInsertError struct {
+	//indexes of not inserted objects in BulkInsert objects
+	Failed []int
+	//count of objects of the insert
+	Total int
+	//the first row error
+	Err error
+	//indexes of objects which mustn't be retried (e.g. they don't match table schema in strict mode). They aren't in Failed
+	Rejected []int
+	//reason of rejection. Is set if Rejected isn't empty
+	RejectErr error
+}
+
+func (pie *PartialInsertError) Error() string {
+	if len(pie.Failed) == 0 {
+		return fmt.Sprintf("%d of %d objects have been rejected: %v", len(pie.Rejected), pie.Total, pie.RejectErr)
+	}
+	message := fmt.Sprintf("%d of %d objects haven't been inserted: %v", len(pie.Failed), pie.Total, pie.Err)
+	if len(pie.Rejected) > 0 {
+		message += fmt.Sprintf(". %d objects have been rejected: %v", len(pie.Rejected), pie.RejectErr)
+	}
+
+	return message
+}
+
+//savepointInsert insert objects with insert func in chunks of savepoint_rows objects in provided transaction without commit
+//Every chunk is inserted after SAVEPOINT. Failed chunk is rolled back to it and inserted row by row (with savepoint per row)
+//so only bad rows aren't inserted. Return indexes of not inserted objects with the first row error or error which fails
+//the whole transaction: savepoint statements errors (e.g. lost connection) and missing table
+//Transaction isn't rolled back on error (see BulkInsert)
+func (p *Postgres) savepointInsert(wrappedTx *Transaction, objects []events.Fact, insert func([]events.Fact) error) (failed []int, rowErr, err error) {
+	for start := 0; start < len(objects); start += p.config.SavepointRows {
+		end := start + p.config.SavepointRows
+		if end > len(objects) {
+			end = len(objects)
+		}
+
+		chunkErr, err := p.insertWithSavepoint(wrappedTx, objects[start:end], insert)
+		if err != nil {
+			return nil, nil, err
+		}
+		if chunkErr == nil {
+			continue
+		}
+		if end-start == 1 {
+			failed = append(failed, start)
+			if rowErr == nil {
+				rowErr = chunkErr
+			}
+			continue
+		}
+
+		for i := start; i < end; i++ {
+			objectErr, err := p.insertWithSavepoint(wrappedTx, objects[i:i+1], insert)
+			if err != nil {
+				return nil, nil, err
+			}
+			if objectErr != nil {
+				failed = append(failed, i)
+				if rowErr == nil {
+					rowErr = objectErr
+				}
+			}
+		}
+	}
+
+	return failed, rowErr, nil
+}
+
+//Insert objects after SAVEPOINT and release it. Roll back to the savepoint if objects haven't been inserted
+//Return insert error of objects or error which fails the whole transaction
+func (p *Postgres) insertWithSavepoint(wrappedTx *Transaction, objects []events.Fact, insert func([]events.Fact) error) (insertErr, err error) {
+	if _, err := p.exec(wrappedTx, createSavepointStatement); err != nil {
+		p.checkConnection(err)
+		return nil, fmt.Errorf("Error creating savepoint: %v", err)
+	}
+
+	insertErr = insert(objects)
+	if insertErr != nil {
+		if IsTableNotFoundError(insertErr) {
+			//all rows will fail
+			return nil, insertErr
+		}
+		if _, err := p.exec(wrappedTx, rollbackToSavepointStatement); err != nil {
+			p.checkConnection(err)
+			return nil, fmt.Errorf("Error rolling back to savepoint after insert error [%v]: %v", insertErr, err)
+		}
+	}
+	if _, err := p.exec(wrappedTx, releaseSavepointStatement); err != nil {
+		p.checkConnection(err)
+		return nil, fmt.Errorf("Error releasing savepoint: %v", err)
+	}
+
+	return insertErr, nil
+}
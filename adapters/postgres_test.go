@@ -0,0 +1,806 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//countingDriver is a fake sql driver (and connector) which counts simultaneously opened connections
+type countingDriver struct {
+	opened    int64
+	maxOpened int64
+}
+
+func (d *countingDriver) Connect(ctx context.Context) (driver.Conn, error) { return d.Open("") }
+func (d *countingDriver) Driver() driver.Driver                            { return d }
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	opened := atomic.AddInt64(&d.opened, 1)
+	for {
+		maxOpened := atomic.LoadInt64(&d.maxOpened)
+		if opened <= maxOpened || atomic.CompareAndSwapInt64(&d.maxOpened, maxOpened, opened) {
+			break
+		}
+	}
+	return &countingConn{driver: d}, nil
+}
+
+type countingConn struct {
+	driver *countingDriver
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) { return &slowStmt{}, nil }
+func (c *countingConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c *countingConn) Commit() error                             { return nil }
+func (c *countingConn) Rollback() error                           { return nil }
+func (c *countingConn) Close() error {
+	atomic.AddInt64(&c.driver.opened, -1)
+	return nil
+}
+
+//slowStmt keeps connection busy for a while
+type slowStmt struct{}
+
+func (s *slowStmt) Close() error  { return nil }
+func (s *slowStmt) NumInput() int { return -1 }
+func (s *slowStmt) Exec(args []driver.Value) (driver.Result, error) {
+	time.Sleep(5 * time.Millisecond)
+	return driver.RowsAffected(1), nil
+}
+func (s *slowStmt) Query(args []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func TestConcurrentInsertsPoolLimit(t *testing.T) {
+	countingDrv := &countingDriver{}
+	dataSource := sql.OpenDB(countingDrv)
+	config := &DataSourceConfig{Schema: "public", MaxOpenConns: 3, MaxIdleConns: 1}
+	configurePool(dataSource, config)
+
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), dataSource, PostgresDialect{}, "Postgres"), config: config}
+	defer p.Close()
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				require.NoError(t, p.Insert(table, map[string]interface{}{"field1": "value"}))
+			} else {
+				require.NoError(t, p.BulkInsert(table, []events.Fact{{"field1": "value1"}, {"field1": "value2"}}))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	maxOpened := atomic.LoadInt64(&countingDrv.maxOpened)
+	require.True(t, maxOpened <= int64(config.MaxOpenConns), "Opened connections %d exceed max %d", maxOpened, config.MaxOpenConns)
+	require.True(t, maxOpened > 1, "Inserts must use several pooled connections")
+	require.Equal(t, config.MaxOpenConns, dataSource.Stats().MaxOpenConnections)
+}
+
+func TestSSLParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *DataSourceConfig
+		expected string
+	}{
+		{
+			"Not configured",
+			&DataSourceConfig{},
+			"",
+		},
+		{
+			"Mode only",
+			&DataSourceConfig{SSLMode: "require"},
+			" sslmode='require'",
+		},
+		{
+			"Verify full with certificates",
+			&DataSourceConfig{SSLMode: "verify-full", SSLRootCert: "/certs/root.crt", SSLCert: "/certs/client.crt", SSLKey: "/certs/client.key"},
+			" sslmode='verify-full' sslrootcert='/certs/root.crt' sslcert='/certs/client.crt' sslkey='/certs/client.key'",
+		},
+		{
+			"Paths with spaces, quotes and backslashes",
+			&DataSourceConfig{SSLMode: "verify-ca", SSLRootCert: "/my certs/root.crt", SSLCert: "/certs/o'brien.crt", SSLKey: `C:\certs\client.key`},
+			` sslmode='verify-ca' sslrootcert='/my certs/root.crt' sslcert='/certs/o\'brien.crt' sslkey='C:\\certs\\client.key'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, sslParameters(tt.config))
+		})
+	}
+}
+
+//Connection to a server which requires client certificates. Skipped if server isn't configured with env variables:
+//PG_TLS_TEST_HOST, PG_TLS_TEST_PORT (default 5432), PG_TLS_TEST_DB, PG_TLS_TEST_USER, PG_TLS_TEST_PASSWORD,
+//PG_TLS_TEST_ROOT_CERT, PG_TLS_TEST_CERT, PG_TLS_TEST_KEY
+func TestPostgresTLSConnection(t *testing.T) {
+	host := os.Getenv("PG_TLS_TEST_HOST")
+	if host == "" {
+		t.Skip("PG_TLS_TEST_HOST isn't set: Postgres TLS connection test is skipped")
+	}
+	port := 5432
+	if portValue := os.Getenv("PG_TLS_TEST_PORT"); portValue != "" {
+		var err error
+		port, err = strconv.Atoi(portValue)
+		require.NoError(t, err)
+	}
+
+	//certificates are copied to a dir with space and quote in the name: paths must be escaped in connection string
+	dir, err := ioutil.TempDir("", "pg tls'test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	copyCert := func(envName, fileName string) string {
+		b, err := ioutil.ReadFile(os.Getenv(envName))
+		require.NoError(t, err, envName)
+		path := filepath.Join(dir, fileName)
+		require.NoError(t, ioutil.WriteFile(path, b, 0600))
+		return path
+	}
+
+	config := &DataSourceConfig{
+		Host:        host,
+		Port:        port,
+		Db:          os.Getenv("PG_TLS_TEST_DB"),
+		Username:    os.Getenv("PG_TLS_TEST_USER"),
+		Password:    os.Getenv("PG_TLS_TEST_PASSWORD"),
+		Schema:      "public",
+		SSLMode:     "verify-full",
+		SSLRootCert: copyCert("PG_TLS_TEST_ROOT_CERT", "root.crt"),
+		SSLCert:     copyCert("PG_TLS_TEST_CERT", "client.crt"),
+		SSLKey:      copyCert("PG_TLS_TEST_KEY", "client.key"),
+	}
+	require.NoError(t, config.Validate())
+
+	p, err := NewPostgres(context.Background(), config)
+	require.NoError(t, err)
+	defer p.Close()
+
+	var ssl bool
+	require.NoError(t, p.dataSource.QueryRow("SELECT ssl FROM pg_stat_ssl WHERE pid = pg_backend_pid()").Scan(&ssl))
+	require.True(t, ssl, "Connection must use TLS")
+
+	//connection without client certificate is rejected by the server
+	withoutCert := *config
+	withoutCert.SSLCert, withoutCert.SSLKey = "", ""
+	_, err = NewPostgres(context.Background(), &withoutCert)
+	require.Error(t, err, "Server must require client certificate")
+}
+
+func TestPostgresDialect(t *testing.T) {
+	dialect := PostgresDialect{}
+	table := &schema.Table{Name: "events", Columns: schema.Columns{
+		"user":     schema.Column{Type: schema.STRING},
+		"count":    schema.Column{Type: schema.INT64, SqlType: "bigint"},
+		"products": schema.Column{Type: schema.STRING, SqlType: "jsonb"},
+	}}
+
+	require.Equal(t, `"odd""name"`, dialect.QuoteIdentifier(`odd"name`))
+	require.Equal(t, "timestamp with time zone", dialect.ColumnType(schema.Column{Type: schema.TIMESTAMP}))
+	require.Equal(t, `CREATE TABLE "public"."events" ("count" bigint,"products" jsonb,"user" character varying(512))`, dialect.CreateTableDDL("public", table))
+	require.Equal(t, `ALTER TABLE "public"."events" ADD COLUMN "order" character varying(512)`,
+		dialect.AlterAddColumnDDL("public", "events", "order", schema.Column{Type: schema.STRING}))
+	//case sensitive column names are kept as is
+	require.Equal(t, `ALTER TABLE "public"."events" ADD COLUMN "userId" character varying(512)`,
+		dialect.AlterAddColumnDDL("public", "events", "userId", schema.Column{Type: schema.STRING}))
+}
+
+//db schema and table names come from event data (table name template) so they are quoted
+func TestInsertQuotesIdentifiers(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"), config: &DataSourceConfig{Schema: "public"}}
+	defer p.Close()
+
+	require.NoError(t, p.CreateDbSchema(`my"schema`))
+	table := &schema.Table{Schema: `my"schema`, Name: `events"; DROP TABLE users; --`, Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}}}
+	require.NoError(t, p.BulkInsert(table, []events.Fact{{"field1": "1"}}))
+	require.NoError(t, p.Insert(table, events.Fact{"field1": "1"}))
+	require.Equal(t, []string{
+		`CREATE SCHEMA IF NOT EXISTS "my""schema"`,
+		`INSERT INTO "my""schema"."events""; DROP TABLE users; --" ("field1") VALUES ($1)`,
+		`INSERT INTO "my""schema"."events""; DROP TABLE users; --" ("field1") VALUES ($1)`,
+	}, recordingDrv.queries)
+}
+
+func TestCreatePartitionedTableQuotesColumn(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"), config: &DataSourceConfig{Schema: "public"}}
+	defer p.Close()
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{`odd"timestamp`: schema.Column{Type: schema.TIMESTAMP}}}
+	require.NoError(t, p.CreatePartitionedTable(table, `odd"timestamp`))
+	require.Equal(t, []string{`CREATE TABLE "public"."events" ("odd""timestamp" timestamp with time zone) PARTITION BY RANGE ("odd""timestamp")`}, recordingDrv.queries)
+}
+
+func TestWidenColumnQuotedName(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public"}}
+	defer p.Close()
+
+	//column name is a key of event json
+	column := `price"; DROP TABLE "events`
+	require.NoError(t, p.PatchTableSchema(&schema.Table{Name: "events", Columns: schema.Columns{column + "_2": schema.Column{Type: schema.FLOAT64}},
+		WidenedColumns: schema.Columns{column: schema.Column{Type: schema.FLOAT64}}}))
+	require.Equal(t, []string{
+		`ALTER TABLE "public"."events" ADD COLUMN "price""; DROP TABLE ""events_2" double precision`,
+		`ALTER TABLE "public"."events" ALTER COLUMN "price""; DROP TABLE ""events" TYPE double precision USING "price""; DROP TABLE ""events"::double precision`,
+	}, recordingDrv.queries)
+}
+
+//recordingDriver is a fake sql driver (and connector) which records prepared statements, count of executions and their arguments
+type recordingDriver struct {
+	mutex   sync.Mutex
+	queries []string
+	execs   int
+	args    [][]driver.Value
+}
+
+func (d *recordingDriver) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *recordingDriver) Driver() driver.Driver                            { return d }
+func (d *recordingDriver) Open(name string) (driver.Conn, error)            { return d, nil }
+func (d *recordingDriver) Begin() (driver.Tx, error)                        { return d, nil }
+func (d *recordingDriver) Commit() error                                    { return nil }
+func (d *recordingDriver) Rollback() error                                  { return nil }
+func (d *recordingDriver) Close() error                                     { return nil }
+func (d *recordingDriver) Prepare(query string) (driver.Stmt, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.queries = append(d.queries, query)
+	return &recordingStmt{driver: d}, nil
+}
+
+type recordingStmt struct {
+	driver *recordingDriver
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mutex.Lock()
+	defer s.driver.mutex.Unlock()
+	s.driver.execs++
+	s.driver.args = append(s.driver.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func TestBulkInsertModes(t *testing.T) {
+	tests := []struct {
+		name            string
+		insertMode      string
+		expectedQueries []string
+		expectedExecs   int
+	}{
+		{
+			"Batch by default",
+			"",
+			[]string{`INSERT INTO "public"."events" ("field1","field2") VALUES ($1,$2),($3,$4),($5,$6)`},
+			1,
+		},
+		{
+			"Streaming",
+			StreamingInsertMode,
+			[]string{`INSERT INTO "public"."events" ("field1","field2") VALUES ($1,$2)`},
+			3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordingDrv := &recordingDriver{}
+			config := &DataSourceConfig{Schema: "public", InsertMode: tt.insertMode}
+			p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"), config: config}
+			defer p.Close()
+
+			table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}, "field2": schema.Column{Type: schema.STRING}}}
+			require.NoError(t, p.BulkInsert(table, []events.Fact{{"field1": "1"}, {"field1": "2", "field2": "2"}, {"field2": "3"}}))
+			require.Equal(t, tt.expectedQueries, recordingDrv.queries)
+			require.Equal(t, tt.expectedExecs, recordingDrv.execs)
+		})
+	}
+}
+
+func TestDataSourceConfigInsertMode(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		dedupKey  string
+		expectErr bool
+	}{
+		{"Default", "", "eventn_ctx_event_id", false},
+		{"Streaming with dedup key", StreamingInsertMode, "eventn_ctx_event_id", false},
+		{"Copy", CopyInsertMode, "", false},
+		{"Copy with dedup key", CopyInsertMode, "eventn_ctx_event_id", true},
+		{"Unknown", "upsert", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &DataSourceConfig{Host: "localhost", Db: "db", Username: "user", InsertMode: tt.mode, DedupKey: tt.dedupKey}
+			err := config.Validate()
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"), config: &DataSourceConfig{Schema: "public"}}
+	defer p.Close()
+
+	deleted, err := p.DeleteOlderThan(context.Background(), "", "events", "_timestamp", time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC), 100)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+	require.Equal(t, []string{`DELETE FROM "public"."events" WHERE ctid IN (SELECT ctid FROM "public"."events" WHERE "_timestamp"::timestamptz < $1 LIMIT 100)`}, recordingDrv.queries)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.DeleteOlderThan(ctx, "", "events", "_timestamp", time.Now(), 100)
+	require.Error(t, err)
+	require.Equal(t, 1, recordingDrv.execs)
+
+	//identifiers are quoted
+	recordingDrv.queries = nil
+	_, err = p.DeleteOlderThan(context.Background(), `my"schema`, `odd"events`, `odd"timestamp`, time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC), 100)
+	require.NoError(t, err)
+	require.NoError(t, p.DropPartition(`my"schema`, `odd"events_p202007`))
+	require.Equal(t, []string{
+		`DELETE FROM "my""schema"."odd""events" WHERE ctid IN (SELECT ctid FROM "my""schema"."odd""events" WHERE "odd""timestamp"::timestamptz < $1 LIMIT 100)`,
+		`DROP TABLE IF EXISTS "my""schema"."odd""events_p202007"`,
+	}, recordingDrv.queries)
+}
+
+func TestPartitionUpperBound(t *testing.T) {
+	tests := []struct {
+		name     string
+		bound    string
+		expected string
+	}{
+		{"Range", "FOR VALUES FROM ('2020-08-01') TO ('2020-09-01')", "2020-09-01"},
+		{"Default partition", "DEFAULT", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual string
+			if match := partitionUpperBound.FindStringSubmatch(tt.bound); match != nil {
+				actual = match[1]
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestBulkInsertUpsert(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	config := &DataSourceConfig{Schema: "public", UpsertKeys: map[string][]string{"users": {"user_id"}}}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"), config: config}
+	defer p.Close()
+
+	table := &schema.Table{Name: "users", Columns: schema.Columns{"user_id": schema.Column{Type: schema.STRING}, "email": schema.Column{Type: schema.STRING}}}
+	objects := []events.Fact{{"user_id": "1", "email": "old@a.com"}, {"user_id": "2", "email": "b@a.com"}, {"email": "anonymous@a.com"}, {"user_id": "1", "email": "new@a.com"}}
+	require.NoError(t, p.BulkInsert(table, objects))
+	require.Equal(t, []string{`INSERT INTO "public"."users" ("email","user_id") VALUES ($1,$2),($3,$4),($5,$6) ON CONFLICT ("user_id") DO UPDATE SET "email"=EXCLUDED."email"`}, recordingDrv.queries)
+
+	kept, indexes := lastByKey(objects, []string{"user_id"})
+	require.Equal(t, []events.Fact{objects[1], objects[2], objects[3]}, kept, "The last object of every key must be kept")
+	require.Equal(t, []int{1, 2, 3}, indexes)
+	require.Equal(t, ` ON CONFLICT ("user_id") DO NOTHING`, p.onConflictClause("users", []string{"user_id"}))
+	require.Equal(t, "", p.onConflictClause("events", []string{"user_id"}))
+
+	p.config = &DataSourceConfig{Schema: "public", DedupKey: "eventn_ctx_event_id"}
+	require.Equal(t, ` ON CONFLICT ("eventn_ctx_event_id") DO NOTHING`, p.onConflictClause("events", []string{"eventn_ctx_event_id", "field1"}))
+}
+
+//badRowDriver is a fake sql driver (and connector) which statements fail if one of values is "bad". Records executed statements
+type badRowDriver struct {
+	mutex      sync.Mutex
+	statements []string
+}
+
+func (d *badRowDriver) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *badRowDriver) Driver() driver.Driver                            { return d }
+func (d *badRowDriver) Open(name string) (driver.Conn, error)            { return d, nil }
+func (d *badRowDriver) Begin() (driver.Tx, error)                        { return d, nil }
+func (d *badRowDriver) Commit() error                                    { return d.record("COMMIT") }
+func (d *badRowDriver) Rollback() error                                  { return d.record("ROLLBACK") }
+func (d *badRowDriver) Close() error                                     { return nil }
+func (d *badRowDriver) Prepare(query string) (driver.Stmt, error) {
+	return &badRowStmt{driver: d, query: query}, nil
+}
+func (d *badRowDriver) record(statement string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.statements = append(d.statements, statement)
+	return nil
+}
+
+type badRowStmt struct {
+	driver *badRowDriver
+	query  string
+}
+
+func (s *badRowStmt) Close() error  { return nil }
+func (s *badRowStmt) NumInput() int { return -1 }
+func (s *badRowStmt) Exec(args []driver.Value) (driver.Result, error) {
+	for _, arg := range args {
+		if arg == "bad" {
+			return nil, errors.New("pq: invalid input syntax")
+		}
+	}
+	s.driver.record(s.query)
+	return driver.RowsAffected(1), nil
+}
+func (s *badRowStmt) Query(args []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func TestBulkInsertSavepoints(t *testing.T) {
+	badRowDrv := &badRowDriver{}
+	config := &DataSourceConfig{Schema: "public", SavepointRows: 2, UpsertKeys: map[string][]string{"users": {"user_id"}}}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(badRowDrv), PostgresDialect{}, "Postgres"), config: config}
+	defer p.Close()
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}}}
+	objects := []events.Fact{{"field1": "1"}, {"field1": "bad"}, {"field1": "3"}, {"field1": "4"}, {"field1": "5"}}
+	err := p.BulkInsert(table, objects)
+	partialErr, ok := err.(*PartialInsertError)
+	require.True(t, ok, "Partial error is expected: %v", err)
+	require.Equal(t, []int{1}, partialErr.Failed)
+	require.Equal(t, 5, partialErr.Total)
+
+	multiRow := `INSERT INTO "public"."events" ("field1") VALUES ($1),($2)`
+	row := `INSERT INTO "public"."events" ("field1") VALUES ($1)`
+	require.Equal(t, []string{
+		//the first chunk fails and is retried row by row
+		createSavepointStatement, rollbackToSavepointStatement, releaseSavepointStatement,
+		createSavepointStatement, row, releaseSavepointStatement,
+		createSavepointStatement, rollbackToSavepointStatement, releaseSavepointStatement,
+		createSavepointStatement, multiRow, releaseSavepointStatement,
+		createSavepointStatement, row, releaseSavepointStatement,
+		"COMMIT",
+	}, badRowDrv.statements)
+
+	//failed indexes are indexes of provided objects (before upsert key deduplication)
+	users := &schema.Table{Name: "users", Columns: schema.Columns{"user_id": schema.Column{Type: schema.STRING}, "email": schema.Column{Type: schema.STRING}}}
+	err = p.BulkInsert(users, []events.Fact{{"user_id": "1", "email": "old"}, {"user_id": "2", "email": "a"}, {"user_id": "1", "email": "bad"}})
+	partialErr, ok = err.(*PartialInsertError)
+	require.True(t, ok, "Partial error is expected: %v", err)
+	require.Equal(t, []int{2}, partialErr.Failed)
+
+	require.NoError(t, p.BulkInsert(table, []events.Fact{{"field1": "1"}}))
+	require.Error(t, (&DataSourceConfig{Host: "host", Db: "db", Username: "user", InsertMode: CopyInsertMode, SavepointRows: 100}).Validate())
+	require.Error(t, (&DataSourceConfig{Host: "host", Db: "db", Username: "user", SavepointRows: -1}).Validate())
+}
+
+//flakyDriver is a fake sql driver which connections fail with driver.ErrBadConn while it is down
+type flakyDriver struct {
+	down int32
+}
+
+func (d *flakyDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	if atomic.LoadInt32(&d.down) == 1 {
+		return nil, driver.ErrBadConn
+	}
+	return &flakyConn{driver: d}, nil
+}
+func (d *flakyDriver) Driver() driver.Driver { return d }
+func (d *flakyDriver) Open(name string) (driver.Conn, error) {
+	return d.Connect(context.Background())
+}
+
+type flakyConn struct {
+	driver *flakyDriver
+}
+
+func (c *flakyConn) alive() error {
+	if atomic.LoadInt32(&c.driver.down) == 1 {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+func (c *flakyConn) Ping(ctx context.Context) error { return c.alive() }
+func (c *flakyConn) Begin() (driver.Tx, error)      { return c, c.alive() }
+func (c *flakyConn) Commit() error                  { return c.alive() }
+func (c *flakyConn) Rollback() error                { return nil }
+func (c *flakyConn) Close() error                   { return nil }
+func (c *flakyConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{driver: &recordingDriver{}}, c.alive()
+}
+
+func TestReconnect(t *testing.T) {
+	flakyDrv := &flakyDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(flakyDrv), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public"}, reconnectBackoffBase: 10 * time.Millisecond, reconnectBackoffMax: 20 * time.Millisecond}
+	defer p.Close()
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}}}
+	objects := []events.Fact{{"field1": "value1"}}
+	require.NoError(t, p.BulkInsert(table, objects))
+	require.NoError(t, p.Health())
+
+	//connection drop
+	atomic.StoreInt32(&flakyDrv.down, 1)
+	require.Error(t, p.BulkInsert(table, objects))
+	require.Error(t, p.Health(), "Adapter must be unhealthy after connection error")
+	time.Sleep(50 * time.Millisecond)
+	require.Error(t, p.Health(), "Adapter must be unhealthy while database is down")
+
+	//database is back: reconnected in background
+	atomic.StoreInt32(&flakyDrv.down, 0)
+	require.Eventually(t, func() bool { return p.Health() == nil }, time.Second, 10*time.Millisecond)
+	require.NoError(t, p.BulkInsert(table, objects))
+
+	require.False(t, isConnectionError(errors.New("syntax error")))
+	require.True(t, isConnectionError(fmt.Errorf("Error: %w", driver.ErrBadConn)))
+}
+
+//hangingDriver is a fake sql driver (and connector) which statements hang until statement context is done
+type hangingDriver struct{}
+
+func (d *hangingDriver) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *hangingDriver) Driver() driver.Driver                            { return d }
+func (d *hangingDriver) Open(name string) (driver.Conn, error)            { return d, nil }
+func (d *hangingDriver) Ping(ctx context.Context) error                   { return nil }
+func (d *hangingDriver) Begin() (driver.Tx, error)                        { return d, nil }
+func (d *hangingDriver) Commit() error                                    { return nil }
+func (d *hangingDriver) Rollback() error                                  { return nil }
+func (d *hangingDriver) Close() error                                     { return nil }
+func (d *hangingDriver) Prepare(query string) (driver.Stmt, error)        { return &hangingStmt{}, nil }
+
+type hangingStmt struct{}
+
+func (s *hangingStmt) Close() error  { return nil }
+func (s *hangingStmt) NumInput() int { return -1 }
+func (s *hangingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("ExecContext must be used")
+}
+func (s *hangingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+func (s *hangingStmt) Query(args []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func TestStatementTimeout(t *testing.T) {
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(&hangingDriver{}), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public"}}
+	p.statementTimeout = 20 * time.Millisecond
+	defer p.Close()
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}},
+		WidenedColumns: schema.Columns{}}
+	tests := []struct {
+		name   string
+		insert func() error
+	}{
+		{"Insert", func() error { return p.Insert(table, map[string]interface{}{"field1": "value1"}) }},
+		{"BulkInsert", func() error { return p.BulkInsert(table, []events.Fact{{"field1": "value1"}}) }},
+		{"CreateTable", func() error { return p.CreateTable(table) }},
+		{"PatchTableSchema", func() error { return p.PatchTableSchema(table) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan error, 1)
+			go func() { done <- tt.insert() }()
+			select {
+			case err := <-done:
+				require.Error(t, err)
+			case <-time.After(time.Second):
+				require.Fail(t, "Statement must be canceled on timeout")
+			}
+		})
+	}
+
+	require.NoError(t, p.Health(), "Statement timeout isn't a connection error")
+	require.Error(t, (&DataSourceConfig{Host: "host", Db: "db", Username: "user", StatementTimeoutMs: -1}).Validate())
+}
+
+//sqlStateError is a fake driver error with SQLSTATE code (like lib/pq errors)
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pq: error with code " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+//missingTableDriver is a fake sql driver (and connector) which statements fail with provided SQLSTATE error
+type missingTableDriver struct {
+	err sqlStateError
+}
+
+func (d *missingTableDriver) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *missingTableDriver) Driver() driver.Driver                            { return d }
+func (d *missingTableDriver) Open(name string) (driver.Conn, error)            { return d, nil }
+func (d *missingTableDriver) Begin() (driver.Tx, error)                        { return d, nil }
+func (d *missingTableDriver) Commit() error                                    { return nil }
+func (d *missingTableDriver) Rollback() error                                  { return nil }
+func (d *missingTableDriver) Close() error                                     { return nil }
+func (d *missingTableDriver) Prepare(query string) (driver.Stmt, error)        { return nil, d.err }
+
+func TestTableNotFoundError(t *testing.T) {
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}}}
+	tests := []struct {
+		name         string
+		state        sqlStateError
+		insertMode   string
+		expectedFlag bool
+	}{
+		{"Undefined table batch", "42P01", BatchInsertMode, true},
+		{"Undefined table streaming", "42P01", StreamingInsertMode, true},
+		{"Invalid schema name", "3F000", BatchInsertMode, true},
+		{"Syntax error", "42601", BatchInsertMode, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(&missingTableDriver{err: tt.state}), PostgresDialect{}, "Postgres"),
+				config: &DataSourceConfig{Schema: "public", InsertMode: tt.insertMode}}
+			defer p.Close()
+
+			err := p.BulkInsert(table, []events.Fact{{"field1": "value1"}})
+			require.Error(t, err)
+			require.Equal(t, tt.expectedFlag, IsTableNotFoundError(err))
+			require.True(t, strings.Contains(err.Error(), "events"), "Error message must be kept")
+
+			err = p.Insert(table, map[string]interface{}{"field1": "value1"})
+			require.Error(t, err)
+			require.Equal(t, tt.expectedFlag, IsTableNotFoundError(err))
+		})
+	}
+
+	require.False(t, IsTableNotFoundError(errors.New("relation events does not exist")))
+	require.True(t, IsTableNotFoundError(fmt.Errorf("Error: %w", &TableNotFoundError{err: errors.New("missing")})))
+}
+
+func TestColumnComments(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	columnComment, err := parseColumnCommentTemplate("source: {{.SourcePath}} type: {{.Type}}")
+	require.NoError(t, err)
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public"}, columnComment: columnComment}
+	defer p.Close()
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{
+		"user_name": schema.Column{Type: schema.STRING, SourcePath: "/user/name'"},
+		"count":     schema.Column{Type: schema.INT64, SourcePath: "/count"}}}
+	require.NoError(t, p.CreateTable(table))
+	require.Equal(t, []string{
+		`CREATE TABLE "public"."events" ("count" bigint,"user_name" character varying(512))`,
+		`COMMENT ON COLUMN "public"."events"."count" IS 'source: /count type: bigint'`,
+		`COMMENT ON COLUMN "public"."events"."user_name" IS 'source: /user/name'' type: character varying(512)'`,
+	}, recordingDrv.queries)
+
+	_, err = parseColumnCommentTemplate("{{.SourcePath")
+	require.Error(t, err)
+}
+
+func TestDbSchemaQualifiedStatements(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public", DedupKey: "eventn_ctx_event_id"}}
+	defer p.Close()
+
+	require.NoError(t, p.CreateTable(&schema.Table{Name: "events", Schema: "acme", Columns: schema.Columns{"count": schema.Column{Type: schema.INT64}}}))
+	require.NoError(t, p.PatchTableSchema(&schema.Table{Name: "events", Columns: schema.Columns{"name": schema.Column{Type: schema.STRING}},
+		WidenedColumns: schema.Columns{}}))
+	require.Equal(t, []string{
+		`CREATE TABLE "acme"."events" ("count" bigint,"eventn_ctx_event_id" character varying(512))`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS "events_eventn_ctx_event_id_key" ON "acme"."events" ("eventn_ctx_event_id")`,
+		`ALTER TABLE "public"."events" ADD COLUMN "name" character varying(512)`,
+	}, recordingDrv.queries)
+
+	for name, expected := range map[string]bool{"acme": true, "tenant_42": true, "_x": true, "42": false, "Acme": false,
+		"pg_catalog": false, "a-b": false, "": false, strings.Repeat("a", 64): false} {
+		require.Equal(t, expected, IsValidDbSchemaName(name), name)
+	}
+}
+
+//failingIndexDriver is a fake sql driver (and connector) which CREATE INDEX statements fail. Records all statements
+type failingIndexDriver struct {
+	recordingDriver
+}
+
+func (d *failingIndexDriver) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *failingIndexDriver) Driver() driver.Driver                            { return d }
+func (d *failingIndexDriver) Open(name string) (driver.Conn, error)            { return d, nil }
+func (d *failingIndexDriver) Prepare(query string) (driver.Stmt, error) {
+	d.recordingDriver.Prepare(query)
+	if strings.HasPrefix(query, "CREATE INDEX") {
+		return nil, errors.New("pq: deadlock detected")
+	}
+	return &recordingStmt{driver: &d.recordingDriver}, nil
+}
+
+func TestCreateIndex(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	config := &DataSourceConfig{Schema: "public", Indexes: map[string][][]string{"events": {{"user_id"}, {"user_id", "_timestamp"}}}}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"), config: config}
+	defer p.Close()
+
+	require.Equal(t, [][]string{{"user_id"}, {"user_id", "_timestamp"}}, p.Indexes("Events"))
+	require.Nil(t, p.Indexes("users"))
+	require.NoError(t, p.CreateIndex("", "events", []string{"user_id", "_timestamp"}, true))
+	require.NoError(t, p.CreateIndex("tenant1", "events", []string{"user_id"}, false))
+	require.Equal(t, []string{
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "events_user_id__timestamp_idx" ON "public"."events" ("user_id","_timestamp")`,
+		`CREATE INDEX IF NOT EXISTS "events_user_id_idx" ON "tenant1"."events" ("user_id")`,
+	}, recordingDrv.queries)
+
+	//invalid index of failed concurrent creation is dropped
+	failingDrv := &failingIndexDriver{}
+	p = &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(failingDrv), PostgresDialect{}, "Postgres"), config: config}
+	defer p.Close()
+	require.Error(t, p.CreateIndex("", "events", []string{"user_id"}, true))
+	require.Error(t, p.CreateIndex("", "events", []string{"user_id"}, false))
+	require.Equal(t, []string{
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "events_user_id_idx" ON "public"."events" ("user_id")`,
+		`DROP INDEX CONCURRENTLY IF EXISTS "public"."events_user_id_idx"`,
+		`CREATE INDEX IF NOT EXISTS "events_user_id_idx" ON "public"."events" ("user_id")`,
+	}, failingDrv.queries)
+
+	require.Error(t, (&DataSourceConfig{Host: "host", Db: "db", Username: "user", Indexes: map[string][][]string{"events": {{}}}}).Validate())
+
+	//long names with the same prefix are truncated to different ones
+	longColumn := strings.Repeat("c", 60)
+	first, second := indexName("events", []string{longColumn + "_1"}, "_idx"), indexName("events", []string{longColumn + "_2"}, "_idx")
+	require.NotEqual(t, first, second)
+	require.Equal(t, maxIdentifierLength, len(first))
+	require.True(t, strings.HasSuffix(first, "_idx"), first)
+	require.Equal(t, "events_user_id_idx", indexName("events", []string{"user_id"}, "_idx"))
+}
+
+func TestCreatePartitionQuotedNames(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public", DedupKey: `event"id`}}
+	defer p.Close()
+
+	from := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, p.CreatePartition("", `odd"events`, `odd"events_p20200901`, from, from.AddDate(0, 0, 1)))
+	require.NoError(t, p.EnsureUniqueKey(&schema.Table{Name: "events", Columns: schema.Columns{`event"id`: schema.Column{Type: schema.STRING}}}))
+	require.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "public"."odd""events_p20200901" PARTITION OF "public"."odd""events" FOR VALUES FROM ('2020-09-01') TO ('2020-09-02')`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS "events_event""id_key" ON "public"."events" ("event""id")`,
+	}, recordingDrv.queries)
+}
+
+func TestSQLHooks(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	p := &Postgres{SQLAdapter: NewSQLAdapter(context.Background(), sql.OpenDB(recordingDrv), PostgresDialect{}, "Postgres"),
+		config: &DataSourceConfig{Schema: "public", SavepointRows: 10}}
+	defer p.Close()
+
+	var ddl, dml []string
+	p.SetHooks(SQLHooks{
+		OnDDL: func(statement string) { ddl = append(ddl, statement) },
+		OnDML: func(statement string) {
+			dml = append(dml, statement)
+			panic("hook failure")
+		},
+	})
+
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"field1": schema.Column{Type: schema.STRING}}}
+	require.NoError(t, p.CreateTable(table))
+	require.NoError(t, p.BulkInsert(table, []events.Fact{{"field1": "1"}}), "Hook panic mustn't fail the statement")
+	require.NoError(t, p.CreateIndex("", "events", []string{"field1"}, false))
+
+	require.Equal(t, []string{
+		`CREATE TABLE "public"."events" ("field1" character varying(512))`,
+		`CREATE INDEX IF NOT EXISTS "events_field1_idx" ON "public"."events" ("field1")`,
+	}, ddl)
+	require.Equal(t, []string{`INSERT INTO "public"."events" ("field1") VALUES ($1)`}, dml, "Savepoints aren't passed to hooks")
+	require.Equal(t, 5, len(recordingDrv.queries))
+}
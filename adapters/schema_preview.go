@@ -0,0 +1,151 @@
+package adapters
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"io"
+	"sort"
+)
+
+//TableSchemaGetter return current destination table schema (not existing table has no columns)
+//Is implemented by Postgres and MySQL adapters
+type TableSchemaGetter interface {
+	GetTableSchema(tableName string) (*schema.Table, error)
+}
+
+//TableChanges is a previewed change of one destination table
+type TableChanges struct {
+	Table string
+	//true if the table would be created
+	Created bool
+	//column name -> sql type of new columns (all columns of created tables)
+	AddedColumns map[string]string
+	//column name -> sql type of existing columns which type would be widened (see schema.Table.Diff)
+	WidenedColumns map[string]string
+	//DDL statements which would be executed: CREATE TABLE or ALTER TABLE ADD COLUMN
+	Statements []string
+}
+
+//SchemaPreview is a dry-run of storing events: facts are processed with schema.Processor and tables schemas are
+//accumulated. DDL statements are computed with SQLDialect but nothing is executed in destination
+//(existing tables schemas are only read if TableSchemaGetter is provided)
+type SchemaPreview struct {
+	processor  *schema.Processor
+	dialect    SQLDialect
+	dbSchema   string
+	tableNames schema.TableNamesConfig
+	existing   TableSchemaGetter
+
+	//destination table name -> merged columns of processed facts
+	tables map[string]*schema.Table
+}
+
+//NewSchemaPreview return SchemaPreview. tableNames is destination table names transformation (e.g. prefix)
+//existing may be nil: then all tables are previewed as new ones
+func NewSchemaPreview(processor *schema.Processor, dialect SQLDialect, dbSchema string, tableNames schema.TableNamesConfig,
+	existing TableSchemaGetter) *SchemaPreview {
+	return &SchemaPreview{
+		processor:  processor,
+		dialect:    dialect,
+		dbSchema:   dbSchema,
+		tableNames: tableNames,
+		existing:   existing,
+		tables:     map[string]*schema.Table{},
+	}
+}
+
+//Process fact with schema.Processor and merge its table schema
+func (sp *SchemaPreview) Process(fact events.Fact) error {
+	table, _, err := sp.processor.ProcessFact(fact)
+	if err != nil {
+		return err
+	}
+
+	name := sp.tableNames.TableName(table.Name)
+	current, ok := sp.tables[name]
+	if !ok {
+		current = &schema.Table{Name: name, Columns: schema.Columns{}}
+		sp.tables[name] = current
+	}
+	current.Columns.Merge(table.Columns)
+
+	return nil
+}
+
+//Changes return changes of all tables of processed facts sorted by table name
+func (sp *SchemaPreview) Changes() ([]*TableChanges, error) {
+	var names []string
+	for name := range sp.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []*TableChanges
+	for _, name := range names {
+		dataSchema := sp.tables[name]
+		dbTableSchema := &schema.Table{Name: name, Columns: schema.Columns{}}
+		if sp.existing != nil {
+			var err error
+			dbTableSchema, err = sp.existing.GetTableSchema(name)
+			if err != nil {
+				return nil, fmt.Errorf("Error getting table %s schema: %v", name, err)
+			}
+		}
+
+		tableChanges := &TableChanges{Table: name, AddedColumns: map[string]string{}, WidenedColumns: map[string]string{}}
+		if !dbTableSchema.Exists() {
+			tableChanges.Created = true
+			for columnName, column := range dataSchema.Columns {
+				tableChanges.AddedColumns[columnName] = sp.dialect.ColumnType(column)
+			}
+			tableChanges.Statements = []string{sp.dialect.CreateTableDDL(sp.dbSchema, dataSchema)}
+			changes = append(changes, tableChanges)
+			continue
+		}
+
+		diff := dbTableSchema.Diff(dataSchema)
+		if !diff.NeedsPatch() {
+			continue
+		}
+		for _, columnName := range diff.Columns.SortedNames() {
+			column := diff.Columns[columnName]
+			tableChanges.AddedColumns[columnName] = sp.dialect.ColumnType(column)
+			tableChanges.Statements = append(tableChanges.Statements, sp.dialect.AlterAddColumnDDL(sp.dbSchema, name, columnName, column))
+		}
+		for columnName, column := range diff.WidenedColumns {
+			tableChanges.WidenedColumns[columnName] = sp.dialect.ColumnType(column)
+		}
+		changes = append(changes, tableChanges)
+	}
+
+	return changes, nil
+}
+
+//Write all DDL statements (one per line) and widened columns as comments to writer
+func (sp *SchemaPreview) Write(writer io.Writer) error {
+	changes, err := sp.Changes()
+	if err != nil {
+		return err
+	}
+
+	for _, tableChanges := range changes {
+		for _, statement := range tableChanges.Statements {
+			if _, err := fmt.Fprintf(writer, "%s;\n", statement); err != nil {
+				return err
+			}
+		}
+		var widened []string
+		for columnName := range tableChanges.WidenedColumns {
+			widened = append(widened, columnName)
+		}
+		sort.Strings(widened)
+		for _, columnName := range widened {
+			if _, err := fmt.Fprintf(writer, "-- %s column %s type would be widened to %s\n", tableChanges.Table, columnName, tableChanges.WidenedColumns[columnName]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
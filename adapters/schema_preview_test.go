@@ -0,0 +1,65 @@
+package adapters
+
+import (
+	"bytes"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type staticTables map[string]*schema.Table
+
+func (st staticTables) GetTableSchema(tableName string) (*schema.Table, error) {
+	if table, ok := st[tableName]; ok {
+		return table, nil
+	}
+	return &schema.Table{Name: tableName, Columns: schema.Columns{}}, nil
+}
+
+func TestSchemaPreview(t *testing.T) {
+	processor, err := schema.NewProcessor(`{{.event_type}}`, []string{"/_timestamp -> "}, schema.ProcessorConfig{NumericTypes: true})
+	require.NoError(t, err)
+
+	existing := staticTables{"stg_click": &schema.Table{Name: "stg_click", Columns: schema.Columns{
+		"event_type": schema.Column{Type: schema.STRING},
+		"x":          schema.Column{Type: schema.INT64},
+	}}}
+	preview := NewSchemaPreview(processor, PostgresDialect{}, "public", schema.TableNamesConfig{Prefix: "stg_"}, existing)
+
+	for _, payload := range []string{
+		`{"_timestamp":"2020-08-02T18:23:58.057807Z","event_type":"page_view","url":"/home"}`,
+		`{"_timestamp":"2020-08-02T18:23:58.057807Z","event_type":"page_view","referer":"google"}`,
+		`{"_timestamp":"2020-08-02T18:23:58.057807Z","event_type":"click","x":1.5,"y":2}`,
+		`{"_timestamp":"2020-08-02T18:23:58.057807Z","event_type":"click","x":1}`,
+	} {
+		fact := events.Fact{}
+		require.NoError(t, events.DecodeJSON([]byte(payload), &fact))
+		require.NoError(t, preview.Process(fact))
+	}
+
+	changes, err := preview.Changes()
+	require.NoError(t, err)
+	require.Equal(t, []*TableChanges{
+		{
+			Table:          "stg_click",
+			AddedColumns:   map[string]string{"y": "bigint"},
+			WidenedColumns: map[string]string{"x": "double precision"},
+			Statements:     []string{`ALTER TABLE "public"."stg_click" ADD COLUMN "y" bigint`},
+		},
+		{
+			Table:          "stg_page_view",
+			Created:        true,
+			AddedColumns:   map[string]string{"event_type": "character varying(512)", "referer": "character varying(512)", "url": "character varying(512)"},
+			WidenedColumns: map[string]string{},
+			Statements:     []string{`CREATE TABLE "public"."stg_page_view" ("event_type" character varying(512),"referer" character varying(512),"url" character varying(512))`},
+		},
+	}, changes)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, preview.Write(buf))
+	require.Equal(t, `ALTER TABLE "public"."stg_click" ADD COLUMN "y" bigint;
+-- stg_click column x type would be widened to double precision
+CREATE TABLE "public"."stg_page_view" ("event_type" character varying(512),"referer" character varying(512),"url" character varying(512));
+`, buf.String())
+}
@@ -0,0 +1,251 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/snowflakedb/gosnowflake"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sfTableSchemaQuery                  = `SELECT column_name, data_type FROM information_schema.columns WHERE table_schema = ? AND table_name = ?`
+	sfCreateDbSchemaIfNotExistsTemplate = `CREATE SCHEMA IF NOT EXISTS "%s"`
+	sfAddColumnTemplate                 = `ALTER TABLE "%s"."%s" ADD COLUMN "%s" %s`
+	sfCreateTableTemplate               = `CREATE TABLE "%s"."%s" (%s)`
+	//file is uploaded to the table stage and compressed with gzip (AUTO_COMPRESS is true by default)
+	sfPutTemplate = `PUT 'file://%s' @"%s".%%"%s"`
+	//loaded file is removed from the stage. Objects keys are matched with quoted (case sensitive) column names
+	sfCopyTemplate = `COPY INTO "%s"."%s" FROM @"%s".%%"%s" FILES = ('%s.gz') FILE_FORMAT = (TYPE = JSON) MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE`
+)
+
+var (
+	schemaToSnowflake = map[schema.DataType]string{
+		schema.STRING:    "text",
+		schema.INT64:     "bigint",
+		schema.FLOAT64:   "double",
+		schema.TIMESTAMP: "timestamp_tz",
+	}
+
+	snowflakeToSchema = map[string]schema.DataType{
+		"TEXT":         schema.STRING,
+		"NUMBER":       schema.INT64,
+		"FLOAT":        schema.FLOAT64,
+		"TIMESTAMP_TZ": schema.TIMESTAMP,
+	}
+)
+
+//SnowflakeConfig dto for deserialized snowflake config
+type SnowflakeConfig struct {
+	Account   string `mapstructure:"account"`
+	Warehouse string `mapstructure:"warehouse"`
+	Db        string `mapstructure:"db"`
+	Schema    string `mapstructure:"schema"`
+	Username  string `mapstructure:"username"`
+	Password  string `mapstructure:"password"`
+	Role      string `mapstructure:"role"`
+
+	StreamingConfig `mapstructure:",squash"`
+}
+
+//Validate required fields in SnowflakeConfig
+func (sc *SnowflakeConfig) Validate() error {
+	if sc == nil {
+		return errors.New("Snowflake config is required")
+	}
+	if sc.Account == "" {
+		return errors.New("Snowflake account is required parameter")
+	}
+	if sc.Warehouse == "" {
+		return errors.New("Snowflake warehouse is required parameter")
+	}
+	if sc.Db == "" {
+		return errors.New("Snowflake db is required parameter")
+	}
+	if sc.Username == "" {
+		return errors.New("Snowflake username is required parameter")
+	}
+
+	return nil
+}
+
+//Snowflake is adapter for creating,patching (schema or table), loading data to snowflake
+//Objects are loaded in batches: json file is put to the table internal stage and copied with COPY INTO
+type Snowflake struct {
+	ctx        context.Context
+	config     *SnowflakeConfig
+	dataSource *sql.DB
+}
+
+//NewSnowflake return configured Snowflake adapter instance
+func NewSnowflake(ctx context.Context, config *SnowflakeConfig) (*Snowflake, error) {
+	dsn, err := gosnowflake.DSN(&gosnowflake.Config{
+		Account:   config.Account,
+		User:      config.Username,
+		Password:  config.Password,
+		Database:  config.Db,
+		Schema:    config.Schema,
+		Warehouse: config.Warehouse,
+		Role:      config.Role,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error building snowflake dsn: %v", err)
+	}
+
+	dataSource, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := dataSource.Ping(); err != nil {
+		dataSource.Close()
+		return nil, err
+	}
+
+	return &Snowflake{ctx: ctx, config: config, dataSource: dataSource}, nil
+}
+
+func (Snowflake) Name() string {
+	return "Snowflake"
+}
+
+//CreateDbSchema create database schema instance if doesn't exist
+func (s *Snowflake) CreateDbSchema(dbSchemaName string) error {
+	if _, err := s.dataSource.ExecContext(s.ctx, fmt.Sprintf(sfCreateDbSchemaIfNotExistsTemplate, dbSchemaName)); err != nil {
+		return fmt.Errorf("Error creating [%s] db schema: %v", dbSchemaName, err)
+	}
+
+	return nil
+}
+
+//GetTableSchema return table (name,columns with name and types) representation wrapped in schema.Table struct
+func (s *Snowflake) GetTableSchema(tableName string) (*schema.Table, error) {
+	table := &schema.Table{Name: tableName, Columns: schema.Columns{}}
+	rows, err := s.dataSource.QueryContext(s.ctx, sfTableSchemaQuery, s.config.Schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying table [%s] schema: %v", tableName, err)
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, columnSnowflakeType string
+		if err := rows.Scan(&columnName, &columnSnowflakeType); err != nil {
+			return nil, fmt.Errorf("Error scanning result: %v", err)
+		}
+		mappedType, ok := snowflakeToSchema[columnSnowflakeType]
+		if !ok {
+			log.Println("Unknown snowflake column type:", columnSnowflakeType)
+			mappedType = schema.STRING
+		}
+		table.Columns[columnName] = schema.Column{Type: mappedType}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("Last rows.Err: %v", err)
+	}
+
+	return table, nil
+}
+
+//CreateTable create database table with name,columns (sorted by name) provided in schema.Table representation
+func (s *Snowflake) CreateTable(tableSchema *schema.Table) error {
+	var columnsDDL []string
+	for _, columnName := range tableSchema.Columns.SortedNames() {
+		//column names are quoted for keeping them in lower case
+		columnsDDL = append(columnsDDL, fmt.Sprintf(`"%s" %s`, columnName, s.columnType(tableSchema.Columns[columnName])))
+	}
+
+	statement := fmt.Sprintf(sfCreateTableTemplate, s.config.Schema, tableSchema.Name, strings.Join(columnsDDL, ","))
+	if _, err := s.dataSource.ExecContext(s.ctx, statement); err != nil {
+		return fmt.Errorf("Error creating [%s] table: %v", tableSchema.Name, err)
+	}
+
+	return nil
+}
+
+//PatchTableSchema add new columns(from provided schema.Table) sorted by name to existing table
+func (s *Snowflake) PatchTableSchema(patchSchema *schema.Table) error {
+	for _, columnName := range patchSchema.Columns.SortedNames() {
+		mappedColumnType := s.columnType(patchSchema.Columns[columnName])
+		statement := fmt.Sprintf(sfAddColumnTemplate, s.config.Schema, patchSchema.Name, columnName, mappedColumnType)
+		if _, err := s.dataSource.ExecContext(s.ctx, statement); err != nil {
+			return fmt.Errorf("Error patching %s table with '%s' - %s column schema: %v", patchSchema.Name, columnName, mappedColumnType, err)
+		}
+	}
+
+	return nil
+}
+
+//BulkInsert write provided objects to a local json file (1 line = 1 object), put it to the table stage
+//and load with COPY INTO command. Missing keys are loaded as NULL
+func (s *Snowflake) BulkInsert(table *schema.Table, objects []events.Fact) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	buf := bytes.Buffer{}
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("Error marshaling object to json: %v", err)
+		}
+		buf.Write(b)
+		buf.Write([]byte("\n"))
+	}
+
+	file, err := ioutil.TempFile("", "snowflake-"+table.Name+"-*.json")
+	if err != nil {
+		return fmt.Errorf("Error creating temporary file for snowflake stage: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("Error writing temporary file %s: %v", file.Name(), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Error closing temporary file %s: %v", file.Name(), err)
+	}
+
+	if _, err := s.dataSource.ExecContext(s.ctx, fmt.Sprintf(sfPutTemplate, file.Name(), s.config.Schema, table.Name)); err != nil {
+		return fmt.Errorf("Error putting file %s to %s table stage: %v", file.Name(), table.Name, err)
+	}
+
+	statement := fmt.Sprintf(sfCopyTemplate, s.config.Schema, table.Name, s.config.Schema, table.Name, filepath.Base(file.Name()))
+	if _, err := s.dataSource.ExecContext(s.ctx, statement); err != nil {
+		return fmt.Errorf("Error copying %d objects from stage to %s table: %v", len(objects), table.Name, err)
+	}
+
+	return nil
+}
+
+//Close underlying sql.DB
+func (s *Snowflake) Close() error {
+	if err := s.dataSource.Close(); err != nil {
+		return fmt.Errorf("Error closing datasource: %v", err)
+	}
+
+	return nil
+}
+
+//Return explicitly configured column sql type or mapped from schema.DataType
+func (s *Snowflake) columnType(column schema.Column) string {
+	if column.SqlType != "" {
+		return column.SqlType
+	}
+
+	mappedType, ok := schemaToSnowflake[column.Type]
+	if !ok {
+		log.Println("Unknown snowflake schema type:", column.Type.String())
+		mappedType = schemaToSnowflake[schema.STRING]
+	}
+
+	return mappedType
+}
@@ -0,0 +1,144 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var sfPutRegexp = regexp.MustCompile(`^PUT 'file://(.+)' @"public".%"events"$`)
+
+//stagingDriver is a recordingDriver which reads files of PUT statements before they are removed
+type stagingDriver struct {
+	*recordingDriver
+	staged []string
+}
+
+func (d *stagingDriver) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *stagingDriver) Driver() driver.Driver                            { return d }
+func (d *stagingDriver) Open(name string) (driver.Conn, error)            { return d, nil }
+func (d *stagingDriver) Prepare(query string) (driver.Stmt, error) {
+	if match := sfPutRegexp.FindStringSubmatch(query); match != nil {
+		b, err := ioutil.ReadFile(match[1])
+		if err != nil {
+			return nil, err
+		}
+		d.staged = append(d.staged, string(b))
+	}
+
+	return d.recordingDriver.Prepare(query)
+}
+
+func newTestSnowflake(drv driver.Connector) *Snowflake {
+	return &Snowflake{ctx: context.Background(), config: &SnowflakeConfig{Schema: "public"}, dataSource: sql.OpenDB(drv)}
+}
+
+func TestSnowflakeConfigValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *SnowflakeConfig
+		expectedError string
+	}{
+		{
+			"Nil config",
+			nil,
+			"Snowflake config is required",
+		},
+		{
+			"Without account",
+			&SnowflakeConfig{Warehouse: "wh", Db: "db", Username: "user"},
+			"Snowflake account is required parameter",
+		},
+		{
+			"Without warehouse",
+			&SnowflakeConfig{Account: "account", Db: "db", Username: "user"},
+			"Snowflake warehouse is required parameter",
+		},
+		{
+			"Without db",
+			&SnowflakeConfig{Account: "account", Warehouse: "wh", Username: "user"},
+			"Snowflake db is required parameter",
+		},
+		{
+			"Without username",
+			&SnowflakeConfig{Account: "account", Warehouse: "wh", Db: "db"},
+			"Snowflake username is required parameter",
+		},
+		{
+			"Valid",
+			&SnowflakeConfig{Account: "account", Warehouse: "wh", Db: "db", Username: "user"},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSnowflakeDDL(t *testing.T) {
+	recordingDrv := &recordingDriver{}
+	s := newTestSnowflake(recordingDrv)
+	defer s.Close()
+
+	require.NoError(t, s.CreateDbSchema("public"))
+	require.NoError(t, s.CreateTable(&schema.Table{Name: "events", Columns: schema.Columns{
+		"user":       schema.Column{Type: schema.STRING},
+		"count":      schema.Column{Type: schema.INT64},
+		"created_at": schema.Column{Type: schema.TIMESTAMP},
+		"products":   schema.Column{Type: schema.STRING, SqlType: "variant"},
+	}}))
+	require.NoError(t, s.PatchTableSchema(&schema.Table{Name: "events", Columns: schema.Columns{
+		"price": schema.Column{Type: schema.FLOAT64},
+		"city":  schema.Column{Type: schema.STRING},
+	}}))
+
+	require.Equal(t, []string{
+		`CREATE SCHEMA IF NOT EXISTS "public"`,
+		`CREATE TABLE "public"."events" ("count" bigint,"created_at" timestamp_tz,"products" variant,"user" text)`,
+		`ALTER TABLE "public"."events" ADD COLUMN "city" text`,
+		`ALTER TABLE "public"."events" ADD COLUMN "price" double`,
+	}, recordingDrv.queries)
+}
+
+func TestSnowflakeBulkInsert(t *testing.T) {
+	stagingDrv := &stagingDriver{recordingDriver: &recordingDriver{}}
+	s := newTestSnowflake(stagingDrv)
+	defer s.Close()
+
+	table := &schema.Table{Name: "events"}
+	require.NoError(t, s.BulkInsert(table, []events.Fact{{"user": "user_1", "count": 1}, {"user": "user_2"}}))
+
+	//json file (1 line = 1 object) is put to the table stage, copied and removed
+	queries := stagingDrv.queries
+	require.Len(t, queries, 2)
+	match := sfPutRegexp.FindStringSubmatch(queries[0])
+	require.NotNil(t, match, "Unexpected PUT statement: %s", queries[0])
+	fileName := filepath.Base(match[1])
+	require.True(t, strings.HasPrefix(fileName, "snowflake-events-") && strings.HasSuffix(fileName, ".json"), fileName)
+	require.Equal(t, `COPY INTO "public"."events" FROM @"public".%"events" FILES = ('`+fileName+`.gz') `+
+		`FILE_FORMAT = (TYPE = JSON) MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE`, queries[1])
+	require.Equal(t, []string{"{\"count\":1,\"user\":\"user_1\"}\n{\"user\":\"user_2\"}\n"}, stagingDrv.staged)
+	_, err := os.Stat(match[1])
+	require.True(t, os.IsNotExist(err), "Local file must be removed after loading")
+
+	//nothing is loaded without objects
+	require.NoError(t, s.BulkInsert(table, nil))
+	require.Len(t, stagingDrv.queries, 2)
+}
@@ -0,0 +1,132 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/ksensehq/eventnative/schema"
+	"log"
+	"time"
+)
+
+const pingTimeout = 5 * time.Second
+
+//SQLAdapter is a generic part of adapters for databases with database/sql driver (e.g. Postgres, MySQL)
+//creating and patching tables in transactions. All statements are generated by SQLDialect
+type SQLAdapter struct {
+	ctx        context.Context
+	dataSource *sql.DB
+	dialect    SQLDialect
+	//used in transaction errors logging (e.g. Postgres or MySQL)
+	dbType string
+	//max duration of one statement (see statementContext). 0 - unlimited
+	statementTimeout time.Duration
+	//called before statements execution (see SetHooks)
+	hooks SQLHooks
+}
+
+//NewSQLAdapter return SQLAdapter over opened sql.DB
+func NewSQLAdapter(ctx context.Context, dataSource *sql.DB, dialect SQLDialect, dbType string) *SQLAdapter {
+	return &SQLAdapter{ctx: ctx, dataSource: dataSource, dialect: dialect, dbType: dbType}
+}
+
+//Ping check database connection (not longer than pingTimeout)
+func (sa *SQLAdapter) Ping() error {
+	ctx, cancel := context.WithTimeout(sa.ctx, pingTimeout)
+	defer cancel()
+
+	if err := sa.dataSource.PingContext(ctx); err != nil {
+		return fmt.Errorf("Error pinging %s: %v", sa.dbType, err)
+	}
+
+	return nil
+}
+
+//statementContext return context of one statement with statementTimeout deadline (if it is configured)
+//Transactions are opened with adapter context: statement timeout doesn't roll back the whole transaction by itself
+func (sa *SQLAdapter) statementContext() (context.Context, context.CancelFunc) {
+	if sa.statementTimeout <= 0 {
+		return sa.ctx, func() {}
+	}
+
+	return context.WithTimeout(sa.ctx, sa.statementTimeout)
+}
+
+//exec execute statement in provided transaction with statement timeout
+func (sa *SQLAdapter) exec(wrappedTx *Transaction, statement string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := sa.statementContext()
+	defer cancel()
+
+	sa.beforeStatement(statement)
+	return wrappedTx.tx.ExecContext(ctx, statement, args...)
+}
+
+//execDataSource execute statement outside of transaction (e.g. CREATE INDEX CONCURRENTLY) with provided context
+func (sa *SQLAdapter) execDataSource(ctx context.Context, statement string, args ...interface{}) (sql.Result, error) {
+	sa.beforeStatement(statement)
+	return sa.dataSource.ExecContext(ctx, statement, args...)
+}
+
+//prepare statement in provided transaction with provided context
+func (sa *SQLAdapter) prepare(ctx context.Context, wrappedTx *Transaction, statement string) (*sql.Stmt, error) {
+	sa.beforeStatement(statement)
+	return wrappedTx.tx.PrepareContext(ctx, statement)
+}
+
+//OpenTx open underline sql transaction and return wrapped instance
+func (sa *SQLAdapter) OpenTx() (*Transaction, error) {
+	tx, err := sa.dataSource.BeginTx(sa.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{tx: tx, dbType: sa.dbType}, nil
+}
+
+//createTable create table in provided transaction without commit. Rollback transaction on error
+func (sa *SQLAdapter) createTable(wrappedTx *Transaction, dbSchema string, tableSchema *schema.Table) error {
+	if _, err := sa.exec(wrappedTx, sa.dialect.CreateTableDDL(dbSchema, tableSchema)); err != nil {
+		wrappedTx.Rollback()
+		return fmt.Errorf("Error creating [%s] table: %v", tableSchema.Name, err)
+	}
+
+	return nil
+}
+
+//addColumns add patchSchema columns (sorted by name) to existing table in provided transaction without commit.
+//Rollback transaction on error
+func (sa *SQLAdapter) addColumns(wrappedTx *Transaction, dbSchema string, patchSchema *schema.Table) error {
+	for _, columnName := range patchSchema.Columns.SortedNames() {
+		column := patchSchema.Columns[columnName]
+		statement := sa.dialect.AlterAddColumnDDL(dbSchema, patchSchema.Name, columnName, column)
+		if _, err := sa.exec(wrappedTx, statement); err != nil {
+			wrappedTx.Rollback()
+			return fmt.Errorf("Error patching %s table with '%s' - %s column schema: %v", patchSchema.Name, columnName, sa.dialect.ColumnType(column), err)
+		}
+	}
+
+	return nil
+}
+
+//Transaction is sql transaction wrapper. Used for handling and log errors with db type (postgres or redshift)
+//on Commit() and Rollback() calls
+type Transaction struct {
+	dbType string
+	tx     *sql.Tx
+}
+
+//Commit transaction. Error is logged and returned
+func (t *Transaction) Commit() error {
+	if err := t.tx.Commit(); err != nil {
+		log.Printf("System error: unable to commit %s transaction: %v", t.dbType, err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *Transaction) Rollback() {
+	if err := t.tx.Rollback(); err != nil {
+		log.Printf("System error: unable to rollback %s transaction: %v", t.dbType, err)
+	}
+}
@@ -0,0 +1,18 @@
+package adapters
+
+import (
+	"github.com/ksensehq/eventnative/schema"
+)
+
+//SQLDialect generates database specific SQL statements (identifiers quoting, column types, DDL)
+//so adapters with the same create/patch/insert logic can be used with different databases
+type SQLDialect interface {
+	//QuoteIdentifier return quoted table or column name
+	QuoteIdentifier(identifier string) string
+	//ColumnType return explicitly configured column sql type or database type mapped from schema.DataType
+	ColumnType(column schema.Column) string
+	//CreateTableDDL return CREATE TABLE statement with all table columns
+	CreateTableDDL(dbSchema string, table *schema.Table) string
+	//AlterAddColumnDDL return statement for adding one column to existing table
+	AlterAddColumnDDL(dbSchema, tableName, columnName string, column schema.Column) string
+}
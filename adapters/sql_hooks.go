@@ -0,0 +1,65 @@
+package adapters
+
+import (
+	"log"
+	"strings"
+)
+
+//length of the longest statement prefix
+const maxStatementPrefixLength = 7
+
+var (
+	ddlStatementPrefixes = []string{"CREATE", "ALTER", "DROP", "COMMENT"}
+	dmlStatementPrefixes = []string{"INSERT", "UPDATE", "DELETE", "COPY"}
+)
+
+//SQLHooks are called with statements text (without values) before execution e.g. for audit trail of automatic
+//schema changes. Hooks are called synchronously in inserting goroutines so they must return fast (e.g. write to log).
+//Hook panics are recovered: hooks never fail statements. Nil hooks are skipped
+type SQLHooks struct {
+	//CREATE, ALTER, DROP and COMMENT statements
+	OnDDL func(statement string)
+	//INSERT, UPDATE, DELETE and COPY statements. Prepared insert statement (streaming insert mode) is passed once per batch
+	OnDML func(statement string)
+}
+
+//SetHooks set statements hooks. Must be called before statements execution
+func (sa *SQLAdapter) SetHooks(hooks SQLHooks) {
+	sa.hooks = hooks
+}
+
+//Call hook of statement kind. Other statements (e.g. SAVEPOINT, SELECT) aren't passed to hooks
+func (sa *SQLAdapter) beforeStatement(statement string) {
+	var hook func(string)
+	switch {
+	case sa.hooks.OnDDL != nil && hasStatementPrefix(statement, ddlStatementPrefixes):
+		hook = sa.hooks.OnDDL
+	case sa.hooks.OnDML != nil && hasStatementPrefix(statement, dmlStatementPrefixes):
+		hook = sa.hooks.OnDML
+	default:
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error in %s statement hook: %v", sa.dbType, r)
+		}
+	}()
+	hook(statement)
+}
+
+func hasStatementPrefix(statement string, prefixes []string) bool {
+	//statements may be long (e.g. multi-row inserts): only the beginning is compared
+	statement = strings.TrimSpace(statement)
+	if len(statement) > maxStatementPrefixLength {
+		statement = statement[:maxStatementPrefixLength]
+	}
+	statement = strings.ToUpper(statement)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(statement, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	//RejectQueuePolicy drop new events when the queue is full
+	RejectQueuePolicy = "reject"
+	//BlockQueuePolicy block event consuming until queue has free space
+	BlockQueuePolicy = "block"
+
+	//DeadLetterOversizedPolicy put events which exceed max event size to the dead-letter queue
+	DeadLetterOversizedPolicy = "dead_letter"
+	//TruncateOversizedPolicy replace the biggest values of events which exceed max event size with marker
+	TruncateOversizedPolicy = "truncate"
+
+	//SkipMarshalFailurePolicy only log and count events which can't be marshaled to json
+	SkipMarshalFailurePolicy = "skip"
+	//FileMarshalFailurePolicy also write events which can't be marshaled to json to the marshal failures file
+	FileMarshalFailurePolicy = "file"
+)
+
+//StreamingConfig dto for deserialized streaming destination parameters (e.g. in Postgres or ClickHouse destination)
+//Used for configuring queue draining
+type StreamingConfig struct {
+	//batch parameters
+	BatchSize       int `mapstructure:"batch_size"`
+	FlushIntervalMs int `mapstructure:"flush_interval_ms"`
+	//retry parameters on insert failures
+	BackoffBaseMs int `mapstructure:"backoff_base_ms"`
+	BackoffMaxMs  int `mapstructure:"backoff_max_ms"`
+	//facts which weren't processed after this count of attempts are put to the dead-letter queue
+	MaxProcessingAttempts int `mapstructure:"max_processing_attempts"`
+	//max time for flushing queued facts on shutdown. Current batches of drain goroutines are finished even if it is exceeded
+	ShutdownTimeoutMs int `mapstructure:"shutdown_timeout_ms"`
+	//queue limits: max count of queued facts and max size of queue files on disk. 0 - unlimited
+	MaxQueueSize  int   `mapstructure:"max_queue_size"`
+	MaxQueueBytes int64 `mapstructure:"max_queue_bytes"`
+	//reject or block. Is used when one of queue limits is exceeded
+	QueueFullPolicy string `mapstructure:"queue_full_policy"`
+	//count of facts per queue segment file: bigger segments for tiny events mean fewer files, smaller ones for large
+	//events mean less data rewritten and reloaded after crash. 2000 by default
+	QueueSegmentSize int `mapstructure:"queue_segment_size"`
+	//empty queue files are removed after this count of seconds without new facts. 0 (default) - queue isn't compacted
+	QueueCompactIdleSec int `mapstructure:"queue_compact_idle_sec"`
+	//storage is reported as unhealthy when count of queued facts exceeds this value. 0 - isn't checked
+	HealthMaxQueueSize int `mapstructure:"health_max_queue_size"`
+	//max size of json marshaled event. 0 - unlimited
+	MaxEventBytes int `mapstructure:"max_event_bytes"`
+	//dead_letter or truncate. Is used when event exceeds max_event_bytes
+	OversizedEventPolicy string `mapstructure:"oversized_event_policy"`
+	//skip (default) or file. Is used when event can't be marshaled to json before enqueueing
+	MarshalFailurePolicy string `mapstructure:"marshal_failure_policy"`
+}
+
+//Validate queue parameters
+func (sc *StreamingConfig) Validate() error {
+	if sc.MaxQueueSize < 0 || sc.MaxQueueBytes < 0 {
+		return errors.New("Queue limits max_queue_size and max_queue_bytes must be positive")
+	}
+	if sc.QueueSegmentSize < 0 {
+		return errors.New("queue_segment_size must be positive")
+	}
+	if sc.QueueCompactIdleSec < 0 {
+		return errors.New("queue_compact_idle_sec must be positive")
+	}
+	switch sc.QueueFullPolicy {
+	case "", RejectQueuePolicy, BlockQueuePolicy:
+	default:
+		return fmt.Errorf("Unsupported queue_full_policy: %s. Supported: %s, %s", sc.QueueFullPolicy, RejectQueuePolicy, BlockQueuePolicy)
+	}
+	if sc.MaxEventBytes < 0 {
+		return errors.New("max_event_bytes must be positive")
+	}
+	switch sc.OversizedEventPolicy {
+	case "", DeadLetterOversizedPolicy, TruncateOversizedPolicy:
+	default:
+		return fmt.Errorf("Unsupported oversized_event_policy: %s. Supported: %s, %s", sc.OversizedEventPolicy, DeadLetterOversizedPolicy, TruncateOversizedPolicy)
+	}
+	switch sc.MarshalFailurePolicy {
+	case "", SkipMarshalFailurePolicy, FileMarshalFailurePolicy:
+		return nil
+	default:
+		return fmt.Errorf("Unsupported marshal_failure_policy: %s. Supported: %s, %s", sc.MarshalFailurePolicy, SkipMarshalFailurePolicy, FileMarshalFailurePolicy)
+	}
+}
+
+//QueueLimited return true if at least one of queue limits is configured
+func (sc *StreamingConfig) QueueLimited() bool {
+	return sc.MaxQueueSize > 0 || sc.MaxQueueBytes > 0
+}
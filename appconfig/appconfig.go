@@ -4,6 +4,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/ksensehq/eventnative/geo"
 	"github.com/ksensehq/eventnative/logging"
+	"github.com/ksensehq/eventnative/privacy"
 	"github.com/ksensehq/eventnative/useragent"
 	"github.com/spf13/viper"
 	"io"
@@ -18,6 +19,8 @@ type AppConfig struct {
 
 	GeoResolver geo.Resolver
 	UaResolver  *useragent.Resolver
+	//applied to events of all destinations. nil if privacy.rules aren't configured
+	Masker *privacy.Masker
 
 	closeMe []io.Closer
 }
@@ -72,9 +75,18 @@ func Init() error {
 	if err != nil {
 		log.Println("Run without geo resolver", err)
 	}
-	appConfig.GeoResolver = geoResolver
+	appConfig.GeoResolver = geo.NewCachingResolver(geoResolver, viper.GetInt("geo.cache_size"))
 	appConfig.UaResolver = useragent.NewResolver()
 
+	if maskingRules := viper.GetStringSlice("privacy.rules"); len(maskingRules) > 0 {
+		masker, err := privacy.NewMasker(maskingRules, viper.GetString("privacy.salt"))
+		if err != nil {
+			return err
+		}
+		appConfig.Masker = masker
+		log.Printf("Events fields masking is enabled with %d rules", len(maskingRules))
+	}
+
 	//authorization
 	// 1. from config
 	tokensArr := viper.GetStringSlice("server.auth")
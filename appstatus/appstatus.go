@@ -1,9 +1,38 @@
 package appstatus
 
-var Instance = &AppStatus{Idle: false}
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var Instance = NewAppStatus()
 
 //Singleton struct for storing application status. Some services check this flag
-//and don't perform any actions if Idle = true
+//and don't perform any actions if idle (see IsIdle). Blocking loops wait on Done() for exiting promptly
 type AppStatus struct {
-	Idle bool
+	idle     int32
+	idleOnce sync.Once
+	done     chan struct{}
+}
+
+func NewAppStatus() *AppStatus {
+	return &AppStatus{done: make(chan struct{})}
+}
+
+//SetIdle mark application as idle (e.g. on shutdown signal) and close Done() channel. Safe for concurrent calls
+func (as *AppStatus) SetIdle() {
+	as.idleOnce.Do(func() {
+		atomic.StoreInt32(&as.idle, 1)
+		close(as.done)
+	})
+}
+
+//IsIdle return true after SetIdle() call
+func (as *AppStatus) IsIdle() bool {
+	return atomic.LoadInt32(&as.idle) == 1
+}
+
+//Done return channel which is closed when application becomes idle
+func (as *AppStatus) Done() <-chan struct{} {
+	return as.done
 }
@@ -0,0 +1,29 @@
+package appstatus
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSetIdle(t *testing.T) {
+	status := NewAppStatus()
+	require.False(t, status.IsIdle())
+	select {
+	case <-status.Done():
+		t.Fatal("Done channel must not be closed before SetIdle")
+	default:
+	}
+
+	go status.SetIdle()
+	select {
+	case <-status.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done channel hasn't been closed after SetIdle")
+	}
+	require.True(t, status.IsIdle())
+
+	//repeated calls don't panic on closed channel
+	status.SetIdle()
+	require.True(t, status.IsIdle())
+}
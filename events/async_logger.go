@@ -2,59 +2,557 @@ package events
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/joncrlsn/dque"
+	"github.com/ksensehq/eventnative/logging"
+	"github.com/ksensehq/eventnative/metrics"
 	"io"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-//AsyncLogger write json logs to file system in different goroutine
+const (
+	DefaultAsyncLoggerBufferSize   = 20000
+	DefaultAsyncLoggerCloseTimeout = 10 * time.Second
+	DefaultGzipFlushInterval       = time.Second
+	DefaultBatchInterval           = time.Second
+
+	droppedEventsLogInterval = 10 * time.Second
+	//channel is considered saturated when it is filled more than this ratio
+	saturatedChannelRatio = 0.9
+)
+
+var (
+	errAsyncLoggerClosed = errors.New("Async logger is closed")
+	//ErrEventDropped is returned by AsyncLogger.ConsumeCtx if incoming event has been skipped because of DropNewest policy
+	ErrEventDropped = errors.New("Event has been dropped: async logger events channel is full")
+)
+
+//OverflowPolicy describes AsyncLogger.Consume behavior when events channel is full
+type OverflowPolicy int
+
+const (
+	//Block caller until there is room in the channel
+	Block OverflowPolicy = iota
+	//DropNewest skip incoming event
+	DropNewest
+	//DropOldest remove the oldest event from the channel and put incoming one
+	DropOldest
+)
+
+var overflowPolicies = map[string]OverflowPolicy{
+	"block":       Block,
+	"drop_newest": DropNewest,
+	"drop_oldest": DropOldest,
+}
+
+//ParseOverflowPolicy return OverflowPolicy from string representation (block, drop_newest, drop_oldest)
+//Empty string means Block
+func ParseOverflowPolicy(value string) (OverflowPolicy, error) {
+	if value == "" {
+		return Block, nil
+	}
+	policy, ok := overflowPolicies[strings.ToLower(value)]
+	if !ok {
+		return Block, fmt.Errorf("Unknown overflow policy: %s. Supported: block, drop_newest, drop_oldest", value)
+	}
+
+	return policy, nil
+}
+
+//AsyncLoggerOptions is a set of optional AsyncLogger parameters
+type AsyncLoggerOptions struct {
+	//print every event in the global logger
+	ShowInGlobalLogger bool
+	//capacity of events channel. DefaultAsyncLoggerBufferSize if not set
+	BufferSize int
+	//behavior when channel is full. Block by default
+	OverflowPolicy OverflowPolicy
+	//logger for async logger own messages (not events). logging.DefaultLogger() if not set
+	Logger logging.Logger
+	//max time for writing buffered events on Close(). DefaultAsyncLoggerCloseTimeout if not set
+	CloseTimeout time.Duration
+	//compress output with gzip
+	Gzip bool
+	//how often compressed data is written to underlying writer. DefaultGzipFlushInterval if not set
+	GzipFlushInterval time.Duration
+	//events are written with one Write() call per BatchSize events or per BatchInterval (whichever comes first)
+	//every event is written immediately if BatchSize <= 1. BatchInterval is DefaultBatchInterval if not set
+	BatchSize     int
+	BatchInterval time.Duration
+	//events format. JSONSerializer if not set
+	Serializer Serializer
+	//written after every record instead of Serializer separator (e.g. "\r\n"). Serializer separator if not set
+	LineSeparator string
+	//write UTF8BOM at the beginning of every file (after every rotation if writer implements logging.HeaderWriter)
+	//Is ignored with Gzip
+	BOM bool
+	//prometheus metrics (see Stats). Metrics aren't exported if not set
+	Metrics *metrics.AsyncLogger
+}
+
+//AsyncLoggerStats is a snapshot of AsyncLogger counters since start
+type AsyncLoggerStats struct {
+	//count of events written to underlying writer
+	Written uint64
+	//count of events which couldn't be serialized
+	MarshalErrors uint64
+	//count of events which were lost because of underlying writer errors
+	WriteErrors uint64
+	//count of events which were skipped because of full channel
+	Dropped uint64
+	//current count of events in the channel
+	QueueLen int
+}
+
+//AsyncLogger write logs (newline-delimited json by default, see Serializer) to file system in different goroutine
 type AsyncLogger struct {
 	writer             io.WriteCloser
+	serializer         Serializer
+	separator          []byte
+	gzipWriter         *logging.GzipWriter
 	logCh              chan Fact
 	showInGlobalLogger bool
+	overflowPolicy     OverflowPolicy
+	closeTimeout       time.Duration
+	logger             logging.Logger
+
+	//is used instead of logCh if logger is persistent (see NewPersistentAsyncLogger)
+	queue *dque.DQue
+	//signals writing goroutine that fact has been put to the queue
+	queued chan struct{}
+
+	//time based rotation of writer which is done by writing goroutine (nil if writer isn't logging.ScheduledRotator)
+	rotator     logging.ScheduledRotator
+	rotateTicks <-chan time.Time
+
+	//marshaled events which haven't been written yet (is used only in writing goroutine)
+	batch         *bytes.Buffer
+	batchSize     int
+	batchedEvents int
+
+	closed chan struct{}
+	//is closed after close timeout: writing goroutine stops after the current write
+	abort     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	dropped        uint64
+	lastDropLogged int64
+	written        uint64
+	marshalErrors  uint64
+	writeErrors    uint64
+	metrics        *metrics.AsyncLogger
+
+	//last write error or nil if the last write succeeded
+	writeErrMutex sync.RWMutex
+	writeErr      error
 }
 
-//Consume event fact and put it to channel
+//Consume event fact and put it to channel (see ConsumeCtx)
 func (al *AsyncLogger) Consume(fact Fact) {
-	al.logCh <- fact
+	al.ConsumeCtx(context.Background(), fact)
 }
 
-//Close underlying log file writer
-func (al *AsyncLogger) Close() (resultErr error) {
-	if err := al.writer.Close(); err != nil {
-		return fmt.Errorf("Error closing writer: %v", err)
+//ConsumeCtx put event fact to channel
+//If channel is full: block until there is room or ctx is done, skip fact or replace the oldest one according to OverflowPolicy
+//Return error if logger is closed, ctx is done before fact has been put or fact has been skipped (ErrEventDropped)
+//Replacing the oldest fact (DropOldest) isn't an error: incoming fact is accepted
+func (al *AsyncLogger) ConsumeCtx(ctx context.Context, fact Fact) error {
+	select {
+	case <-al.closed:
+		al.logger.Warn("Async logger is closed. Event will be skipped", "event", fact)
+		return errAsyncLoggerClosed
+	default:
+	}
+
+	if al.queue != nil {
+		return al.enqueue(fact)
+	}
+
+	switch al.overflowPolicy {
+	case DropNewest:
+		select {
+		case al.logCh <- fact:
+		default:
+			al.drop()
+			return ErrEventDropped
+		}
+	case DropOldest:
+		for {
+			select {
+			case al.logCh <- fact:
+				return nil
+			default:
+			}
+			select {
+			case <-al.logCh:
+				al.drop()
+			default:
+			}
+		}
+	default:
+		select {
+		case al.logCh <- fact:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-al.closed:
+			al.logger.Warn("Async logger is closed. Event will be skipped", "event", fact)
+			return errAsyncLoggerClosed
+		}
 	}
+
 	return nil
 }
 
-//Create AsyncLogger and run goroutine that's read from channel and write to file
-func NewAsyncLogger(writer io.WriteCloser, showInGlobalLogger bool) Consumer {
-	logger := &AsyncLogger{writer: writer, logCh: make(chan Fact, 20000), showInGlobalLogger: showInGlobalLogger}
+//Dropped return count of events which were skipped because of full channel
+func (al *AsyncLogger) Dropped() uint64 {
+	return atomic.LoadUint64(&al.dropped)
+}
 
-	go func() {
-		for {
-			fact := <-logger.logCh
-			bts, err := json.Marshal(fact)
-			if err != nil {
-				log.Printf("Error marshaling event to json: %v", err)
-				continue
+//Stats return current counters and channel length
+func (al *AsyncLogger) Stats() AsyncLoggerStats {
+	return AsyncLoggerStats{
+		Written:       atomic.LoadUint64(&al.written),
+		MarshalErrors: atomic.LoadUint64(&al.marshalErrors),
+		WriteErrors:   atomic.LoadUint64(&al.writeErrors),
+		Dropped:       atomic.LoadUint64(&al.dropped),
+		QueueLen:      al.QueueLen(),
+	}
+}
+
+//increment dropped counter and write warning not more than once per droppedEventsLogInterval
+func (al *AsyncLogger) drop() {
+	dropped := atomic.AddUint64(&al.dropped, 1)
+	if al.metrics != nil {
+		al.metrics.Dropped.Inc()
+	}
+
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&al.lastDropLogged)
+	if now-last >= int64(droppedEventsLogInterval) && atomic.CompareAndSwapInt64(&al.lastDropLogged, last, now) {
+		al.logger.Warn("Async logger events channel is full", "capacity", cap(al.logCh), "dropped_total", dropped)
+	}
+}
+
+//Health return error if logger is closed, the last write to underlying writer failed
+//or events channel is saturated
+func (al *AsyncLogger) Health() error {
+	select {
+	case <-al.closed:
+		return errAsyncLoggerClosed
+	default:
+	}
+
+	al.writeErrMutex.RLock()
+	writeErr := al.writeErr
+	al.writeErrMutex.RUnlock()
+	if writeErr != nil {
+		return fmt.Errorf("Async logger writer isn't writable: %v", writeErr)
+	}
+
+	//persistent queue is limited only by disk space
+	if queued := len(al.logCh); al.queue == nil && float64(queued) >= saturatedChannelRatio*float64(cap(al.logCh)) {
+		return fmt.Errorf("Async logger events channel is saturated: %d of %d", queued, cap(al.logCh))
+	}
+
+	return nil
+}
+
+//QueueLen return count of events in the channel (or persistent queue) which haven't been written yet
+func (al *AsyncLogger) QueueLen() int {
+	if al.queue != nil {
+		return al.queue.Size()
+	}
+
+	return len(al.logCh)
+}
+
+//Close stop accepting new events, wait until all buffered events are written (but not longer than close timeout)
+//and close underlying log file writer. Return error if some events haven't been written
+//After close timeout writing goroutine is aborted: the writer and the queue are closed after its current write
+func (al *AsyncLogger) Close() (resultErr error) {
+	al.closeOnce.Do(func() {
+		close(al.closed)
+
+		select {
+		case <-al.done:
+		case <-time.After(al.closeTimeout):
+			close(al.abort)
+			<-al.done
+		}
+
+		if remaining := al.QueueLen(); remaining > 0 {
+			if al.queue != nil {
+				al.logger.Info("Not written events are kept in the persistent queue. They will be written after restart", "events", remaining)
+			} else {
+				resultErr = fmt.Errorf("Error closing async logger: %d events haven't been written in %s", remaining, al.closeTimeout)
 			}
+		}
 
-			if logger.showInGlobalLogger {
-				prettyJsonBytes, _ := json.MarshalIndent(&fact, " ", " ")
-				log.Println(string(prettyJsonBytes))
+		if err := al.writer.Close(); err != nil {
+			resultErr = multierror.Append(resultErr, fmt.Errorf("Error closing writer: %v", err))
+		}
+		if al.queue != nil {
+			if err := al.queue.Close(); err != nil {
+				resultErr = multierror.Append(resultErr, fmt.Errorf("Error closing persistent queue: %v", err))
 			}
+		}
+	})
+
+	return
+}
+
+//Create AsyncLogger with default options and run goroutine that's read from channel and write to file
+func NewAsyncLogger(writer io.WriteCloser, showInGlobalLogger bool) *AsyncLogger {
+	return NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{ShowInGlobalLogger: showInGlobalLogger})
+}
+
+//Create AsyncLogger which writes to dir/prefix.log file
+//File is rolled over (renamed to prefix-<timestamp>.log) when it exceeds maxSizeMB or every maxAgeMinutes
+//Every event is written with one Write() call so it won't be split between files
+func NewRotatingAsyncLogger(dir, prefix string, maxSizeMB int, maxAgeMinutes int) *AsyncLogger {
+	return NewRotatingAsyncLoggerWithOptions(dir, prefix, maxSizeMB, maxAgeMinutes, AsyncLoggerOptions{})
+}
+
+//Create rotating AsyncLogger (see NewRotatingAsyncLogger) with options
+//With gzip option file is dir/prefix.gz and rotated ones are prefix-<timestamp>.gz
+func NewRotatingAsyncLoggerWithOptions(dir, prefix string, maxSizeMB int, maxAgeMinutes int, options AsyncLoggerOptions) *AsyncLogger {
+	extension := ".log"
+	if options.Gzip {
+		extension = ".gz"
+	}
+	writer := logging.NewRollingWriter(filepath.Join(dir, prefix+extension), maxSizeMB, 0, int64(maxAgeMinutes))
+	return NewAsyncLoggerWithOptions(writer, options)
+}
+
+//Create AsyncLogger and run goroutine that's read from channel and write to file
+func NewAsyncLoggerWithOptions(writer io.WriteCloser, options AsyncLoggerOptions) *AsyncLogger {
+	return newAsyncLogger(writer, options, nil)
+}
+
+//Create AsyncLogger over events channel or persistent queue (if queue isn't nil) and run writing goroutine
+func newAsyncLogger(writer io.WriteCloser, options AsyncLoggerOptions, queue *dque.DQue) *AsyncLogger {
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncLoggerBufferSize
+	}
+	logger := &AsyncLogger{
+		writer:             writer,
+		serializer:         options.Serializer,
+		logCh:              make(chan Fact, bufferSize),
+		queue:              queue,
+		queued:             make(chan struct{}, 1),
+		showInGlobalLogger: options.ShowInGlobalLogger,
+		overflowPolicy:     options.OverflowPolicy,
+		closeTimeout:       options.CloseTimeout,
+		logger:             options.Logger,
+		batch:              &bytes.Buffer{},
+		batchSize:          options.BatchSize,
+		metrics:            options.Metrics,
+		closed:             make(chan struct{}),
+		abort:              make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+	if logger.closeTimeout <= 0 {
+		logger.closeTimeout = DefaultAsyncLoggerCloseTimeout
+	}
+	if logger.logger == nil {
+		logger.logger = logging.DefaultLogger()
+	}
+	if logger.serializer == nil {
+		logger.serializer = JSONSerializer{}
+	}
+	logger.separator = logger.serializer.Separator()
+	if options.LineSeparator != "" {
+		logger.separator = []byte(options.LineSeparator)
+	}
+	if options.BOM {
+		if options.Gzip {
+			logger.logger.Warn("BOM can't be written to gzip event log. It will be ignored")
+		} else if hw, ok := writer.(logging.HeaderWriter); ok {
+			hw.SetHeader(UTF8BOM)
+		} else if _, err := writer.Write(UTF8BOM); err != nil {
+			logger.logger.Error("Error writing BOM to event log", "error", err)
+		}
+	}
+
+	//rotation is done in writing goroutine between batches (batch and gzip member are written to the current file before it)
+	//so no Write() straddles two files and Consume callers never wait for rotation
+	if rotator, ok := writer.(logging.ScheduledRotator); ok && rotator.RotationInterval() > 0 {
+		rotator.DisableScheduledRotation()
+		rotateTicker := time.NewTicker(rotator.RotationInterval())
+		logger.rotator = rotator
+		logger.rotateTicks = rotateTicker.C
+		go func() {
+			<-logger.done
+			rotateTicker.Stop()
+		}()
+	}
+
+	//gzip data is written to file only on flush so flush it periodically
+	var flushTicks <-chan time.Time
+	if options.Gzip {
+		logger.gzipWriter = logging.NewGzipWriter(writer)
+		logger.writer = logger.gzipWriter
+
+		flushInterval := options.GzipFlushInterval
+		if flushInterval <= 0 {
+			flushInterval = DefaultGzipFlushInterval
+		}
+		flushTicker := time.NewTicker(flushInterval)
+		flushTicks = flushTicker.C
+		go func() {
+			<-logger.done
+			flushTicker.Stop()
+		}()
+	}
+
+	//partial batch is written periodically
+	var batchTicks <-chan time.Time
+	if logger.batchSize > 1 {
+		batchInterval := options.BatchInterval
+		if batchInterval <= 0 {
+			batchInterval = DefaultBatchInterval
+		}
+		batchTicker := time.NewTicker(batchInterval)
+		batchTicks = batchTicker.C
+		go func() {
+			<-logger.done
+			batchTicker.Stop()
+		}()
+	}
 
-			buf := bytes.NewBuffer(bts)
-			buf.Write([]byte("\n"))
+	if logger.queue != nil {
+		go logger.writeQueued()
+		return logger
+	}
 
-			if _, err := logger.writer.Write(buf.Bytes()); err != nil {
-				log.Printf("Error writing event to log file: %v", err)
-				continue
+	go func() {
+		defer close(logger.done)
+		//partial batch is written on Close()
+		defer logger.writeBatch()
+		for !logger.aborted() {
+			select {
+			case fact := <-logger.logCh:
+				logger.write(fact)
+			case <-batchTicks:
+				logger.writeBatch()
+			case <-logger.rotateTicks:
+				logger.rotate()
+			case <-flushTicks:
+				logger.writeBatch()
+				if err := logger.gzipWriter.Flush(); err != nil {
+					logger.logger.Error("Error flushing gzip event log", "error", err)
+				}
+			case <-logger.closed:
+				//drain events which are left in the channel
+				for !logger.aborted() {
+					select {
+					case fact := <-logger.logCh:
+						logger.write(fact)
+					default:
+						return
+					}
+				}
+				return
 			}
 		}
 	}()
 
 	return logger
 }
+
+//Return true if writing goroutine has been aborted on close timeout
+func (al *AsyncLogger) aborted() bool {
+	select {
+	case <-al.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+//Write the batch and buffered gzip data to the current file and rotate it. Is called only in writing goroutine
+func (al *AsyncLogger) rotate() {
+	al.writeBatch()
+	if al.gzipWriter != nil {
+		if err := al.gzipWriter.Flush(); err != nil {
+			al.logger.Error("Error flushing gzip event log", "error", err)
+		}
+	}
+
+	if err := al.rotator.Rotate(); err != nil {
+		al.logger.Error("Error rotating event log", "error", err)
+	}
+}
+
+//Marshal fact and add it to the batch. Write the batch if it is full
+//Return writer error if the batch has been written and failed (facts which can't be marshaled are skipped)
+func (al *AsyncLogger) write(fact Fact) error {
+	if al.metrics != nil {
+		al.metrics.QueueLen.Set(float64(al.QueueLen()))
+	}
+
+	bts, err := al.serializer.Marshal(fact)
+	if err != nil {
+		atomic.AddUint64(&al.marshalErrors, 1)
+		if al.metrics != nil {
+			al.metrics.MarshalErrors.Inc()
+		}
+		al.logger.Error("Error marshaling event", "error", err)
+		return nil
+	}
+
+	if al.showInGlobalLogger {
+		prettyJsonBytes, _ := json.MarshalIndent(&fact, " ", " ")
+		log.Println(string(prettyJsonBytes))
+	}
+
+	al.batch.Write(bts)
+	al.batch.Write(al.separator)
+	al.batchedEvents++
+
+	if al.batchedEvents >= al.batchSize {
+		return al.writeBatch()
+	}
+
+	return nil
+}
+
+//Write all batched events with one Write() call so they won't be split between files
+func (al *AsyncLogger) writeBatch() error {
+	if al.batchedEvents == 0 {
+		return nil
+	}
+
+	_, err := al.writer.Write(al.batch.Bytes())
+	if err != nil {
+		atomic.AddUint64(&al.writeErrors, uint64(al.batchedEvents))
+		if al.metrics != nil {
+			al.metrics.WriteErrors.Add(float64(al.batchedEvents))
+		}
+		al.logger.Error("Error writing events to log file", "events", al.batchedEvents, "error", err)
+	} else {
+		atomic.AddUint64(&al.written, uint64(al.batchedEvents))
+		if al.metrics != nil {
+			al.metrics.Written.Add(float64(al.batchedEvents))
+		}
+	}
+	al.batch.Reset()
+	al.batchedEvents = 0
+
+	al.writeErrMutex.Lock()
+	al.writeErr = err
+	al.writeErrMutex.Unlock()
+
+	return err
+}
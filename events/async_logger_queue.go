@@ -0,0 +1,138 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/joncrlsn/dque"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	eventsPerQueueSegment = 1000
+	//how often the head of the persistent queue is written again after writer error
+	queuedWriteRetryInterval = time.Second
+)
+
+//persistedFact is a persistent queue record of AsyncLogger (gob encoded by dque)
+type persistedFact struct {
+	FactBytes []byte
+}
+
+//persistedFactBuilder is used by dque when segment of the queue is loaded from disk
+func persistedFactBuilder() interface{} {
+	return &persistedFact{}
+}
+
+//NewPersistentAsyncLogger create AsyncLogger which keeps consumed events in persistent queue queueDir/queueName
+//instead of in-memory channel. Every event is removed from the queue only after it has been written to writer:
+//events which haven't been written before crash or Close() are written after restart (at-least-once).
+//Events are written one by one (BatchSize is ignored) and OverflowPolicy isn't applied. Gzip isn't supported:
+//compressed data is buffered in memory
+func NewPersistentAsyncLogger(writer io.WriteCloser, queueDir, queueName string, options AsyncLoggerOptions) (*AsyncLogger, error) {
+	if options.Gzip {
+		return nil, errors.New("Async logger persistent queue can't be used with gzip")
+	}
+
+	queue, err := dque.NewOrOpen(queueName, queueDir, eventsPerQueueSegment, persistedFactBuilder)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening/creating async logger queue %s: %v", queueName, err)
+	}
+
+	options.BatchSize = 0
+	return newAsyncLogger(writer, options, queue), nil
+}
+
+//Put fact to the persistent queue and notify writing goroutine
+func (al *AsyncLogger) enqueue(fact Fact) error {
+	factBytes, err := json.Marshal(fact)
+	if err != nil {
+		atomic.AddUint64(&al.marshalErrors, 1)
+		if al.metrics != nil {
+			al.metrics.MarshalErrors.Inc()
+		}
+		al.logger.Error("Error marshaling event", "error", err)
+		return err
+	}
+
+	if err := al.queue.Enqueue(persistedFact{FactBytes: factBytes}); err != nil {
+		al.logger.Error("Error putting event to async logger queue", "error", err)
+		return fmt.Errorf("Error putting event to async logger queue: %v", err)
+	}
+
+	select {
+	case al.queued <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+//Write events from the persistent queue until logger is closed. Events which haven't been written in close timeout
+//stay in the queue
+func (al *AsyncLogger) writeQueued() {
+	defer close(al.done)
+	for !al.aborted() {
+		select {
+		case <-al.closed:
+			for !al.aborted() && al.writeHead() {
+			}
+			return
+		case <-al.rotateTicks:
+			al.rotate()
+		default:
+		}
+
+		if al.writeHead() {
+			continue
+		}
+
+		select {
+		case <-al.queued:
+		case <-al.rotateTicks:
+			al.rotate()
+		case <-time.After(queuedWriteRetryInterval):
+		case <-al.closed:
+		}
+	}
+}
+
+//Write the head of the persistent queue and remove it from the queue if it has been written
+//(or can't be written at all e.g. corrupted record). Return false if queue is empty or write failed
+func (al *AsyncLogger) writeHead() bool {
+	iface, err := al.queue.Peek()
+	if err == dque.ErrEmpty {
+		return false
+	}
+	if err != nil {
+		al.logger.Error("Error reading event from async logger queue", "error", err)
+		return false
+	}
+
+	//dque returns objects enqueued in this process as is and objects loaded from disk as persistedFactBuilder results
+	var record persistedFact
+	switch v := iface.(type) {
+	case persistedFact:
+		record = v
+	case *persistedFact:
+		if v != nil {
+			record = *v
+		}
+	}
+
+	fact := Fact{}
+	if err := DecodeJSON(record.FactBytes, &fact); err != nil {
+		al.logger.Error("Error unmarshalling event from async logger queue. It will be skipped", "error", err)
+	} else if err := al.write(fact); err != nil {
+		return false
+	}
+
+	if _, err := al.queue.Dequeue(); err != nil {
+		al.logger.Error("Error removing written event from async logger queue", "error", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,379 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bufferWriter struct {
+	bytes.Buffer
+}
+
+func (bw *bufferWriter) Close() error {
+	return nil
+}
+
+//failingWriter fails every write after the first failAfter ones
+type failingWriter struct {
+	bufferWriter
+	failAfter int
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.failAfter <= 0 {
+		return 0, errors.New("disk is full")
+	}
+	fw.failAfter--
+	return fw.bufferWriter.Write(p)
+}
+
+//blockingFileWriter blocks every write until unblock is closed
+type blockingFileWriter struct {
+	bufferWriter
+	unblock chan struct{}
+}
+
+func (bw *blockingFileWriter) Write(p []byte) (int, error) {
+	<-bw.unblock
+	return bw.bufferWriter.Write(p)
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      OverflowPolicy
+		expectedError string
+	}{
+		{"Empty", "", Block, ""},
+		{"Block", "block", Block, ""},
+		{"Drop newest", "drop_newest", DropNewest, ""},
+		{"Drop oldest in upper case", "DROP_OLDEST", DropOldest, ""},
+		{"Unknown", "drop_all", Block, "Unknown overflow policy: drop_all. Supported: block, drop_newest, drop_oldest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseOverflowPolicy(tt.value)
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestAsyncLoggerOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          OverflowPolicy
+		expectedErr     error
+		expectedOutput  string
+		expectedDropped uint64
+	}{
+		{
+			"Block until ctx is done",
+			Block,
+			context.DeadlineExceeded,
+			"{\"key\":0}\n{\"key\":1}\n{\"key\":2}\n",
+			0,
+		},
+		{
+			"Drop newest",
+			DropNewest,
+			ErrEventDropped,
+			"{\"key\":0}\n{\"key\":1}\n{\"key\":2}\n",
+			3,
+		},
+		{
+			"Drop oldest",
+			DropOldest,
+			nil,
+			"{\"key\":0}\n{\"key\":4}\n{\"key\":5}\n",
+			3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &blockingFileWriter{unblock: make(chan struct{})}
+			logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{BufferSize: 2, OverflowPolicy: tt.policy})
+
+			//the first event is taken by the writing goroutine which is blocked on Write
+			require.NoError(t, logger.ConsumeCtx(context.Background(), Fact{"key": 0}))
+			require.Eventually(t, func() bool { return logger.QueueLen() == 0 }, time.Second, time.Millisecond)
+			require.NoError(t, logger.ConsumeCtx(context.Background(), Fact{"key": 1}))
+			require.NoError(t, logger.ConsumeCtx(context.Background(), Fact{"key": 2}))
+
+			//channel is full
+			for i := 3; i < 6; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				err := logger.ConsumeCtx(ctx, Fact{"key": i})
+				cancel()
+				require.Equal(t, tt.expectedErr, err)
+			}
+			require.Equal(t, 2, logger.QueueLen())
+
+			close(writer.unblock)
+			require.NoError(t, logger.Close())
+			require.Equal(t, tt.expectedOutput, writer.String())
+			require.Equal(t, tt.expectedDropped, logger.Dropped())
+			require.Equal(t, uint64(3), logger.Stats().Written)
+		})
+	}
+}
+
+func TestAsyncLoggerBlock(t *testing.T) {
+	writer := &blockingFileWriter{unblock: make(chan struct{})}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{BufferSize: 1})
+
+	logger.Consume(Fact{"key": 0})
+	require.Eventually(t, func() bool { return logger.QueueLen() == 0 }, time.Second, time.Millisecond)
+	logger.Consume(Fact{"key": 1})
+
+	consumed := make(chan struct{})
+	go func() {
+		logger.Consume(Fact{"key": 2})
+		close(consumed)
+	}()
+	select {
+	case <-consumed:
+		t.Fatal("Consume must be blocked while channel is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(writer.unblock)
+	select {
+	case <-consumed:
+	case <-time.After(time.Second):
+		t.Fatal("Consume must continue when there is room in the channel")
+	}
+	require.NoError(t, logger.Close())
+	require.Equal(t, "{\"key\":0}\n{\"key\":1}\n{\"key\":2}\n", writer.String())
+	require.Equal(t, uint64(0), logger.Dropped())
+}
+
+func TestAsyncLoggerClose(t *testing.T) {
+	writer := &bufferWriter{}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{})
+	for i := 0; i < 100; i++ {
+		logger.Consume(Fact{"key": i})
+	}
+	require.NoError(t, logger.Close())
+	require.Equal(t, 100, bytes.Count(writer.Bytes(), []byte("\n")), "All buffered events must be written on Close")
+
+	//events aren't accepted after Close
+	logger.Consume(Fact{"key": 100})
+	require.Equal(t, 0, logger.QueueLen())
+	require.NoError(t, logger.Close())
+}
+
+func TestAsyncLoggerCloseTimeout(t *testing.T) {
+	writer := &blockingFileWriter{unblock: make(chan struct{})}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{CloseTimeout: 50 * time.Millisecond})
+	for i := 0; i < 3; i++ {
+		logger.Consume(Fact{"key": i})
+	}
+	require.Eventually(t, func() bool { return logger.QueueLen() == 2 }, time.Second, time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- logger.Close()
+	}()
+
+	//writer isn't closed while it is being written
+	select {
+	case <-closed:
+		t.Fatal("Close mustn't return before the current write is finished")
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	close(writer.unblock)
+	select {
+	case err := <-closed:
+		require.EqualError(t, err, "Error closing async logger: 2 events haven't been written in 50ms")
+	case <-time.After(time.Second):
+		t.Fatal("Close must return after the current write")
+	}
+	//writing goroutine is aborted: buffered events aren't written after close timeout
+	require.Equal(t, "{\"key\":0}\n", writer.String())
+}
+
+//lockedBufferWriter is bufferWriter which may be read concurrently with writing goroutine
+type lockedBufferWriter struct {
+	mutex sync.Mutex
+	bufferWriter
+}
+
+func (lbw *lockedBufferWriter) Write(p []byte) (int, error) {
+	lbw.mutex.Lock()
+	defer lbw.mutex.Unlock()
+	return lbw.bufferWriter.Write(p)
+}
+
+func (lbw *lockedBufferWriter) Len() int {
+	lbw.mutex.Lock()
+	defer lbw.mutex.Unlock()
+	return lbw.bufferWriter.Len()
+}
+
+func TestAsyncLoggerGzip(t *testing.T) {
+	writer := &lockedBufferWriter{}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{Gzip: true, GzipFlushInterval: 10 * time.Millisecond})
+	logger.Consume(Fact{"key": "value1"})
+
+	//compressed data is flushed periodically
+	require.Eventually(t, func() bool { return writer.Len() > 0 }, time.Second, 5*time.Millisecond)
+
+	logger.Consume(Fact{"key": "value2"})
+	logger.Consume(Fact{"key": "value3"})
+	require.NoError(t, logger.Close())
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(writer.Bytes()))
+	require.NoError(t, err)
+	output, err := ioutil.ReadAll(gzipReader)
+	require.NoError(t, err)
+	require.Equal(t, "{\"key\":\"value1\"}\n{\"key\":\"value2\"}\n{\"key\":\"value3\"}\n", string(output),
+		"Output must be decompressed to all events")
+}
+
+//rotatingWriter keeps every file in a separate buffer. It isn't safe for concurrent use:
+//Write and Rotate must be called only by the writing goroutine
+type rotatingWriter struct {
+	files     []*bytes.Buffer
+	interval  time.Duration
+	scheduled bool
+}
+
+func (rw *rotatingWriter) Write(p []byte) (int, error) {
+	return rw.files[len(rw.files)-1].Write(p)
+}
+
+func (rw *rotatingWriter) Close() error {
+	return nil
+}
+
+func (rw *rotatingWriter) RotationInterval() time.Duration {
+	return rw.interval
+}
+
+func (rw *rotatingWriter) DisableScheduledRotation() {
+	rw.scheduled = false
+}
+
+func (rw *rotatingWriter) Rotate() error {
+	rw.files = append(rw.files, &bytes.Buffer{})
+	return nil
+}
+
+func TestAsyncLoggerRotation(t *testing.T) {
+	writer := &rotatingWriter{files: []*bytes.Buffer{{}}, interval: 10 * time.Millisecond, scheduled: true}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{BatchSize: 7, BatchInterval: 3 * time.Millisecond})
+	require.False(t, writer.scheduled, "Writer own rotation must be disabled")
+
+	sent := 0
+	for deadline := time.Now().Add(100 * time.Millisecond); time.Now().Before(deadline); sent++ {
+		logger.Consume(Fact{"key": sent})
+	}
+	require.NoError(t, logger.Close())
+
+	require.True(t, len(writer.files) >= 3, "Several rotations are expected")
+	lines := 0
+	for _, file := range writer.files {
+		content := file.Bytes()
+		if len(content) == 0 {
+			continue
+		}
+		require.Equal(t, byte('\n'), content[len(content)-1], "Every file must contain whole events")
+		for _, line := range bytes.Split(content[:len(content)-1], []byte("\n")) {
+			fact := Fact{}
+			require.NoError(t, DecodeJSON(line, &fact))
+			lines++
+		}
+	}
+	require.Equal(t, sent, lines)
+	require.Equal(t, uint64(sent), logger.Stats().Written)
+}
+
+func TestAsyncLoggerDropNewestError(t *testing.T) {
+	writer := &blockingFileWriter{unblock: make(chan struct{})}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{BufferSize: 1, OverflowPolicy: DropNewest})
+
+	//the first event may be taken by the blocked writing goroutine
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = logger.ConsumeCtx(context.Background(), Fact{"key": i})
+	}
+	require.Equal(t, ErrEventDropped, err, "Skipped event must be reported to the caller")
+	require.Equal(t, uint64(1), logger.Dropped())
+
+	close(writer.unblock)
+	require.NoError(t, logger.Close())
+}
+
+func TestAsyncLoggerStats(t *testing.T) {
+	writer := &failingWriter{failAfter: 2}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{})
+	logger.Consume(Fact{"key": "value1"})
+	logger.Consume(Fact{"key": make(chan int)})
+	logger.Consume(Fact{"key": "value2"})
+	logger.Consume(Fact{"key": "value3"})
+	require.NoError(t, logger.Close())
+
+	require.Equal(t, AsyncLoggerStats{Written: 2, MarshalErrors: 1, WriteErrors: 1}, logger.Stats())
+}
+
+func TestAsyncLoggerLineSeparatorAndBOM(t *testing.T) {
+	writer := &bufferWriter{}
+	logger := NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{LineSeparator: "\r\n", BOM: true})
+	logger.Consume(Fact{"key": "value1"})
+	logger.Consume(Fact{"key": "value2"})
+	require.NoError(t, logger.Close())
+
+	output := writer.Bytes()
+	require.Equal(t, "\xEF\xBB\xBF{\"key\":\"value1\"}\r\n{\"key\":\"value2\"}\r\n", string(output))
+
+	//the first line with BOM and CRLF line endings are readable
+	for _, line := range bytes.SplitAfter(output, []byte("\n"))[:2] {
+		fact := Fact{}
+		require.NoError(t, DecodeJSON(line, &fact))
+		require.Contains(t, fact, "key")
+	}
+}
+
+func TestPersistentAsyncLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "async_logger_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	failingWriter := &failingWriter{failAfter: 1}
+	logger, err := NewPersistentAsyncLogger(failingWriter, dir, "event-test", AsyncLoggerOptions{CloseTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	logger.Consume(Fact{"key": "value1"})
+	logger.Consume(Fact{"key": "value2"})
+	logger.Consume(Fact{"key": "value3"})
+	require.Eventually(t, func() bool { return logger.Stats().WriteErrors > 0 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, logger.Close())
+	require.Equal(t, "{\"key\":\"value1\"}\n", failingWriter.String())
+	require.Equal(t, 2, logger.QueueLen(), "Not written events must be kept in the queue")
+
+	//restart: kept events are written
+	writer := &bufferWriter{}
+	logger, err = NewPersistentAsyncLogger(writer, dir, "event-test", AsyncLoggerOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return logger.Stats().Written == 2 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, logger.Close())
+	require.Equal(t, "{\"key\":\"value2\"}\n{\"key\":\"value3\"}\n", writer.String())
+
+	_, err = NewPersistentAsyncLogger(writer, dir, "event-test", AsyncLoggerOptions{Gzip: true})
+	require.Error(t, err)
+}
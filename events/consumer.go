@@ -1,12 +1,48 @@
 package events
 
 import (
+	"context"
 	"io"
+	"strings"
 )
 
 type Fact map[string]interface{}
 
+//Get return value by JSON path e.g. /eventn_ctx/user_agent or nil if it doesn't exist
+func (f Fact) Get(path string) interface{} {
+	var current interface{} = map[string]interface{}(f)
+	for _, key := range strings.Split(strings.Trim(path, "/"), "/") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+
+	return current
+}
+
 type Consumer interface {
 	io.Closer
 	Consume(fact Fact)
 }
+
+//ContextConsumer is implemented by consumers which may block or fail on Consume (e.g. full channel or queue, disk is full)
+//ConsumeCtx returns when fact is accepted or ctx is done (with ctx.Err()) instead of blocking forever
+//and returns error if fact hasn't been accepted so callers can apply own error handling (e.g. HTTP status)
+//Consume of such consumers is ConsumeCtx with context.Background() and the error is only logged by the consumer
+type ContextConsumer interface {
+	Consumer
+	ConsumeCtx(ctx context.Context, fact Fact) error
+}
+
+//ConsumeCtx pass fact to consumer ConsumeCtx() or to Consume() if consumer doesn't implement ContextConsumer
+//Is used by consumer wrappers for passing context to underlying consumers
+func ConsumeCtx(ctx context.Context, consumer Consumer, fact Fact) error {
+	if cc, ok := consumer.(ContextConsumer); ok {
+		return cc.ConsumeCtx(ctx, fact)
+	}
+
+	consumer.Consume(fact)
+	return nil
+}
@@ -0,0 +1,7 @@
+package events
+
+//DeadLetterer is implemented by consumers with dead-letter queue (e.g. streaming storages)
+//Is used by consumer wrappers for putting rejected facts there instead of skipping
+type DeadLetterer interface {
+	DeadLetter(fact Fact, reason error)
+}
@@ -0,0 +1,19 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+//UTF8BOM is written at the beginning of event log files if AsyncLoggerOptions.BOM is set
+var UTF8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+//DecodeJSON unmarshal json bytes into v with numbers as json.Number
+//so big integers (e.g. ids) aren't rounded like float64 values
+//Leading UTF8BOM (the first line of event log file) is skipped
+func DecodeJSON(b []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(b, UTF8BOM)))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
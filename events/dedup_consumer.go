@@ -0,0 +1,148 @@
+package events
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//DefaultDedupKeyField is used if dedup key field isn't configured
+const DefaultDedupKeyField = "/eventn_ctx/event_id"
+
+//DedupConsumer skips facts with key field value (e.g. event_id of retried HTTP delivery) which has been passed
+//to underlying consumer within the window: the last windowSize keys (LRU) which have been seen less than
+//windowTTL ago (if configured). Facts without key are passed. Skipped facts are counted
+//Keys are kept in memory so duplicates aren't detected across restarts and instances
+type DedupConsumer struct {
+	consumer   Consumer
+	keyField   string
+	windowSize int
+	//0 - keys are evicted only by windowSize
+	windowTTL time.Duration
+
+	mutex sync.Mutex
+	//most recently seen keys are at the front
+	order *list.List
+	keys  map[string]*list.Element
+
+	duplicates uint64
+}
+
+type dedupEntry struct {
+	key string
+	//time of the first passed fact with the key
+	seen time.Time
+}
+
+//NewDedupConsumer return DedupConsumer with key field path (e.g. /eventn_ctx/event_id), max count of kept keys
+//and optional keys TTL
+func NewDedupConsumer(consumer Consumer, keyField string, windowSize int, windowTTL time.Duration) (*DedupConsumer, error) {
+	if strings.TrimSpace(keyField) == "" {
+		return nil, errors.New("Dedup key field can't be empty")
+	}
+	if windowSize <= 0 {
+		return nil, errors.New("Dedup window size must be positive")
+	}
+	if windowTTL < 0 {
+		return nil, errors.New("Dedup window time can't be negative")
+	}
+
+	return &DedupConsumer{
+		consumer:   consumer,
+		keyField:   strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(keyField), "/"), "/", "_")),
+		windowSize: windowSize,
+		windowTTL:  windowTTL,
+		order:      list.New(),
+		keys:       map[string]*list.Element{},
+	}, nil
+}
+
+//Consume pass fact to underlying consumer if it isn't a duplicate
+func (dc *DedupConsumer) Consume(fact Fact) {
+	dc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to underlying consumer if it isn't a duplicate (see events.ConsumeCtx)
+//Key is forgotten if underlying consumer returns error so the retried fact isn't skipped
+func (dc *DedupConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	flatObject := map[string]string{}
+	flattenFact("", fact, flatObject)
+	key, ok := flatObject[dc.keyField]
+	if !ok || key == "" {
+		return ConsumeCtx(ctx, dc.consumer, fact)
+	}
+
+	if !dc.remember(key, time.Now()) {
+		atomic.AddUint64(&dc.duplicates, 1)
+		return nil
+	}
+
+	if err := ConsumeCtx(ctx, dc.consumer, fact); err != nil {
+		dc.forget(key)
+		return err
+	}
+
+	return nil
+}
+
+//Return false if key is in the window. Otherwise put key to the window and evict the oldest keys
+func (dc *DedupConsumer) remember(key string, now time.Time) bool {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	if element, ok := dc.keys[key]; ok {
+		entry := element.Value.(*dedupEntry)
+		if dc.windowTTL == 0 || now.Sub(entry.seen) < dc.windowTTL {
+			dc.order.MoveToFront(element)
+			return false
+		}
+		dc.order.Remove(element)
+		delete(dc.keys, key)
+	}
+
+	dc.keys[key] = dc.order.PushFront(&dedupEntry{key: key, seen: now})
+	for dc.order.Len() > dc.windowSize {
+		dc.removeElement(dc.order.Back())
+	}
+	if dc.windowTTL > 0 {
+		//entries aren't ordered by seen time (duplicates are moved to the front) so only expired back ones are evicted here
+		for back := dc.order.Back(); back != nil && now.Sub(back.Value.(*dedupEntry).seen) >= dc.windowTTL; back = dc.order.Back() {
+			dc.removeElement(back)
+		}
+	}
+
+	return true
+}
+
+func (dc *DedupConsumer) forget(key string) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	if element, ok := dc.keys[key]; ok {
+		dc.removeElement(element)
+	}
+}
+
+func (dc *DedupConsumer) removeElement(element *list.Element) {
+	dc.order.Remove(element)
+	delete(dc.keys, element.Value.(*dedupEntry).key)
+}
+
+//Duplicates return count of skipped facts
+func (dc *DedupConsumer) Duplicates() uint64 {
+	return atomic.LoadUint64(&dc.duplicates)
+}
+
+//Health return underlying consumer health
+func (dc *DedupConsumer) Health() error {
+	return CheckHealth(dc.consumer)
+}
+
+//Close underlying consumer
+func (dc *DedupConsumer) Close() error {
+	return dc.consumer.Close()
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDedupConsumer(t *testing.T) {
+	underlying := &recordingConsumer{}
+	dc, err := NewDedupConsumer(underlying, DefaultDedupKeyField, 2, 0)
+	require.NoError(t, err)
+
+	fact := func(eventId string) Fact {
+		return Fact{"eventn_ctx": map[string]interface{}{"event_id": eventId}}
+	}
+	dc.Consume(fact("1"))
+	dc.Consume(fact("1"))
+	dc.Consume(fact("2"))
+	dc.Consume(Fact{"id": "without key"})
+	dc.Consume(Fact{"id": "without key"})
+	//1 is the most recently seen key after duplicate so 2 is evicted
+	dc.Consume(fact("1"))
+	dc.Consume(fact("3"))
+	dc.Consume(fact("2"))
+
+	require.Equal(t, []Fact{fact("1"), fact("2"), {"id": "without key"}, {"id": "without key"}, fact("3"), fact("2")}, underlying.facts)
+	require.Equal(t, uint64(2), dc.Duplicates())
+
+	//rejected fact is passed again on retry
+	underlying.reject = true
+	require.Error(t, dc.ConsumeCtx(context.Background(), fact("4")))
+	underlying.reject = false
+	require.NoError(t, dc.ConsumeCtx(context.Background(), fact("4")))
+	require.Equal(t, fact("4"), underlying.facts[len(underlying.facts)-1])
+	require.Equal(t, uint64(2), dc.Duplicates())
+}
+
+func TestDedupWindowTime(t *testing.T) {
+	dc, err := NewDedupConsumer(&recordingConsumer{}, "/id", 10, time.Minute)
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.True(t, dc.remember("1", now))
+	require.False(t, dc.remember("1", now.Add(30*time.Second)))
+	require.True(t, dc.remember("2", now.Add(50*time.Second)))
+	require.True(t, dc.remember("1", now.Add(time.Minute)), "Expired key must be passed")
+	require.False(t, dc.remember("1", now.Add(90*time.Second)))
+	require.True(t, dc.remember("3", now.Add(115*time.Second)))
+	require.Equal(t, 2, dc.order.Len(), "Expired keys must be evicted")
+
+	_, err = NewDedupConsumer(&recordingConsumer{}, "", 10, 0)
+	require.Error(t, err)
+	_, err = NewDedupConsumer(&recordingConsumer{}, "/id", 0, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var filterOperators = []string{"==", "!=", "=~", "!~"}
+
+//FilterRule compares flatten fact field value with constant value or regexp
+type FilterRule struct {
+	field    string
+	operator string
+	value    string
+	regexp   *regexp.Regexp
+}
+
+//FilterConsumer passes to underlying consumer only facts which match all rules
+//Not matched facts are counted
+type FilterConsumer struct {
+	consumer Consumer
+	rules    []*FilterRule
+	filtered uint64
+}
+
+//NewFilterConsumer return FilterConsumer with parsed rules in format: /field/subfield operator value
+//where operator is one of: == (equal), != (not equal), =~ (match regexp), !~ (doesn't match regexp)
+//e.g. /event_type != heartbeat or /eventn_ctx/user_agent !~ "(?i)bot"
+//If there are no rules consumer is returned as is
+func NewFilterConsumer(consumer Consumer, rules []string) (Consumer, error) {
+	if len(rules) == 0 {
+		return consumer, nil
+	}
+
+	fc := &FilterConsumer{consumer: consumer}
+	for _, rule := range rules {
+		parsed, err := parseFilterRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		fc.rules = append(fc.rules, parsed)
+	}
+
+	return fc, nil
+}
+
+func parseFilterRule(rule string) (*FilterRule, error) {
+	//the first operator in the rule divides field and value (value may contain operators e.g. in regexp)
+	operator := ""
+	position := -1
+	for _, op := range filterOperators {
+		if i := strings.Index(rule, op); i >= 0 && (position == -1 || i < position) {
+			operator = op
+			position = i
+		}
+	}
+	if position == -1 {
+		return nil, fmt.Errorf("Malformed filter rule [%s]. Use format: /field1/subfield1 operator value where operator is one of: %s", rule, strings.Join(filterOperators, ", "))
+	}
+
+	field := strings.TrimSpace(rule[:position])
+	if field == "" {
+		return nil, fmt.Errorf("Malformed filter rule [%s]: field can't be empty", rule)
+	}
+	field = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(field, "/"), "/", "_"))
+
+	value := strings.TrimSpace(rule[position+len(operator):])
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+
+	parsed := &FilterRule{field: field, operator: operator, value: value}
+	if operator == "=~" || operator == "!~" {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed filter rule [%s] regexp: %v", rule, err)
+		}
+		parsed.regexp = re
+	}
+
+	return parsed, nil
+}
+
+//Match return true if flatten object field satisfies the rule
+//Not existing field is equal to empty string
+func (fr *FilterRule) Match(flatObject map[string]string) bool {
+	value := flatObject[fr.field]
+	switch fr.operator {
+	case "==":
+		return value == fr.value
+	case "!=":
+		return value != fr.value
+	case "=~":
+		return fr.regexp.MatchString(value)
+	case "!~":
+		return !fr.regexp.MatchString(value)
+	default:
+		return false
+	}
+}
+
+//Consume pass fact to underlying consumer if it matches all rules
+func (fc *FilterConsumer) Consume(fact Fact) {
+	fc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to underlying consumer if it matches all rules (see events.ConsumeCtx)
+func (fc *FilterConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	flatObject := map[string]string{}
+	flattenFact("", fact, flatObject)
+	for _, rule := range fc.rules {
+		if !rule.Match(flatObject) {
+			atomic.AddUint64(&fc.filtered, 1)
+			return nil
+		}
+	}
+
+	return ConsumeCtx(ctx, fc.consumer, fact)
+}
+
+//Filtered return count of facts which weren't passed to underlying consumer
+func (fc *FilterConsumer) Filtered() uint64 {
+	return atomic.LoadUint64(&fc.filtered)
+}
+
+//Health return underlying consumer health
+func (fc *FilterConsumer) Health() error {
+	return CheckHealth(fc.consumer)
+}
+
+//Close underlying consumer
+func (fc *FilterConsumer) Close() error {
+	return fc.consumer.Close()
+}
+
+//Flatten fact into destination with lowercase keys joined with '_' and string values
+//(the same way as schema.Processor does). Floats are formatted without exponent: 1e+06 -> 1000000
+func flattenFact(key string, value interface{}, destination map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, subValue := range v {
+			newKey := strings.ToLower(k)
+			if key != "" {
+				newKey = key + "_" + newKey
+			}
+			flattenFact(newKey, subValue, destination)
+		}
+	case Fact:
+		flattenFact(key, map[string]interface{}(v), destination)
+	case float64:
+		destination[key] = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		destination[key] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case nil:
+	default:
+		destination[key] = fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,210 @@
+package events
+
+import (
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestParseFilterRule(t *testing.T) {
+	tests := []struct {
+		name          string
+		rule          string
+		expected      *FilterRule
+		expectedError string
+	}{
+		{
+			"Equal",
+			"/event_type == heartbeat",
+			&FilterRule{field: "event_type", operator: "==", value: "heartbeat"},
+			"",
+		},
+		{
+			"Nested field is flatten and lowercased",
+			"/eventn_ctx/User_Agent != curl",
+			&FilterRule{field: "eventn_ctx_user_agent", operator: "!=", value: "curl"},
+			"",
+		},
+		{
+			"Quoted value keeps spaces",
+			`/page_title == " Home page "`,
+			&FilterRule{field: "page_title", operator: "==", value: " Home page "},
+			"",
+		},
+		{
+			"The first operator divides field and value",
+			`/url =~ "^https?://a==b"`,
+			&FilterRule{field: "url", operator: "=~", value: "^https?://a==b", regexp: regexp.MustCompile("^https?://a==b")},
+			"",
+		},
+		{
+			"Not match regexp",
+			"/eventn_ctx/user_agent !~ (?i)bot",
+			&FilterRule{field: "eventn_ctx_user_agent", operator: "!~", value: "(?i)bot", regexp: regexp.MustCompile("(?i)bot")},
+			"",
+		},
+		{
+			"Empty value",
+			"/user_id ==",
+			&FilterRule{field: "user_id", operator: "==", value: ""},
+			"",
+		},
+		{
+			"Without operator",
+			"/event_type heartbeat",
+			nil,
+			"Malformed filter rule [/event_type heartbeat]. Use format: /field1/subfield1 operator value where operator is one of: ==, !=, =~, !~",
+		},
+		{
+			"Empty field",
+			" == heartbeat",
+			nil,
+			"Malformed filter rule [ == heartbeat]: field can't be empty",
+		},
+		{
+			"Malformed regexp",
+			"/url =~ (",
+			nil,
+			"Malformed filter rule [/url =~ (] regexp: error parsing regexp: missing closing ): `(`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseFilterRule(tt.rule)
+			if tt.expectedError != "" {
+				require.EqualError(t, err, tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestFilterRuleMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		fact     Fact
+		expected bool
+	}{
+		{
+			"Equal string",
+			"/event_type == heartbeat",
+			Fact{"event_type": "heartbeat"},
+			true,
+		},
+		{
+			"Not equal string",
+			"/event_type != heartbeat",
+			Fact{"event_type": "heartbeat"},
+			false,
+		},
+		{
+			"Nested field",
+			"/eventn_ctx/user_agent == curl",
+			Fact{"eventn_ctx": map[string]interface{}{"User_Agent": "curl"}},
+			true,
+		},
+		{
+			"Not existing field is equal to empty string",
+			"/user_id ==",
+			Fact{"event_type": "heartbeat"},
+			true,
+		},
+		{
+			"Not existing field isn't equal to value",
+			"/user_id != 42",
+			Fact{},
+			true,
+		},
+		{
+			"Integer",
+			"/count == 42",
+			Fact{"count": 42},
+			true,
+		},
+		{
+			"Float from json",
+			"/count == 42",
+			Fact{"count": float64(42)},
+			true,
+		},
+		{
+			"Big float from json isn't formatted with exponent",
+			"/count == 1000000",
+			Fact{"count": float64(1000000)},
+			true,
+		},
+		{
+			"Fractional float",
+			"/price == 10.5",
+			Fact{"price": 10.5},
+			true,
+		},
+		{
+			"Float32",
+			"/price == 0.1",
+			Fact{"price": float32(0.1)},
+			true,
+		},
+		{
+			"Bool",
+			"/enabled == true",
+			Fact{"enabled": true},
+			true,
+		},
+		{
+			"Match regexp",
+			"/eventn_ctx/user_agent =~ (?i)bot",
+			Fact{"eventn_ctx": map[string]interface{}{"user_agent": "Googlebot/2.1"}},
+			true,
+		},
+		{
+			"Not match regexp",
+			"/eventn_ctx/user_agent !~ (?i)bot",
+			Fact{"eventn_ctx": map[string]interface{}{"user_agent": "Googlebot/2.1"}},
+			false,
+		},
+		{
+			"Regexp matches number",
+			"/count =~ ^1[0-9]{6}$",
+			Fact{"count": float64(1500000)},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseFilterRule(tt.rule)
+			require.NoError(t, err)
+
+			flatObject := map[string]string{}
+			flattenFact("", tt.fact, flatObject)
+			require.Equal(t, tt.expected, rule.Match(flatObject))
+		})
+	}
+}
+
+func TestFilterConsumer(t *testing.T) {
+	consumer := &recordingConsumer{}
+	fc, err := NewFilterConsumer(consumer, []string{"/event_type != heartbeat", "/eventn_ctx/user_agent !~ (?i)bot"})
+	require.NoError(t, err)
+
+	facts := []Fact{
+		{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user_agent": "Mozilla/5.0"}},
+		{"event_type": "heartbeat", "eventn_ctx": map[string]interface{}{"user_agent": "Mozilla/5.0"}},
+		{"event_type": "pageview", "eventn_ctx": map[string]interface{}{"user_agent": "Googlebot/2.1"}},
+		{"event_type": "click"},
+	}
+	for _, fact := range facts {
+		fc.Consume(fact)
+	}
+
+	require.Equal(t, []Fact{facts[0], facts[3]}, consumer.facts)
+	require.Equal(t, uint64(2), fc.(*FilterConsumer).Filtered())
+
+	same, err := NewFilterConsumer(consumer, nil)
+	require.NoError(t, err)
+	require.Equal(t, consumer, same, "Consumer without rules must be returned as is")
+}
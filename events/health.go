@@ -0,0 +1,17 @@
+package events
+
+//HealthChecker is implemented by consumers which can report their liveness (e.g. destination connection and queue state)
+type HealthChecker interface {
+	//Health return nil if consumer is able to accept and store events
+	Health() error
+}
+
+//CheckHealth return consumer Health() result or nil if consumer doesn't implement HealthChecker
+//Is used by consumer wrappers for passing health checks to underlying consumers
+func CheckHealth(consumer Consumer) error {
+	if hc, ok := consumer.(HealthChecker); ok {
+		return hc.Health()
+	}
+
+	return nil
+}
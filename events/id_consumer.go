@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	//HashIdStrategy generates the same id for events with the same values of hash fields
+	HashIdStrategy = "hash"
+	//RandomIdStrategy generates random UUID
+	RandomIdStrategy = "random"
+)
+
+//IdConsumer stamps generated ids on facts without id field (missing, null or empty) and passes them to underlying consumer
+//Hash strategy id is a name based (SHA-1) UUID of hash fields values so retried deliveries of the same event get the same id
+//and can be deduplicated. Random UUID is generated if the strategy is random or none of hash fields exist in the fact
+type IdConsumer struct {
+	consumer   Consumer
+	idField    string
+	strategy   string
+	hashFields []string
+
+	generated uint64
+}
+
+//NewIdConsumer return IdConsumer with id field path (e.g. /eventn_ctx/event_id), strategy (hash or random) and
+//hash fields paths (e.g. /user/id, /timestamp). Hash fields are required by hash strategy
+func NewIdConsumer(consumer Consumer, idField, strategy string, hashFields []string) (*IdConsumer, error) {
+	idField = strings.Trim(strings.TrimSpace(idField), "/")
+	if idField == "" {
+		return nil, errors.New("Event id field can't be empty")
+	}
+
+	ic := &IdConsumer{consumer: consumer, idField: idField, strategy: strings.ToLower(strings.TrimSpace(strategy))}
+	switch ic.strategy {
+	case HashIdStrategy:
+		for _, field := range hashFields {
+			if field = strings.TrimSpace(field); field != "" {
+				ic.hashFields = append(ic.hashFields, field)
+			}
+		}
+		if len(ic.hashFields) == 0 {
+			return nil, fmt.Errorf("Event id %s strategy requires at least one hash field", HashIdStrategy)
+		}
+	case RandomIdStrategy:
+	default:
+		return nil, fmt.Errorf("Unsupported event id strategy: %s. Supported: %s, %s", strategy, HashIdStrategy, RandomIdStrategy)
+	}
+
+	return ic, nil
+}
+
+//Consume stamp id on fact if it doesn't have one and pass it to underlying consumer
+func (ic *IdConsumer) Consume(fact Fact) {
+	ic.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx stamp id on fact if it doesn't have one and pass it with ctx to underlying consumer (see events.ConsumeCtx)
+//Fact (and objects on id field path) is copied because the same fact is passed to other consumers
+func (ic *IdConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	if id := fact.Get(ic.idField); id != nil && id != "" {
+		return ConsumeCtx(ctx, ic.consumer, fact)
+	}
+
+	atomic.AddUint64(&ic.generated, 1)
+	return ConsumeCtx(ctx, ic.consumer, withValue(fact, ic.idField, ic.generate(fact)))
+}
+
+//Return hash based UUID of hash fields values or random UUID
+func (ic *IdConsumer) generate(fact Fact) string {
+	if ic.strategy == HashIdStrategy {
+		values := make([]interface{}, len(ic.hashFields))
+		found := false
+		for i, field := range ic.hashFields {
+			values[i] = fact.Get(field)
+			found = found || values[i] != nil
+		}
+		if found {
+			//map keys are sorted by json.Marshal so equal values give equal bytes
+			if b, err := json.Marshal(values); err == nil {
+				return uuid.NewSHA1(uuid.NameSpaceOID, b).String()
+			}
+		}
+	}
+
+	return uuid.New().String()
+}
+
+//Generated return count of facts with generated ids
+func (ic *IdConsumer) Generated() uint64 {
+	return atomic.LoadUint64(&ic.generated)
+}
+
+//Health return underlying consumer health
+func (ic *IdConsumer) Health() error {
+	return CheckHealth(ic.consumer)
+}
+
+//Close underlying consumer
+func (ic *IdConsumer) Close() error {
+	return ic.consumer.Close()
+}
+
+//Return copy of fact with value by path (without leading and trailing /). Objects on the path are copied or created
+//Not object values on the path are replaced
+func withValue(fact Fact, path string, value interface{}) Fact {
+	copied := Fact{}
+	for k, v := range fact {
+		copied[k] = v
+	}
+
+	keys := strings.Split(path, "/")
+	current := map[string]interface{}(copied)
+	for _, key := range keys[:len(keys)-1] {
+		object := map[string]interface{}{}
+		if existing, ok := current[key].(map[string]interface{}); ok {
+			for k, v := range existing {
+				object[k] = v
+			}
+		}
+		current[key] = object
+		current = object
+	}
+	current[keys[len(keys)-1]] = value
+
+	return copied
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIdConsumer(t *testing.T) {
+	underlying := &recordingConsumer{}
+	ic, err := NewIdConsumer(underlying, DefaultDedupKeyField, HashIdStrategy, []string{"/user/id", "/timestamp"})
+	require.NoError(t, err)
+
+	withId := Fact{"eventn_ctx": map[string]interface{}{"event_id": "1"}, "user": map[string]interface{}{"id": "u1"}}
+	ic.Consume(withId)
+	original := Fact{"eventn_ctx": map[string]interface{}{"ip": "1.1.1.1"}, "user": map[string]interface{}{"id": "u1"}, "timestamp": "t1"}
+	ic.Consume(original)
+	ic.Consume(Fact{"eventn_ctx": map[string]interface{}{"event_id": ""}, "user": map[string]interface{}{"id": "u1"}, "timestamp": "t1"})
+	ic.Consume(Fact{"user": map[string]interface{}{"id": "u2"}, "timestamp": "t1"})
+	ic.Consume(Fact{"page": "/"})
+	ic.Consume(Fact{"page": "/"})
+
+	require.Equal(t, 6, len(underlying.facts))
+	require.Equal(t, withId, underlying.facts[0], "Fact with id must be passed as is")
+	require.Equal(t, Fact{"eventn_ctx": map[string]interface{}{"ip": "1.1.1.1"}, "user": map[string]interface{}{"id": "u1"}, "timestamp": "t1"},
+		original, "Original fact mustn't be modified")
+
+	ids := []string{"1"}
+	for _, fact := range underlying.facts[1:] {
+		id, ok := fact.Get(DefaultDedupKeyField).(string)
+		require.True(t, ok)
+		require.Len(t, id, 36)
+		ids = append(ids, id)
+	}
+	require.Equal(t, "1.1.1.1", underlying.facts[1].Get("/eventn_ctx/ip"))
+	require.Equal(t, ids[1], ids[2], "Facts with the same hash fields must get the same id")
+	require.NotEqual(t, ids[1], ids[3])
+	require.NotEqual(t, ids[4], ids[5], "Facts without hash fields must get random ids")
+	require.Equal(t, uint64(5), ic.Generated())
+}
+
+func TestIdConsumerConfig(t *testing.T) {
+	ic, err := NewIdConsumer(&recordingConsumer{}, "/id", "Random", nil)
+	require.NoError(t, err)
+	require.Equal(t, RandomIdStrategy, ic.strategy)
+
+	_, err = NewIdConsumer(&recordingConsumer{}, "", RandomIdStrategy, nil)
+	require.Error(t, err)
+	_, err = NewIdConsumer(&recordingConsumer{}, "/id", HashIdStrategy, []string{" "})
+	require.Error(t, err)
+	_, err = NewIdConsumer(&recordingConsumer{}, "/id", "sequence", nil)
+	require.Error(t, err)
+}
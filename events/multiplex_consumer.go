@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"log"
+)
+
+//FactFilter return true if fact must be passed to the consumer
+type FactFilter func(fact Fact) bool
+
+//MultiplexConsumer passes every fact to all underlying consumers (destinations)
+//which filters accept the fact
+type MultiplexConsumer struct {
+	routes []*route
+}
+
+type route struct {
+	consumer Consumer
+	filter   FactFilter
+}
+
+//NewMultiplexConsumer return MultiplexConsumer which passes all facts to all consumers
+func NewMultiplexConsumer(consumers ...Consumer) *MultiplexConsumer {
+	mc := &MultiplexConsumer{}
+	for _, consumer := range consumers {
+		mc.AddConsumer(consumer, nil)
+	}
+
+	return mc
+}
+
+//AddConsumer add destination consumer. Facts are passed to it only if filter returns true (nil filter accepts all facts)
+func (mc *MultiplexConsumer) AddConsumer(consumer Consumer, filter FactFilter) {
+	mc.routes = append(mc.routes, &route{consumer: consumer, filter: filter})
+}
+
+//Consume pass fact to every accepting consumer (see ConsumeCtx)
+func (mc *MultiplexConsumer) Consume(fact Fact) {
+	mc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to every accepting consumer (see events.ConsumeCtx)
+//Panic or error in one consumer doesn't prevent passing fact to others. Return all consumers errors
+func (mc *MultiplexConsumer) ConsumeCtx(ctx context.Context, fact Fact) (multiErr error) {
+	for _, r := range mc.routes {
+		if r.filter != nil && !r.filter(fact) {
+			continue
+		}
+		if err := consumeSafely(ctx, r.consumer, fact); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
+
+//Health return all unhealthy underlying consumers errors
+func (mc *MultiplexConsumer) Health() (multiErr error) {
+	for _, r := range mc.routes {
+		if err := CheckHealth(r.consumer); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
+
+//Close all underlying consumers and return all errors
+func (mc *MultiplexConsumer) Close() (multiErr error) {
+	for _, r := range mc.routes {
+		if err := r.consumer.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return
+}
+
+func consumeSafely(ctx context.Context, consumer Consumer, fact Fact) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Consumer %T panic while consuming fact %v: %v", consumer, fact, r)
+			log.Println("System error:", err)
+		}
+	}()
+
+	return ConsumeCtx(ctx, consumer, fact)
+}
@@ -0,0 +1,208 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//DropRateLimitPolicy skip over-limit facts (ConsumeCtx returns ErrRateLimited)
+	DropRateLimitPolicy = "drop"
+	//BlockRateLimitPolicy delay over-limit facts until the key has tokens or ctx is done
+	BlockRateLimitPolicy = "block"
+	//DeadLetterRateLimitPolicy put over-limit facts to the dead-letter queue (low-priority path which may be replayed)
+	DeadLetterRateLimitPolicy = "dead_letter"
+
+	//how often buckets which have been refilled completely are removed
+	rateLimitCleanupInterval = time.Minute
+)
+
+//ErrRateLimited is returned by RateLimitConsumer.ConsumeCtx if fact has been skipped because its key exceeds the rate limit
+var ErrRateLimited = errors.New("Event has been dropped: rate limit of its source is exceeded")
+
+//RateLimitConsumer passes to underlying consumer not more than rate facts per second (with bursts up to burst facts)
+//of every key field value (e.g. api key) so one noisy source doesn't starve others. Facts without key share one limit
+//Over-limit facts are counted and skipped, delayed or put to the dead-letter queue according to the policy
+//Limits are kept in memory per instance (token bucket per key)
+type RateLimitConsumer struct {
+	consumer   Consumer
+	deadLetter DeadLetterer
+	keyField   string
+	rate       float64
+	burst      float64
+	policy     string
+
+	mutex       sync.Mutex
+	buckets     map[string]*tokenBucket
+	cleanedUpAt time.Time
+
+	limited uint64
+}
+
+type tokenBucket struct {
+	//may be negative: tokens are reserved by delayed facts (block policy)
+	tokens    float64
+	updatedAt time.Time
+}
+
+//NewRateLimitConsumer return RateLimitConsumer with optional key field path (e.g. /api_key), rate of facts per second
+//per key, burst (rate rounded up by default) and policy (drop, block or dead_letter)
+//deadLetter is required by dead_letter policy (e.g. storage with masking consumer, see events.DeadLetterer)
+func NewRateLimitConsumer(consumer Consumer, deadLetter DeadLetterer, keyField string, rate float64, burst int, policy string) (*RateLimitConsumer, error) {
+	if rate <= 0 {
+		return nil, errors.New("Rate limit rate must be positive")
+	}
+	if burst < 0 {
+		return nil, errors.New("Rate limit burst must be positive")
+	}
+	if burst == 0 {
+		burst = int(math.Ceil(rate))
+	}
+	switch policy {
+	case "":
+		policy = DropRateLimitPolicy
+	case DropRateLimitPolicy, BlockRateLimitPolicy:
+	case DeadLetterRateLimitPolicy:
+		if deadLetter == nil {
+			return nil, errors.New("Rate limit dead_letter policy requires consumer with dead-letter queue")
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported rate limit policy: %s. Supported: %s, %s, %s", policy, DropRateLimitPolicy, BlockRateLimitPolicy, DeadLetterRateLimitPolicy)
+	}
+
+	return &RateLimitConsumer{
+		consumer:    consumer,
+		deadLetter:  deadLetter,
+		keyField:    strings.TrimSpace(keyField),
+		rate:        rate,
+		burst:       float64(burst),
+		policy:      policy,
+		buckets:     map[string]*tokenBucket{},
+		cleanedUpAt: time.Now(),
+	}, nil
+}
+
+//Consume pass fact to underlying consumer if its key hasn't exceeded the limit (see ConsumeCtx)
+func (rlc *RateLimitConsumer) Consume(fact Fact) {
+	rlc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to underlying consumer if its key hasn't exceeded the limit (see events.ConsumeCtx)
+//Over-limit fact: return ErrRateLimited (drop policy), wait for a token (block policy, return ctx.Err() if ctx is done)
+//or put fact to the dead-letter queue (dead_letter policy)
+func (rlc *RateLimitConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	key := rlc.key(fact)
+	delay, allowed := rlc.take(key, time.Now())
+	if allowed && delay == 0 {
+		return ConsumeCtx(ctx, rlc.consumer, fact)
+	}
+
+	atomic.AddUint64(&rlc.limited, 1)
+	switch rlc.policy {
+	case BlockRateLimitPolicy:
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return ConsumeCtx(ctx, rlc.consumer, fact)
+		case <-ctx.Done():
+			rlc.giveBack(key)
+			return ctx.Err()
+		}
+	case DeadLetterRateLimitPolicy:
+		rlc.deadLetter.DeadLetter(fact, ErrRateLimited)
+		return nil
+	default:
+		return ErrRateLimited
+	}
+}
+
+//Return key field value as string or empty string if key field isn't configured or fact doesn't have it
+func (rlc *RateLimitConsumer) key(fact Fact) string {
+	if rlc.keyField == "" {
+		return ""
+	}
+	value := fact.Get(rlc.keyField)
+	if value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+//Take a token of the key. Return true if there is a token. Block policy reserves a token anyway and returns
+//delay after which the reserved token is available
+func (rlc *RateLimitConsumer) take(key string, now time.Time) (time.Duration, bool) {
+	rlc.mutex.Lock()
+	defer rlc.mutex.Unlock()
+
+	if now.Sub(rlc.cleanedUpAt) >= rateLimitCleanupInterval {
+		rlc.cleanUp(now)
+	}
+
+	bucket, ok := rlc.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rlc.burst, updatedAt: now}
+		rlc.buckets[key] = bucket
+	}
+	rlc.refill(bucket, now)
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, true
+	}
+	if rlc.policy != BlockRateLimitPolicy {
+		return 0, false
+	}
+
+	bucket.tokens--
+	return time.Duration(-bucket.tokens / rlc.rate * float64(time.Second)), true
+}
+
+//Return token reserved by delayed fact which hasn't been passed
+func (rlc *RateLimitConsumer) giveBack(key string) {
+	rlc.mutex.Lock()
+	defer rlc.mutex.Unlock()
+
+	if bucket, ok := rlc.buckets[key]; ok {
+		bucket.tokens++
+	}
+}
+
+//Add tokens for time since the last update. Must be called under mutex
+func (rlc *RateLimitConsumer) refill(bucket *tokenBucket, now time.Time) {
+	bucket.tokens = math.Min(rlc.burst, bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*rlc.rate)
+	bucket.updatedAt = now
+}
+
+//Remove full buckets: they are the same as new ones. Must be called under mutex
+func (rlc *RateLimitConsumer) cleanUp(now time.Time) {
+	for key, bucket := range rlc.buckets {
+		rlc.refill(bucket, now)
+		if bucket.tokens >= rlc.burst {
+			delete(rlc.buckets, key)
+		}
+	}
+	rlc.cleanedUpAt = now
+}
+
+//Limited return count of over-limit facts (skipped, delayed or put to the dead-letter queue)
+func (rlc *RateLimitConsumer) Limited() uint64 {
+	return atomic.LoadUint64(&rlc.limited)
+}
+
+//Health return underlying consumer health
+func (rlc *RateLimitConsumer) Health() error {
+	return CheckHealth(rlc.consumer)
+}
+
+//Close underlying consumer
+func (rlc *RateLimitConsumer) Close() error {
+	return rlc.consumer.Close()
+}
@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+//recordingDeadLetterer records dead-lettered facts
+type recordingDeadLetterer struct {
+	facts []Fact
+}
+
+func (rdl *recordingDeadLetterer) DeadLetter(fact Fact, reason error) {
+	rdl.facts = append(rdl.facts, fact)
+}
+
+func TestRateLimitConsumer(t *testing.T) {
+	underlying := &recordingConsumer{}
+	rlc, err := NewRateLimitConsumer(underlying, nil, "/api_key", 0.01, 2, "")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, rlc.ConsumeCtx(ctx, Fact{"api_key": "a", "id": 1}))
+	require.NoError(t, rlc.ConsumeCtx(ctx, Fact{"api_key": "a", "id": 2}))
+	require.Equal(t, ErrRateLimited, rlc.ConsumeCtx(ctx, Fact{"api_key": "a", "id": 3}))
+	require.NoError(t, rlc.ConsumeCtx(ctx, Fact{"api_key": "b", "id": 4}), "Other keys have own limits")
+	require.NoError(t, rlc.ConsumeCtx(ctx, Fact{"id": 5}))
+	require.NoError(t, rlc.ConsumeCtx(ctx, Fact{"id": 6}))
+	require.Equal(t, ErrRateLimited, rlc.ConsumeCtx(ctx, Fact{"id": 7}), "Facts without key share one limit")
+
+	require.Equal(t, []Fact{{"api_key": "a", "id": 1}, {"api_key": "a", "id": 2}, {"api_key": "b", "id": 4}, {"id": 5}, {"id": 6}}, underlying.facts)
+	require.Equal(t, uint64(2), rlc.Limited())
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	rlc, err := NewRateLimitConsumer(&recordingConsumer{}, nil, "/api_key", 2, 0, DropRateLimitPolicy)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), rlc.burst, "Burst must be rate by default")
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		_, allowed := rlc.take("a", now)
+		require.True(t, allowed)
+	}
+	_, allowed := rlc.take("a", now)
+	require.False(t, allowed)
+	_, allowed = rlc.take("a", now.Add(500*time.Millisecond))
+	require.True(t, allowed, "Token must be added after 1/rate seconds")
+	_, allowed = rlc.take("a", now.Add(500*time.Millisecond))
+	require.False(t, allowed)
+
+	//full buckets are removed
+	_, allowed = rlc.take("b", now.Add(time.Minute-100*time.Millisecond))
+	require.True(t, allowed)
+	rlc.take("c", now.Add(time.Minute))
+	require.Equal(t, 2, len(rlc.buckets), "Only not full buckets must be kept")
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	deadLetterer := &recordingDeadLetterer{}
+	underlying := &recordingConsumer{}
+	rlc, err := NewRateLimitConsumer(underlying, deadLetterer, "/api_key", 0.01, 1, DeadLetterRateLimitPolicy)
+	require.NoError(t, err)
+	require.NoError(t, rlc.ConsumeCtx(context.Background(), Fact{"api_key": "a", "id": 1}))
+	require.NoError(t, rlc.ConsumeCtx(context.Background(), Fact{"api_key": "a", "id": 2}))
+	require.Equal(t, []Fact{{"api_key": "a", "id": 1}}, underlying.facts)
+	require.Equal(t, []Fact{{"api_key": "a", "id": 2}}, deadLetterer.facts)
+
+	//block: the second fact waits for 1/rate seconds
+	underlying = &recordingConsumer{}
+	rlc, err = NewRateLimitConsumer(underlying, nil, "", 50, 1, BlockRateLimitPolicy)
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, rlc.ConsumeCtx(context.Background(), Fact{"id": 1}))
+	require.NoError(t, rlc.ConsumeCtx(context.Background(), Fact{"id": 2}))
+	require.True(t, time.Since(start) >= 15*time.Millisecond, "Over-limit fact must be delayed")
+	require.Equal(t, 2, len(underlying.facts))
+
+	//block: ctx is done before token is available
+	rlc, err = NewRateLimitConsumer(&recordingConsumer{}, nil, "", 0.01, 1, BlockRateLimitPolicy)
+	require.NoError(t, err)
+	require.NoError(t, rlc.ConsumeCtx(context.Background(), Fact{"id": 1}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, rlc.ConsumeCtx(ctx, Fact{"id": 2}))
+	require.True(t, rlc.buckets[""].tokens > -1, "Reserved token must be returned")
+	require.Equal(t, uint64(1), rlc.Limited())
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	_, err := NewRateLimitConsumer(&recordingConsumer{}, nil, "", 0, 1, "")
+	require.Error(t, err)
+	_, err = NewRateLimitConsumer(&recordingConsumer{}, nil, "", 1, -1, "")
+	require.Error(t, err)
+	_, err = NewRateLimitConsumer(&recordingConsumer{}, nil, "", 1, 1, "queue")
+	require.Error(t, err)
+	_, err = NewRateLimitConsumer(&recordingConsumer{}, nil, "", 1, 1, DeadLetterRateLimitPolicy)
+	require.Error(t, err, "dead_letter policy requires dead-letter queue")
+}
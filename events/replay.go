@@ -0,0 +1,127 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/ksensehq/eventnative/logging"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+//ReplayOptions configures Replay
+type ReplayOptions struct {
+	//max count of consumed facts per second. 0 - unlimited
+	RatePerSecond int
+	//Progress is called every ProgressEvery lines and once after the last line. 0 - isn't called
+	ProgressEvery int64
+	Progress      func(stats ReplayStats)
+}
+
+//ReplayStats is a Replay progress
+type ReplayStats struct {
+	//read lines (without empty ones)
+	Lines int64
+	//facts which have been accepted by consumer
+	Consumed int64
+	//lines which aren't json objects. They are skipped
+	Malformed int64
+	//facts which have been rejected by consumer (e.g. queue is full, see ConsumeCtx)
+	Failed int64
+}
+
+//Replay read newline-delimited json facts (e.g. AsyncLogger files) and pass them to consumer (e.g. streaming storage)
+//Malformed lines are skipped and counted. Return stats and error if reader fails or ctx is done
+func Replay(ctx context.Context, reader io.Reader, consumer Consumer, options ReplayOptions) (ReplayStats, error) {
+	stats := ReplayStats{}
+	logger := logging.DefaultLogger()
+
+	var interval time.Duration
+	if options.RatePerSecond > 0 {
+		interval = time.Second / time.Duration(options.RatePerSecond)
+	}
+	next := time.Now()
+
+	//bufio.Reader isn't limited by line size unlike bufio.Scanner
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, readErr := bufReader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return stats, fmt.Errorf("Error reading facts: %v", readErr)
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			stats.Lines++
+
+			fact := Fact{}
+			if err := DecodeJSON(line, &fact); err != nil {
+				stats.Malformed++
+				logger.Warn("Malformed line is skipped", "line", stats.Lines, "error", err)
+			} else {
+				if interval > 0 {
+					if wait := time.Until(next); wait > 0 {
+						select {
+						case <-ctx.Done():
+							return stats, ctx.Err()
+						case <-time.After(wait):
+						}
+					}
+					next = next.Add(interval)
+					//don't burst after a slow consumer
+					if now := time.Now(); next.Before(now) {
+						next = now
+					}
+				}
+
+				if err := ConsumeCtx(ctx, consumer, fact); err != nil {
+					stats.Failed++
+				} else {
+					stats.Consumed++
+				}
+			}
+
+			if options.Progress != nil && options.ProgressEvery > 0 && stats.Lines%options.ProgressEvery == 0 {
+				options.Progress(stats)
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if options.Progress != nil && options.ProgressEvery > 0 {
+		options.Progress(stats)
+	}
+
+	return stats, nil
+}
+
+//ReplayFile replay facts from AsyncLogger file (see Replay). Files with .gz extension are decompressed
+func ReplayFile(ctx context.Context, filePath string, consumer Consumer, options ReplayOptions) (ReplayStats, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ReplayStats{}, fmt.Errorf("Error opening file %s: %v", filePath, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(filePath, ".gz") {
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return ReplayStats{}, fmt.Errorf("Error reading gzip file %s: %v", filePath, err)
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	return Replay(ctx, reader, consumer, options)
+}
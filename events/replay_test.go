@@ -0,0 +1,110 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingConsumer struct {
+	facts  []Fact
+	reject bool
+}
+
+func (rc *recordingConsumer) Consume(fact Fact) {
+	rc.ConsumeCtx(context.Background(), fact)
+}
+
+func (rc *recordingConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	if rc.reject {
+		return errors.New("Queue is full")
+	}
+	rc.facts = append(rc.facts, fact)
+	return nil
+}
+
+func (rc *recordingConsumer) Close() error {
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	input := `{"event_type":"page_view","id":1234567890123456789}
+not a json
+
+{"event_type":"click"}
+["array"]
+{"event_type":"last_without_newline"}`
+
+	tests := []struct {
+		name          string
+		reject        bool
+		expectedStats ReplayStats
+	}{
+		{"malformed lines are skipped", false, ReplayStats{Lines: 5, Consumed: 3, Malformed: 2}},
+		{"rejected facts are counted", true, ReplayStats{Lines: 5, Failed: 3, Malformed: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := &recordingConsumer{reject: tt.reject}
+			var progress []ReplayStats
+			stats, err := Replay(context.Background(), strings.NewReader(input), consumer,
+				ReplayOptions{ProgressEvery: 2, Progress: func(stats ReplayStats) { progress = append(progress, stats) }})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedStats, stats)
+			//every 2 lines and the final one
+			require.Len(t, progress, 3)
+			require.Equal(t, tt.expectedStats, progress[2])
+
+			if !tt.reject {
+				require.Len(t, consumer.facts, 3)
+				require.Equal(t, json.Number("1234567890123456789"), consumer.facts[0]["id"], "Big numbers must not be rounded")
+				require.Equal(t, "last_without_newline", consumer.facts[2]["event_type"])
+			}
+		})
+	}
+}
+
+func TestReplayRateLimit(t *testing.T) {
+	input := strings.Repeat(`{"event_type":"page_view"}`+"\n", 5)
+	consumer := &recordingConsumer{}
+
+	started := time.Now()
+	stats, err := Replay(context.Background(), strings.NewReader(input), consumer, ReplayOptions{RatePerSecond: 50})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stats.Consumed)
+	//the first fact isn't delayed, 4 others - 20ms each
+	require.True(t, time.Since(started) >= 80*time.Millisecond, "Rate limit isn't applied")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Replay(ctx, strings.NewReader(input), &recordingConsumer{}, ReplayOptions{RatePerSecond: 1})
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestReplayFileGzip(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "replay_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(`{"event_type":"page_view"}` + "\n"))
+	gz.Close()
+	filePath := filepath.Join(tmpDir, "events-2020-08-02T18-23-58.gz")
+	require.NoError(t, ioutil.WriteFile(filePath, buf.Bytes(), 0644))
+
+	consumer := &recordingConsumer{}
+	stats, err := ReplayFile(context.Background(), filePath, consumer, ReplayOptions{})
+	require.NoError(t, err)
+	require.Equal(t, ReplayStats{Lines: 1, Consumed: 1}, stats)
+	require.Equal(t, "page_view", consumer.facts[0]["event_type"])
+}
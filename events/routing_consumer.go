@@ -0,0 +1,220 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/logging"
+	"strings"
+	"sync"
+	"time"
+)
+
+//failed tenant consumer creating is retried on facts of the tenant not earlier than after this delay
+const defaultTenantRetryDelay = time.Minute
+
+//TenantConsumerFactory return consumer (e.g. storage with tenant credentials) of the lowercase tenant
+//or nil consumer if tenant is unknown
+type TenantConsumerFactory func(tenant string) (Consumer, error)
+
+//tenantConsumer is created once: concurrent facts of the tenant wait for creating, facts of other tenants don't
+type tenantConsumer struct {
+	//closed when creating is finished
+	ready    chan struct{}
+	consumer Consumer
+	err      error
+	//creating isn't retried before retry delay after failure
+	failedAt time.Time
+}
+
+//RoutingConsumer passes every fact to the consumer of the fact tenant (lowercase value of tenant field)
+//Tenant consumers are created with factory on the first fact of the tenant and are reused afterwards
+//Facts without tenant field, of unknown tenants or of tenants which consumers can't be created
+//are passed to the default (quarantine) consumer. Failed creating is retried after retry delay
+type RoutingConsumer struct {
+	tenantField     string
+	factory         TenantConsumerFactory
+	defaultConsumer Consumer
+	logger          logging.Logger
+	retryDelay      time.Duration
+
+	mutex sync.RWMutex
+	//lowercase tenant -> consumer. nil consumer - unknown tenant (default consumer is used)
+	consumers map[string]*tenantConsumer
+	closed    bool
+}
+
+//NewRoutingConsumer return RoutingConsumer with tenant field path (e.g. /eventn_ctx/tenant_id)
+func NewRoutingConsumer(tenantField string, factory TenantConsumerFactory, defaultConsumer Consumer) *RoutingConsumer {
+	return &RoutingConsumer{
+		tenantField:     tenantField,
+		factory:         factory,
+		defaultConsumer: defaultConsumer,
+		logger:          logging.DefaultLogger().With("consumer", "routing", "tenant_field", tenantField),
+		retryDelay:      defaultTenantRetryDelay,
+		consumers:       map[string]*tenantConsumer{},
+	}
+}
+
+//Consume pass fact to the tenant consumer (see ConsumeCtx)
+func (rc *RoutingConsumer) Consume(fact Fact) {
+	rc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to the tenant consumer or to the default one (see events.ConsumeCtx)
+//If the tenant consumer is being created, fact waits for it until ctx is done
+func (rc *RoutingConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	return ConsumeCtx(ctx, rc.route(ctx, fact), fact)
+}
+
+//DeadLetter put fact to the dead-letter queue of the tenant consumer (if it has one, see events.DeadLetterer)
+func (rc *RoutingConsumer) DeadLetter(fact Fact, reason error) {
+	if dl, ok := rc.route(context.Background(), fact).(DeadLetterer); ok {
+		dl.DeadLetter(fact, reason)
+	}
+}
+
+//Return consumer of the fact tenant. Create it on the first fact of the tenant (or after retry delay if creating
+//has failed). Consumer is created without holding the lock so slow creating doesn't block facts of other tenants
+func (rc *RoutingConsumer) route(ctx context.Context, fact Fact) Consumer {
+	tenant := rc.tenant(fact)
+	if tenant == "" {
+		return rc.defaultConsumer
+	}
+
+	rc.mutex.RLock()
+	tc, ok := rc.consumers[tenant]
+	rc.mutex.RUnlock()
+	if !ok || tc.retryable(rc.retryDelay) {
+		rc.mutex.Lock()
+		if rc.closed {
+			rc.mutex.Unlock()
+			return rc.defaultConsumer
+		}
+		//created (or being created) by concurrent call
+		tc, ok = rc.consumers[tenant]
+		if !ok || tc.retryable(rc.retryDelay) {
+			tc = &tenantConsumer{ready: make(chan struct{})}
+			rc.consumers[tenant] = tc
+			rc.mutex.Unlock()
+			rc.create(tenant, tc)
+		} else {
+			rc.mutex.Unlock()
+		}
+	}
+
+	select {
+	case <-tc.ready:
+	case <-ctx.Done():
+		return rc.defaultConsumer
+	}
+	if tc.err != nil || tc.consumer == nil {
+		return rc.defaultConsumer
+	}
+
+	return tc.consumer
+}
+
+//Create tenant consumer and mark it ready. Consumer created after Close() is closed at once
+func (rc *RoutingConsumer) create(tenant string, tc *tenantConsumer) {
+	consumer, err := rc.factory(tenant)
+	if err != nil {
+		rc.logger.Error("Error creating tenant consumer. Facts will be passed to the default consumer until retry", "tenant", tenant,
+			"retry_delay", rc.retryDelay, "error", err)
+		tc.err = err
+		tc.failedAt = time.Now()
+	} else if consumer != nil {
+		rc.logger.Info("Tenant consumer has been created", "tenant", tenant)
+		tc.consumer = consumer
+	}
+
+	rc.mutex.Lock()
+	closed := rc.closed
+	close(tc.ready)
+	rc.mutex.Unlock()
+	if closed && tc.consumer != nil {
+		tc.consumer.Close()
+	}
+}
+
+//Return true if creating has failed and retry delay has passed
+func (tc *tenantConsumer) retryable(retryDelay time.Duration) bool {
+	select {
+	case <-tc.ready:
+		return tc.err != nil && time.Since(tc.failedAt) >= retryDelay
+	default:
+		return false
+	}
+}
+
+//Return created consumer or nil if it hasn't been created (yet)
+func (tc *tenantConsumer) created() Consumer {
+	select {
+	case <-tc.ready:
+		return tc.consumer
+	default:
+		return nil
+	}
+}
+
+//Return lowercase string value of tenant field or "" if it doesn't exist
+func (rc *RoutingConsumer) tenant(fact Fact) string {
+	value := fact.Get(rc.tenantField)
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.ToLower(v)
+	case map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return strings.ToLower(fmt.Sprint(v))
+	}
+}
+
+//Health return all unhealthy tenant and default consumers errors
+func (rc *RoutingConsumer) Health() (multiErr error) {
+	if err := CheckHealth(rc.defaultConsumer); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	for tenant, tc := range rc.consumers {
+		consumer := tc.created()
+		if consumer == nil {
+			continue
+		}
+		if err := CheckHealth(consumer); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Tenant %s: %v", tenant, err))
+		}
+	}
+
+	return
+}
+
+//Close all created tenant consumers and the default one. Return all errors
+func (rc *RoutingConsumer) Close() (multiErr error) {
+	rc.mutex.Lock()
+	rc.closed = true
+	//consumers which are being created are closed after creating (see create)
+	var consumers []Consumer
+	for _, tc := range rc.consumers {
+		if consumer := tc.created(); consumer != nil {
+			consumers = append(consumers, consumer)
+		}
+	}
+	rc.consumers = map[string]*tenantConsumer{}
+	rc.mutex.Unlock()
+
+	for _, consumer := range consumers {
+		if err := consumer.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	if err := rc.defaultConsumer.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+
+	return
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRoutingConsumer(t *testing.T) {
+	defaultConsumer := &recordingConsumer{}
+	tenantConsumers := map[string]*recordingConsumer{}
+	created := map[string]int{}
+	factory := func(tenant string) (Consumer, error) {
+		created[tenant]++
+		switch tenant {
+		case "acme", "42":
+			consumer := &recordingConsumer{}
+			tenantConsumers[tenant] = consumer
+			return consumer, nil
+		case "broken":
+			return nil, errors.New("Connection refused")
+		default:
+			return nil, nil
+		}
+	}
+
+	rc := NewRoutingConsumer("/eventn_ctx/tenant", factory, defaultConsumer)
+	facts := []Fact{
+		{"eventn_ctx": map[string]interface{}{"tenant": "acme"}, "id": "1"},
+		{"eventn_ctx": map[string]interface{}{"tenant": "acme"}, "id": "2"},
+		{"eventn_ctx": map[string]interface{}{"tenant": float64(42)}, "id": "3"},
+		{"eventn_ctx": map[string]interface{}{"tenant": "unknown"}, "id": "4"},
+		{"eventn_ctx": map[string]interface{}{"tenant": "unknown"}, "id": "5"},
+		{"eventn_ctx": map[string]interface{}{"tenant": "broken"}, "id": "6"},
+		{"eventn_ctx": map[string]interface{}{"tenant": "broken"}, "id": "7"},
+		{"id": "8"},
+	}
+	for _, fact := range facts {
+		rc.Consume(fact)
+	}
+
+	require.Equal(t, []Fact{facts[0], facts[1]}, tenantConsumers["acme"].facts)
+	require.Equal(t, []Fact{facts[2]}, tenantConsumers["42"].facts)
+	require.Equal(t, []Fact{facts[3], facts[4], facts[5], facts[6], facts[7]}, defaultConsumer.facts)
+	require.Equal(t, map[string]int{"acme": 1, "42": 1, "unknown": 1, "broken": 1}, created,
+		"Consumers must be created once. Failed creating mustn't be retried before retry delay")
+
+	//retry delay has passed
+	rc.retryDelay = 0
+	rc.Consume(Fact{"eventn_ctx": map[string]interface{}{"tenant": "broken"}, "id": "9"})
+	rc.Consume(Fact{"eventn_ctx": map[string]interface{}{"tenant": "broken"}, "id": "10"})
+	require.Equal(t, 3, created["broken"], "Failed creating must be retried after retry delay")
+	require.NoError(t, rc.Close())
+}
+
+func TestRoutingConsumerTenantCase(t *testing.T) {
+	consumer := &recordingConsumer{}
+	var tenants []string
+	factory := func(tenant string) (Consumer, error) {
+		tenants = append(tenants, tenant)
+		return consumer, nil
+	}
+
+	rc := NewRoutingConsumer("/tenant", factory, &recordingConsumer{})
+	facts := []Fact{{"tenant": "Acme", "id": "1"}, {"tenant": "acme", "id": "2"}, {"tenant": "ACME", "id": "3"}}
+	for _, fact := range facts {
+		rc.Consume(fact)
+	}
+
+	require.Equal(t, []string{"acme"}, tenants, "Tenant consumer must be created once for lowercase tenant")
+	require.Equal(t, facts, consumer.facts)
+	require.NoError(t, rc.Close())
+}
+
+func TestRoutingConsumerSlowTenant(t *testing.T) {
+	creating := make(chan struct{})
+	unblock := make(chan struct{})
+	fastConsumer := &recordingConsumer{}
+	factory := func(tenant string) (Consumer, error) {
+		if tenant == "slow" {
+			close(creating)
+			<-unblock
+			return &recordingConsumer{}, nil
+		}
+		return fastConsumer, nil
+	}
+
+	rc := NewRoutingConsumer("/tenant", factory, &recordingConsumer{})
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		rc.Consume(Fact{"tenant": "slow"})
+	}()
+	<-creating
+
+	fastDone := make(chan struct{})
+	go func() {
+		defer close(fastDone)
+		rc.Consume(Fact{"tenant": "fast"})
+	}()
+	select {
+	case <-fastDone:
+	case <-time.After(time.Second):
+		require.Fail(t, "Slow creating of one tenant consumer mustn't block other tenants")
+	}
+	require.Len(t, fastConsumer.facts, 1)
+
+	//fact waits for the tenant consumer until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	quarantine := rc.route(ctx, Fact{"tenant": "slow"})
+	require.Equal(t, rc.defaultConsumer, quarantine)
+
+	close(unblock)
+	<-slowDone
+	require.NoError(t, rc.Close())
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+)
+
+const eventTypeKey = "event_type"
+
+//SamplingConsumer passes to underlying consumer only a part of facts according to sample rate of fact event_type
+//If key field is configured, sampling is deterministic: all facts with the same key value (e.g. user id)
+//are either passed or skipped. Otherwise facts are sampled randomly
+type SamplingConsumer struct {
+	consumer Consumer
+	//event_type -> share of passed facts [0,1]. Facts of event types without rate are passed
+	rates    map[string]float64
+	keyField string
+
+	sampledIn  uint64
+	sampledOut uint64
+}
+
+//NewSamplingConsumer return SamplingConsumer with rates per event type and optional key field path (e.g. /eventn_ctx/user/anonymous_id)
+//If there are no rates consumer is returned as is
+func NewSamplingConsumer(consumer Consumer, rates map[string]float64, keyField string) (Consumer, error) {
+	if len(rates) == 0 {
+		return consumer, nil
+	}
+
+	for eventType, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("Malformed sample rate of %s event type: %v. Rate must be in [0,1]", eventType, rate)
+		}
+	}
+
+	return &SamplingConsumer{
+		consumer: consumer,
+		rates:    rates,
+		keyField: strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(keyField, "/"), "/", "_")),
+	}, nil
+}
+
+//Consume pass fact to underlying consumer if it is sampled in
+func (sc *SamplingConsumer) Consume(fact Fact) {
+	sc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to underlying consumer if it is sampled in (see events.ConsumeCtx)
+func (sc *SamplingConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	if !sc.sample(fact) {
+		atomic.AddUint64(&sc.sampledOut, 1)
+		return nil
+	}
+
+	atomic.AddUint64(&sc.sampledIn, 1)
+	return ConsumeCtx(ctx, sc.consumer, fact)
+}
+
+//Return true if fact should be passed
+func (sc *SamplingConsumer) sample(fact Fact) bool {
+	eventType, _ := fact[eventTypeKey].(string)
+	rate, ok := sc.rates[eventType]
+	if !ok || rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	if sc.keyField != "" {
+		flatObject := map[string]string{}
+		flattenFact("", fact, flatObject)
+		if key, ok := flatObject[sc.keyField]; ok && key != "" {
+			h := fnv.New32a()
+			h.Write([]byte(key))
+			return float64(h.Sum32()) < rate*math.MaxUint32
+		}
+	}
+
+	return rand.Float64() < rate
+}
+
+//SampledIn return count of facts which have been passed to underlying consumer
+func (sc *SamplingConsumer) SampledIn() uint64 {
+	return atomic.LoadUint64(&sc.sampledIn)
+}
+
+//SampledOut return count of skipped facts
+func (sc *SamplingConsumer) SampledOut() uint64 {
+	return atomic.LoadUint64(&sc.sampledOut)
+}
+
+//Health return underlying consumer health
+func (sc *SamplingConsumer) Health() error {
+	return CheckHealth(sc.consumer)
+}
+
+//Close underlying consumer
+func (sc *SamplingConsumer) Close() error {
+	return sc.consumer.Close()
+}
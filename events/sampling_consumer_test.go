@@ -0,0 +1,99 @@
+package events
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSamplingConsumerRates(t *testing.T) {
+	consumer := &recordingConsumer{}
+	sc, err := NewSamplingConsumer(consumer, map[string]float64{"heartbeat": 0, "pageview": 1, "click": 0.5}, "")
+	require.NoError(t, err)
+
+	facts := []Fact{
+		{"event_type": "heartbeat", "id": "1"},
+		{"event_type": "pageview", "id": "2"},
+		{"event_type": "signup", "id": "3"},
+		{"id": "4"},
+		{"event_type": "heartbeat", "id": "5"},
+	}
+	for _, fact := range facts {
+		sc.Consume(fact)
+	}
+
+	require.Equal(t, []Fact{facts[1], facts[2], facts[3]}, consumer.facts,
+		"Facts with rate 0 must be skipped, with rate 1 or without rate must be passed")
+	samplingConsumer := sc.(*SamplingConsumer)
+	require.Equal(t, uint64(3), samplingConsumer.SampledIn())
+	require.Equal(t, uint64(2), samplingConsumer.SampledOut())
+
+	//random sampling
+	for i := 0; i < 10000; i++ {
+		sc.Consume(Fact{"event_type": "click"})
+	}
+	clicks := len(consumer.facts) - 3
+	require.True(t, clicks > 4500 && clicks < 5500, "Half of click facts must be passed: %d", clicks)
+	require.Equal(t, uint64(3+clicks), samplingConsumer.SampledIn())
+	require.Equal(t, uint64(2+10000-clicks), samplingConsumer.SampledOut())
+}
+
+func TestSamplingConsumerKeyField(t *testing.T) {
+	consumer := &recordingConsumer{}
+	sc, err := NewSamplingConsumer(consumer, map[string]float64{"click": 0.3}, "/eventn_ctx/User_Id")
+	require.NoError(t, err)
+	samplingConsumer := sc.(*SamplingConsumer)
+
+	//the same key always gets the same decision
+	passed := map[string]bool{}
+	for i := 0; i < 10000; i++ {
+		userID := fmt.Sprintf("user_%d", i)
+		passed[userID] = samplingConsumer.sample(Fact{"event_type": "click", "eventn_ctx": map[string]interface{}{"user_id": userID}})
+	}
+	sampledIn := 0
+	for attempt := 0; attempt < 3; attempt++ {
+		for userID, expected := range passed {
+			fact := Fact{"event_type": "click", "id": attempt, "eventn_ctx": map[string]interface{}{"user_id": userID}}
+			require.Equal(t, expected, samplingConsumer.sample(fact), "Sampling decision must be the same for key %s", userID)
+		}
+	}
+	for _, in := range passed {
+		if in {
+			sampledIn++
+		}
+	}
+	require.True(t, sampledIn > 2700 && sampledIn < 3300, "30%% of keys must be sampled in: %d", sampledIn)
+
+	//numeric keys are sampled by value
+	require.Equal(t,
+		samplingConsumer.sample(Fact{"event_type": "click", "eventn_ctx": map[string]interface{}{"user_id": "1000000"}}),
+		samplingConsumer.sample(Fact{"event_type": "click", "eventn_ctx": map[string]interface{}{"user_id": float64(1000000)}}))
+
+	//facts are consumed according to the decision and counted
+	for i := 0; i < 100; i++ {
+		sc.Consume(Fact{"event_type": "click", "eventn_ctx": map[string]interface{}{"user_id": fmt.Sprintf("user_%d", i)}})
+	}
+	expectedIn := 0
+	for i := 0; i < 100; i++ {
+		if passed[fmt.Sprintf("user_%d", i)] {
+			expectedIn++
+		}
+	}
+	require.Len(t, consumer.facts, expectedIn)
+	require.Equal(t, uint64(expectedIn), samplingConsumer.SampledIn())
+	require.Equal(t, uint64(100-expectedIn), samplingConsumer.SampledOut())
+}
+
+func TestNewSamplingConsumer(t *testing.T) {
+	consumer := &recordingConsumer{}
+
+	same, err := NewSamplingConsumer(consumer, nil, "/user_id")
+	require.NoError(t, err)
+	require.Equal(t, consumer, same, "Consumer without rates must be returned as is")
+
+	_, err = NewSamplingConsumer(consumer, map[string]float64{"click": 1.5}, "")
+	require.EqualError(t, err, "Malformed sample rate of click event type: 1.5. Rate must be in [0,1]")
+
+	_, err = NewSamplingConsumer(consumer, map[string]float64{"click": -0.1}, "")
+	require.EqualError(t, err, "Malformed sample rate of click event type: -0.1. Rate must be in [0,1]")
+}
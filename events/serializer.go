@@ -0,0 +1,25 @@
+package events
+
+import "encoding/json"
+
+var jsonSeparator = []byte("\n")
+
+//Serializer converts facts to event log records (see AsyncLoggerOptions)
+//note: Uploader reads only line-delimited json files (JSONSerializer) so other formats can't be used
+//with batch destinations
+type Serializer interface {
+	Marshal(fact Fact) ([]byte, error)
+	//Separator is written after every record. nil for formats with self-delimiting records (e.g. msgpack)
+	Separator() []byte
+}
+
+//JSONSerializer writes facts as newline-delimited json
+type JSONSerializer struct{}
+
+func (JSONSerializer) Marshal(fact Fact) ([]byte, error) {
+	return json.Marshal(fact)
+}
+
+func (JSONSerializer) Separator() []byte {
+	return jsonSeparator
+}
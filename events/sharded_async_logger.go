@@ -0,0 +1,230 @@
+package events
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/logging"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	//DefaultMaxOpenShards is used if max count of open shards isn't configured
+	DefaultMaxOpenShards = 100
+	//MissingShard is a shard of events without shard field (or with empty value)
+	MissingShard = "unknown"
+	//ShardSeparator is put between logger name and shard name in sharded event log names (e.g. event-<token>.<shard>)
+	ShardSeparator = "."
+
+	maxShardNameLength = 64
+)
+
+//shard names may be used in file names: other characters are replaced with '_'
+var notAllowedShardCharacters = regexp.MustCompile(`[^a-z0-9_]`)
+
+//ShardedAsyncLogger routes events to separate AsyncLoggers (e.g. one file per event type) by shard field value
+//AsyncLoggers are created with newLogger on the first event of the shard. Not more than maxOpen of them are kept open:
+//the least recently used idle one is closed (its buffered events are written) before a new one is created.
+//Closed shard is created again on its next event (rolling writers append to the same file)
+type ShardedAsyncLogger struct {
+	//flatten key of shard field e.g. event_type
+	field     string
+	maxOpen   int
+	newLogger func(shard string) (*AsyncLogger, error)
+	logger    logging.Logger
+
+	//guards shards, order and closed. Evicted loggers are closed under mutex so their last writes
+	//don't interleave with the recreated logger of the same shard
+	mutex sync.Mutex
+	//most recently used shards are at the front
+	order  *list.List
+	shards map[string]*list.Element
+	closed bool
+
+	evicted uint64
+}
+
+type loggerShard struct {
+	name   string
+	logger *AsyncLogger
+	//count of ConsumeCtx calls in progress: used shard isn't evicted
+	inUse int
+}
+
+//NewShardedAsyncLogger return ShardedAsyncLogger with shard field path (e.g. /event_type), max count of open loggers
+//(DefaultMaxOpenShards if not set) and func which creates AsyncLogger of a shard (see ShardName)
+func NewShardedAsyncLogger(field string, maxOpen int, newLogger func(shard string) (*AsyncLogger, error), logger logging.Logger) (*ShardedAsyncLogger, error) {
+	if strings.TrimSpace(field) == "" {
+		return nil, errors.New("Shard field can't be empty")
+	}
+	if maxOpen < 0 {
+		return nil, errors.New("Max open shards must be positive")
+	}
+	if maxOpen == 0 {
+		maxOpen = DefaultMaxOpenShards
+	}
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	return &ShardedAsyncLogger{
+		field:     strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(field), "/"), "/", "_")),
+		maxOpen:   maxOpen,
+		newLogger: newLogger,
+		logger:    logger,
+		order:     list.New(),
+		shards:    map[string]*list.Element{},
+	}, nil
+}
+
+//ShardName return shard field value which can be used in file names: lowercase letters, digits and underscores
+//(other characters are replaced with '_') not longer than 64 characters. Values which differ only by
+//not allowed characters are put to one shard. Return MissingShard if value is empty
+func ShardName(value string) string {
+	name := notAllowedShardCharacters.ReplaceAllString(strings.ToLower(strings.TrimSpace(value)), "_")
+	if len(name) > maxShardNameLength {
+		name = name[:maxShardNameLength]
+	}
+	if name == "" {
+		return MissingShard
+	}
+
+	return name
+}
+
+//Consume put fact to AsyncLogger of its shard (see ConsumeCtx)
+func (sal *ShardedAsyncLogger) Consume(fact Fact) {
+	sal.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx put fact to AsyncLogger of its shard (see AsyncLogger.ConsumeCtx). The logger is created if it isn't open
+func (sal *ShardedAsyncLogger) ConsumeCtx(ctx context.Context, fact Fact) error {
+	flatObject := map[string]string{}
+	flattenFact("", fact, flatObject)
+
+	shard, err := sal.acquire(ShardName(flatObject[sal.field]))
+	if err != nil {
+		return err
+	}
+	defer sal.release(shard)
+
+	return shard.logger.ConsumeCtx(ctx, fact)
+}
+
+//Return open shard (create it if needed) marked as used
+func (sal *ShardedAsyncLogger) acquire(name string) (*loggerShard, error) {
+	sal.mutex.Lock()
+	defer sal.mutex.Unlock()
+
+	if sal.closed {
+		return nil, errAsyncLoggerClosed
+	}
+
+	if element, ok := sal.shards[name]; ok {
+		sal.order.MoveToFront(element)
+		shard := element.Value.(*loggerShard)
+		shard.inUse++
+		return shard, nil
+	}
+
+	if sal.order.Len() >= sal.maxOpen {
+		sal.evict()
+	}
+
+	logger, err := sal.newLogger(name)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating async logger of %s shard: %v", name, err)
+	}
+	shard := &loggerShard{name: name, logger: logger, inUse: 1}
+	sal.shards[name] = sal.order.PushFront(shard)
+
+	return shard, nil
+}
+
+func (sal *ShardedAsyncLogger) release(shard *loggerShard) {
+	sal.mutex.Lock()
+	shard.inUse--
+	sal.mutex.Unlock()
+}
+
+//Close the least recently used idle shard. All shards may be used: then the limit is exceeded temporarily
+//Must be called under mutex
+func (sal *ShardedAsyncLogger) evict() {
+	for element := sal.order.Back(); element != nil; element = element.Prev() {
+		shard := element.Value.(*loggerShard)
+		if shard.inUse > 0 {
+			continue
+		}
+
+		sal.order.Remove(element)
+		delete(sal.shards, shard.name)
+		atomic.AddUint64(&sal.evicted, 1)
+		if err := shard.logger.Close(); err != nil {
+			sal.logger.Error("Error closing evicted async logger shard", "shard", shard.name, "error", err)
+		}
+		return
+	}
+}
+
+//Shards return names of open shards (the most recently used first)
+func (sal *ShardedAsyncLogger) Shards() []string {
+	sal.mutex.Lock()
+	defer sal.mutex.Unlock()
+
+	var names []string
+	for element := sal.order.Front(); element != nil; element = element.Next() {
+		names = append(names, element.Value.(*loggerShard).name)
+	}
+
+	return names
+}
+
+//Evicted return count of shards which have been closed because of max open shards limit
+func (sal *ShardedAsyncLogger) Evicted() uint64 {
+	return atomic.LoadUint64(&sal.evicted)
+}
+
+//Health return errors of open shards loggers
+func (sal *ShardedAsyncLogger) Health() (multiErr error) {
+	sal.mutex.Lock()
+	defer sal.mutex.Unlock()
+
+	if sal.closed {
+		return errAsyncLoggerClosed
+	}
+	for element := sal.order.Front(); element != nil; element = element.Next() {
+		shard := element.Value.(*loggerShard)
+		if err := shard.logger.Health(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("%s shard: %v", shard.name, err))
+		}
+	}
+
+	return
+}
+
+//Close stop accepting new events and close all open shards loggers (see AsyncLogger.Close)
+func (sal *ShardedAsyncLogger) Close() (multiErr error) {
+	sal.mutex.Lock()
+	defer sal.mutex.Unlock()
+
+	if sal.closed {
+		return nil
+	}
+	sal.closed = true
+
+	for element := sal.order.Front(); element != nil; element = element.Next() {
+		shard := element.Value.(*loggerShard)
+		if err := shard.logger.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("%s shard: %v", shard.name, err))
+		}
+	}
+	sal.order.Init()
+	sal.shards = map[string]*list.Element{}
+
+	return
+}
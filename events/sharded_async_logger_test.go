@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestShardedAsyncLogger(t *testing.T) {
+	//shard -> writers of created loggers (shard is created again after eviction)
+	writers := map[string][]*bufferWriter{}
+	newLogger := func(shard string) (*AsyncLogger, error) {
+		writer := &bufferWriter{}
+		writers[shard] = append(writers[shard], writer)
+		return NewAsyncLoggerWithOptions(writer, AsyncLoggerOptions{}), nil
+	}
+	logger, err := NewShardedAsyncLogger("/event_type", 2, newLogger, nil)
+	require.NoError(t, err)
+
+	logger.Consume(Fact{"event_type": "page_view", "id": 1})
+	logger.Consume(Fact{"event_type": "click", "id": 2})
+	logger.Consume(Fact{"event_type": "page_view", "id": 3})
+	require.Equal(t, []string{"page_view", "click"}, logger.Shards())
+
+	//the least recently used one is evicted
+	logger.Consume(Fact{"id": 4})
+	require.Equal(t, []string{MissingShard, "page_view"}, logger.Shards())
+	require.Equal(t, uint64(1), logger.Evicted())
+	logger.Consume(Fact{"event_type": "click", "id": 5})
+	require.NoError(t, logger.Close())
+	require.Error(t, logger.ConsumeCtx(context.Background(), Fact{"event_type": "click"}))
+
+	require.Equal(t, "{\"event_type\":\"page_view\",\"id\":1}\n{\"event_type\":\"page_view\",\"id\":3}\n", writers["page_view"][0].String())
+	require.Equal(t, "{\"id\":4}\n", writers[MissingShard][0].String())
+	require.Equal(t, 2, len(writers["click"]), "Evicted shard must be created again")
+	require.Equal(t, "{\"event_type\":\"click\",\"id\":2}\n", writers["click"][0].String())
+	require.Equal(t, "{\"event_type\":\"click\",\"id\":5}\n", writers["click"][1].String())
+
+	_, err = NewShardedAsyncLogger("", 2, newLogger, nil)
+	require.Error(t, err)
+}
+
+func TestShardName(t *testing.T) {
+	for value, expected := range map[string]string{"page_view": "page_view", "Page View": "page_view", "../etc/passwd": "___etc_passwd",
+		"": MissingShard, " ": MissingShard, strings.Repeat("a", 70): strings.Repeat("a", 64)} {
+		require.Equal(t, expected, ShardName(value), value)
+	}
+}
@@ -0,0 +1,130 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//count of marshaled facts waiting for writing. Facts are dropped from the tee output when it is full
+	teeBufferSize = 1000
+	//max time of writing buffered facts on Close
+	teeCloseTimeout = time.Second
+)
+
+//TeeConsumer passes facts to primary consumer and also writes them as indented json to writer (e.g. os.Stdout)
+//for debugging events shape. Writing is asynchronous: slow or failed writer never blocks or fails the primary
+//consumer. Facts which don't fit into the buffer aren't written (see Dropped)
+type TeeConsumer struct {
+	primary Consumer
+	writer  io.Writer
+
+	buffer    chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+
+	dropped     uint64
+	writeErrors uint64
+}
+
+//NewTeeConsumer return TeeConsumer and start writing goroutine
+func NewTeeConsumer(primary Consumer, writer io.Writer) *TeeConsumer {
+	tc := &TeeConsumer{
+		primary: primary,
+		writer:  writer,
+		buffer:  make(chan []byte, teeBufferSize),
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go tc.write()
+
+	return tc
+}
+
+//Consume pass fact to primary consumer and tee output
+func (tc *TeeConsumer) Consume(fact Fact) {
+	tc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to primary consumer (see events.ConsumeCtx) and tee output
+//Fact is marshaled before passing: primary consumer may modify it
+func (tc *TeeConsumer) ConsumeCtx(ctx context.Context, fact Fact) error {
+	if b, err := json.MarshalIndent(fact, "", "  "); err != nil {
+		atomic.AddUint64(&tc.writeErrors, 1)
+	} else {
+		select {
+		case tc.buffer <- append(b, '\n'):
+		default:
+			atomic.AddUint64(&tc.dropped, 1)
+		}
+	}
+
+	return ConsumeCtx(ctx, tc.primary, fact)
+}
+
+//DeadLetter put fact to the dead-letter queue of primary consumer (if it has one, see events.DeadLetterer)
+//Dead-lettered facts aren't written to tee output
+func (tc *TeeConsumer) DeadLetter(fact Fact, reason error) {
+	if dl, ok := tc.primary.(DeadLetterer); ok {
+		dl.DeadLetter(fact, reason)
+	}
+}
+
+//Write buffered facts until consumer is closed
+func (tc *TeeConsumer) write() {
+	defer close(tc.done)
+	for {
+		select {
+		case b := <-tc.buffer:
+			tc.writeFact(b)
+		case <-tc.closed:
+			for {
+				select {
+				case b := <-tc.buffer:
+					tc.writeFact(b)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (tc *TeeConsumer) writeFact(b []byte) {
+	if _, err := tc.writer.Write(b); err != nil {
+		atomic.AddUint64(&tc.writeErrors, 1)
+	}
+}
+
+//Dropped return count of facts which weren't written because the buffer was full
+func (tc *TeeConsumer) Dropped() uint64 {
+	return atomic.LoadUint64(&tc.dropped)
+}
+
+//WriteErrors return count of facts which couldn't be marshaled or written
+func (tc *TeeConsumer) WriteErrors() uint64 {
+	return atomic.LoadUint64(&tc.writeErrors)
+}
+
+//Health return primary consumer health. Tee output doesn't affect it
+func (tc *TeeConsumer) Health() error {
+	return CheckHealth(tc.primary)
+}
+
+//Close write buffered facts (not longer than teeCloseTimeout) and close primary consumer
+func (tc *TeeConsumer) Close() error {
+	tc.closeOnce.Do(func() {
+		close(tc.closed)
+		select {
+		case <-tc.done:
+		case <-time.After(teeCloseTimeout):
+		}
+	})
+
+	return tc.primary.Close()
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type blockingWriter struct {
+	unblock chan struct{}
+	buffer  bytes.Buffer
+}
+
+func (bw *blockingWriter) Write(p []byte) (int, error) {
+	<-bw.unblock
+	return bw.buffer.Write(p)
+}
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("Broken pipe")
+}
+
+func TestTeeConsumer(t *testing.T) {
+	primary := &recordingConsumer{}
+	writer := &blockingWriter{unblock: make(chan struct{})}
+	tc := NewTeeConsumer(primary, writer)
+
+	//the first fact may be taken by the blocked writing goroutine
+	count := teeBufferSize + 10
+	for i := 0; i < count; i++ {
+		tc.Consume(Fact{"id": i})
+	}
+	require.Len(t, primary.facts, count, "Blocked tee output mustn't block primary consumer")
+	require.True(t, tc.Dropped() >= 9, "Facts which don't fit into the buffer must be dropped")
+
+	close(writer.unblock)
+	require.NoError(t, tc.Close())
+	require.NoError(t, tc.Close(), "Second Close mustn't panic")
+	require.Equal(t, "{\n  \"id\": 0\n}\n", writer.buffer.String()[:len("{\n  \"id\": 0\n}\n")])
+	require.Equal(t, uint64(count)-tc.Dropped(), uint64(bytes.Count(writer.buffer.Bytes(), []byte("}\n"))))
+
+	primary.reject = true
+	failed := NewTeeConsumer(primary, brokenWriter{})
+	require.Error(t, failed.ConsumeCtx(context.Background(), Fact{"id": 1}), "Primary consumer error must be returned")
+	require.NoError(t, failed.Close())
+	require.Equal(t, uint64(1), failed.WriteErrors())
+}
+
+func TestTeeConsumerDeadLetter(t *testing.T) {
+	primary := &struct {
+		recordingConsumer
+		recordingDeadLetterer
+	}{}
+	writer := &blockingWriter{unblock: make(chan struct{})}
+	close(writer.unblock)
+	tc := NewTeeConsumer(primary, writer)
+
+	var deadLetterer DeadLetterer = tc
+	deadLetterer.DeadLetter(Fact{"id": 1}, errors.New("Invalid event"))
+	require.NoError(t, tc.Close())
+
+	require.Equal(t, []Fact{{"id": 1}}, primary.recordingDeadLetterer.facts, "Fact must be put to primary consumer dead-letter queue")
+	require.Empty(t, primary.recordingConsumer.facts)
+	require.Empty(t, writer.buffer.String(), "Dead-lettered facts mustn't be written")
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -53,7 +54,7 @@ func NewUploader(fileMask string, filesBatchSize, uploadEveryS int, tokenizedEve
 func (u *PeriodicUploader) Start() {
 	go func() {
 		for {
-			if appstatus.Instance.Idle {
+			if appstatus.Instance.IsIdle() {
 				break
 			}
 			files, err := filepath.Glob(u.fileMask)
@@ -88,6 +89,11 @@ func (u *PeriodicUploader) Start() {
 
 				token := regexResult[1]
 				eventStorages, ok := u.tokenizedEventStorages[token]
+				//sharded event logs are named event-<token>.<shard> (see ShardedAsyncLogger)
+				if i := strings.LastIndex(token, ShardSeparator); !ok && i > 0 {
+					token = token[:i]
+					eventStorages, ok = u.tokenizedEventStorages[token]
+				}
 				//TODO remove it if we want to write logs with streaming postgres
 				if !ok {
 					log.Printf("Destination storages weren't found for token %s", token)
@@ -109,7 +115,11 @@ func (u *PeriodicUploader) Start() {
 				os.Remove(filePath)
 			}
 
-			time.Sleep(u.uploadEvery)
+			select {
+			case <-appstatus.Instance.Done():
+				return
+			case <-time.After(u.uploadEvery):
+			}
 		}
 	}()
 }
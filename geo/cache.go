@@ -0,0 +1,49 @@
+package geo
+
+import "sync"
+
+const defaultCacheSize = 100000
+
+//CachingResolver keeps resolved Data (or nil for ip without location) in memory
+//Cache is cleared when it exceeds max size
+type CachingResolver struct {
+	resolver Resolver
+	maxSize  int
+
+	mutex sync.RWMutex
+	cache map[string]*Data
+}
+
+//NewCachingResolver return CachingResolver with maxSize cached ip addresses (100000 if maxSize <= 0)
+func NewCachingResolver(resolver Resolver, maxSize int) *CachingResolver {
+	if maxSize <= 0 {
+		maxSize = defaultCacheSize
+	}
+
+	return &CachingResolver{resolver: resolver, maxSize: maxSize, cache: map[string]*Data{}}
+}
+
+//Resolve return cached Data or resolve it with underlying resolver
+//Errors aren't cached
+func (cr *CachingResolver) Resolve(ip string) (*Data, error) {
+	cr.mutex.RLock()
+	data, ok := cr.cache[ip]
+	cr.mutex.RUnlock()
+	if ok {
+		return data, nil
+	}
+
+	data, err := cr.resolver.Resolve(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	cr.mutex.Lock()
+	if len(cr.cache) >= cr.maxSize {
+		cr.cache = map[string]*Data{}
+	}
+	cr.cache[ip] = data
+	cr.mutex.Unlock()
+
+	return data, nil
+}
@@ -0,0 +1,70 @@
+package geo
+
+import (
+	"context"
+	"github.com/ksensehq/eventnative/events"
+)
+
+const (
+	CountryKey = "geo_country"
+	CityKey    = "geo_city"
+	RegionKey  = "geo_region"
+)
+
+//EnrichmentConsumer adds geo_country, geo_city and geo_region fields (resolved from ip field) to facts
+//and passes them to underlying consumer. Fields aren't added if ip is private, malformed or isn't in geo db
+type EnrichmentConsumer struct {
+	consumer events.Consumer
+	resolver Resolver
+	ipField  string
+}
+
+//NewEnrichmentConsumer return EnrichmentConsumer which reads ip from field path e.g. /eventn_ctx/ip
+func NewEnrichmentConsumer(consumer events.Consumer, resolver Resolver, ipField string) *EnrichmentConsumer {
+	return &EnrichmentConsumer{consumer: consumer, resolver: resolver, ipField: ipField}
+}
+
+//Consume enrich fact with geo data and pass it to underlying consumer
+func (ec *EnrichmentConsumer) Consume(fact events.Fact) {
+	ec.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx enrich fact with geo data and pass it with ctx to underlying consumer (see events.ConsumeCtx)
+//Fact is copied because the same fact is passed to other consumers
+func (ec *EnrichmentConsumer) ConsumeCtx(ctx context.Context, fact events.Fact) error {
+	ip, _ := fact.Get(ec.ipField).(string)
+	if ip == "" {
+		return events.ConsumeCtx(ctx, ec.consumer, fact)
+	}
+
+	data, err := ec.resolver.Resolve(ip)
+	if err != nil || data == nil {
+		return events.ConsumeCtx(ctx, ec.consumer, fact)
+	}
+
+	enriched := events.Fact{}
+	for k, v := range fact {
+		enriched[k] = v
+	}
+	if data.Country != "" {
+		enriched[CountryKey] = data.Country
+	}
+	if data.City != "" {
+		enriched[CityKey] = data.City
+	}
+	if data.Region != "" {
+		enriched[RegionKey] = data.Region
+	}
+
+	return events.ConsumeCtx(ctx, ec.consumer, enriched)
+}
+
+//Health return underlying consumer health
+func (ec *EnrichmentConsumer) Health() error {
+	return events.CheckHealth(ec.consumer)
+}
+
+//Close underlying consumer
+func (ec *EnrichmentConsumer) Close() error {
+	return ec.consumer.Close()
+}
@@ -0,0 +1,171 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"net"
+	"sync"
+	"testing"
+)
+
+//in-memory Resolver which counts calls
+type fakeResolver struct {
+	mutex sync.Mutex
+	data  map[string]*Data
+	calls map[string]int
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		data: map[string]*Data{
+			"8.8.8.8": {Country: "US", City: "Mountain View", Region: "CA"},
+			"1.1.1.1": {Country: "AU"},
+		},
+		calls: map[string]int{},
+	}
+}
+
+func (fr *fakeResolver) Resolve(ip string) (*Data, error) {
+	fr.mutex.Lock()
+	defer fr.mutex.Unlock()
+
+	fr.calls[ip]++
+	if ip == "broken" {
+		return nil, errors.New("Error parsing geo from ip broken: malformed ip address")
+	}
+
+	return fr.data[ip], nil
+}
+
+type recordingConsumer struct {
+	facts []events.Fact
+}
+
+func (rc *recordingConsumer) Consume(fact events.Fact) {
+	rc.facts = append(rc.facts, fact)
+}
+
+func (rc *recordingConsumer) Close() error {
+	return nil
+}
+
+func TestEnrichmentConsumer(t *testing.T) {
+	tests := []struct {
+		name     string
+		fact     events.Fact
+		expected events.Fact
+	}{
+		{
+			"All geo fields",
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "8.8.8.8"}},
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "8.8.8.8"}, CountryKey: "US", CityKey: "Mountain View", RegionKey: "CA"},
+		},
+		{
+			"Empty geo fields aren't added",
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "1.1.1.1"}},
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "1.1.1.1"}, CountryKey: "AU"},
+		},
+		{
+			"Ip without location",
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "10.0.0.1"}},
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "10.0.0.1"}},
+		},
+		{
+			"Resolving error",
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "broken"}},
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "broken"}},
+		},
+		{
+			"Without ip",
+			events.Fact{"event_type": "pageview"},
+			events.Fact{"event_type": "pageview"},
+		},
+		{
+			"Ip isn't a string",
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": 8}},
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": 8}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := &recordingConsumer{}
+			ec := NewEnrichmentConsumer(consumer, newFakeResolver(), "/eventn_ctx/ip")
+
+			require.NoError(t, ec.ConsumeCtx(context.Background(), tt.fact))
+			require.Equal(t, []events.Fact{tt.expected}, consumer.facts)
+			_, ok := tt.fact[CountryKey]
+			require.False(t, ok, "Source fact mustn't be modified")
+		})
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	resolver := newFakeResolver()
+	cr := NewCachingResolver(resolver, 2)
+
+	for i := 0; i < 3; i++ {
+		data, err := cr.Resolve("8.8.8.8")
+		require.NoError(t, err)
+		require.Equal(t, "US", data.Country)
+
+		data, err = cr.Resolve("10.0.0.1")
+		require.NoError(t, err)
+		require.Nil(t, data)
+
+		_, err = cr.Resolve("broken")
+		require.Error(t, err)
+	}
+	require.Equal(t, 1, resolver.calls["8.8.8.8"], "Resolved data must be cached")
+	require.Equal(t, 1, resolver.calls["10.0.0.1"], "Ip without location must be cached")
+	require.Equal(t, 3, resolver.calls["broken"], "Errors mustn't be cached")
+
+	//cache is cleared when it exceeds max size
+	_, err := cr.Resolve("1.1.1.1")
+	require.NoError(t, err)
+	require.Len(t, cr.cache, 1)
+	_, err = cr.Resolve("8.8.8.8")
+	require.NoError(t, err)
+	require.Equal(t, 2, resolver.calls["8.8.8.8"])
+}
+
+func TestIsPrivate(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"127.0.0.1", true},
+		{"169.254.1.1", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			require.Equal(t, tt.expected, isPrivate(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestMaxMindResolverWithoutLookup(t *testing.T) {
+	//private and malformed ip addresses are resolved without geo db
+	mr := &MaxMindResolver{}
+
+	data, err := mr.Resolve(" 192.168.0.1 ")
+	require.NoError(t, err)
+	require.Nil(t, data)
+
+	_, err = mr.Resolve("not_ip")
+	require.EqualError(t, err, "Error parsing geo from ip not_ip: malformed ip address")
+
+	_, err = mr.Resolve("")
+	require.Equal(t, EmptyIp, err)
+}
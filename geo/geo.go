@@ -17,6 +17,10 @@ const GeoDataKey = "location"
 var (
 	EmptyIp    = errors.New("IP is empty")
 	mmdbSuffix = ".mmdb"
+
+	//private and special purpose networks which aren't in geo db
+	privateNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10",
+		"127.0.0.0/8", "169.254.0.0/16", "fc00::/7", "fe80::/10", "::1/128")
 )
 
 type Resolver interface {
@@ -81,13 +85,22 @@ func createGeoIpParser(geoipPath string) (*geoip2.Reader, error) {
 }
 
 //Get location info from client ip address
+//Return nil data without error for private ip addresses
 func (mr *MaxMindResolver) Resolve(ip string) (*Data, error) {
 	data := &Data{}
 	if ip == "" {
 		return nil, EmptyIp
 	}
 
-	city, err := mr.parser.City(net.ParseIP(ip))
+	parsedIp := net.ParseIP(strings.TrimSpace(ip))
+	if parsedIp == nil {
+		return nil, fmt.Errorf("Error parsing geo from ip %s: malformed ip address", ip)
+	}
+	if isPrivate(parsedIp) {
+		return nil, nil
+	}
+
+	city, err := mr.parser.City(parsedIp)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing geo from ip %s: %v", ip, err)
 	}
@@ -113,6 +126,30 @@ func (dr *DummyResolver) Resolve(ip string) (*Data, error) {
 	return nil, nil
 }
 
+func isPrivate(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Println("System error: malformed network", cidr, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
 func findMmdbFile(path string) string {
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
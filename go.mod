@@ -6,17 +6,23 @@ require (
 	bou.ke/monkey v1.0.2
 	cloud.google.com/go/bigquery v1.10.0
 	cloud.google.com/go/storage v1.10.0
+	github.com/ClickHouse/clickhouse-go v1.4.3
+	github.com/Shopify/sarama v1.27.0
 	github.com/aws/aws-sdk-go v1.34.0
 	github.com/gin-gonic/gin v1.6.3
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/google/uuid v1.1.1
 	github.com/hashicorp/go-multierror v1.1.0
 	github.com/joncrlsn/dque v0.0.0-20200702023911-3e80e3146ce5
 	github.com/lib/pq v1.8.0
 	github.com/mailru/easyjson v0.7.2
 	github.com/oschwald/geoip2-golang v1.4.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/snowflakedb/gosnowflake v1.3.8
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.5.1
 	github.com/ua-parser/uap-go v0.0.0-20200325213135-e1c09f13e2fe
+	github.com/xeipuuv/gojsonschema v1.2.0
 	google.golang.org/api v0.30.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
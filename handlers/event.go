@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/ksensehq/eventnative/appconfig"
 	"github.com/ksensehq/eventnative/events"
 	"github.com/ksensehq/eventnative/geo"
 	"github.com/ksensehq/eventnative/middleware"
 	"github.com/ksensehq/eventnative/timestamp"
+	"github.com/ksensehq/eventnative/tracing"
 	"github.com/ksensehq/eventnative/useragent"
 	"log"
 	"net/http"
@@ -19,15 +21,21 @@ const uaKey = "user_agent"
 
 //Accept all events
 type EventHandler struct {
-	eventConsumersByToken map[string][]events.Consumer
+	eventConsumersByToken map[string]*events.MultiplexConsumer
 	geoResolver           geo.Resolver
 	uaResolver            *useragent.Resolver
 }
 
 //Accept all events according to token
+//Consumers aren't closed by EventHandler
 func NewEventHandler(eventConsumersByToken map[string][]events.Consumer) (eventHandler *EventHandler) {
+	multiplexConsumers := map[string]*events.MultiplexConsumer{}
+	for token, consumers := range eventConsumersByToken {
+		multiplexConsumers[token] = events.NewMultiplexConsumer(consumers...)
+	}
+
 	return &EventHandler{
-		eventConsumersByToken: eventConsumersByToken,
+		eventConsumersByToken: multiplexConsumers,
 		geoResolver:           appconfig.Instance.GeoResolver,
 		uaResolver:            appconfig.Instance.UaResolver,
 	}
@@ -35,7 +43,10 @@ func NewEventHandler(eventConsumersByToken map[string][]events.Consumer) (eventH
 
 func (eh *EventHandler) Handler(c *gin.Context) {
 	payload := map[string]interface{}{}
-	if err := c.BindJSON(&payload); err != nil {
+	//numbers are decoded as json.Number: big integer ids aren't rounded like float64 values
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
 		c.Writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -82,10 +93,20 @@ func (eh *EventHandler) Handler(c *gin.Context) {
 	if !ok {
 		log.Println("System error: token wasn't found in context")
 	} else {
-		consumers, ok := eh.eventConsumersByToken[token.(string)]
+		consumer, ok := eh.eventConsumersByToken[token.(string)]
 		if ok {
-			for _, consumer := range consumers {
-				consumer.Consume(payload)
+			ctx := c.Request.Context()
+			if sc, ok := tracing.ParseTraceParent(c.GetHeader(tracing.TraceParentHeader)); ok {
+				ctx = tracing.ContextWithSpanContext(ctx, sc)
+			}
+			ctx, span := tracing.Start(ctx, "consume")
+			//blocking consumers (e.g. block queue policy) return when request is canceled
+			//consumers errors are logged and counted by consumers themselves
+			err := consumer.ConsumeCtx(ctx, payload)
+			span.End(err)
+			//event hasn't been accepted by some consumer (e.g. full queue or disk): client may retry it later
+			if err != nil && ctx.Err() == nil {
+				c.Writer.WriteHeader(http.StatusServiceUnavailable)
 			}
 		} else {
 			log.Printf("Unknown token[%s] request was received", token.(string))
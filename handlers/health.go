@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ksensehq/eventnative/events"
+	"net/http"
+)
+
+//HealthResponse is a readiness probe response. Errors contain unhealthy consumers reasons
+type HealthResponse struct {
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+//HealthHandler aggregates Health() of all consumers (which implement events.HealthChecker)
+//Responds 200 if all of them are healthy and 503 otherwise
+type HealthHandler struct {
+	consumers []events.Consumer
+}
+
+//NewHealthHandler return HealthHandler over all unique consumers
+//(one consumer may be configured for several tokens)
+func NewHealthHandler(eventConsumersByToken map[string][]events.Consumer) *HealthHandler {
+	unique := map[events.Consumer]bool{}
+	hh := &HealthHandler{}
+	for _, consumers := range eventConsumersByToken {
+		for _, consumer := range consumers {
+			if !unique[consumer] {
+				unique[consumer] = true
+				hh.consumers = append(hh.consumers, consumer)
+			}
+		}
+	}
+
+	return hh
+}
+
+func (hh *HealthHandler) Handler(c *gin.Context) {
+	var errs []string
+	for _, consumer := range hh.consumers {
+		if err := events.CheckHealth(consumer); err != nil {
+			errs = append(errs, fmt.Sprintf("%T: %v", consumer, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: "unhealthy", Errors: errs})
+		return
+	}
+
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//flush compressed data when it exceeds this size even if Flush() hasn't been called
+const gzipMemberMaxSizeBytes = 1024 * 1024
+
+//GzipWriter compresses written data and passes it to underlying writer as complete gzip members
+//(concatenated members are valid gzip stream, see RFC 1952). Every member is written with one Write() call
+//so underlying rolling writer never splits it between files
+type GzipWriter struct {
+	mutex      sync.Mutex
+	underlying io.WriteCloser
+	buf        *bytes.Buffer
+	gz         *gzip.Writer
+	empty      bool
+}
+
+func NewGzipWriter(underlying io.WriteCloser) *GzipWriter {
+	buf := &bytes.Buffer{}
+	return &GzipWriter{underlying: underlying, buf: buf, gz: gzip.NewWriter(buf), empty: true}
+}
+
+//Write compress p into current gzip member
+func (gw *GzipWriter) Write(p []byte) (int, error) {
+	gw.mutex.Lock()
+	defer gw.mutex.Unlock()
+
+	n, err := gw.gz.Write(p)
+	if err != nil {
+		return n, err
+	}
+	gw.empty = false
+	if gw.buf.Len() >= gzipMemberMaxSizeBytes {
+		if err := gw.flush(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+//Flush finish current gzip member and write it to underlying writer
+func (gw *GzipWriter) Flush() error {
+	gw.mutex.Lock()
+	defer gw.mutex.Unlock()
+
+	return gw.flush()
+}
+
+//Close flush gzip layer and close underlying writer
+func (gw *GzipWriter) Close() error {
+	gw.mutex.Lock()
+	defer gw.mutex.Unlock()
+
+	if err := gw.flush(); err != nil {
+		gw.underlying.Close()
+		return err
+	}
+
+	return gw.underlying.Close()
+}
+
+func (gw *GzipWriter) flush() error {
+	if gw.empty {
+		return nil
+	}
+	if err := gw.gz.Close(); err != nil {
+		return fmt.Errorf("Error closing gzip member: %v", err)
+	}
+	if _, err := gw.underlying.Write(gw.buf.Bytes()); err != nil {
+		return fmt.Errorf("Error writing gzip member: %v", err)
+	}
+	gw.buf.Reset()
+	gw.gz.Reset(gw.buf)
+	gw.empty = true
+
+	return nil
+}
@@ -12,6 +12,8 @@ type Config struct {
 	FileDir     string
 	RotationMin int64
 	MaxBackups  int
+	//rotate file when it exceeds this size (100 MB by default)
+	MaxSizeMB int
 }
 
 func (c Config) Validate() error {
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+//Logger is a leveled logger with key-value fields e.g. logger.Warn("Error inserting objects", "table", "events", "error", err)
+//Implement it for plugging custom logging backend (see SetDefaultLogger)
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+	//With return logger which adds keysAndValues to every message
+	With(keysAndValues ...interface{}) Logger
+}
+
+//Level is a min severity of written messages
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levels = map[string]Level{
+	"debug": DebugLevel,
+	"info":  InfoLevel,
+	"warn":  WarnLevel,
+	"error": ErrorLevel,
+}
+
+//ParseLevel return Level from string representation (debug, info, warn, error). Empty string means InfoLevel
+func ParseLevel(value string) (Level, error) {
+	if value == "" {
+		return InfoLevel, nil
+	}
+	level, ok := levels[strings.ToLower(value)]
+	if !ok {
+		return InfoLevel, fmt.Errorf("Unknown log level: %s. Supported: debug, info, warn, error", value)
+	}
+
+	return level, nil
+}
+
+var (
+	defaultLoggerMutex sync.RWMutex
+	defaultLogger      Logger = NewStdLogger(InfoLevel)
+)
+
+//SetDefaultLogger set logger which is used by storages and async loggers created after this call
+func SetDefaultLogger(logger Logger) {
+	defaultLoggerMutex.Lock()
+	defaultLogger = logger
+	defaultLoggerMutex.Unlock()
+}
+
+//DefaultLogger return configured default logger (stdlib backed one if not set)
+func DefaultLogger() Logger {
+	defaultLoggerMutex.RLock()
+	defer defaultLoggerMutex.RUnlock()
+
+	return defaultLogger
+}
+
+//StdLogger is a Logger over stdlib global logger. Messages are written as: Warn: message key1=value1 key2=value2
+type StdLogger struct {
+	level  Level
+	fields []interface{}
+}
+
+//NewStdLogger return stdlib backed logger. Messages with lower severity than level are skipped
+func NewStdLogger(level Level) *StdLogger {
+	return &StdLogger{level: level}
+}
+
+func (sl *StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	sl.write(DebugLevel, "Debug: ", msg, keysAndValues)
+}
+
+func (sl *StdLogger) Info(msg string, keysAndValues ...interface{}) {
+	sl.write(InfoLevel, "", msg, keysAndValues)
+}
+
+func (sl *StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	sl.write(WarnLevel, "Warn: ", msg, keysAndValues)
+}
+
+func (sl *StdLogger) Error(msg string, keysAndValues ...interface{}) {
+	sl.write(ErrorLevel, "Error: ", msg, keysAndValues)
+}
+
+func (sl *StdLogger) With(keysAndValues ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(sl.fields)+len(keysAndValues))
+	fields = append(fields, sl.fields...)
+	fields = append(fields, keysAndValues...)
+
+	return &StdLogger{level: sl.level, fields: fields}
+}
+
+//Write message with logger fields and message keysAndValues. Key without value is written as key=MISSING
+func (sl *StdLogger) write(level Level, prefix, msg string, keysAndValues []interface{}) {
+	if level < sl.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteString(msg)
+	for _, kv := range [][]interface{}{sl.fields, keysAndValues} {
+		for i := 0; i < len(kv); i += 2 {
+			var value interface{} = "MISSING"
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			fmt.Fprintf(&b, " %v=%v", kv[i], value)
+		}
+	}
+
+	log.Println(b.String())
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSummaryInterval = time.Minute
+	//distinct reasons count in one summary. Others are counted as otherSummaryReason
+	maxSummaryReasons  = 10
+	otherSummaryReason = "other"
+)
+
+var (
+	summaryIntervalMutex sync.RWMutex
+	summaryInterval      = DefaultSummaryInterval
+)
+
+//SetSummaryInterval set interval of summaries created after this call
+func SetSummaryInterval(interval time.Duration) {
+	summaryIntervalMutex.Lock()
+	summaryInterval = interval
+	summaryIntervalMutex.Unlock()
+}
+
+//Summary collapses repetitive messages (e.g. per event failures) into periodic warnings with counts per reason:
+//Warn: Re-enqueued events count=4213 interval=1m0s reason=connection refused
+//Summary is written on the first Add() after interval is elapsed or on Flush()
+type Summary struct {
+	logger   Logger
+	msg      string
+	interval time.Duration
+
+	mutex  sync.Mutex
+	since  time.Time
+	counts map[string]int
+	//reasons in order of first appearance
+	reasons []string
+}
+
+//NewSummary return Summary which writes msg with logger every configured summary interval (see SetSummaryInterval)
+func NewSummary(logger Logger, msg string) *Summary {
+	summaryIntervalMutex.RLock()
+	interval := summaryInterval
+	summaryIntervalMutex.RUnlock()
+
+	return &Summary{logger: logger, msg: msg, interval: interval, since: time.Now(), counts: map[string]int{}}
+}
+
+//Add count occurrences of reason and write summary if interval is elapsed
+func (s *Summary) Add(reason string, count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.counts[reason]; !ok {
+		if len(s.reasons) >= maxSummaryReasons {
+			reason = otherSummaryReason
+		}
+		if _, ok := s.counts[reason]; !ok {
+			s.reasons = append(s.reasons, reason)
+		}
+	}
+	s.counts[reason] += count
+
+	if time.Since(s.since) >= s.interval {
+		s.flush()
+	}
+}
+
+//Flush write collected counts (if any) and reset them
+func (s *Summary) Flush() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.flush()
+}
+
+func (s *Summary) flush() {
+	elapsed := time.Since(s.since).Round(time.Second)
+	for _, reason := range s.reasons {
+		s.logger.Warn(s.msg, "count", s.counts[reason], "interval", elapsed, "reason", reason)
+	}
+
+	s.since = time.Now()
+	s.counts = map[string]int{}
+	s.reasons = nil
+}
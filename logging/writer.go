@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -25,25 +27,155 @@ func NewWriter(config Config) (io.WriteCloser, error) {
 
 func newRollingWriter(config Config) (io.WriteCloser, error) {
 	fileNamePath := filepath.Join(config.FileDir, fmt.Sprintf("%s-%s.log", config.ServerName, config.LoggerName))
+	return NewRollingWriter(fileNamePath, config.MaxSizeMB, config.MaxBackups, config.RotationMin), nil
+}
+
+//NewRollingWriter return file writer which rotates file (old file is renamed with timestamp suffix)
+//when file exceeds maxSizeMB (100 MB by default) or every rotationMin (24 hours by default)
+//Rotation and writing are mutually exclusive so every Write() call goes to one file
+//Implements HeaderWriter and ScheduledRotator
+func NewRollingWriter(fileNamePath string, maxSizeMB, maxBackups int, rotationMin int64) io.WriteCloser {
 	lWriter := &lumberjack.Logger{
 		Filename: fileNamePath,
 		MaxSize:  logFileMaxSizeMB,
 	}
-	if config.MaxBackups > 0 {
-		lWriter.MaxBackups = config.MaxBackups
+	if maxSizeMB > 0 {
+		lWriter.MaxSize = maxSizeMB
+	}
+	if maxBackups > 0 {
+		lWriter.MaxBackups = maxBackups
 	}
 
-	if config.RotationMin == 0 {
-		config.RotationMin = 1440 //24 hours
+	if rotationMin == 0 {
+		rotationMin = 1440 //24 hours
 	}
-	rotation := time.Duration(config.RotationMin) * time.Minute
-	ticker := time.NewTicker(rotation)
+	rotation := time.Duration(rotationMin) * time.Minute
+	writer := &rollingWriter{Logger: lWriter, rotation: rotation, ticker: time.NewTicker(rotation), closed: make(chan struct{}), size: -1}
 	go func() {
 		for {
-			<-ticker.C
-			lWriter.Rotate()
+			select {
+			case <-writer.ticker.C:
+				if err := writer.Rotate(); err != nil {
+					log.Printf("Error rotating log file %s: %v", fileNamePath, err)
+				}
+			case <-writer.closed:
+				return
+			}
 		}
 	}()
 
-	return lWriter, nil
+	return writer
+}
+
+//HeaderWriter is implemented by writers which write header (e.g. BOM) at the beginning of every file
+type HeaderWriter interface {
+	//SetHeader set header which is written before the first Write() of every file
+	SetHeader(header []byte)
+}
+
+//ScheduledRotator is implemented by writers with time based rotation which can be driven by the caller
+//(e.g. by the single writing goroutine between writes so that rotation never happens in the middle of buffered data)
+type ScheduledRotator interface {
+	//RotationInterval return time based rotation interval
+	RotationInterval() time.Duration
+	//DisableScheduledRotation stop own rotation goroutine. Caller must call Rotate() every RotationInterval
+	DisableScheduledRotation()
+	//Rotate close current file and open a new one
+	Rotate() error
+}
+
+//rollingWriter is a lumberjack.Logger with time based rotation and optional file header
+type rollingWriter struct {
+	*lumberjack.Logger
+
+	rotation  time.Duration
+	ticker    *time.Ticker
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	//guards header and size
+	mutex  sync.Mutex
+	header []byte
+	//current file size. -1 - unknown (file hasn't been written by this writer yet)
+	size int64
+}
+
+func (rw *rollingWriter) SetHeader(header []byte) {
+	rw.mutex.Lock()
+	rw.header = header
+	rw.mutex.Unlock()
+}
+
+//Write p to current file. Header is written with p (one Write() call) if current file is empty
+//File is rotated here (not by lumberjack) if p doesn't fit: so header is written to the new file
+func (rw *rollingWriter) Write(p []byte) (int, error) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if len(rw.header) == 0 {
+		return rw.Logger.Write(p)
+	}
+
+	if rw.size < 0 {
+		rw.size = 0
+		if info, err := os.Stat(rw.Logger.Filename); err == nil {
+			rw.size = info.Size()
+		}
+	}
+	maxBytes := int64(rw.Logger.MaxSize) * 1024 * 1024
+	if rw.size > 0 && rw.size+int64(len(p)) >= maxBytes {
+		if err := rw.Logger.Rotate(); err != nil {
+			return 0, err
+		}
+		rw.size = 0
+	}
+
+	data := p
+	if rw.size == 0 {
+		data = append(append(make([]byte, 0, len(rw.header)+len(p)), rw.header...), p...)
+	}
+	n, err := rw.Logger.Write(data)
+	rw.size += int64(n)
+	if n >= len(data)-len(p) {
+		n -= len(data) - len(p)
+	} else {
+		n = 0
+	}
+
+	return n, err
+}
+
+//Rotate close current file and open a new one. Header is written before next Write()
+func (rw *rollingWriter) Rotate() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if err := rw.Logger.Rotate(); err != nil {
+		return err
+	}
+	rw.size = 0
+
+	return nil
+}
+
+func (rw *rollingWriter) RotationInterval() time.Duration {
+	return rw.rotation
+}
+
+func (rw *rollingWriter) DisableScheduledRotation() {
+	rw.stopTicker()
+}
+
+//Close stop rotation goroutine and close current file
+func (rw *rollingWriter) Close() error {
+	rw.stopTicker()
+
+	return rw.Logger.Close()
+}
+
+func (rw *rollingWriter) stopTicker() {
+	rw.closeOnce.Do(func() {
+		rw.ticker.Stop()
+		close(rw.closed)
+	})
 }
@@ -10,8 +10,12 @@ import (
 	"github.com/ksensehq/eventnative/events"
 	"github.com/ksensehq/eventnative/handlers"
 	"github.com/ksensehq/eventnative/logging"
+	"github.com/ksensehq/eventnative/metrics"
 	"github.com/ksensehq/eventnative/middleware"
+	"github.com/ksensehq/eventnative/privacy"
 	"github.com/ksensehq/eventnative/storages"
+	"github.com/ksensehq/eventnative/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	"math/rand"
 	"net/http"
@@ -71,13 +75,26 @@ func main() {
 		log.Fatal(err)
 	}
 
+	logLevel, err := logging.ParseLevel(viper.GetString("log.level"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	logging.SetDefaultLogger(logging.NewStdLogger(logLevel))
+	if summaryIntervalSec := viper.GetInt("log.summary_interval_sec"); summaryIntervalSec > 0 {
+		logging.SetSummaryInterval(time.Duration(summaryIntervalSec) * time.Second)
+	}
+	if viper.IsSet("tracing.log_threshold_ms") {
+		threshold := time.Duration(viper.GetInt("tracing.log_threshold_ms")) * time.Millisecond
+		tracing.SetTracer(tracing.NewLogTracer(logging.DefaultLogger().With("component", "tracing"), threshold))
+	}
+
 	//listen to shutdown signal to free up all resources
 	ctx, cancel := context.WithCancel(context.Background())
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL, syscall.SIGHUP)
 	go func() {
 		<-c
-		appstatus.Instance.Idle = true
+		appstatus.Instance.SetIdle()
 		cancel()
 		appconfig.Instance.Close()
 		os.Exit(0)
@@ -105,17 +122,84 @@ func main() {
 
 	//logger consumers per token
 	loggingConsumers := map[string]events.Consumer{}
+	overflowPolicy, err := events.ParseOverflowPolicy(viper.GetString("log.overflow_policy"))
+	if err != nil {
+		log.Fatal(err)
+	}
 	for token := range appconfig.Instance.AuthorizedTokens {
-		eventLogWriter, err := logging.NewWriter(logging.Config{
+		writerConfig := logging.Config{
 			LoggerName:  "event-" + token,
 			ServerName:  appconfig.Instance.ServerName,
 			FileDir:     logEventPath,
-			RotationMin: viper.GetInt64("log.rotation_min")})
-		if err != nil {
-			log.Fatal(err)
+			RotationMin: viper.GetInt64("log.rotation_min"),
+			MaxSizeMB:   viper.GetInt("log.max_size_mb")}
+		loggerOptions := events.AsyncLoggerOptions{
+			ShowInGlobalLogger: viper.GetBool("log.show_in_server"),
+			BufferSize:         viper.GetInt("log.buffer_size"),
+			OverflowPolicy:     overflowPolicy,
+			BatchSize:          viper.GetInt("log.batch_size"),
+			BatchInterval:      time.Duration(viper.GetInt("log.batch_interval_ms")) * time.Millisecond,
+			LineSeparator:      viper.GetString("log.line_separator"),
+			BOM:                viper.GetBool("log.bom"),
+			Metrics:            metrics.NewAsyncLogger("event-" + token)}
+		var logger events.Consumer
+		if shardField := viper.GetString("log.shard_field"); shardField != "" {
+			if viper.GetBool("log.persistent_queue") {
+				log.Fatal("log.shard_field can't be used with log.persistent_queue")
+			}
+			//one file per shard: $serverName-event-$token.$shard.log. Shards share token metrics
+			logger, err = events.NewShardedAsyncLogger(shardField, viper.GetInt("log.max_open_shards"), func(shard string) (*events.AsyncLogger, error) {
+				shardConfig := writerConfig
+				shardConfig.LoggerName += events.ShardSeparator + shard
+				shardWriter, err := logging.NewWriter(shardConfig)
+				if err != nil {
+					return nil, err
+				}
+				return events.NewAsyncLoggerWithOptions(shardWriter, loggerOptions), nil
+			}, nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			eventLogWriter, err := logging.NewWriter(writerConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if viper.GetBool("log.persistent_queue") {
+				//accepted events survive crash: they are written after restart
+				logger, err = events.NewPersistentAsyncLogger(eventLogWriter, logEventPath, "event-"+token+"-queue", loggerOptions)
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				logger = events.NewAsyncLoggerWithOptions(eventLogWriter, loggerOptions)
+			}
+		}
+		//batch destinations load events from log files so they are masked before writing
+		loggingConsumers[token] = privacy.NewMaskingConsumer(logger, appconfig.Instance.Masker)
+		if viper.IsSet("log.dedup") {
+			dedupConfig := &storages.Dedup{
+				KeyField:   viper.GetString("log.dedup.key_field"),
+				WindowSize: viper.GetInt("log.dedup.window_size"),
+				WindowSec:  viper.GetInt("log.dedup.window_sec")}
+			deduplicated, err := storages.CreateDedupConsumer("event-"+token, "log", loggingConsumers[token], dedupConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			loggingConsumers[token] = deduplicated
+		}
+		//ids are generated before dedup and written to log files with events
+		if viper.IsSet("log.event_id") {
+			idConfig := &storages.EventId{
+				Field:      viper.GetString("log.event_id.field"),
+				Strategy:   viper.GetString("log.event_id.strategy"),
+				HashFields: viper.GetStringSlice("log.event_id.hash_fields")}
+			identified, err := storages.CreateIdConsumer("event-"+token, "log", loggingConsumers[token], idConfig)
+			if err != nil {
+				log.Fatal(err)
+			}
+			loggingConsumers[token] = identified
 		}
-		logger := events.NewAsyncLogger(eventLogWriter, viper.GetBool("log.show_in_server"))
-		loggingConsumers[token] = logger
 		appconfig.Instance.ScheduleClosing(logger)
 	}
 
@@ -175,6 +259,9 @@ func SetupRouter(tokenizedEventConsumers map[string][]events.Consumer) *gin.Engi
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
+	router.GET("/prometheus", gin.WrapH(promhttp.Handler()))
+	//readiness probe: 503 if at least one destination or event logger is unhealthy
+	router.GET("/health", handlers.NewHealthHandler(tokenizedEventConsumers).Handler)
 
 	publicUrl := viper.GetString("server.public_url")
 
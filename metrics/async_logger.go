@@ -0,0 +1,66 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const asyncLoggerSubsystem = "async_logger"
+
+var (
+	asyncLoggerLabels = []string{"logger_name"}
+
+	writtenLogEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: asyncLoggerSubsystem,
+		Name:      "written_events_total",
+		Help:      "Count of events written to the event log",
+	}, asyncLoggerLabels)
+	logMarshalErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: asyncLoggerSubsystem,
+		Name:      "marshal_errors_total",
+		Help:      "Count of events which couldn't be serialized",
+	}, asyncLoggerLabels)
+	logWriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: asyncLoggerSubsystem,
+		Name:      "write_errors_total",
+		Help:      "Count of events which couldn't be written to the event log because of writer errors",
+	}, asyncLoggerLabels)
+	droppedLogEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: asyncLoggerSubsystem,
+		Name:      "dropped_events_total",
+		Help:      "Count of events which were skipped because the events channel is full",
+	}, asyncLoggerLabels)
+	logQueueLen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: asyncLoggerSubsystem,
+		Name:      "queue_size",
+		Help:      "Current count of events in the channel which haven't been written yet",
+	}, asyncLoggerLabels)
+)
+
+func init() {
+	prometheus.MustRegister(writtenLogEvents, logMarshalErrors, logWriteErrors, droppedLogEvents, logQueueLen)
+}
+
+//AsyncLogger is a set of event log metrics with bound logger name label
+type AsyncLogger struct {
+	Written       prometheus.Counter
+	MarshalErrors prometheus.Counter
+	WriteErrors   prometheus.Counter
+	Dropped       prometheus.Counter
+
+	QueueLen prometheus.Gauge
+}
+
+func NewAsyncLogger(loggerName string) *AsyncLogger {
+	labels := prometheus.Labels{"logger_name": loggerName}
+	return &AsyncLogger{
+		Written:       writtenLogEvents.With(labels),
+		MarshalErrors: logMarshalErrors.With(labels),
+		WriteErrors:   logWriteErrors.With(labels),
+		Dropped:       droppedLogEvents.With(labels),
+
+		QueueLen: logQueueLen.With(labels),
+	}
+}
@@ -0,0 +1,21 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const schemaSubsystem = "schema"
+
+var addedColumns = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: schemaSubsystem,
+	Name:      "added_columns_total",
+	Help:      "Count of columns which were added to destination tables automatically (schema drift)",
+}, []string{"destination_type", "storage_name", "table"})
+
+func init() {
+	prometheus.MustRegister(addedColumns)
+}
+
+//AddedColumns return counter of columns added to the destination table
+func AddedColumns(destinationType, storageName, table string) prometheus.Counter {
+	return addedColumns.With(prometheus.Labels{"destination_type": destinationType, "storage_name": storageName, "table": table})
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	namespace          = "eventnative"
+	streamingSubsystem = "streaming"
+)
+
+var (
+	streamingLabels = []string{"destination_type", "storage_name"}
+
+	enqueuedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "enqueued_events_total",
+		Help:      "Count of events put to the persistent queue",
+	}, streamingLabels)
+	dequeuedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "dequeued_events_total",
+		Help:      "Count of events read from the persistent queue",
+	}, streamingLabels)
+	insertedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "inserted_events_total",
+		Help:      "Count of events stored in the destination",
+	}, streamingLabels)
+	reenqueuedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "reenqueued_events_total",
+		Help:      "Count of events put to the persistent queue one more time after processing or insert failure",
+	}, streamingLabels)
+	skippedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "skipped_events_total",
+		Help:      "Count of events which were lost (couldn't be enqueued)",
+	}, streamingLabels)
+	rejectedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "rejected_events_total",
+		Help:      "Count of events which were dropped because the persistent queue is full",
+	}, streamingLabels)
+	oversizedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "oversized_events_total",
+		Help:      "Count of events which exceed max event size (they are truncated or put to the dead-letter queue)",
+	}, streamingLabels)
+	processingFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "processing_failures_total",
+		Help:      "Count of failed event processing attempts (e.g. schema processing errors)",
+	}, streamingLabels)
+	deadLetteredEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "dead_lettered_events_total",
+		Help:      "Count of events put to the dead-letter queue",
+	}, streamingLabels)
+	corruptedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "corrupted_events_total",
+		Help:      "Count of queued records which couldn't be decoded (they are put to the dead-letter queue as is)",
+	}, streamingLabels)
+	marshalFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "marshal_failures_total",
+		Help:      "Count of events which couldn't be marshaled to json (they are skipped or written to marshal failures file)",
+	}, streamingLabels)
+	insertLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "insert_duration_seconds",
+		Help:      "Latency of one table batch insert",
+		Buckets:   prometheus.DefBuckets,
+	}, streamingLabels)
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: streamingSubsystem,
+		Name:      "queue_size",
+		Help:      "Current count of events in the persistent queue",
+	}, streamingLabels)
+)
+
+func init() {
+	prometheus.MustRegister(enqueuedEvents, dequeuedEvents, insertedEvents, reenqueuedEvents, skippedEvents,
+		rejectedEvents, oversizedEvents, processingFailures, deadLetteredEvents, corruptedEvents, marshalFailures, insertLatency, queueDepth)
+}
+
+//Streaming is a set of streaming storage metrics with bound destination type and storage name labels
+type Streaming struct {
+	Enqueued   prometheus.Counter
+	Dequeued   prometheus.Counter
+	Inserted   prometheus.Counter
+	Reenqueued prometheus.Counter
+	Skipped    prometheus.Counter
+	Rejected   prometheus.Counter
+	Oversized  prometheus.Counter
+
+	ProcessingFailures prometheus.Counter
+	DeadLettered       prometheus.Counter
+	Corrupted          prometheus.Counter
+	MarshalFailures    prometheus.Counter
+
+	InsertLatency prometheus.Observer
+	QueueDepth    prometheus.Gauge
+}
+
+func NewStreaming(destinationType, storageName string) *Streaming {
+	labels := prometheus.Labels{"destination_type": destinationType, "storage_name": storageName}
+	return &Streaming{
+		Enqueued:   enqueuedEvents.With(labels),
+		Dequeued:   dequeuedEvents.With(labels),
+		Inserted:   insertedEvents.With(labels),
+		Reenqueued: reenqueuedEvents.With(labels),
+		Skipped:    skippedEvents.With(labels),
+		Rejected:   rejectedEvents.With(labels),
+		Oversized:  oversizedEvents.With(labels),
+
+		ProcessingFailures: processingFailures.With(labels),
+		DeadLettered:       deadLetteredEvents.With(labels),
+		Corrupted:          corruptedEvents.With(labels),
+		MarshalFailures:    marshalFailures.With(labels),
+
+		InsertLatency: insertLatency.With(labels),
+		QueueDepth:    queueDepth.With(labels),
+	}
+}
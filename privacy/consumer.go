@@ -0,0 +1,53 @@
+package privacy
+
+import (
+	"context"
+	"github.com/ksensehq/eventnative/events"
+	"log"
+)
+
+//MaskingConsumer masks facts with Masker and passes them to underlying consumer
+//It must wrap storage directly (before schema.Processor) so masked values define columns and types
+type MaskingConsumer struct {
+	consumer events.Consumer
+	masker   *Masker
+}
+
+//NewMaskingConsumer return MaskingConsumer or consumer as is if masker is nil
+func NewMaskingConsumer(consumer events.Consumer, masker *Masker) events.Consumer {
+	if masker == nil {
+		return consumer
+	}
+
+	return &MaskingConsumer{consumer: consumer, masker: masker}
+}
+
+//Consume pass masked fact to underlying consumer
+func (mc *MaskingConsumer) Consume(fact events.Fact) {
+	mc.consumer.Consume(mc.masker.Mask(fact))
+}
+
+//ConsumeCtx pass masked fact with ctx to underlying consumer (see events.ConsumeCtx)
+func (mc *MaskingConsumer) ConsumeCtx(ctx context.Context, fact events.Fact) error {
+	return events.ConsumeCtx(ctx, mc.consumer, mc.masker.Mask(fact))
+}
+
+//DeadLetter put masked fact to the dead-letter queue of underlying consumer (if it has one, see events.DeadLetterer)
+//so not masked values aren't stored anywhere
+func (mc *MaskingConsumer) DeadLetter(fact events.Fact, reason error) {
+	if dl, ok := mc.consumer.(events.DeadLetterer); ok {
+		dl.DeadLetter(mc.masker.Mask(fact), reason)
+		return
+	}
+	log.Printf("Warn: event is invalid: %v. This event will be skipped", reason)
+}
+
+//Health return underlying consumer health
+func (mc *MaskingConsumer) Health() error {
+	return events.CheckHealth(mc.consumer)
+}
+
+//Close underlying consumer
+func (mc *MaskingConsumer) Close() error {
+	return mc.consumer.Close()
+}
@@ -0,0 +1,120 @@
+package privacy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"strings"
+)
+
+const (
+	DropAction   = "drop"
+	Sha256Action = "sha256"
+	RedactAction = "redact"
+
+	RedactedValue = "***"
+)
+
+var maskingActions = []string{DropAction, Sha256Action, RedactAction}
+
+//MaskingRule is a masking action applied to one field
+type MaskingRule struct {
+	path   []string
+	action string
+}
+
+//Masker applies masking rules to facts: drops fields, replaces values with salted sha256 hex or with RedactedValue
+type Masker struct {
+	rules []*MaskingRule
+	salt  string
+}
+
+//NewMasker return Masker with parsed rules in format: /field/subfield -> action
+//where action is one of: drop, sha256, redact e.g. /user/email -> sha256
+func NewMasker(rules []string, salt string) (*Masker, error) {
+	masker := &Masker{salt: salt}
+	for _, rule := range rules {
+		parsed, err := parseMaskingRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		masker.rules = append(masker.rules, parsed)
+	}
+
+	return masker, nil
+}
+
+func parseMaskingRule(rule string) (*MaskingRule, error) {
+	parts := strings.Split(rule, "->")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Malformed masking rule [%s]. Use format: /field1/subfield1 -> action where action is one of: %s", rule, strings.Join(maskingActions, ", "))
+	}
+
+	path := strings.Trim(strings.TrimSpace(parts[0]), "/")
+	if path == "" {
+		return nil, fmt.Errorf("Malformed masking rule [%s]: field path is empty", rule)
+	}
+	action := strings.TrimSpace(parts[1])
+	for _, supported := range maskingActions {
+		if action == supported {
+			return &MaskingRule{path: strings.Split(path, "/"), action: action}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unsupported masking action [%s] in rule [%s]. Supported: %s", action, rule, strings.Join(maskingActions, ", "))
+}
+
+//Mask return masked copy of the fact or the fact as is if it doesn't have masked fields
+//Only objects on masked paths are copied because the same fact is passed to other consumers
+func (m *Masker) Mask(fact events.Fact) events.Fact {
+	masked := map[string]interface{}(fact)
+	copied := false
+	for _, rule := range m.rules {
+		if fact.Get(strings.Join(rule.path, "/")) == nil {
+			continue
+		}
+		if !copied {
+			masked = copyObject(masked)
+			copied = true
+		}
+		m.apply(masked, rule)
+	}
+
+	return events.Fact(masked)
+}
+
+//apply rule to already copied root object: copy nested objects on the path and mask the last key
+func (m *Masker) apply(object map[string]interface{}, rule *MaskingRule) {
+	last := len(rule.path) - 1
+	for _, key := range rule.path[:last] {
+		nested := copyObject(object[key].(map[string]interface{}))
+		object[key] = nested
+		object = nested
+	}
+
+	key := rule.path[last]
+	switch rule.action {
+	case DropAction:
+		delete(object, key)
+	case Sha256Action:
+		object[key] = m.hash(object[key])
+	case RedactAction:
+		object[key] = RedactedValue
+	}
+}
+
+//Return hex encoded sha256 of salt + value string representation
+func (m *Masker) hash(value interface{}) string {
+	sum := sha256.Sum256([]byte(m.salt + fmt.Sprint(value)))
+	return hex.EncodeToString(sum[:])
+}
+
+func copyObject(object map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(object))
+	for k, v := range object {
+		copied[k] = v
+	}
+
+	return copied
+}
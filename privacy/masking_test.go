@@ -0,0 +1,81 @@
+package privacy
+
+import (
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		input    events.Fact
+		expected events.Fact
+	}{
+		{
+			"Empty rules",
+			nil,
+			events.Fact{"email": "a@b.com"},
+			events.Fact{"email": "a@b.com"},
+		},
+		{
+			"Field doesn't exist",
+			[]string{"/user/email -> sha256"},
+			events.Fact{"user": "abc"},
+			events.Fact{"user": "abc"},
+		},
+		{
+			"Drop nested field",
+			[]string{"/eventn_ctx/ip -> drop"},
+			events.Fact{"eventn_ctx": map[string]interface{}{"ip": "10.0.0.1", "event_id": "1"}},
+			events.Fact{"eventn_ctx": map[string]interface{}{"event_id": "1"}},
+		},
+		{
+			"Redact field",
+			[]string{"/name -> redact"},
+			events.Fact{"name": "John", "age": 30},
+			events.Fact{"name": RedactedValue, "age": 30},
+		},
+		{
+			"Salted sha256",
+			[]string{"/user/email->sha256"},
+			events.Fact{"user": map[string]interface{}{"email": "a@b.com"}},
+			events.Fact{"user": map[string]interface{}{"email": "d3bdaa92b6373f6067a450fb11488f88965636df6452f34eff6ffaf7803b1db0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masker, err := NewMasker(tt.rules, "salt")
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expected, masker.Mask(tt.input))
+		})
+	}
+}
+
+func TestMaskDoesntChangeInput(t *testing.T) {
+	masker, err := NewMasker([]string{"/user/email -> drop"}, "")
+	require.NoError(t, err)
+
+	input := events.Fact{"user": map[string]interface{}{"email": "a@b.com"}}
+	masker.Mask(input)
+	require.Equal(t, events.Fact{"user": map[string]interface{}{"email": "a@b.com"}}, input)
+}
+
+func TestNewMaskerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"Without action", "/user/email"},
+		{"Empty path", "/ -> drop"},
+		{"Unsupported action", "/user/email -> md5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMasker([]string{tt.rule}, "")
+			require.Error(t, err)
+		})
+	}
+}
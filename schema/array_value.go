@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	textArrayColumnType    = "text[]"
+	bigintArrayColumnType  = "bigint[]"
+	doubleArrayColumnType  = "double precision[]"
+	booleanArrayColumnType = "boolean[]"
+)
+
+//ArrayValue is a homogeneous scalar array of an event (see array types in NewProcessor)
+//It is marshaled as json array in json files and as Postgres array literal (e.g. {"a","b"}) in sql inserts
+type ArrayValue struct {
+	Elements []interface{}
+	//Postgres column type e.g. text[] or bigint[]
+	SqlType string
+}
+
+func (av ArrayValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(av.Elements)
+}
+
+//Value implements driver.Valuer: return Postgres array literal. Strings are quoted and escaped
+func (av ArrayValue) Value() (driver.Value, error) {
+	var literal strings.Builder
+	literal.WriteString("{")
+	for i, element := range av.Elements {
+		if i > 0 {
+			literal.WriteString(",")
+		}
+		if s, ok := element.(string); ok {
+			literal.WriteString(`"`)
+			literal.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s))
+			literal.WriteString(`"`)
+		} else {
+			literal.WriteString(fmt.Sprintf("%v", element))
+		}
+	}
+	literal.WriteString("}")
+
+	return literal.String(), nil
+}
+
+//Return ArrayValue if all elements are scalars of one type (strings, integers, numbers or booleans) and
+//JSONValue for arrays of objects, nested arrays and mixed types. Integer and float numbers are stored as numbers
+//Empty arrays are text arrays: empty array literal is valid for all array column types
+func arrayValue(elements []interface{}) interface{} {
+	if len(elements) == 0 {
+		return ArrayValue{Elements: elements, SqlType: textArrayColumnType}
+	}
+
+	sqlType := ""
+	for _, element := range elements {
+		elementType := ""
+		switch v := element.(type) {
+		case string:
+			elementType = textArrayColumnType
+		case bool:
+			elementType = booleanArrayColumnType
+		case json.Number:
+			if _, err := v.Int64(); err == nil {
+				elementType = bigintArrayColumnType
+			} else {
+				elementType = doubleArrayColumnType
+			}
+		case int, int64:
+			elementType = bigintArrayColumnType
+		case float64:
+			elementType = doubleArrayColumnType
+		default:
+			return JSONValue{Data: elements}
+		}
+
+		switch {
+		case sqlType == "" || sqlType == elementType:
+			sqlType = elementType
+		case isNumberArrayType(sqlType) && isNumberArrayType(elementType):
+			//integers and floats in one array
+			sqlType = doubleArrayColumnType
+		default:
+			return JSONValue{Data: elements}
+		}
+	}
+
+	return ArrayValue{Elements: elements, SqlType: sqlType}
+}
+
+func isNumberArrayType(sqlType string) bool {
+	return sqlType == bigintArrayColumnType || sqlType == doubleArrayColumnType
+}
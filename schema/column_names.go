@@ -0,0 +1,152 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//hash suffix length with '_' delimiter
+const hashSuffixLength = 9
+
+//DefaultFlattenDelimiter is put between nested keys of flatten column names if delimiter isn't configured
+const DefaultFlattenDelimiter = "_"
+
+//flatten keys are lowercase so delimiter must be lowercase too. All these characters are allowed in not quoted
+//identifiers of all destinations
+var validFlattenDelimiter = regexp.MustCompile(`^[a-z0-9_]{1,8}$`)
+
+//ColumnNamesConfig configures flatten keys to column names transformation
+type ColumnNamesConfig struct {
+	//convert keys to snake_case (userId -> user_id) and replace not allowed characters with '_'
+	SnakeCase bool
+	//max column name length (e.g. 63 in Postgres). Longer names are truncated with hash suffix. 0 - unlimited
+	MaxLength int
+	//put between nested keys: user + email -> user__email if it is '__'. DefaultFlattenDelimiter if not set
+	//SnakeCase is applied to keys only (delimiter is kept as is) and MaxLength includes delimiters
+	Delimiter string
+	//keep keys case (userId and userid are different columns). Otherwise keys are lowercased
+	//Destination must quote identifiers (e.g. Postgres) and mapping rules must use the same case
+	CaseSensitive bool
+}
+
+//Validate delimiter (lowercase letters, digits and underscores, not longer than 8 characters) and that
+//case sensitive names aren't converted to snake_case
+func (cnc ColumnNamesConfig) Validate() error {
+	if cnc.Delimiter != "" && !validFlattenDelimiter.MatchString(cnc.Delimiter) {
+		return fmt.Errorf("Malformed flatten delimiter [%s]: it must contain only lowercase letters, digits and underscores (max 8 characters)", cnc.Delimiter)
+	}
+	if cnc.CaseSensitive && cnc.SnakeCase {
+		return errors.New("Case sensitive column names can't be converted to snake_case")
+	}
+	return nil
+}
+
+//FlattenDelimiter return configured delimiter or DefaultFlattenDelimiter
+func (cnc ColumnNamesConfig) FlattenDelimiter() string {
+	if cnc.Delimiter == "" {
+		return DefaultFlattenDelimiter
+	}
+
+	return cnc.Delimiter
+}
+
+//Enabled return true if any transformation is configured
+func (cnc ColumnNamesConfig) Enabled() bool {
+	return cnc.SnakeCase || cnc.MaxLength > 0
+}
+
+//ColumnNames transforms flatten keys to column names according to ColumnNamesConfig
+//Keeps collision map: different source paths never get the same column name
+//(column of the first seen path keeps the name, others get hash suffix)
+type ColumnNames struct {
+	config ColumnNamesConfig
+
+	mutex sync.RWMutex
+	//column name -> source path
+	sources map[string]string
+	//source path -> column name
+	columns map[string]string
+}
+
+func NewColumnNames(config ColumnNamesConfig) *ColumnNames {
+	return &ColumnNames{config: config, sources: map[string]string{}, columns: map[string]string{}}
+}
+
+//Segment return transformed nested key
+func (cn *ColumnNames) Segment(key string) string {
+	if !cn.config.SnakeCase {
+		return key
+	}
+
+	return toSnakeCase(key)
+}
+
+//Column return unique column name for source path (e.g. /eventn_ctx/userId) and flatten key (e.g. eventn_ctx_user_id)
+func (cn *ColumnNames) Column(sourcePath, key string) string {
+	cn.mutex.RLock()
+	column, ok := cn.columns[sourcePath]
+	cn.mutex.RUnlock()
+	if ok {
+		return column
+	}
+
+	cn.mutex.Lock()
+	defer cn.mutex.Unlock()
+
+	column = key
+	if cn.config.MaxLength > 0 && len(column) > cn.config.MaxLength {
+		column = withHashSuffix(column, sourcePath, cn.config.MaxLength)
+	}
+	if source, ok := cn.sources[column]; ok && source != sourcePath {
+		column = withHashSuffix(key, sourcePath, cn.config.MaxLength)
+	}
+
+	cn.sources[column] = sourcePath
+	cn.columns[sourcePath] = column
+
+	return column
+}
+
+//Return name truncated to maxLength (if maxLength > 0) with hash of source path suffix
+func withHashSuffix(name, sourcePath string, maxLength int) string {
+	h := fnv.New32a()
+	h.Write([]byte(sourcePath))
+	suffix := fmt.Sprintf("_%08x", h.Sum32())
+
+	if maxLength > 0 && len(name)+hashSuffixLength > maxLength {
+		prefixLength := maxLength - hashSuffixLength
+		if prefixLength < 0 {
+			prefixLength = 0
+		}
+		name = name[:prefixLength]
+	}
+
+	return name + suffix
+}
+
+//Return snake_case key: userId -> user_id, HTTPCode -> http_code, page-view -> page_view
+func toSnakeCase(key string) string {
+	runes := []rune(key)
+	var builder strings.Builder
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1]))) {
+				builder.WriteRune('_')
+			}
+			builder.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"github.com/ksensehq/eventnative/test"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"user_id", "user_id"},
+		{"userId", "user_id"},
+		{"UserID", "user_id"},
+		{"HTTPCode", "http_code"},
+		{"page-view", "page_view"},
+		{"utm.source", "utm_source"},
+		{"key1Value", "key1_value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			require.Equal(t, tt.expected, toSnakeCase(tt.input))
+		})
+	}
+}
+
+func TestColumnNamesFlatten(t *testing.T) {
+	longKey := strings.Repeat("a", 70)
+	tests := []struct {
+		name         string
+		config       ColumnNamesConfig
+		inputJson    map[string]interface{}
+		expectedJson map[string]interface{}
+	}{
+		{
+			"Snake case nested keys",
+			ColumnNamesConfig{SnakeCase: true},
+			map[string]interface{}{"eventnCtx": map[string]interface{}{"userId": "1"}, "event-type": "view"},
+			map[string]interface{}{"eventn_ctx_user_id": "1", "event_type": "view"},
+		},
+		{
+			"Long name is truncated with hash suffix",
+			ColumnNamesConfig{MaxLength: 63},
+			map[string]interface{}{longKey: "1", "key2": "2"},
+			map[string]interface{}{strings.Repeat("a", 54) + "_" + "c9204e40": "1", "key2": "2"},
+		},
+		{
+			"Different paths get different columns",
+			ColumnNamesConfig{MaxLength: 63},
+			map[string]interface{}{"key1": map[string]interface{}{"key2": "1"}},
+			map[string]interface{}{"key1_key2_822d2c96": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("", []string{}, ProcessorConfig{ColumnNames: tt.config})
+			require.NoError(t, err)
+
+			//register key1_key2 column for another source path
+			if strings.HasPrefix(tt.name, "Different") {
+				require.Equal(t, "key1_key2", p.columnNames.Column("/key1_key2", "key1_key2"))
+			}
+
+			actualFlattenJson, err := p.flattenObject(tt.inputJson, nil)
+			require.NoError(t, err)
+			test.ObjectsEqual(t, tt.expectedJson, actualFlattenJson, "Wrong flattened json")
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"sort"
+	"sync"
+)
+
+//ColumnUsage tracks existing table columns which are absent in incoming data (see Table.Diff MissingColumns).
+//Column is unused if it hasn't been received in the last window events. Unused columns are only reported
+//(see Unused) so they can be dropped manually
+//Safe for concurrent calls
+type ColumnUsage struct {
+	window int64
+
+	mutex sync.Mutex
+	//table qualified name -> column name -> count of the latest events without column
+	missing map[string]map[string]int64
+}
+
+func NewColumnUsage(window int) *ColumnUsage {
+	return &ColumnUsage{window: int64(window), missing: map[string]map[string]int64{}}
+}
+
+//Observe count events of the diff table which don't contain diff MissingColumns. Counts of other columns are reset
+//Return sorted names of columns which have become unused with these events
+func (cu *ColumnUsage) Observe(diff *Table, events int) []string {
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
+
+	previous := cu.missing[diff.QualifiedName()]
+	current := make(map[string]int64, len(diff.MissingColumns))
+	var becameUnused []string
+	for columnName := range diff.MissingColumns {
+		count := previous[columnName] + int64(events)
+		if previous[columnName] < cu.window && count >= cu.window {
+			becameUnused = append(becameUnused, columnName)
+		}
+		current[columnName] = count
+	}
+	cu.missing[diff.QualifiedName()] = current
+
+	sort.Strings(becameUnused)
+	return becameUnused
+}
+
+//Unused return table qualified name (see Table.QualifiedName) -> sorted names of columns which haven't been received in the last window events
+//Tables without unused columns aren't returned
+func (cu *ColumnUsage) Unused() map[string][]string {
+	cu.mutex.Lock()
+	defer cu.mutex.Unlock()
+
+	report := map[string][]string{}
+	for tableName, columns := range cu.missing {
+		var unused []string
+		for columnName, count := range columns {
+			if count >= cu.window {
+				unused = append(unused, columnName)
+			}
+		}
+		if len(unused) > 0 {
+			sort.Strings(unused)
+			report[tableName] = unused
+		}
+	}
+
+	return report
+}
+
+//Forget remove table counts by qualified name (e.g. after table has been dropped)
+func (cu *ColumnUsage) Forget(qualifiedName string) {
+	cu.mutex.Lock()
+	delete(cu.missing, qualifiedName)
+	cu.mutex.Unlock()
+}
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestColumnUsage(t *testing.T) {
+	dbSchema := &Table{Name: "events", Columns: Columns{"a": Column{Type: STRING}, "b": Column{Type: STRING}, "c": Column{Type: INT64}}}
+	tests := []struct {
+		name                 string
+		dataColumns          []string
+		events               int
+		expectedBecameUnused []string
+		expectedUnused       map[string][]string
+	}{
+		{
+			"All columns are received",
+			[]string{"a", "b", "c"},
+			10,
+			nil,
+			map[string][]string{},
+		},
+		{
+			"Missing columns in less than window events",
+			[]string{"a"},
+			2,
+			nil,
+			map[string][]string{},
+		},
+		{
+			"Missing columns reach window",
+			[]string{"a"},
+			1,
+			[]string{"b", "c"},
+			map[string][]string{"events": {"b", "c"}},
+		},
+		{
+			"Unused columns are reported only once",
+			[]string{"a", "c"},
+			5,
+			nil,
+			map[string][]string{"events": {"b"}},
+		},
+		{
+			"Received column is used again",
+			[]string{"a", "b", "c"},
+			1,
+			nil,
+			map[string][]string{},
+		},
+	}
+	usage := NewColumnUsage(3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataSchema := &Table{Name: "events", Columns: Columns{}}
+			for _, name := range tt.dataColumns {
+				dataSchema.Columns[name] = Column{Type: STRING}
+			}
+
+			actual := usage.Observe(dbSchema.Diff(dataSchema), tt.events)
+			require.Equal(t, tt.expectedBecameUnused, actual, "Became unused columns aren't equal")
+			require.Equal(t, tt.expectedUnused, usage.Unused(), "Unused columns aren't equal")
+		})
+	}
+
+	usage.Observe(dbSchema.Diff(&Table{Name: "events", Columns: Columns{"a": Column{Type: STRING}}}), 3)
+	require.Equal(t, map[string][]string{"events": {"b", "c"}}, usage.Unused())
+	usage.Forget("events")
+	require.Equal(t, map[string][]string{}, usage.Unused())
+}
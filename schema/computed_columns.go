@@ -0,0 +1,395 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+var (
+	validComputedColumnName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+	errDivisionByZero = errors.New("Division by zero")
+)
+
+//ComputedColumns adds columns computed by expressions over flatten fields (e.g. revenue_usd = amount * fx_rate)
+//Expression language: flatten keys (e.g. amount, eventn_ctx_user_id), numbers (1, 0.5), 'quoted strings', parentheses,
+//+ - * / and unary minus on numbers (numeric strings are parsed), || concatenation of any values as strings
+//Columns are computed in configured order so an expression may use columns computed before it
+//Evaluation errors (missing field, not a number, division by zero) leave the column null and are counted (see Errors)
+type ComputedColumns struct {
+	columns []*computedColumn
+	errors  uint64
+}
+
+type computedColumn struct {
+	name       string
+	expression expression
+}
+
+//NewComputedColumns return ComputedColumns from definitions in format: column_name = expression
+func NewComputedColumns(definitions []string) (*ComputedColumns, error) {
+	cc := &ComputedColumns{}
+	for _, definition := range definitions {
+		parts := strings.SplitN(definition, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Malformed computed column [%s]. Use format: column_name = expression", definition)
+		}
+		name := strings.TrimSpace(parts[0])
+		if !validComputedColumnName.MatchString(name) {
+			return nil, fmt.Errorf("Malformed computed column [%s]: name must contain only lowercase letters, digits and underscores", definition)
+		}
+		expr, err := parseExpression(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("Malformed computed column [%s] expression: %v", definition, err)
+		}
+		cc.columns = append(cc.columns, &computedColumn{name: name, expression: expr})
+	}
+
+	return cc, nil
+}
+
+//Compute put values of all computed columns to flatten object. Column isn't put (null) if evaluation fails
+//Existing values are overwritten
+func (cc *ComputedColumns) Compute(flatObject map[string]interface{}) {
+	for _, column := range cc.columns {
+		value, err := column.expression.eval(flatObject)
+		if err != nil {
+			atomic.AddUint64(&cc.errors, 1)
+			delete(flatObject, column.name)
+			continue
+		}
+		flatObject[column.name] = value
+	}
+}
+
+//Names return computed column names in configured order
+func (cc *ComputedColumns) Names() []string {
+	names := make([]string, 0, len(cc.columns))
+	for _, column := range cc.columns {
+		names = append(names, column.name)
+	}
+
+	return names
+}
+
+//Errors return count of failed evaluations
+func (cc *ComputedColumns) Errors() uint64 {
+	return atomic.LoadUint64(&cc.errors)
+}
+
+//expression is a node of parsed expression tree. eval returns int64, float64 or string
+type expression interface {
+	eval(object map[string]interface{}) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (l literal) eval(map[string]interface{}) (interface{}, error) {
+	return l.value, nil
+}
+
+type field struct {
+	key string
+}
+
+func (f field) eval(object map[string]interface{}) (interface{}, error) {
+	value, ok := object[f.key]
+	if !ok || value == nil {
+		return nil, fmt.Errorf("Field %s is missing", f.key)
+	}
+
+	return value, nil
+}
+
+type negation struct {
+	operand expression
+}
+
+func (n negation) eval(object map[string]interface{}) (interface{}, error) {
+	value, err := n.operand.eval(object)
+	if err != nil {
+		return nil, err
+	}
+	number, err := toNumber(value)
+	if err != nil {
+		return nil, err
+	}
+	if i, ok := number.(int64); ok {
+		return -i, nil
+	}
+
+	return -number.(float64), nil
+}
+
+type binary struct {
+	operator    string
+	left, right expression
+}
+
+func (b binary) eval(object map[string]interface{}) (interface{}, error) {
+	left, err := b.left.eval(object)
+	if err != nil {
+		return nil, err
+	}
+	right, err := b.right.eval(object)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.operator == "||" {
+		return fmt.Sprint(left) + fmt.Sprint(right), nil
+	}
+
+	leftNumber, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	rightNumber, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	leftInt, leftIsInt := leftNumber.(int64)
+	rightInt, rightIsInt := rightNumber.(int64)
+	if leftIsInt && rightIsInt && b.operator != "/" {
+		switch b.operator {
+		case "+":
+			return leftInt + rightInt, nil
+		case "-":
+			return leftInt - rightInt, nil
+		default:
+			return leftInt * rightInt, nil
+		}
+	}
+
+	l, r := toFloat(leftNumber), toFloat(rightNumber)
+	switch b.operator {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	default:
+		if r == 0 {
+			return nil, errDivisionByZero
+		}
+		return l / r, nil
+	}
+}
+
+//Return int64 or float64 from number or numeric string (flatten values are strings if numeric types are disabled)
+func toNumber(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int64, float64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		return parseNumber(v.String())
+	case string:
+		return parseNumber(v)
+	default:
+		return nil, fmt.Errorf("Value %v (%T) isn't a number", value, value)
+	}
+}
+
+func parseNumber(value string) (interface{}, error) {
+	s := strings.TrimSpace(value)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("Value [%s] isn't a number", value)
+}
+
+func toFloat(number interface{}) float64 {
+	if i, ok := number.(int64); ok {
+		return float64(i)
+	}
+
+	return number.(float64)
+}
+
+//expressionParser is a recursive descent parser. Precedence (from lowest): ||, + -, * /, unary -
+type expressionParser struct {
+	tokens   []string
+	position int
+}
+
+func parseExpression(input string) (expression, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("Expression is empty")
+	}
+
+	parser := &expressionParser{tokens: tokens}
+	expr, err := parser.concat()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(tokens) {
+		return nil, fmt.Errorf("Unexpected token [%s]", tokens[parser.position])
+	}
+
+	return expr, nil
+}
+
+func (ep *expressionParser) peek() string {
+	if ep.position < len(ep.tokens) {
+		return ep.tokens[ep.position]
+	}
+
+	return ""
+}
+
+func (ep *expressionParser) next() string {
+	token := ep.peek()
+	ep.position++
+	return token
+}
+
+func (ep *expressionParser) concat() (expression, error) {
+	return ep.binary(ep.additive, "||")
+}
+
+func (ep *expressionParser) additive() (expression, error) {
+	return ep.binary(ep.term, "+", "-")
+}
+
+func (ep *expressionParser) term() (expression, error) {
+	return ep.binary(ep.unary, "*", "/")
+}
+
+//Parse left associative sequence of operands joined with operators
+func (ep *expressionParser) binary(operand func() (expression, error), operators ...string) (expression, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator := ep.peek()
+		matched := false
+		for _, o := range operators {
+			if operator == o {
+				matched = true
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		ep.next()
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{operator: operator, left: left, right: right}
+	}
+}
+
+func (ep *expressionParser) unary() (expression, error) {
+	if ep.peek() == "-" {
+		ep.next()
+		operand, err := ep.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand: operand}, nil
+	}
+
+	return ep.primary()
+}
+
+func (ep *expressionParser) primary() (expression, error) {
+	token := ep.next()
+	switch {
+	case token == "":
+		return nil, errors.New("Unexpected end of expression")
+	case token == "(":
+		expr, err := ep.concat()
+		if err != nil {
+			return nil, err
+		}
+		if ep.next() != ")" {
+			return nil, errors.New("Missing closing parenthesis")
+		}
+		return expr, nil
+	case strings.HasPrefix(token, "'"):
+		return literal{value: strings.ReplaceAll(token[1:len(token)-1], "''", "'")}, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		number, err := parseNumber(token)
+		if err != nil {
+			return nil, err
+		}
+		return literal{value: number}, nil
+	case token[0] == '_' || unicode.IsLetter(rune(token[0])):
+		return field{key: strings.ToLower(token)}, nil
+	default:
+		return nil, fmt.Errorf("Unexpected token [%s]", token)
+	}
+}
+
+//Split input into operators, parentheses, numbers, 'strings' (” is an escaped quote) and keys
+func tokenize(input string) ([]string, error) {
+	var tokens []string
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '|':
+			if i+1 >= len(runes) || runes[i+1] != '|' {
+				return nil, errors.New("Unexpected [|]. Use || for concatenation")
+			}
+			tokens = append(tokens, "||")
+			i += 2
+		case strings.ContainsRune("+-*/()", r):
+			tokens = append(tokens, string(r))
+			i++
+		case r == '\'':
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == '\'' {
+					if j+1 < len(runes) && runes[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(runes) {
+				return nil, errors.New("Unterminated string literal")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case unicode.IsDigit(r) || r == '.' || r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == '_' || unicode.IsLetter(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("Unexpected character [%c]", r)
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestComputedColumns(t *testing.T) {
+	tests := []struct {
+		name           string
+		definitions    []string
+		input          map[string]interface{}
+		expectedObject map[string]interface{}
+		expectedErrors uint64
+	}{
+		{
+			"Arithmetic over numeric strings",
+			[]string{"revenue_usd = amount * fx_rate", "total = (price + tax) * -2", "half = count / 2"},
+			map[string]interface{}{"amount": "10", "fx_rate": "1.5", "price": int64(3), "tax": int64(1), "count": "5"},
+			map[string]interface{}{"amount": "10", "fx_rate": "1.5", "price": int64(3), "tax": int64(1), "count": "5",
+				"revenue_usd": float64(15), "total": int64(-8), "half": 2.5},
+			0,
+		},
+		{
+			"Concatenation and chained columns",
+			[]string{"full_name = first_name || ' ' || last_name", "greeting = 'It''s ' || full_name || ' ' || age + 1"},
+			map[string]interface{}{"first_name": "John", "last_name": "Doe", "age": int64(30)},
+			map[string]interface{}{"first_name": "John", "last_name": "Doe", "age": int64(30),
+				"full_name": "John Doe", "greeting": "It's John Doe 31"},
+			0,
+		},
+		{
+			"Evaluation errors leave columns null",
+			[]string{"missing = amount * fx_rate", "mismatch = name + 1", "zero = amount / 0"},
+			map[string]interface{}{"amount": "10", "name": "abc", "mismatch": "old"},
+			map[string]interface{}{"amount": "10", "name": "abc"},
+			3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc, err := NewComputedColumns(tt.definitions)
+			require.NoError(t, err)
+			cc.Compute(tt.input)
+			require.Equal(t, tt.expectedObject, tt.input)
+			require.Equal(t, tt.expectedErrors, cc.Errors())
+		})
+	}
+}
+
+func TestComputedColumnsMalformed(t *testing.T) {
+	for _, definition := range []string{"revenue", "Revenue = a", "revenue = ", "revenue = (a + b", "revenue = a + ", "revenue = a | b",
+		"revenue = 'abc", "revenue = a b", "revenue = a % b"} {
+		_, err := NewComputedColumns([]string{definition})
+		require.Error(t, err, definition)
+	}
+}
+
+func TestProcessFactComputedColumns(t *testing.T) {
+	p, err := NewProcessor(`{{.event_type}}_{{.currency}}`, []string{"/_timestamp -> ", "/fx_rate -> "}, ProcessorConfig{
+		ComputedColumns: []string{"revenue_usd = order_amount * fx_rate", "currency = 'usd'"}})
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": "order",
+		"order": map[string]interface{}{"amount": "10"}, "fx_rate": 2})
+	require.NoError(t, err)
+	require.Equal(t, "order_usd", table.Name, "Computed columns must be available in table name template")
+	require.Equal(t, map[string]interface{}{"event_type": "order", "order_amount": "10", "currency": "usd", "revenue_usd": int64(20)}, object)
+	require.Equal(t, Column{Type: INT64}, table.Columns["revenue_usd"])
+
+	_, object, err = p.ProcessFact(events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": "order"})
+	require.NoError(t, err)
+	_, ok := object["revenue_usd"]
+	require.False(t, ok, "Column must be null if evaluation fails")
+	require.Equal(t, uint64(1), p.ComputedColumnErrors())
+}
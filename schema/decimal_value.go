@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//max precision of Postgres numeric type with declared precision
+const maxDecimalPrecision = 1000
+
+var decimalNumberRegexp = regexp.MustCompile(`^[-+]?(\d+(\.\d*)?|\.\d+)([eE][-+]?\d+)?$`)
+
+//DecimalValue is a number of decimal field (see ProcessorConfig.DecimalFields) which is kept as exact decimal text without float64 rounding
+//It is marshaled as json number in json files and as decimal string in sql inserts: numeric column parses it exactly
+//(and rounds it to the column scale)
+type DecimalValue struct {
+	Number string
+	//Postgres column type e.g. numeric(18,2)
+	SqlType string
+}
+
+func (dv DecimalValue) MarshalJSON() ([]byte, error) {
+	return []byte(dv.Number), nil
+}
+
+//Value implements driver.Valuer: return decimal string
+func (dv DecimalValue) Value() (driver.Value, error) {
+	return dv.Number, nil
+}
+
+//Return decimal value of json number, number or numeric string. Return false for other values
+func decimalValue(value interface{}, sqlType string) (DecimalValue, bool) {
+	var number string
+	switch v := value.(type) {
+	case string:
+		number = strings.TrimSpace(v)
+	case fmt.Stringer:
+		//json.Number
+		number = v.String()
+	case float64:
+		number = strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		number = strconv.FormatInt(v, 10)
+	case int:
+		number = strconv.Itoa(v)
+	default:
+		return DecimalValue{}, false
+	}
+	if !decimalNumberRegexp.MatchString(number) {
+		return DecimalValue{}, false
+	}
+
+	return DecimalValue{Number: number, SqlType: sqlType}, true
+}
+
+//Return source path and numeric(precision,scale) column type from rule in format: /field1/subfield1 -> precision,scale
+func parseDecimalRule(rule string) (string, string, error) {
+	parts := strings.Split(rule, "->")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("Malformed decimal field rule [%s]. Use format: /field1/subfield1 -> precision,scale", rule)
+	}
+	sourcePath := strings.TrimSuffix(strings.TrimSpace(parts[0]), "/")
+	if !strings.HasPrefix(sourcePath, "/") {
+		return "", "", fmt.Errorf("Malformed decimal field rule [%s]: path must start with /", rule)
+	}
+
+	precisionScale := strings.Split(parts[1], ",")
+	if len(precisionScale) != 2 {
+		return "", "", fmt.Errorf("Malformed decimal field rule [%s]. Use format: /field1/subfield1 -> precision,scale", rule)
+	}
+	precision, err := strconv.Atoi(strings.TrimSpace(precisionScale[0]))
+	if err != nil || precision < 1 || precision > maxDecimalPrecision {
+		return "", "", fmt.Errorf("Malformed decimal field rule [%s]: precision must be an integer from 1 to %d", rule, maxDecimalPrecision)
+	}
+	scale, err := strconv.Atoi(strings.TrimSpace(precisionScale[1]))
+	if err != nil || scale < 0 || scale > precision {
+		return "", "", fmt.Errorf("Malformed decimal field rule [%s]: scale must be an integer from 0 to precision", rule)
+	}
+
+	return sourcePath, fmt.Sprintf("numeric(%d,%d)", precision, scale), nil
+}
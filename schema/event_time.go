@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ksensehq/eventnative/timestamp"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//DefaultEventTimeColumn is populated with canonical event time if column isn't configured
+	DefaultEventTimeColumn = "timestamp"
+
+	RFC3339Format      = "rfc3339"
+	EpochMillisFormat  = "epoch_millis"
+	EpochSecondsFormat = "epoch_seconds"
+
+	//RejectMissingEventTime - event without parseable event time isn't processed (default)
+	RejectMissingEventTime = "reject"
+	//NowMissingEventTime - current time is used if event time is missing or unparseable
+	NowMissingEventTime = "now"
+)
+
+//DefaultEventTimeFormats are accepted if formats aren't configured
+var DefaultEventTimeFormats = []string{RFC3339Format, timestamp.Layout, EpochMillisFormat}
+
+//EventTimeConfig configures canonical event time: the first existing source field is parsed with accepted formats
+//and stored in UTC in one column (e.g. for partitioning and retention)
+type EventTimeConfig struct {
+	//source paths (e.g. /timestamp or /eventn_ctx/utc_time) in priority order. Empty - event time isn't normalized
+	SourcePaths []string
+	//DefaultEventTimeColumn if not set
+	Column string
+	//Go time layouts or rfc3339, epoch_millis, epoch_seconds. The first format which parses value wins
+	//DefaultEventTimeFormats if not set
+	Formats []string
+	//reject (default) or now
+	OnMissing string
+}
+
+//Enabled return true if any source path is configured
+func (etc EventTimeConfig) Enabled() bool {
+	return len(etc.SourcePaths) > 0
+}
+
+//Validate source paths and missing event time behavior
+func (etc EventTimeConfig) Validate() error {
+	for _, sourcePath := range etc.SourcePaths {
+		if !strings.HasPrefix(strings.TrimSpace(sourcePath), "/") {
+			return fmt.Errorf("Malformed event time field path [%s]. Use format: /field1/subfield1", sourcePath)
+		}
+	}
+	for _, format := range etc.Formats {
+		if strings.TrimSpace(format) == "" {
+			return errors.New("Event time format can't be empty")
+		}
+	}
+	switch etc.OnMissing {
+	case "", RejectMissingEventTime, NowMissingEventTime:
+		return nil
+	default:
+		return fmt.Errorf("Unsupported missing event time behavior: %s. Supported: %s, %s", etc.OnMissing, RejectMissingEventTime, NowMissingEventTime)
+	}
+}
+
+//ColumnName return configured column or DefaultEventTimeColumn
+func (etc EventTimeConfig) ColumnName() string {
+	if etc.Column == "" {
+		return DefaultEventTimeColumn
+	}
+
+	return etc.Column
+}
+
+//EventTime return UTC event time from the first existing source field of not flattened object
+//Return current time or error (according to OnMissing) if there are no source fields or value can't be parsed
+func (etc EventTimeConfig) EventTime(object map[string]interface{}) (time.Time, error) {
+	for _, sourcePath := range etc.SourcePaths {
+		value, ok := lookupPath(object, strings.TrimSpace(sourcePath))
+		if !ok || value == nil {
+			continue
+		}
+
+		t, err := etc.parse(value)
+		if err != nil {
+			if etc.OnMissing == NowMissingEventTime {
+				return time.Now().UTC(), nil
+			}
+			return time.Time{}, fmt.Errorf("Error extracting event time: malformed %s field: %v", sourcePath, err)
+		}
+
+		return t.UTC(), nil
+	}
+
+	if etc.OnMissing == NowMissingEventTime {
+		return time.Now().UTC(), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Error extracting event time: none of %v fields exist", etc.SourcePaths)
+}
+
+//Return time parsed with the first suitable format
+func (etc EventTimeConfig) parse(value interface{}) (time.Time, error) {
+	formats := etc.Formats
+	if len(formats) == 0 {
+		formats = DefaultEventTimeFormats
+	}
+
+	if t, ok := value.(time.Time); ok {
+		return t, nil
+	}
+	//json.Number and numbers are formatted as is
+	str := fmt.Sprintf("%v", value)
+
+	for _, format := range formats {
+		switch format {
+		case EpochMillisFormat, EpochSecondsFormat:
+			unit := time.Second
+			if format == EpochMillisFormat {
+				unit = time.Millisecond
+			}
+			//integers are converted without float rounding
+			if epoch, err := strconv.ParseInt(str, 10, 64); err == nil {
+				return time.Unix(0, epoch*int64(unit)), nil
+			}
+			if epoch, err := strconv.ParseFloat(str, 64); err == nil && !math.IsInf(epoch, 0) && !math.IsNaN(epoch) {
+				//fractional values are rounded to microseconds (float64 can't keep nanoseconds of current epoch)
+				return time.Unix(0, int64(math.Round(epoch*float64(unit/time.Microsecond)))*int64(time.Microsecond)), nil
+			}
+		case RFC3339Format:
+			if t, err := time.Parse(time.RFC3339Nano, str); err == nil {
+				return t, nil
+			}
+		default:
+			if t, err := time.Parse(format, str); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("value [%s] doesn't match any of formats %v", str, formats)
+}
+
+//Return value of not flattened object by source path (e.g. /eventn_ctx/utc_time). Keys are compared case insensitively
+func lookupPath(object map[string]interface{}, sourcePath string) (interface{}, bool) {
+	var current interface{} = object
+	for _, segment := range strings.Split(strings.Trim(sourcePath, "/"), "/") {
+		node, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		found := false
+		for key, value := range node {
+			if strings.EqualFold(key, segment) {
+				current = value
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
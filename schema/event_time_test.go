@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"encoding/json"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEventTime(t *testing.T) {
+	expected := time.Date(2020, 9, 13, 12, 26, 40, 123000000, time.UTC)
+	tests := []struct {
+		name        string
+		config      EventTimeConfig
+		input       map[string]interface{}
+		expected    time.Time
+		expectedErr bool
+	}{
+		{
+			"RFC3339 with offset",
+			EventTimeConfig{SourcePaths: []string{"/timestamp"}},
+			map[string]interface{}{"timestamp": "2020-09-13T14:26:40.123+02:00"},
+			expected,
+			false,
+		},
+		{
+			"eventnative layout",
+			EventTimeConfig{SourcePaths: []string{"/_timestamp"}},
+			map[string]interface{}{"_timestamp": "2020-09-13T12:26:40.123000Z"},
+			expected,
+			false,
+		},
+		{
+			"epoch millis json number",
+			EventTimeConfig{SourcePaths: []string{"/timestamp"}},
+			map[string]interface{}{"timestamp": json.Number("1600000000123")},
+			expected,
+			false,
+		},
+		{
+			"epoch seconds",
+			EventTimeConfig{SourcePaths: []string{"/timestamp"}, Formats: []string{EpochSecondsFormat}},
+			map[string]interface{}{"timestamp": 1600000000.123},
+			expected,
+			false,
+		},
+		{
+			"nested field case insensitive with fallback",
+			EventTimeConfig{SourcePaths: []string{"/timestamp", "/eventn_ctx/utc_time"}},
+			map[string]interface{}{"eventn_ctx": map[string]interface{}{"UTC_Time": "2020-09-13T12:26:40.123Z"}},
+			expected,
+			false,
+		},
+		{
+			"custom layout",
+			EventTimeConfig{SourcePaths: []string{"/date"}, Formats: []string{"02.01.2006 15:04:05.000"}},
+			map[string]interface{}{"date": "13.09.2020 12:26:40.123"},
+			expected,
+			false,
+		},
+		{
+			"unparseable value is rejected",
+			EventTimeConfig{SourcePaths: []string{"/timestamp"}, Formats: []string{RFC3339Format}},
+			map[string]interface{}{"timestamp": "1600000000123"},
+			time.Time{},
+			true,
+		},
+		{
+			"missing field is rejected",
+			EventTimeConfig{SourcePaths: []string{"/timestamp"}, OnMissing: RejectMissingEventTime},
+			map[string]interface{}{"field1": "value1"},
+			time.Time{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.config.Validate())
+			actual, err := tt.config.EventTime(tt.input)
+			if tt.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+
+	now, err := EventTimeConfig{SourcePaths: []string{"/timestamp"}, OnMissing: NowMissingEventTime}.EventTime(map[string]interface{}{"timestamp": "yesterday"})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), now, time.Minute)
+
+	require.Error(t, EventTimeConfig{SourcePaths: []string{"timestamp"}}.Validate())
+	require.Error(t, EventTimeConfig{SourcePaths: []string{"/timestamp"}, OnMissing: "skip"}.Validate())
+}
+
+func TestProcessFactEventTime(t *testing.T) {
+	p, err := NewProcessor(`{{.event_type}}_{{.timestamp.Format "200601"}}`, []string{}, ProcessorConfig{Partition: PartitionConfig{Field: DefaultEventTimeColumn},
+		EventTime: EventTimeConfig{SourcePaths: []string{"/timestamp"}}})
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(events.Fact{"_timestamp": "2020-10-01T00:00:00.000000Z", "event_type": "click", "timestamp": json.Number("1600000000123")})
+	require.NoError(t, err)
+	require.Equal(t, "click_202009", table.Name)
+	require.Equal(t, time.Date(2020, 9, 13, 12, 26, 40, 123000000, time.UTC), object["timestamp"])
+	require.Equal(t, "2020-09-13", object[PartitionColumn])
+	require.Equal(t, TIMESTAMP, table.Columns["timestamp"].Type, "Event time must be stored in timestamp column")
+	require.Equal(t, STRING, table.Columns["_timestamp"].Type)
+
+	_, _, err = p.ProcessFact(events.Fact{"_timestamp": "2020-10-01T00:00:00.000000Z", "event_type": "click"})
+	require.Error(t, err, "Event without event time must be rejected")
+}
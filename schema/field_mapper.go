@@ -21,7 +21,12 @@ type MappingRule struct {
 	destination string
 }
 
+//NewFieldMapper return Mapper of flatten keys with DefaultFlattenDelimiter from rules in format: /field1/subfield1 -> /field2/subfield2
 func NewFieldMapper(mappings []string) (Mapper, error) {
+	return newFieldMapper(mappings, DefaultFlattenDelimiter)
+}
+
+func newFieldMapper(mappings []string, delimiter string) (Mapper, error) {
 	if len(mappings) == 0 {
 		return &DummyMapper{}, nil
 	}
@@ -36,8 +41,8 @@ func NewFieldMapper(mappings []string) (Mapper, error) {
 		}
 
 		rules = append(rules, &MappingRule{
-			source:      formatKey(parts[0]),
-			destination: formatKey(parts[1]),
+			source:      formatKey(parts[0], delimiter),
+			destination: formatKey(parts[1], delimiter),
 		})
 	}
 
@@ -69,15 +74,26 @@ func (fm FieldMapper) Map(object map[string]interface{}) map[string]interface{}
 	return mappedObject
 }
 
+//Return flatten key which is mapped to destination key by the last matched rule
+func (fm FieldMapper) sourceKey(destination string) (string, bool) {
+	for i := len(fm.rules) - 1; i >= 0; i-- {
+		if fm.rules[i].destination == destination {
+			return fm.rules[i].source, true
+		}
+	}
+
+	return "", false
+}
+
 //Return object as is
 func (DummyMapper) Map(object map[string]interface{}) map[string]interface{} {
 	return object
 }
 
-//Replace all '/' with '_'
-func formatKey(key string) string {
+//Replace all '/' with flatten delimiter (e.g. '_')
+func formatKey(key, delimiter string) string {
 	if strings.HasPrefix(key, "/") {
 		key = key[1:]
 	}
-	return strings.ReplaceAll(key, "/", "_")
+	return strings.ReplaceAll(key, "/", delimiter)
 }
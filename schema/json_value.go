@@ -0,0 +1,34 @@
+package schema
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	//JSONColumnType is sql type of columns with JSONValue values
+	JSONColumnType = "jsonb"
+	//PayloadColumn contains the whole event if "/" is configured in json paths
+	PayloadColumn = "_payload"
+)
+
+//JSONValue is a not flattened subtree of an event (see json paths in NewProcessor)
+//It is marshaled as the subtree itself in json files and as json string in sql inserts
+type JSONValue struct {
+	Data interface{}
+}
+
+func (jv JSONValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jv.Data)
+}
+
+//Value implements driver.Valuer: return subtree marshaled to json string
+func (jv JSONValue) Value() (driver.Value, error) {
+	b, err := json.Marshal(jv.Data)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling json value: %v", err)
+	}
+
+	return string(b), nil
+}
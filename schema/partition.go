@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/timestamp"
+	"time"
+)
+
+const (
+	//PartitionColumn is populated with date of partition field value truncated to partition granularity
+	PartitionColumn = "_partition_date"
+	//PartitionColumnType is sql type of PartitionColumn
+	PartitionColumnType = "date"
+
+	DayPartitioning   = "day"
+	MonthPartitioning = "month"
+
+	partitionDateLayout = "2006-01-02"
+)
+
+//PartitionConfig configures PartitionColumn populating
+type PartitionConfig struct {
+	//flatten key (e.g. _timestamp or eventn_ctx_utc_time) with timestamp value. Empty - partitioning is disabled
+	Field string
+	//day (default) or month
+	Granularity string
+}
+
+//Enabled return true if partition field is configured
+func (pc PartitionConfig) Enabled() bool {
+	return pc.Field != ""
+}
+
+//Validate granularity value
+func (pc PartitionConfig) Validate() error {
+	switch pc.Granularity {
+	case "", DayPartitioning, MonthPartitioning:
+		return nil
+	default:
+		return fmt.Errorf("Unsupported partition granularity: %s. Supported: %s, %s", pc.Granularity, DayPartitioning, MonthPartitioning)
+	}
+}
+
+//PartitionDate return partition column value (e.g. 2020-01-15 or 2020-01-01 with month granularity) from flatten object
+func (pc PartitionConfig) PartitionDate(object map[string]interface{}) (string, error) {
+	value, ok := object[pc.Field]
+	if !ok {
+		return "", fmt.Errorf("Error extracting partition date: %s field doesn't exist", pc.Field)
+	}
+
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		//_timestamp is converted to time.Time during table name extracting
+		t = v
+	case string:
+		parsed, err := parseTimestamp(v)
+		if err != nil {
+			return "", fmt.Errorf("Error extracting partition date: malformed %s field: %v", pc.Field, err)
+		}
+		t = parsed
+	default:
+		return "", fmt.Errorf("Error extracting partition date: %s field isn't a timestamp: %v", pc.Field, value)
+	}
+
+	return pc.truncate(t.UTC()).Format(partitionDateLayout), nil
+}
+
+//Bounds return partition range [from, to) which contains partition column value
+func (pc PartitionConfig) Bounds(partitionDate string) (time.Time, time.Time, error) {
+	from, err := time.Parse(partitionDateLayout, partitionDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("Error parsing partition date %s: %v", partitionDate, err)
+	}
+	from = pc.truncate(from)
+
+	if pc.Granularity == MonthPartitioning {
+		return from, from.AddDate(0, 1, 0), nil
+	}
+
+	return from, from.AddDate(0, 0, 1), nil
+}
+
+func (pc PartitionConfig) truncate(t time.Time) time.Time {
+	if pc.Granularity == MonthPartitioning {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+//Parse timestamp in eventnative layout or RFC3339
+func parseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(timestamp.Layout, value)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
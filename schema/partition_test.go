@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPartitionDate(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       PartitionConfig
+		input        map[string]interface{}
+		expectedDate string
+		expectedErr  bool
+	}{
+		{
+			"day granularity by default",
+			PartitionConfig{Field: "_timestamp"},
+			map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z"},
+			"2020-08-02",
+			false,
+		},
+		{
+			"month granularity",
+			PartitionConfig{Field: "_timestamp", Granularity: MonthPartitioning},
+			map[string]interface{}{"_timestamp": "2020-08-02T18:23:58.057807Z"},
+			"2020-08-01",
+			false,
+		},
+		{
+			"time value in UTC",
+			PartitionConfig{Field: "_timestamp"},
+			map[string]interface{}{"_timestamp": time.Date(2020, 8, 2, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))},
+			"2020-08-03",
+			false,
+		},
+		{
+			"RFC3339 value",
+			PartitionConfig{Field: "eventn_ctx_utc_time", Granularity: DayPartitioning},
+			map[string]interface{}{"eventn_ctx_utc_time": "2020-08-02T18:23:58+03:00"},
+			"2020-08-02",
+			false,
+		},
+		{
+			"missing field",
+			PartitionConfig{Field: "_timestamp"},
+			map[string]interface{}{"event_type": "page_view"},
+			"",
+			true,
+		},
+		{
+			"malformed value",
+			PartitionConfig{Field: "_timestamp"},
+			map[string]interface{}{"_timestamp": "yesterday"},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.config.PartitionDate(tt.input)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedDate, actual, "Partition dates aren't equal")
+		})
+	}
+}
+
+func TestPartitionBounds(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       PartitionConfig
+		input        string
+		expectedFrom string
+		expectedTo   string
+	}{
+		{
+			"day",
+			PartitionConfig{Field: "_timestamp", Granularity: DayPartitioning},
+			"2020-12-31",
+			"2020-12-31",
+			"2021-01-01",
+		},
+		{
+			"month",
+			PartitionConfig{Field: "_timestamp", Granularity: MonthPartitioning},
+			"2020-12-01",
+			"2020-12-01",
+			"2021-01-01",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := tt.config.Bounds(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedFrom, from.Format(partitionDateLayout), "From bounds aren't equal")
+			require.Equal(t, tt.expectedTo, to.Format(partitionDateLayout), "To bounds aren't equal")
+		})
+	}
+
+	require.Error(t, PartitionConfig{Field: "_timestamp", Granularity: "week"}.Validate())
+}
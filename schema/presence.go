@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+//PresenceColumnSuffix is added to flatten key of presence field. Presence column value is 1 if the field exists
+//in event (even with null value) and 0 if it is absent
+const PresenceColumnSuffix = "_present"
+
+//Return lowercase source path -> presence column name
+//Presence paths must be reachable by flattening: they can't be inside json paths and deeper than maxFlattenDepth
+func (p *Processor) presenceColumnNames(presencePaths []string) (map[string]string, error) {
+	columns := map[string]string{}
+	for _, presencePath := range presencePaths {
+		presencePath = strings.TrimSuffix(strings.TrimSpace(presencePath), "/")
+		if !strings.HasPrefix(presencePath, "/") {
+			return nil, fmt.Errorf("Malformed presence field path [%s]. Use format: /field1/subfield1", presencePath)
+		}
+
+		segments := strings.Split(strings.TrimPrefix(presencePath, "/"), "/")
+		if p.maxFlattenDepth > 0 && len(segments) > p.maxFlattenDepth {
+			return nil, fmt.Errorf("Presence field path [%s] is deeper than max flatten depth: %d", presencePath, p.maxFlattenDepth)
+		}
+
+		lowerPath := strings.ToLower(presencePath)
+		for jsonPath := range p.jsonPaths {
+			if strings.HasPrefix(lowerPath+"/", jsonPath+"/") && lowerPath != jsonPath {
+				return nil, fmt.Errorf("Presence field path [%s] is inside json path [%s]", presencePath, jsonPath)
+			}
+		}
+
+		//flatten key is built the same way as in flatten()
+		for i, segment := range segments {
+			if p.columnNames != nil {
+				segments[i] = p.columnNames.Segment(segment)
+			}
+		}
+		key := strings.ToLower(strings.Join(segments, p.delimiter)) + PresenceColumnSuffix
+		columns[lowerPath] = p.columnName(lowerPath+PresenceColumnSuffix, key)
+	}
+
+	return columns, nil
+}
@@ -10,14 +10,59 @@ import (
 	"io"
 	"log"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 )
 
+//table names which can be used without quoting: letters, digits and underscores
+var validTableName = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
 type Processor struct {
-	fieldMapper          Mapper
-	tableNameExtractFunc TableNameExtractFunction
+	fieldMapper    Mapper
+	typeResolver   TypeResolver
+	schemaResolver SchemaResolver
+	//nested objects deeper than this level are stored as json strings. 0 - unlimited
+	maxFlattenDepth int
+	//put between nested keys of flatten column names (see ColumnNamesConfig.Delimiter)
+	delimiter string
+	//nil if column names transformation isn't configured
+	columnNames *ColumnNames
+	//PartitionColumn is populated if partitioning is enabled
+	partition PartitionConfig
+	//lowercase source paths (e.g. /properties) of subtrees which are stored as JSONValue without flattening
+	jsonPaths map[string]bool
+	//the whole event is stored as JSONValue in PayloadColumn
+	jsonPayload bool
+	//the original event is also stored as JSONValue in this column next to flatten ones. Disabled if empty (see ProcessorConfig.RawColumn)
+	rawColumn string
+	//json.Number values are stored in INT64 or FLOAT64 columns. Otherwise all values are stored as strings
+	numericTypes bool
+	//lowercase source paths (e.g. /user/email) -> presence column name (see PresenceColumnSuffix)
+	presenceColumns map[string]string
+	//lowercase source paths (e.g. /amount) -> numeric(p,s) column type. Values are stored as DecimalValue (see ProcessorConfig.DecimalFields)
+	decimalPaths map[string]string
+	//count of decimal fields values which aren't numbers (such columns are null)
+	decimalErrors uint64
+	//arrays are stored as ArrayValue (Postgres arrays) or JSONValue. Otherwise they are stored as json strings
+	arrayTypes bool
+	//canonical event time column is populated if it is enabled (see ProcessorConfig.EventTime)
+	eventTime EventTimeConfig
+	//nil if computed columns aren't configured
+	computedColumns *ComputedColumns
+	//nil if pre-insert transforms aren't configured
+	transforms *Transforms
+	//Column.SourcePath is filled (see ProcessorConfig.TrackSourcePaths)
+	trackSourcePaths bool
+	//keys and source paths keep case (see ColumnNamesConfig.CaseSensitive). Otherwise they are lowercased
+	caseSensitive bool
+	//count of sibling keys which differ only by case and are stored in one column (if keys are lowercased)
+	caseCollisions uint64
+	//source paths of logged case collisions
+	loggedCaseCollisions sync.Map
 }
 
 type ProcessedFile struct {
@@ -26,8 +71,70 @@ type ProcessedFile struct {
 	DataSchema *Table
 }
 
-func NewProcessor(tableNameFuncExpression string, mappings []string) (*Processor, error) {
-	mapper, err := NewFieldMapper(mappings)
+//ProcessorConfig is an optional configuration of Processor. Zero value means defaults
+type ProcessorConfig struct {
+	//table name used if the table name template result is empty
+	DefaultTableName string
+	//rules in format: /field1/subfield1 -> sql type (see TypeResolver)
+	ColumnTypes []string
+	//nested objects deeper than this level are stored as json strings. 0 - unlimited
+	MaxFlattenDepth int
+	ColumnNames     ColumnNamesConfig
+	Partition       PartitionConfig
+	//source paths (e.g. /properties or /user/traits) of subtrees which aren't flattened and stored in one
+	//JSONColumnType column. "/" means the whole event is stored in PayloadColumn (mappings aren't applied)
+	JSONPaths []string
+	//integer and float values (decoded as json.Number, see events.DecodeJSON) get INT64 and FLOAT64 column types
+	NumericTypes bool
+	//source paths (e.g. /user/email) of fields which get presence columns for distinguishing
+	//explicit null values from absent fields
+	PresencePaths []string
+	//homogeneous scalar arrays are stored in Postgres array columns (e.g. text[]) and other arrays in jsonb columns
+	ArrayTypes bool
+	//canonical event time column. It is populated before table name resolving so it can be used
+	//in table name template and as partition field
+	EventTime EventTimeConfig
+	//columns computed by expressions over flatten fields (see ComputedColumns). They are computed before
+	//table name resolving and mapping
+	ComputedColumns []string
+	//ordered operations (rename, drop, cast, default_value, lowercase) which are applied to flatten objects
+	//after mapping right before table schema is built
+	Transforms []TransformConfig
+	//column (e.g. _raw) with the original not flattened event as jsonb next to flatten columns (lossless record
+	//for debugging and reprocessing). The column isn't flattened, mapped, transformed and its type isn't resolved
+	//by column types. Disabled if empty
+	RawColumn string
+	//rules in format: /field1/subfield1 -> precision,scale of fields which are stored in numeric(precision,scale)
+	//columns as exact decimals instead of float64 (e.g. monetary amounts). json numbers and numeric strings are
+	//stored as DecimalValue, other values are counted and skipped (see DecimalErrors). column_types rules take precedence
+	DecimalFields []string
+	//fill Column.SourcePath with source JSON paths of columns (e.g. for column comments)
+	TrackSourcePaths bool
+}
+
+//NewProcessor return Processor with table name template, mapping rules and optional config
+func NewProcessor(tableNameFuncExpression string, mappings []string, config ProcessorConfig) (*Processor, error) {
+	if err := config.Partition.Validate(); err != nil {
+		return nil, err
+	}
+	if config.DefaultTableName != "" && !validTableName.MatchString(config.DefaultTableName) {
+		return nil, fmt.Errorf("Error default table name [%s] must contain only letters, digits and underscores", config.DefaultTableName)
+	}
+	if err := config.EventTime.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.ColumnNames.Validate(); err != nil {
+		return nil, err
+	}
+	delimiter := config.ColumnNames.FlattenDelimiter()
+
+	//mapping and column types JSON paths are converted to flatten keys with the same delimiter
+	mapper, err := newFieldMapper(mappings, delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	typeResolver, err := newTypeResolver(config.ColumnTypes, delimiter)
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +166,108 @@ func NewProcessor(tableNameFuncExpression string, mappings []string) (*Processor
 		return buf.String(), nil
 	}
 
-	return &Processor{fieldMapper: mapper, tableNameExtractFunc: tableNameExtractFunc}, nil
+	processor := &Processor{
+		fieldMapper:      mapper,
+		typeResolver:     typeResolver,
+		schemaResolver:   NewTemplateSchemaResolver(tableNameExtractFunc, config.DefaultTableName),
+		maxFlattenDepth:  config.MaxFlattenDepth,
+		delimiter:        delimiter,
+		partition:        config.Partition,
+		jsonPaths:        map[string]bool{},
+		numericTypes:     config.NumericTypes,
+		arrayTypes:       config.ArrayTypes,
+		eventTime:        config.EventTime,
+		rawColumn:        strings.TrimSpace(config.RawColumn),
+		trackSourcePaths: config.TrackSourcePaths,
+		caseSensitive:    config.ColumnNames.CaseSensitive,
+	}
+	for _, jsonPath := range config.JSONPaths {
+		jsonPath = strings.ToLower(strings.TrimSpace(jsonPath))
+		if jsonPath == "/" {
+			processor.jsonPayload = true
+			continue
+		}
+		if !strings.HasPrefix(jsonPath, "/") {
+			return nil, fmt.Errorf("Malformed json path [%s]. Use format: /field1/subfield1", jsonPath)
+		}
+		processor.jsonPaths[strings.TrimSuffix(jsonPath, "/")] = true
+	}
+	if config.ColumnNames.Enabled() {
+		processor.columnNames = NewColumnNames(config.ColumnNames)
+	}
+	processor.presenceColumns, err = processor.presenceColumnNames(config.PresencePaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.ComputedColumns) > 0 {
+		if processor.computedColumns, err = NewComputedColumns(config.ComputedColumns); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.Transforms) > 0 {
+		if processor.transforms, err = NewTransforms(config.Transforms); err != nil {
+			return nil, err
+		}
+	}
+	if processor.decimalPaths, err = decimalPaths(config.DecimalFields); err != nil {
+		return nil, err
+	}
+
+	return processor, nil
+}
+
+//Return lowercase source paths -> numeric(p,s) column type from decimal rules
+func decimalPaths(rules []string) (map[string]string, error) {
+	paths := map[string]string{}
+	for _, rule := range rules {
+		sourcePath, sqlType, err := parseDecimalRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		paths[strings.ToLower(sourcePath)] = sqlType
+	}
+
+	return paths, nil
+}
+
+//Partitioning return partition config (disabled if partition field isn't configured)
+func (p *Processor) Partitioning() PartitionConfig {
+	return p.partition
+}
+
+//SetSchemaResolver replace default TemplateSchemaResolver (table name template and default table name)
+//Must be called before processing
+func (p *Processor) SetSchemaResolver(schemaResolver SchemaResolver) {
+	p.schemaResolver = schemaResolver
+}
+
+//ComputedColumnErrors return count of failed computed column evaluations (such columns are null)
+func (p *Processor) ComputedColumnErrors() uint64 {
+	if p.computedColumns == nil {
+		return 0
+	}
+
+	return p.computedColumns.Errors()
+}
+
+//TransformErrors return count of values which couldn't be casted by transforms (such columns are null)
+func (p *Processor) TransformErrors() uint64 {
+	if p.transforms == nil {
+		return 0
+	}
+
+	return p.transforms.Errors()
+}
+
+//DecimalErrors return count of decimal fields values which aren't numbers (such columns are null)
+func (p *Processor) DecimalErrors() uint64 {
+	return atomic.LoadUint64(&p.decimalErrors)
+}
+
+//CaseCollisions return count of keys which differ only by case from sibling keys (e.g. userId and userid)
+//Values of such keys are stored in one column if column names aren't case sensitive
+func (p *Processor) CaseCollisions() uint64 {
+	return atomic.LoadUint64(&p.caseCollisions)
 }
 
 //ProcessFact return table representation, processed flatten object
@@ -112,7 +320,7 @@ func (p *Processor) ProcessFilePayload(fileName string, payload []byte, breakOnE
 func (p *Processor) processFileLine(line []byte) (*Table, []byte, error) {
 	object := map[string]interface{}{}
 
-	err := json.Unmarshal(line, &object)
+	err := events.DecodeJSON(line, &object)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -132,70 +340,282 @@ func (p *Processor) processFileLine(line []byte) (*Table, []byte, error) {
 
 //Return table representation of object and flatten object
 func (p *Processor) processObject(object map[string]interface{}) (*Table, map[string]interface{}, error) {
-	flatObject, err := p.flattenObject(object)
+	var sourcePaths map[string]string
+	if p.trackSourcePaths {
+		sourcePaths = map[string]string{}
+	}
+	flatObject, err := p.flattenObject(object, sourcePaths)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	tableName, err := p.tableNameExtractFunc(flatObject)
+	var eventTime time.Time
+	if p.eventTime.Enabled() {
+		eventTime, err = p.eventTime.EventTime(object)
+		if err != nil {
+			return nil, nil, err
+		}
+		flatObject[p.eventTime.ColumnName()] = eventTime
+	}
+	if p.computedColumns != nil {
+		p.computedColumns.Compute(flatObject)
+	}
+
+	tableName, fixedColumns, err := p.schemaResolver.Resolve(flatObject)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Error extracting table name from object {%v}: %v", flatObject, err)
+		return nil, nil, err
 	}
 	if tableName == "" {
 		return nil, nil, fmt.Errorf("Unknown table name. Object {%v}", flatObject)
 	}
 
-	mappedObject := p.fieldMapper.Map(flatObject)
+	var mappedObject map[string]interface{}
+	if p.jsonPayload {
+		mappedObject = map[string]interface{}{PayloadColumn: JSONValue{Data: object}}
+		if p.computedColumns != nil {
+			for _, column := range p.computedColumns.Names() {
+				if value, ok := flatObject[column]; ok {
+					mappedObject[column] = value
+				}
+			}
+		}
+	} else {
+		mappedObject = p.fieldMapper.Map(flatObject)
+	}
+	for column, value := range fixedColumns {
+		mappedObject[column] = value
+	}
+	if p.eventTime.Enabled() {
+		mappedObject[p.eventTime.ColumnName()] = eventTime
+	}
+	if p.transforms != nil {
+		p.transforms.Apply(mappedObject)
+	}
 
 	table := &Table{Name: tableName, Columns: Columns{}}
-	for k := range mappedObject {
+	for k, v := range mappedObject {
 		//TODO add types
-		table.Columns[k] = Column{Type: STRING}
+		//column type patterns are lowercase
+		columnType := k
+		if p.caseSensitive {
+			columnType = strings.ToLower(k)
+		}
+		sqlType := p.typeResolver.Resolve(columnType)
+		if sqlType == "" {
+			switch value := v.(type) {
+			case JSONValue:
+				sqlType = JSONColumnType
+			case ArrayValue:
+				sqlType = value.SqlType
+			case DecimalValue:
+				sqlType = value.SqlType
+			}
+		}
+		dataType := ValueType(v)
+		//other time values (e.g. _timestamp after table name extracting) are stored as strings
+		if _, ok := v.(time.Time); ok && p.eventTime.Enabled() && k == p.eventTime.ColumnName() {
+			dataType = TIMESTAMP
+		}
+		table.Columns[k] = Column{Type: dataType, SqlType: sqlType, SourcePath: p.sourcePath(k, sourcePaths)}
+	}
+
+	if p.partition.Enabled() {
+		//partition field is taken before mapping because it can be removed or renamed by mappings
+		partitionDate, err := p.partition.PartitionDate(flatObject)
+		if err != nil {
+			return nil, nil, err
+		}
+		mappedObject[PartitionColumn] = partitionDate
+		table.Columns[PartitionColumn] = Column{Type: STRING, SqlType: PartitionColumnType}
+	}
+
+	if p.rawColumn != "" {
+		mappedObject[p.rawColumn] = JSONValue{Data: object}
+		table.Columns[p.rawColumn] = Column{Type: STRING, SqlType: JSONColumnType, SourcePath: p.sourcePath(p.rawColumn, sourcePaths)}
 	}
 
 	return table, mappedObject, nil
 }
 
 //Return flatten object e.g. from {"key1":{"key2":123}} to {"key1_key2":123}
-func (p *Processor) flattenObject(json map[string]interface{}) (map[string]interface{}, error) {
+//flatten key -> source path (e.g. key1_key2 -> /key1/key2) is written to sourcePaths if it isn't nil
+func (p *Processor) flattenObject(json map[string]interface{}, sourcePaths map[string]string) (map[string]interface{}, error) {
 	flattenMap := make(map[string]interface{})
 
-	err := p.flatten("", json, flattenMap)
+	err := p.flatten("", "", json, flattenMap, sourcePaths, 0)
 	if err != nil {
 		return nil, err
 	}
 
+	//presence columns of existing fields have been filled in flatten()
+	for _, column := range p.presenceColumns {
+		if _, ok := flattenMap[column]; !ok {
+			flattenMap[column] = int64(0)
+		}
+	}
+
 	return flattenMap, nil
 
 }
 
-//omit nil values and make all keys to lowercase
-func (p *Processor) flatten(key string, value interface{}, destination map[string]interface{}) error {
-	key = strings.ToLower(key)
+//omit nil values and make all keys to lowercase (if column names aren't case sensitive)
+//objects on maxFlattenDepth level are stored as json strings and subtrees on json paths as JSONValue
+//path is a source JSON path of the value e.g. /key1/key2 (is used for column names collisions detection)
+func (p *Processor) flatten(key, path string, value interface{}, destination map[string]interface{}, sourcePaths map[string]string, depth int) error {
+	//configured paths are lowercase
+	configPath := path
+	if p.caseSensitive {
+		configPath = strings.ToLower(path)
+	} else {
+		key = strings.ToLower(key)
+	}
+	if column, ok := p.presenceColumns[configPath]; ok {
+		destination[column] = int64(1)
+	}
+	if p.jsonPaths[configPath] {
+		if value != nil {
+			p.setFlatten(destination, sourcePaths, path, key, JSONValue{Data: value})
+		}
+		return nil
+	}
+
 	t := reflect.ValueOf(value)
 	switch t.Kind() {
 	case reflect.Slice:
+		if elements, ok := value.([]interface{}); ok && p.arrayTypes {
+			p.setFlatten(destination, sourcePaths, path, key, arrayValue(elements))
+			return nil
+		}
 		b, err := json.Marshal(value)
 		if err != nil {
 			return fmt.Errorf("Error marshaling array with key %s: %v", key, err)
 		}
-		destination[key] = string(b)
+		p.setFlatten(destination, sourcePaths, path, key, string(b))
 	case reflect.Map:
+		if p.maxFlattenDepth > 0 && depth >= p.maxFlattenDepth {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("Error marshaling object with key %s: %v", key, err)
+			}
+			p.setFlatten(destination, sourcePaths, path, key, string(b))
+			return nil
+		}
+
 		unboxed := value.(map[string]interface{})
+		//keys with upper case letters by lowercase key (for case collisions detection)
+		var upperCaseKeys map[string]string
 		for k, v := range unboxed {
+			segment := k
+			if !p.caseSensitive {
+				segment = strings.ToLower(k)
+				if segment != k {
+					if _, ok := unboxed[segment]; ok {
+						p.caseCollision(path+"/"+segment, segment, k)
+					} else if other, ok := upperCaseKeys[segment]; ok {
+						p.caseCollision(path+"/"+segment, other, k)
+					} else {
+						if upperCaseKeys == nil {
+							upperCaseKeys = map[string]string{}
+						}
+						upperCaseKeys[segment] = k
+					}
+				}
+			}
+
 			newKey := k
+			if p.columnNames != nil {
+				newKey = p.columnNames.Segment(k)
+			}
 			if key != "" {
-				newKey = key + "_" + newKey
+				newKey = key + p.delimiter + newKey
 			}
-			if err := p.flatten(newKey, v, destination); err != nil {
+			if err := p.flatten(newKey, path+"/"+segment, v, destination, sourcePaths, depth+1); err != nil {
 				return fmt.Errorf("Error flatten object with key %s_%s: %v", key, k, err)
 			}
 		}
 	default:
-		if value != nil {
-			destination[key] = fmt.Sprintf("%v", value)
+		if sqlType, ok := p.decimalPaths[configPath]; ok && value != nil {
+			if decimal, ok := decimalValue(value, sqlType); ok {
+				p.setFlatten(destination, sourcePaths, path, key, decimal)
+			} else {
+				atomic.AddUint64(&p.decimalErrors, 1)
+			}
+		} else if number, ok := value.(json.Number); ok && p.numericTypes {
+			p.setFlatten(destination, sourcePaths, path, key, numberValue(number))
+		} else if value != nil {
+			//json.Number is formatted as is without float64 rounding
+			p.setFlatten(destination, sourcePaths, path, key, fmt.Sprintf("%v", value))
 		}
 	}
 
 	return nil
 }
+
+//Count keys which are stored in one column and log every colliding source path once
+func (p *Processor) caseCollision(path, key, otherKey string) {
+	atomic.AddUint64(&p.caseCollisions, 1)
+	if _, logged := p.loggedCaseCollisions.LoadOrStore(path, true); !logged {
+		log.Printf("Warn: keys [%s] and [%s] of %s differ only by case: their values are stored in one column. Enable case sensitive column names to store them separately", key, otherKey, path)
+	}
+}
+
+//Put value with unique column name to destination and remember column source path if sourcePaths isn't nil
+func (p *Processor) setFlatten(destination map[string]interface{}, sourcePaths map[string]string, path, key string, value interface{}) {
+	column := p.columnName(path, key)
+	destination[column] = value
+	if sourcePaths != nil {
+		sourcePaths[column] = path
+	}
+}
+
+//Return int64 for integer numbers which fit int64 and float64 for others
+//Numbers which can't be parsed (e.g. out of float64 range) are kept as strings
+func numberValue(number json.Number) interface{} {
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	if f, err := number.Float64(); err == nil {
+		return f
+	}
+
+	return number.String()
+}
+
+//ValueType return column type of flattened value
+func ValueType(value interface{}) DataType {
+	switch value.(type) {
+	case int64:
+		return INT64
+	case float64, DecimalValue:
+		return FLOAT64
+	default:
+		return STRING
+	}
+}
+
+//Return source path of mapped column (source path of mapping rule source if column is a mapping destination)
+//or empty string if source paths aren't tracked
+func (p *Processor) sourcePath(column string, sourcePaths map[string]string) string {
+	if sourcePaths == nil {
+		return ""
+	}
+	if (column == PayloadColumn && p.jsonPayload) || column == p.rawColumn {
+		return "/"
+	}
+	if fieldMapper, ok := p.fieldMapper.(*FieldMapper); ok {
+		if source, ok := fieldMapper.sourceKey(column); ok {
+			return sourcePaths[source]
+		}
+	}
+
+	return sourcePaths[column]
+}
+
+//Return unique column name if column names transformation is configured or key as is
+func (p *Processor) columnName(path, key string) string {
+	if p.columnNames == nil {
+		return key
+	}
+
+	return p.columnNames.Column(path, key)
+}
@@ -2,6 +2,8 @@ package schema
 
 import (
 	"bytes"
+	"encoding/json"
+	"github.com/ksensehq/eventnative/events"
 	"github.com/ksensehq/eventnative/test"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
@@ -45,11 +47,49 @@ func TestFlattenObject(t *testing.T) {
 				"key8_sub_key2": "123123.3123", "key8_sub_key3_sub_sub_key1": "[\"1,\",\"2.\"]"},
 		},
 	}
-	p, err := NewProcessor("", []string{})
+	p, err := NewProcessor("", []string{}, ProcessorConfig{})
 	require.NoError(t, err)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualFlattenJson, err := p.flattenObject(tt.inputJson)
+			actualFlattenJson, err := p.flattenObject(tt.inputJson, nil)
+			require.NoError(t, err)
+			test.ObjectsEqual(t, tt.expectedJson, actualFlattenJson, "Wrong flattened json")
+		})
+	}
+}
+
+func TestFlattenObjectMaxDepth(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxDepth     int
+		inputJson    map[string]interface{}
+		expectedJson map[string]interface{}
+	}{
+		{
+			"Unlimited depth",
+			0,
+			map[string]interface{}{"key1": map[string]interface{}{"key2": map[string]interface{}{"key3": 1}}},
+			map[string]interface{}{"key1_key2_key3": "1"},
+		},
+		{
+			"First level objects are json strings",
+			1,
+			map[string]interface{}{"key1": map[string]interface{}{"Key2": map[string]interface{}{"key3": 1}}, "key4": "value"},
+			map[string]interface{}{"key1": `{"Key2":{"key3":1}}`, "key4": "value"},
+		},
+		{
+			"Second level objects are json strings",
+			2,
+			map[string]interface{}{"key1": map[string]interface{}{"key2": map[string]interface{}{"key3": 1}, "key5": 2}},
+			map[string]interface{}{"key1_key2": `{"key3":1}`, "key1_key5": "2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor("", []string{}, ProcessorConfig{MaxFlattenDepth: tt.maxDepth})
+			require.NoError(t, err)
+
+			actualFlattenJson, err := p.flattenObject(tt.inputJson, nil)
 			require.NoError(t, err)
 			test.ObjectsEqual(t, tt.expectedJson, actualFlattenJson, "Wrong flattened json")
 		})
@@ -105,7 +145,7 @@ func TestProcess(t *testing.T) {
 			},
 		},
 	}
-	p, err := NewProcessor(`{{.event_type}}_{{._timestamp.Format "2006_01"}}`, []string{})
+	p, err := NewProcessor(`{{.event_type}}_{{._timestamp.Format "2006_01"}}`, []string{}, ProcessorConfig{})
 	require.NoError(t, err)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -140,3 +180,419 @@ func TestProcess(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessFactDefaultTableName(t *testing.T) {
+	tests := []struct {
+		name              string
+		input             events.Fact
+		expectedTableName string
+	}{
+		{
+			"routing key exists",
+			events.Fact{"event_type": "page_view", "_timestamp": "2020-08-02T18:23:58.057807Z"},
+			"events_page_view",
+		},
+		{
+			"missing routing key",
+			events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z"},
+			"events_default",
+		},
+		{
+			"invalid table name",
+			events.Fact{"event_type": "page view;", "_timestamp": "2020-08-02T18:23:58.057807Z"},
+			"events_default",
+		},
+		{
+			"missing timestamp",
+			events.Fact{"event_type": "page_view"},
+			"events_default",
+		},
+	}
+	p, err := NewProcessor(`events_{{.event_type}}`, []string{}, ProcessorConfig{DefaultTableName: "events_default"})
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, _, err := p.ProcessFact(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTableName, table.Name, "Table names aren't equal")
+		})
+	}
+
+	_, err = NewProcessor(`events_{{.event_type}}`, []string{}, ProcessorConfig{DefaultTableName: "events-default"})
+	require.Error(t, err)
+}
+
+func TestProcessFactPartitionColumn(t *testing.T) {
+	p, err := NewProcessor(`events`, []string{"/_timestamp -> "}, ProcessorConfig{Partition: PartitionConfig{Field: "_timestamp", Granularity: MonthPartitioning}})
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(events.Fact{"event_type": "page_view", "_timestamp": "2020-08-02T18:23:58.057807Z"})
+	require.NoError(t, err)
+	require.Equal(t, "2020-08-01", object[PartitionColumn], "Partition column values aren't equal")
+	require.Equal(t, Column{Type: STRING, SqlType: PartitionColumnType}, table.Columns[PartitionColumn], "Partition columns aren't equal")
+	_, ok := object["_timestamp"]
+	require.False(t, ok, "Mapped out field must be removed")
+
+	_, _, err = p.ProcessFact(events.Fact{"event_type": "page_view"})
+	require.Error(t, err)
+}
+
+func TestProcessFactJSONPaths(t *testing.T) {
+	tests := []struct {
+		name            string
+		jsonPaths       []string
+		input           events.Fact
+		expectedObject  map[string]interface{}
+		expectedColumns Columns
+	}{
+		{
+			"subtree",
+			[]string{"/Properties", "/user/traits"},
+			events.Fact{"event_type": "page_view", "properties": map[string]interface{}{"a": 1.0, "b": []interface{}{"c"}},
+				"user": map[string]interface{}{"id": "u1", "traits": map[string]interface{}{"plan": "free"}}},
+			map[string]interface{}{"event_type": "page_view", "properties": JSONValue{Data: map[string]interface{}{"a": 1.0, "b": []interface{}{"c"}}},
+				"user_id": "u1", "user_traits": JSONValue{Data: map[string]interface{}{"plan": "free"}}},
+			Columns{"event_type": Column{Type: STRING}, "properties": Column{Type: STRING, SqlType: JSONColumnType},
+				"user_id": Column{Type: STRING}, "user_traits": Column{Type: STRING, SqlType: JSONColumnType}},
+		},
+		{
+			"whole payload",
+			[]string{"/"},
+			events.Fact{"event_type": "page_view", "properties": map[string]interface{}{"a": 1.0}},
+			map[string]interface{}{PayloadColumn: JSONValue{Data: map[string]interface{}{"event_type": "page_view", "properties": map[string]interface{}{"a": 1.0}}}},
+			Columns{PayloadColumn: Column{Type: STRING, SqlType: JSONColumnType}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//events without _timestamp are stored in default table
+			p, err := NewProcessor(`events`, []string{}, ProcessorConfig{DefaultTableName: "events", JSONPaths: tt.jsonPaths})
+			require.NoError(t, err)
+
+			table, object, err := p.ProcessFact(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedObject, object, "Processed objects aren't equal")
+			require.Equal(t, tt.expectedColumns, table.Columns, "Columns aren't equal")
+		})
+	}
+
+	b, err := JSONValue{Data: map[string]interface{}{"a": 1}}.Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1}`, b)
+}
+
+func TestProcessFactNumbers(t *testing.T) {
+	fact := events.Fact{}
+	require.NoError(t, events.DecodeJSON([]byte(`{"_timestamp": "2020-08-02T18:23:58.057807Z", "id": 1234567890123456789, "price": 10.5, "nested": {"count": 3}}`), &fact))
+
+	tests := []struct {
+		name            string
+		numericTypes    bool
+		expectedObject  map[string]interface{}
+		expectedColumns Columns
+	}{
+		{
+			"numbers as strings",
+			false,
+			map[string]interface{}{"id": "1234567890123456789", "price": "10.5", "nested_count": "3"},
+			Columns{"id": Column{Type: STRING}, "price": Column{Type: STRING}, "nested_count": Column{Type: STRING}},
+		},
+		{
+			"numeric types",
+			true,
+			map[string]interface{}{"id": int64(1234567890123456789), "price": 10.5, "nested_count": int64(3)},
+			Columns{"id": Column{Type: INT64}, "price": Column{Type: FLOAT64}, "nested_count": Column{Type: INT64}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor(`events`, []string{"/_timestamp -> "}, ProcessorConfig{NumericTypes: tt.numericTypes})
+			require.NoError(t, err)
+
+			table, object, err := p.ProcessFact(fact)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedObject, object, "Processed objects aren't equal")
+			require.Equal(t, tt.expectedColumns, table.Columns, "Columns aren't equal")
+		})
+	}
+}
+
+func TestProcessFactPresenceFields(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          events.Fact
+		expectedObject map[string]interface{}
+	}{
+		{
+			"field with value",
+			events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "user": map[string]interface{}{"email": "a@b.c"}},
+			map[string]interface{}{"user_email": "a@b.c", "user_email_present": int64(1)},
+		},
+		{
+			"field with explicit null",
+			events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "user": map[string]interface{}{"email": nil}},
+			map[string]interface{}{"user_email_present": int64(1)},
+		},
+		{
+			"absent field",
+			events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "user": map[string]interface{}{"id": "1"}},
+			map[string]interface{}{"user_id": "1", "user_email_present": int64(0)},
+		},
+		{
+			"absent parent",
+			events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z"},
+			map[string]interface{}{"user_email_present": int64(0)},
+		},
+	}
+	p, err := NewProcessor(`events`, []string{"/_timestamp -> "}, ProcessorConfig{PresencePaths: []string{"/user/email"}})
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, object, err := p.ProcessFact(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedObject, object, "Processed objects aren't equal")
+			require.Equal(t, Column{Type: INT64}, table.Columns["user_email_present"], "Presence columns aren't equal")
+		})
+	}
+
+	_, err = NewProcessor(`events`, []string{}, ProcessorConfig{MaxFlattenDepth: 1, PresencePaths: []string{"/user/email"}})
+	require.Error(t, err, "Presence field deeper than max flatten depth must be rejected")
+	_, err = NewProcessor(`events`, []string{}, ProcessorConfig{JSONPaths: []string{"/user"}, PresencePaths: []string{"/user/email"}})
+	require.Error(t, err, "Presence field inside json path must be rejected")
+	_, err = NewProcessor(`events`, []string{}, ProcessorConfig{PresencePaths: []string{"user"}})
+	require.Error(t, err, "Malformed presence field path must be rejected")
+}
+
+func TestProcessFactFlattenDelimiter(t *testing.T) {
+	tests := []struct {
+		name           string
+		delimiter      string
+		expectedObject map[string]interface{}
+		expectedErr    bool
+	}{
+		{
+			"default delimiter",
+			"",
+			map[string]interface{}{"user_email": "a@b.c", "user_first_name": "a", "page_url_present": int64(1), "page_title": "t"},
+			false,
+		},
+		{
+			"double underscore",
+			"__",
+			map[string]interface{}{"user__email": "a@b.c", "user__first_name": "a", "page__url_present": int64(1), "page__title": "t"},
+			false,
+		},
+		{
+			"dot isn't allowed in sql identifiers",
+			".",
+			nil,
+			true,
+		},
+		{
+			"uppercase isn't allowed",
+			"X",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor(`events`, []string{"/_timestamp -> ", "/page/url -> /page/title"}, ProcessorConfig{
+				ColumnNames: ColumnNamesConfig{SnakeCase: true, Delimiter: tt.delimiter}, PresencePaths: []string{"/page/url"}})
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			_, object, err := p.ProcessFact(events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z",
+				"user": map[string]interface{}{"email": "a@b.c", "firstName": "a"}, "page": map[string]interface{}{"url": "t"}})
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedObject, object, "Processed objects aren't equal")
+		})
+	}
+}
+
+func TestProcessFactCaseSensitiveColumns(t *testing.T) {
+	input := events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "user": map[string]interface{}{"userId": "1", "userid": "2", "Email": "a@b.c"}}
+	tests := []struct {
+		name               string
+		config             ColumnNamesConfig
+		expectedColumns    []string
+		expectedCollisions uint64
+	}{
+		{
+			"keys are lowercased",
+			ColumnNamesConfig{MaxLength: 63},
+			[]string{"user_email", "user_userid"},
+			1,
+		},
+		{
+			"keys keep case",
+			ColumnNamesConfig{MaxLength: 63, CaseSensitive: true},
+			[]string{"user_Email", "user_userId", "user_userid"},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProcessor(`events`, []string{"/_timestamp -> "}, ProcessorConfig{
+				ColumnTypes: []string{"/user/email -> text"}, ColumnNames: tt.config, PresencePaths: []string{"/user/email"}})
+			require.NoError(t, err)
+			table, _, err := p.ProcessFact(input)
+			require.NoError(t, err)
+			delete(table.Columns, "user_email_present")
+			require.Equal(t, tt.expectedColumns, table.Columns.SortedNames())
+			require.Equal(t, tt.expectedCollisions, p.CaseCollisions())
+			for _, column := range tt.expectedColumns {
+				if strings.EqualFold(column, "user_email") {
+					require.Equal(t, "text", table.Columns[column].SqlType, "Column type rules must be case insensitive")
+				}
+			}
+		})
+	}
+
+	_, err := NewProcessor(`events`, []string{}, ProcessorConfig{ColumnNames: ColumnNamesConfig{SnakeCase: true, CaseSensitive: true}})
+	require.Error(t, err, "Snake case can't be used with case sensitive columns")
+}
+
+func TestProcessFactArrayTypes(t *testing.T) {
+	fact := events.Fact{}
+	require.NoError(t, events.DecodeJSON([]byte(`{"_timestamp": "2020-08-02T18:23:58.057807Z", "tags": ["a", "b"], "ids": [1, 2],
+		"scores": [1, 2.5], "flags": [true], "empty": [], "items": [{"id": 1}], "mixed": ["a", 1]}`), &fact))
+
+	p, err := NewProcessor(`events`, []string{"/_timestamp -> "}, ProcessorConfig{ArrayTypes: true})
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(fact)
+	require.NoError(t, err)
+	expectedTypes := map[string]string{
+		"tags":   "text[]",
+		"ids":    "bigint[]",
+		"scores": "double precision[]",
+		"flags":  "boolean[]",
+		"empty":  "text[]",
+		"items":  JSONColumnType,
+		"mixed":  JSONColumnType,
+	}
+	for column, expectedType := range expectedTypes {
+		require.Equal(t, expectedType, table.Columns[column].SqlType, "Column %s types aren't equal", column)
+	}
+
+	tags, err := object["tags"].(ArrayValue).Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"a","b"}`, tags)
+	scores, err := object["scores"].(ArrayValue).Value()
+	require.NoError(t, err)
+	require.Equal(t, `{1,2.5}`, scores)
+	mixed, err := object["mixed"].(JSONValue).Value()
+	require.NoError(t, err)
+	require.Equal(t, `["a",1]`, mixed)
+
+	b, err := json.Marshal(object["ids"])
+	require.NoError(t, err)
+	require.Equal(t, `[1,2]`, string(b), "Arrays must be marshaled to json arrays in json files")
+}
+
+func TestArrayValueEscaping(t *testing.T) {
+	value, err := ArrayValue{Elements: []interface{}{`quote"d`, `back\slash`, "comma,{brace}", ""}}.Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"quote\"d","back\\slash","comma,{brace}",""}`, value)
+
+	value, err = ArrayValue{Elements: []interface{}{}}.Value()
+	require.NoError(t, err)
+	require.Equal(t, `{}`, value)
+}
+
+//tenantResolver routes objects to table per tenant and adds tenant_id column
+type tenantResolver struct{}
+
+func (tr tenantResolver) Resolve(flatObject map[string]interface{}) (string, map[string]interface{}, error) {
+	tenant, ok := flatObject["tenant"].(string)
+	if !ok {
+		return "", nil, nil
+	}
+
+	return "events_" + tenant, map[string]interface{}{"tenant_id": tenant}, nil
+}
+
+func TestProcessFactSchemaResolver(t *testing.T) {
+	p, err := NewProcessor("", []string{"/tenant -> "}, ProcessorConfig{})
+	require.NoError(t, err)
+	p.SetSchemaResolver(tenantResolver{})
+
+	table, object, err := p.ProcessFact(events.Fact{"tenant": "acme", "field1": "value1"})
+	require.NoError(t, err)
+	require.Equal(t, "events_acme", table.Name)
+	require.Equal(t, map[string]interface{}{"field1": "value1", "tenant_id": "acme"}, object)
+	require.Equal(t, Columns{"field1": Column{Type: STRING}, "tenant_id": Column{Type: STRING}}, table.Columns)
+
+	_, _, err = p.ProcessFact(events.Fact{"field1": "value1"})
+	require.Error(t, err, "Empty table name must be rejected")
+}
+
+func TestProcessFactSourcePaths(t *testing.T) {
+	p, err := NewProcessor("events", []string{"/user/id -> /user_id", "/secret -> "}, ProcessorConfig{
+		Partition: PartitionConfig{Field: "_timestamp"}, JSONPaths: []string{"/properties"}, TrackSourcePaths: true})
+	require.NoError(t, err)
+
+	table, _, err := p.ProcessFact(events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "user": map[string]interface{}{"id": "1", "Name": "John"},
+		"properties": map[string]interface{}{"key": "value"}, "secret": "value"})
+	require.NoError(t, err)
+	require.Equal(t, Columns{
+		"_timestamp":    Column{Type: STRING, SourcePath: "/_timestamp"},
+		"user_id":       Column{Type: STRING, SourcePath: "/user/id"},
+		"user_name":     Column{Type: STRING, SourcePath: "/user/name"},
+		"properties":    Column{Type: STRING, SqlType: JSONColumnType, SourcePath: "/properties"},
+		PartitionColumn: Column{Type: STRING, SqlType: PartitionColumnType},
+	}, table.Columns)
+}
+
+func TestProcessFactRawColumn(t *testing.T) {
+	//events without _timestamp are stored in default table
+	p, err := NewProcessor("events", []string{"/secret -> "}, ProcessorConfig{DefaultTableName: "events", ColumnTypes: []string{"/_raw -> text"},
+		Transforms: []TransformConfig{{Type: DropTransform, Field: "_raw"}}, RawColumn: "_raw", TrackSourcePaths: true})
+	require.NoError(t, err)
+
+	fact := events.Fact{"user": map[string]interface{}{"id": "1"}, "secret": "value"}
+	table, object, err := p.ProcessFact(fact)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"user_id": "1", "_raw": JSONValue{Data: map[string]interface{}{"user": map[string]interface{}{"id": "1"}, "secret": "value"}}}, object,
+		"Raw column must contain the original event with mapped out fields and must not be transformed")
+	require.Equal(t, Columns{
+		"user_id": Column{Type: STRING, SourcePath: "/user/id"},
+		"_raw":    Column{Type: STRING, SqlType: JSONColumnType, SourcePath: "/"},
+	}, table.Columns, "Raw column type must not be resolved by column types")
+}
+
+func TestProcessFactDecimalFields(t *testing.T) {
+	p, err := NewProcessor("events", []string{"/order/total -> /total"}, ProcessorConfig{
+		DefaultTableName: "events", ColumnTypes: []string{"/fee -> money"}, NumericTypes: true,
+		DecimalFields: []string{"/Amount -> 18,2", "/order/total -> 12, 4", "/fee -> 10,2", "/discount -> 5,2"}})
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(events.Fact{"amount": json.Number("12345678901234567.89"), "order": map[string]interface{}{"total": "0.1"},
+		"fee": 1.5, "discount": "free", "count": json.Number("2")})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"amount": DecimalValue{Number: "12345678901234567.89", SqlType: "numeric(18,2)"},
+		"total": DecimalValue{Number: "0.1", SqlType: "numeric(12,4)"}, "fee": DecimalValue{Number: "1.5", SqlType: "numeric(10,2)"}, "count": int64(2)}, object)
+	require.Equal(t, Columns{
+		"amount": Column{Type: FLOAT64, SqlType: "numeric(18,2)"},
+		"total":  Column{Type: FLOAT64, SqlType: "numeric(12,4)"},
+		"fee":    Column{Type: FLOAT64, SqlType: "money"},
+		"count":  Column{Type: INT64},
+	}, table.Columns, "Decimal type must be kept after mapping, column_types take precedence")
+	require.Equal(t, uint64(1), p.DecimalErrors(), "Not numeric values must be skipped")
+
+	b, err := json.Marshal(object["amount"])
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567.89", string(b), "Decimal must be marshaled as exact json number")
+	value, err := object["total"].(DecimalValue).Value()
+	require.NoError(t, err)
+	require.Equal(t, "0.1", value)
+
+	for _, rule := range []string{"/amount", "amount -> 18,2", "/amount -> 18", "/amount -> 0,0", "/amount -> 2,3", "/amount -> x,2"} {
+		_, err := NewProcessor("events", []string{}, ProcessorConfig{DecimalFields: []string{rule}})
+		require.Error(t, err, rule)
+	}
+}
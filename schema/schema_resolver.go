@@ -0,0 +1,40 @@
+package schema
+
+import "fmt"
+
+//SchemaResolver return destination table name and fixed columns of flatten object
+//Fixed columns (e.g. tenant_id) are added to every processed object after mappings and override mapped values
+//Custom implementations (e.g. table per tenant) are injected with Processor.SetSchemaResolver
+type SchemaResolver interface {
+	Resolve(flatObject map[string]interface{}) (string, map[string]interface{}, error)
+}
+
+//TemplateSchemaResolver is the default SchemaResolver: table name from table name template
+//or default table name (if configured) when routing keys are missing or result isn't a valid table name
+//There are no fixed columns
+type TemplateSchemaResolver struct {
+	tableNameExtractFunc TableNameExtractFunction
+	//objects with missing routing keys or invalid table names are stored here. Empty - such objects are skipped
+	defaultTableName string
+}
+
+func NewTemplateSchemaResolver(tableNameExtractFunc TableNameExtractFunction, defaultTableName string) *TemplateSchemaResolver {
+	return &TemplateSchemaResolver{tableNameExtractFunc: tableNameExtractFunc, defaultTableName: defaultTableName}
+}
+
+func (tsr *TemplateSchemaResolver) Resolve(flatObject map[string]interface{}) (string, map[string]interface{}, error) {
+	tableName, err := tsr.tableNameExtractFunc(flatObject)
+	if tsr.defaultTableName == "" {
+		if err != nil {
+			return "", nil, fmt.Errorf("Error extracting table name from object {%v}: %v", flatObject, err)
+		}
+		//empty table name is rejected by Processor
+		return tableName, nil, nil
+	}
+
+	if err != nil || !validTableName.MatchString(tableName) {
+		return tsr.defaultTableName, nil, nil
+	}
+
+	return tableName, nil, nil
+}
@@ -1,9 +1,18 @@
 package schema
 
+import (
+	"sort"
+	"strings"
+)
+
 type DataType int
 
 const (
 	STRING DataType = iota
+	INT64
+	FLOAT64
+	//UTC time. Is used only for canonical event time column (see EventTimeConfig). Isn't widened and doesn't widen other types
+	TIMESTAMP
 )
 
 func (dt DataType) String() string {
@@ -12,38 +21,86 @@ func (dt DataType) String() string {
 		return ""
 	case STRING:
 		return "STRING"
+	case INT64:
+		return "INT64"
+	case FLOAT64:
+		return "FLOAT64"
+	case TIMESTAMP:
+		return "TIMESTAMP"
 	}
 }
 
+//position in types promotion chain: INT64 -> FLOAT64 -> STRING
+var widening = map[DataType]int{
+	INT64:   0,
+	FLOAT64: 1,
+	STRING:  2,
+}
+
+//IsWiderThan return true if dt can hold all values of other type and they aren't equal (e.g. FLOAT64 is wider than INT64)
+func (dt DataType) IsWiderThan(other DataType) bool {
+	dtPosition, ok := widening[dt]
+	if !ok {
+		return false
+	}
+	otherPosition, ok := widening[other]
+	if !ok {
+		return false
+	}
+
+	return dtPosition > otherPosition
+}
+
 type TableNameExtractFunction func(map[string]interface{}) (string, error)
 type Columns map[string]Column
 
 //Add all columns from other to current instance
+//If column exists in both: keep the wider type
 func (c Columns) Merge(other Columns) {
 	for name, column := range other {
-		//TODO when we support several Column types (not only String) we need check if type was changed
+		if current, ok := c[name]; ok && current.Type.IsWiderThan(column.Type) {
+			continue
+		}
 		c[name] = column
 	}
 }
 
-//Header return comma separated column names string
-func (c Columns) Header() string {
-	header := ""
+//SortedNames return column names sorted alphabetically
+//Is used for deterministic DDL statements and headers
+func (c Columns) SortedNames() []string {
+	names := make([]string, 0, len(c))
 	for columnName := range c {
-		header += columnName + ","
+		names = append(names, columnName)
 	}
+	sort.Strings(names)
 
-	// Remove last comma
-	if last := len(header) - 1; last >= 0 && header[last] == ',' {
-		header = header[:last]
-	}
+	return names
+}
 
-	return header
+//Header return comma separated sorted column names string
+func (c Columns) Header() string {
+	return strings.Join(c.SortedNames(), ",")
 }
 
 type Table struct {
-	Name    string
+	Name string
+	//database schema of the table (e.g. Postgres schema). Default schema of the adapter is used if empty
+	Schema  string
 	Columns Columns
+	//existing columns which type must be widened. Is filled only in Diff() result
+	WidenedColumns Columns
+	//existing columns which are absent in another schema. Is filled only in Diff() result. Informational:
+	//columns are never dropped (see ColumnUsage)
+	MissingColumns Columns
+}
+
+//QualifiedName return schema.table or table name if schema isn't set
+func (t *Table) QualifiedName() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+
+	return t.Schema + "." + t.Name
 }
 
 //Return true if there is at least one column
@@ -51,23 +108,48 @@ func (t *Table) Exists() bool {
 	return t != nil && len(t.Columns) > 0
 }
 
+//Return true if Diff() result contains new columns or columns with widened types
+func (t *Table) NeedsPatch() bool {
+	return t.Exists() || (t != nil && len(t.WidenedColumns) > 0)
+}
+
 // Diff calculates diff between current schema and another one.
 // Assume that current schema exists (at least with empty columns)
 // Return schema to add to current schema (for being equal) or empty if
 // 1) another one is empty
 // 2) all fields from another schema exist in current schema
+// Existing columns with narrower type than in another schema are returned in WidenedColumns
+// (only if another column type isn't explicitly configured)
+// Existing columns which another not empty schema doesn't contain are returned in MissingColumns (don't require patch)
 func (t Table) Diff(another *Table) *Table {
-	diff := &Table{Name: t.Name, Columns: Columns{}}
+	diff := &Table{Name: t.Name, Schema: t.Schema, Columns: Columns{}}
 
 	if another == nil || len(another.Columns) == 0 {
 		return diff
 	}
 
 	//not empty main schema => write only new columns to the result
-	for columnName, columnType := range another.Columns {
-		if _, ok := t.Columns[columnName]; !ok {
-			//TODO add type check
-			diff.Columns[columnName] = columnType
+	for columnName, column := range another.Columns {
+		current, ok := t.Columns[columnName]
+		if !ok {
+			diff.Columns[columnName] = column
+			continue
+		}
+
+		if column.SqlType == "" && column.Type.IsWiderThan(current.Type) {
+			if diff.WidenedColumns == nil {
+				diff.WidenedColumns = Columns{}
+			}
+			diff.WidenedColumns[columnName] = column
+		}
+	}
+
+	for columnName, column := range t.Columns {
+		if _, ok := another.Columns[columnName]; !ok {
+			if diff.MissingColumns == nil {
+				diff.MissingColumns = Columns{}
+			}
+			diff.MissingColumns[columnName] = column
 		}
 	}
 
@@ -76,4 +158,9 @@ func (t Table) Diff(another *Table) *Table {
 
 type Column struct {
 	Type DataType
+	//explicitly configured destination type (see TypeResolver). Is used instead of Type mapping if not empty
+	SqlType string
+	//source JSON path of column values (e.g. /eventn_ctx/user_agent). Is filled only if Processor tracks source paths
+	//(see ProcessorConfig.TrackSourcePaths). Empty for generated columns (e.g. PartitionColumn)
+	SourcePath string
 }
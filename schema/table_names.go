@@ -0,0 +1,31 @@
+package schema
+
+import "fmt"
+
+//TableNamesConfig configures destination table names: prefix and suffix (e.g. for environments isolation) and length limit
+type TableNamesConfig struct {
+	Prefix string
+	Suffix string
+	//max table name length (e.g. 63 in Postgres). Longer names are truncated with hash suffix. 0 - unlimited
+	MaxLength int
+}
+
+//Validate return error if prefix and suffix don't leave space for truncated name with hash suffix
+func (tnc TableNamesConfig) Validate() error {
+	if tnc.MaxLength > 0 && len(tnc.Prefix)+len(tnc.Suffix)+hashSuffixLength >= tnc.MaxLength {
+		return fmt.Errorf("Table name prefix [%s] and suffix [%s] are too long: max table name length is %d", tnc.Prefix, tnc.Suffix, tnc.MaxLength)
+	}
+
+	return nil
+}
+
+//TableName return prefix + name + suffix. If result is longer than MaxLength only name is truncated
+//and hash suffix of the whole name is added so prefix and suffix are always kept and names stay unique
+func (tnc TableNamesConfig) TableName(name string) string {
+	full := tnc.Prefix + name + tnc.Suffix
+	if tnc.MaxLength <= 0 || len(full) <= tnc.MaxLength {
+		return full
+	}
+
+	return tnc.Prefix + withHashSuffix(name, full, tnc.MaxLength-len(tnc.Prefix)-len(tnc.Suffix)) + tnc.Suffix
+}
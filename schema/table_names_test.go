@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestTableName(t *testing.T) {
+	longName := strings.Repeat("a", 60)
+	tests := []struct {
+		name     string
+		config   TableNamesConfig
+		input    string
+		expected string
+	}{
+		{
+			"Empty config",
+			TableNamesConfig{},
+			"events",
+			"events",
+		},
+		{
+			"Prefix and suffix",
+			TableNamesConfig{Prefix: "staging_", Suffix: "_v2", MaxLength: 63},
+			"events",
+			"staging_events_v2",
+		},
+		{
+			"Unlimited length",
+			TableNamesConfig{Prefix: "staging_"},
+			longName,
+			"staging_" + longName,
+		},
+		{
+			"Truncated with hash",
+			TableNamesConfig{Prefix: "staging_", Suffix: "_v2", MaxLength: 63},
+			longName,
+			"staging_" + strings.Repeat("a", 43) + "_3b07c618" + "_v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.config.TableName(tt.input)
+			require.Equal(t, tt.expected, actual)
+			if tt.config.MaxLength > 0 {
+				require.True(t, len(actual) <= tt.config.MaxLength)
+			}
+		})
+	}
+}
+
+func TestTableNamesConfigValidate(t *testing.T) {
+	require.NoError(t, TableNamesConfig{Prefix: "staging_", MaxLength: 63}.Validate())
+	require.NoError(t, TableNamesConfig{Prefix: strings.Repeat("a", 100)}.Validate())
+	require.Error(t, TableNamesConfig{Prefix: strings.Repeat("a", 30), Suffix: strings.Repeat("b", 30), MaxLength: 63}.Validate())
+}
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"github.com/ksensehq/eventnative/test"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -46,7 +47,26 @@ func TestDiff(t *testing.T) {
 			"Several fields diff",
 			&Table{Name: "some", Columns: Columns{"col3": Column{Type: STRING}, "col4": Column{Type: STRING}}},
 			&Table{Name: "some", Columns: Columns{"col1": Column{Type: STRING}, "col2": Column{Type: STRING}}},
-			&Table{Name: "some", Columns: Columns{"col2": Column{Type: STRING}, "col1": Column{Type: STRING}}},
+			&Table{Name: "some", Columns: Columns{"col2": Column{Type: STRING}, "col1": Column{Type: STRING}},
+				MissingColumns: Columns{"col3": Column{Type: STRING}, "col4": Column{Type: STRING}}},
+		},
+		{
+			"Missing fields diff",
+			&Table{Name: "some", Columns: Columns{"col1": Column{Type: STRING}, "col2": Column{Type: INT64}}},
+			&Table{Name: "some", Columns: Columns{"col1": Column{Type: STRING}}},
+			&Table{Name: "some", Columns: Columns{}, MissingColumns: Columns{"col2": Column{Type: INT64}}},
+		},
+		{
+			"Widened fields diff",
+			&Table{Name: "some", Columns: Columns{"col1": Column{Type: INT64}, "col2": Column{Type: FLOAT64}, "col3": Column{Type: INT64}}},
+			&Table{Name: "some", Columns: Columns{"col1": Column{Type: FLOAT64}, "col2": Column{Type: STRING}, "col3": Column{Type: INT64}, "col4": Column{Type: STRING}}},
+			&Table{Name: "some", Columns: Columns{"col4": Column{Type: STRING}}, WidenedColumns: Columns{"col1": Column{Type: FLOAT64}, "col2": Column{Type: STRING}}},
+		},
+		{
+			"Narrower and explicitly typed fields aren't widened",
+			&Table{Name: "some", Columns: Columns{"col1": Column{Type: STRING}, "col2": Column{Type: INT64}}},
+			&Table{Name: "some", Columns: Columns{"col1": Column{Type: INT64}, "col2": Column{Type: STRING, SqlType: "bigint"}}},
+			&Table{Name: "some", Columns: Columns{}},
 		},
 	}
 	for _, tt := range tests {
@@ -56,3 +76,13 @@ func TestDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestColumnsSortedNames(t *testing.T) {
+	columns := Columns{"utm_source": Column{Type: STRING}, "count": Column{Type: INT64}, "_timestamp": Column{Type: STRING}, "amount": Column{Type: FLOAT64}}
+	for i := 0; i < 10; i++ {
+		require.Equal(t, []string{"_timestamp", "amount", "count", "utm_source"}, columns.SortedNames(), "Column names aren't sorted")
+		require.Equal(t, "_timestamp,amount,count,utm_source", columns.Header(), "Headers aren't equal")
+	}
+	require.Empty(t, Columns{}.SortedNames())
+	require.Equal(t, "", Columns{}.Header())
+}
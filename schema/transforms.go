@@ -0,0 +1,217 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	RenameTransform       = "rename"
+	DropTransform         = "drop"
+	CastTransform         = "cast"
+	DefaultValueTransform = "default_value"
+	LowercaseTransform    = "lowercase"
+
+	IntCast    = "int"
+	FloatCast  = "float"
+	StringCast = "string"
+)
+
+//TransformConfig is one declarative operation of the pre-insert transform chain over flatten columns (after mapping)
+type TransformConfig struct {
+	//rename, drop, cast, default_value or lowercase
+	Type string
+	//column name. drop, cast and lowercase accept patterns (e.g. utm_*)
+	Field string
+	//rename: new column name (existing value is overwritten)
+	To string
+	//cast: int, float or string
+	As string
+	//default_value: value of missing or null column
+	Value interface{}
+}
+
+//Validate operation type and its parameters
+func (tc TransformConfig) Validate() error {
+	if strings.TrimSpace(tc.Field) == "" {
+		return fmt.Errorf("Transform %s field can't be empty", tc.Type)
+	}
+	if _, err := path.Match(tc.Field, ""); err != nil {
+		return fmt.Errorf("Malformed transform %s field pattern [%s]: %v", tc.Type, tc.Field, err)
+	}
+
+	switch tc.Type {
+	case RenameTransform:
+		if strings.TrimSpace(tc.To) == "" {
+			return fmt.Errorf("Transform rename of %s requires 'to' column name", tc.Field)
+		}
+	case CastTransform:
+		switch tc.As {
+		case IntCast, FloatCast, StringCast:
+		default:
+			return fmt.Errorf("Unsupported transform cast type [%s] of %s. Supported: %s, %s, %s", tc.As, tc.Field, IntCast, FloatCast, StringCast)
+		}
+	case DefaultValueTransform:
+		if tc.Value == nil {
+			return fmt.Errorf("Transform default_value of %s requires 'value'", tc.Field)
+		}
+	case DropTransform, LowercaseTransform:
+	default:
+		return fmt.Errorf("Unsupported transform type [%s]. Supported: %s, %s, %s, %s, %s", tc.Type,
+			RenameTransform, DropTransform, CastTransform, DefaultValueTransform, LowercaseTransform)
+	}
+
+	return nil
+}
+
+//Transforms is an ordered chain of operations over flatten object which is applied right before table schema
+//is built (so it is applied to events of all sources: streaming and batch). Values which can't be casted
+//are removed (null) and counted (see Errors)
+type Transforms struct {
+	configs []TransformConfig
+	errors  uint64
+}
+
+//NewTransforms return validated transform chain
+func NewTransforms(configs []TransformConfig) (*Transforms, error) {
+	transforms := &Transforms{}
+	for _, config := range configs {
+		config.Type = strings.ToLower(strings.TrimSpace(config.Type))
+		config.Field = strings.TrimSpace(config.Field)
+		config.To = strings.TrimSpace(config.To)
+		config.As = strings.ToLower(strings.TrimSpace(config.As))
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		if config.Type == DefaultValueTransform {
+			config.Value = defaultValue(config.Value)
+		}
+		transforms.configs = append(transforms.configs, config)
+	}
+
+	return transforms, nil
+}
+
+//Apply all operations in configured order
+func (t *Transforms) Apply(object map[string]interface{}) {
+	for _, config := range t.configs {
+		switch config.Type {
+		case RenameTransform:
+			if value, ok := object[config.Field]; ok {
+				delete(object, config.Field)
+				object[config.To] = value
+			}
+		case DefaultValueTransform:
+			if value, ok := object[config.Field]; !ok || value == nil {
+				object[config.Field] = config.Value
+			}
+		default:
+			for _, column := range matchedColumns(object, config.Field) {
+				t.applyToColumn(config, object, column)
+			}
+		}
+	}
+}
+
+//Errors return count of values which couldn't be casted
+func (t *Transforms) Errors() uint64 {
+	return atomic.LoadUint64(&t.errors)
+}
+
+func (t *Transforms) applyToColumn(config TransformConfig, object map[string]interface{}, column string) {
+	switch config.Type {
+	case DropTransform:
+		delete(object, column)
+	case LowercaseTransform:
+		if s, ok := object[column].(string); ok {
+			object[column] = strings.ToLower(s)
+		}
+	case CastTransform:
+		if object[column] == nil {
+			return
+		}
+		casted, err := cast(object[column], config.As)
+		if err != nil {
+			atomic.AddUint64(&t.errors, 1)
+			delete(object, column)
+			return
+		}
+		object[column] = casted
+	}
+}
+
+//Return field as is if it isn't a pattern or all matched columns
+func matchedColumns(object map[string]interface{}, field string) []string {
+	if !strings.ContainsAny(field, "*?[") {
+		if _, ok := object[field]; ok {
+			return []string{field}
+		}
+		return nil
+	}
+
+	var columns []string
+	for column := range object {
+		if matched, _ := path.Match(field, column); matched {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+//Return value converted to int64, float64 or string
+func cast(value interface{}, as string) (interface{}, error) {
+	switch as {
+	case StringCast:
+		if number, ok := value.(json.Number); ok {
+			return number.String(), nil
+		}
+		return fmt.Sprint(value), nil
+	case IntCast:
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return int64(v), nil
+		}
+		s := strings.TrimSpace(fmt.Sprint(value))
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Value [%v] can't be casted to int", value)
+		}
+		return int64(f), nil
+	default:
+		if v, ok := value.(float64); ok {
+			return v, nil
+		}
+		if v, ok := value.(int64); ok {
+			return float64(v), nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(value)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Value [%v] can't be casted to float", value)
+		}
+		return f, nil
+	}
+}
+
+//Return config value as flatten value: integers are int64, other numbers are float64, other values are strings
+func defaultValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64, float64, string:
+		return v
+	case float32:
+		return float64(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name           string
+		configs        []TransformConfig
+		input          map[string]interface{}
+		expectedObject map[string]interface{}
+		expectedErrors uint64
+	}{
+		{
+			"Rename and drop",
+			[]TransformConfig{{Type: "rename", Field: "user_email", To: "email"}, {Type: "drop", Field: "internal_*"}, {Type: "drop", Field: "missing"}},
+			map[string]interface{}{"user_email": "a@b.c", "internal_id": "1", "internal_host": "h", "page": "/"},
+			map[string]interface{}{"email": "a@b.c", "page": "/"},
+			0,
+		},
+		{
+			"Cast",
+			[]TransformConfig{{Type: "cast", Field: "amount", As: "float"}, {Type: "cast", Field: "count_*", As: "int"},
+				{Type: "cast", Field: "code", As: "string"}, {Type: "cast", Field: "price", As: "float"}},
+			map[string]interface{}{"amount": "10.5", "count_a": "3", "count_b": 2.7, "code": int64(200), "price": "free"},
+			map[string]interface{}{"amount": 10.5, "count_a": int64(3), "count_b": int64(2), "code": "200"},
+			1,
+		},
+		{
+			"Default value and lowercase in order",
+			[]TransformConfig{{Type: "default_value", Field: "country", Value: "Unknown"}, {Type: "default_value", Field: "version", Value: 1},
+				{Type: "default_value", Field: "city", Value: "none"}, {Type: "lowercase", Field: "country"}, {Type: "lowercase", Field: "version"}},
+			map[string]interface{}{"city": "Berlin"},
+			map[string]interface{}{"country": "unknown", "version": int64(1), "city": "Berlin"},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transforms, err := NewTransforms(tt.configs)
+			require.NoError(t, err)
+			transforms.Apply(tt.input)
+			require.Equal(t, tt.expectedObject, tt.input)
+			require.Equal(t, tt.expectedErrors, transforms.Errors())
+		})
+	}
+}
+
+func TestTransformsMalformed(t *testing.T) {
+	for _, config := range []TransformConfig{{Type: "upper", Field: "a"}, {Type: "drop"}, {Type: "rename", Field: "a"},
+		{Type: "cast", Field: "a", As: "date"}, {Type: "default_value", Field: "a"}, {Type: "drop", Field: "a["}} {
+		_, err := NewTransforms([]TransformConfig{config})
+		require.Error(t, err, config)
+	}
+}
+
+func TestProcessFactTransforms(t *testing.T) {
+	p, err := NewProcessor(`events`, []string{"/_timestamp -> "}, ProcessorConfig{
+		Transforms: []TransformConfig{{Type: "cast", Field: "order_amount", As: "int"}, {Type: "rename", Field: "order_amount", To: "amount"}}})
+	require.NoError(t, err)
+
+	table, object, err := p.ProcessFact(events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "order": map[string]interface{}{"amount": "10"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"amount": int64(10)}, object)
+	require.Equal(t, Columns{"amount": Column{Type: INT64}}, table.Columns, "Table schema must be built from transformed object")
+}
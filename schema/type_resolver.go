@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+//TypeResolver return explicit SQL type for column name or "" if type isn't configured
+type TypeResolver interface {
+	Resolve(columnName string) string
+}
+
+//ColumnTypeResolver matches flatten column names against configured JSON path glob patterns
+type ColumnTypeResolver struct {
+	rules []*TypeRule
+}
+
+type DummyTypeResolver struct{}
+
+type TypeRule struct {
+	pattern string
+	sqlType string
+}
+
+//NewTypeResolver return TypeResolver from rules in format: /field1/subfield1 -> sql type
+//glob patterns are supported e.g. /user/* -> text
+func NewTypeResolver(columnTypes []string) (TypeResolver, error) {
+	return newTypeResolver(columnTypes, DefaultFlattenDelimiter)
+}
+
+//Return TypeResolver which patterns are converted to flatten keys with delimiter
+func newTypeResolver(columnTypes []string, delimiter string) (TypeResolver, error) {
+	if len(columnTypes) == 0 {
+		return &DummyTypeResolver{}, nil
+	}
+
+	var rules []*TypeRule
+	for _, columnType := range columnTypes {
+		parts := strings.Split(columnType, "->")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Malformed column type rule [%s]. Use format: /field1/subfield1 -> sql type", columnType)
+		}
+
+		pattern := strings.ToLower(formatKey(strings.TrimSpace(parts[0]), delimiter))
+		sqlType := strings.TrimSpace(parts[1])
+		if pattern == "" || sqlType == "" {
+			return nil, fmt.Errorf("Malformed column type rule [%s]: path and sql type can't be empty", columnType)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Malformed column type rule [%s] pattern: %v", columnType, err)
+		}
+
+		rules = append(rules, &TypeRule{pattern: pattern, sqlType: sqlType})
+	}
+
+	log.Println("Configured column type rules:")
+	for _, r := range rules {
+		log.Println(r.pattern, "->", r.sqlType)
+	}
+
+	return &ColumnTypeResolver{rules: rules}, nil
+}
+
+//Resolve return sql type of the first matched rule
+func (ctr ColumnTypeResolver) Resolve(columnName string) string {
+	for _, rule := range ctr.rules {
+		if matched, _ := path.Match(rule.pattern, columnName); matched {
+			return rule.sqlType
+		}
+	}
+
+	return ""
+}
+
+//Resolve always return ""
+func (DummyTypeResolver) Resolve(columnName string) string {
+	return ""
+}
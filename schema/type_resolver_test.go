@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name         string
+		columnTypes  []string
+		columnName   string
+		expectedType string
+	}{
+		{
+			"Dummy resolver",
+			nil,
+			"user_id",
+			"",
+		},
+		{
+			"Exact path",
+			[]string{"/user_id -> text"},
+			"user_id",
+			"text",
+		},
+		{
+			"Nested path",
+			[]string{"/User/Id -> bigint"},
+			"user_id",
+			"bigint",
+		},
+		{
+			"Glob path",
+			[]string{"/key1 -> text", "/utm/* -> character varying(256)"},
+			"utm_source",
+			"character varying(256)",
+		},
+		{
+			"First matched rule wins",
+			[]string{"/utm_source -> text", "/utm/* -> varchar"},
+			"utm_source",
+			"text",
+		},
+		{
+			"Not matched",
+			[]string{"/utm/* -> text"},
+			"user_id",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewTypeResolver(tt.columnTypes)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expectedType, resolver.Resolve(tt.columnName), "Resolved types aren't equal")
+		})
+	}
+}
+
+func TestNewTypeResolverMalformed(t *testing.T) {
+	for _, rule := range []string{"/user_id text", "/user_id -> ", "-> text", "/[user -> text"} {
+		_, err := NewTypeResolver([]string{rule})
+		require.Error(t, err, rule)
+	}
+}
@@ -0,0 +1,48 @@
+package storages
+
+import (
+	"math/rand"
+	"time"
+)
+
+//backoff calculates exponentially growing delays with jitter between retries after consecutive failures
+//not thread safe: must be used from one goroutine
+type backoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures int
+}
+
+func newBackoff(base, max time.Duration) *backoff {
+	return &backoff{base: base, max: max}
+}
+
+//Increment consecutive failures counter and return delay before next retry:
+//random value in [delay/2, delay) where delay = base * 2^(failures-1) capped with max
+func (b *backoff) fail() time.Duration {
+	b.failures++
+
+	delay := b.max
+	//avoid overflow on long outages
+	if b.failures < 32 {
+		if d := b.base * time.Duration(1<<uint(b.failures-1)); d > 0 && d < b.max {
+			delay = d
+		}
+	}
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+//Reset consecutive failures counter
+func (b *backoff) reset() {
+	b.failures = 0
+}
+
+//consecutiveFailures return current consecutive failures count
+func (b *backoff) consecutiveFailures() int {
+	return b.failures
+}
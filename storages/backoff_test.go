@@ -0,0 +1,29 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, time.Second)
+
+	//[delay/2, delay) where delay doubles until max
+	for _, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		delay := b.fail()
+		require.True(t, delay >= expected/2 && delay < expected, "Delay %s must be in [%s, %s)", delay, expected/2, expected)
+	}
+	require.Equal(t, 6, b.consecutiveFailures())
+
+	b.reset()
+	require.Equal(t, 0, b.consecutiveFailures())
+	delay := b.fail()
+	require.True(t, delay >= 50*time.Millisecond && delay < 100*time.Millisecond, "Delay must start from base after reset: %s", delay)
+
+	//long outage doesn't overflow
+	for i := 0; i < 100; i++ {
+		delay = b.fail()
+	}
+	require.True(t, delay >= 500*time.Millisecond && delay < time.Second, "Delay must be capped with max: %s", delay)
+}
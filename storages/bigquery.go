@@ -23,9 +23,10 @@ type BigQuery struct {
 	schemaProcessor *schema.Processor
 	tables          map[string]*schema.Table
 	breakOnError    bool
+	name            string
 }
 
-func NewBigQuery(ctx context.Context, config *adapters.GoogleConfig, processor *schema.Processor, breakOnError bool) (*BigQuery, error) {
+func NewBigQuery(ctx context.Context, config *adapters.GoogleConfig, processor *schema.Processor, breakOnError bool, storageName string) (*BigQuery, error) {
 	gcsAdapter, err := adapters.NewGoogleCloudStorage(ctx, config)
 	if err != nil {
 		return nil, err
@@ -48,6 +49,7 @@ func NewBigQuery(ctx context.Context, config *adapters.GoogleConfig, processor *
 		schemaProcessor: processor,
 		tables:          map[string]*schema.Table{},
 		breakOnError:    breakOnError,
+		name:            storageName,
 	}
 	bq.start()
 
@@ -61,11 +63,12 @@ func NewBigQuery(ctx context.Context, config *adapters.GoogleConfig, processor *
 func (bq *BigQuery) start() {
 	go func() {
 		for {
-			if appstatus.Instance.Idle {
-				break
-			}
 			//TODO configurable
-			time.Sleep(1 * time.Minute)
+			select {
+			case <-appstatus.Instance.Done():
+				return
+			case <-time.After(1 * time.Minute):
+			}
 
 			filesKeys, err := bq.gcsAdapter.ListBucket(appconfig.Instance.ServerName)
 			if err != nil {
@@ -131,6 +134,7 @@ func (bq *BigQuery) Store(fileName string, payload []byte) error {
 			if err := bq.bqAdapter.PatchTableSchema(schemaDiff); err != nil {
 				return err
 			}
+			notifySchemaChange("bigquery", bq.name, schemaDiff)
 			//Save
 			for k, v := range schemaDiff.Columns {
 				dbTableSchema.Columns[k] = v
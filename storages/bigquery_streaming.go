@@ -0,0 +1,72 @@
+package storages
+
+import (
+	"context"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+)
+
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and store events to google BigQuery in streaming mode via streaming insert api
+//Keeping tables schema state inmemory and update it according to incoming new data
+//note: Assume that after any outer changes in db we need to recreate this structure
+//for keeping actual db tables schema state
+type BigQueryStreaming struct {
+	*streamingWorker
+
+	adapter *adapters.BigQuery
+	tables  *tablesCache
+}
+
+func NewBigQueryStreaming(ctx context.Context, config *adapters.GoogleConfig, processor *schema.Processor,
+	fallbackDir, storageName string) (*BigQueryStreaming, error) {
+	adapter, err := adapters.NewBigQuery(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	//create dataset if doesn't exist
+	err = adapter.CreateDataset(config.Dataset)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+
+	bq := &BigQueryStreaming{
+		adapter: adapter,
+		tables:  newTablesCache(),
+	}
+
+	bq.streamingWorker, err = newStreamingWorker("bigquery", storageName, fallbackDir, config.StreamingConfig, processor, bq.insert)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+	bq.start()
+
+	return bq, nil
+}
+
+//insert facts in BigQuery
+func (bq *BigQueryStreaming) insert(dataSchema *schema.Table, objects []events.Fact) error {
+	dbTableSchema, err := bq.tables.ensureTable("BigQuery", bq.adapter, dataSchema, nil, bq.notifySchemaChange)
+	if err != nil {
+		return err
+	}
+
+	return bq.adapter.Insert(dbTableSchema, objects)
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.BigQuery
+func (bq *BigQueryStreaming) Close() (multiErr error) {
+	if err := bq.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if err := bq.adapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+
+	return
+}
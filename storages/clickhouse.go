@@ -0,0 +1,72 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+)
+
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and store events to ClickHouse in streaming mode with batches
+//Keeping tables schema state inmemory and update it according to incoming new data
+//note: Assume that after any outer changes in db we need to recreate this structure
+//for keeping actual db tables schema state
+type ClickHouse struct {
+	*streamingWorker
+
+	adapter *adapters.ClickHouse
+	tables  *tablesCache
+}
+
+func NewClickHouse(ctx context.Context, config *adapters.ClickHouseConfig, processor *schema.Processor,
+	fallbackDir, storageName string) (*ClickHouse, error) {
+	adapter, err := adapters.NewClickHouse(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	//create db if doesn't exist
+	err = adapter.CreateDB(config.Db)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &ClickHouse{
+		adapter: adapter,
+		tables:  newTablesCache(),
+	}
+
+	ch.streamingWorker, err = newStreamingWorker("clickhouse", storageName, fallbackDir, config.StreamingConfig, processor, ch.insert)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+	ch.start()
+
+	return ch, nil
+}
+
+//insert facts in ClickHouse
+func (ch *ClickHouse) insert(dataSchema *schema.Table, objects []events.Fact) error {
+	dbTableSchema, err := ch.tables.ensureTable("clickhouse", ch.adapter, dataSchema, nil, ch.notifySchemaChange)
+	if err != nil {
+		return err
+	}
+
+	return ch.adapter.BulkInsert(dbTableSchema, objects)
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.ClickHouse
+func (ch *ClickHouse) Close() (multiErr error) {
+	if err := ch.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if err := ch.adapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing clickhouse datasource: %v", err))
+	}
+
+	return
+}
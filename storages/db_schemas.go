@@ -0,0 +1,71 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"sort"
+	"strings"
+)
+
+//dbSchemaRouter selects Postgres db schema of every object by value of the schema column
+//(see adapters.DataSourceConfig schema_column)
+type dbSchemaRouter struct {
+	//flatten column name. Empty - all objects are stored in defaultSchema
+	column        string
+	defaultSchema string
+	//lowercase schema names. Empty - all valid names are allowed
+	allowed map[string]bool
+	//name validation (see adapters.IsValidDbSchemaName)
+	isValid func(string) bool
+}
+
+func newDbSchemaRouter(column, defaultSchema string, allowed []string, isValid func(string) bool) *dbSchemaRouter {
+	router := &dbSchemaRouter{column: column, defaultSchema: defaultSchema, allowed: map[string]bool{}, isValid: isValid}
+	for _, name := range allowed {
+		router.allowed[strings.ToLower(name)] = true
+	}
+
+	return router
+}
+
+//Return lowercase schema column value of the object or default schema if the value is missing, invalid or isn't allowed
+func (dsr *dbSchemaRouter) dbSchema(object events.Fact) string {
+	value, ok := object[dsr.column]
+	if dsr.column == "" || !ok || value == nil {
+		return dsr.defaultSchema
+	}
+
+	dbSchema := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", value)))
+	if !dsr.isValid(dbSchema) || (len(dsr.allowed) > 0 && !dsr.allowed[dbSchema]) {
+		return dsr.defaultSchema
+	}
+
+	return dbSchema
+}
+
+//Return db schema names (sorted), objects of every db schema (in original order) and their indexes in objects
+func (dsr *dbSchemaRouter) group(objects []events.Fact) ([]string, map[string][]events.Fact, map[string][]int) {
+	if dsr.column == "" {
+		indexes := make([]int, len(objects))
+		for i := range objects {
+			indexes[i] = i
+		}
+		return []string{dsr.defaultSchema}, map[string][]events.Fact{dsr.defaultSchema: objects}, map[string][]int{dsr.defaultSchema: indexes}
+	}
+
+	groups := map[string][]events.Fact{}
+	indexes := map[string][]int{}
+	for i, object := range objects {
+		dbSchema := dsr.dbSchema(object)
+		groups[dbSchema] = append(groups[dbSchema], object)
+		indexes[dbSchema] = append(indexes[dbSchema], i)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, groups, indexes
+}
@@ -0,0 +1,65 @@
+package storages
+
+import (
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+var testDbSchemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+func TestDbSchemaRouter(t *testing.T) {
+	tests := []struct {
+		name           string
+		column         string
+		allowed        []string
+		expectedNames  []string
+		expectedGroups map[string][]events.Fact
+	}{
+		{
+			"Schema column isn't configured",
+			"",
+			nil,
+			[]string{"public"},
+			map[string][]events.Fact{"public": dbSchemaFacts},
+		},
+		{
+			"All valid schemas",
+			"tenant",
+			nil,
+			[]string{"acme", "public", "tenant_2"},
+			map[string][]events.Fact{"acme": {dbSchemaFacts[0], dbSchemaFacts[2]}, "tenant_2": {dbSchemaFacts[1]}, "public": {dbSchemaFacts[3], dbSchemaFacts[4], dbSchemaFacts[5]}},
+		},
+		{
+			"Only allowed schemas",
+			"tenant",
+			[]string{"ACME"},
+			[]string{"acme", "public"},
+			map[string][]events.Fact{"acme": {dbSchemaFacts[0], dbSchemaFacts[2]}, "public": {dbSchemaFacts[1], dbSchemaFacts[3], dbSchemaFacts[4], dbSchemaFacts[5]}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newDbSchemaRouter(tt.column, "public", tt.allowed, testDbSchemaName.MatchString)
+			names, groups, indexes := router.group(dbSchemaFacts)
+			require.Equal(t, tt.expectedNames, names)
+			require.Equal(t, tt.expectedGroups, groups)
+			for name, group := range groups {
+				require.Equal(t, len(group), len(indexes[name]))
+				for i, index := range indexes[name] {
+					require.Equal(t, dbSchemaFacts[index], group[i], "Index must point to the object in the original batch")
+				}
+			}
+		})
+	}
+}
+
+var dbSchemaFacts = []events.Fact{
+	{"tenant": "acme", "id": 1},
+	{"tenant": "tenant_2", "id": 2},
+	{"tenant": " Acme ", "id": 3},
+	{"tenant": "drop table; --", "id": 4},
+	{"tenant": nil, "id": 5},
+	{"id": 6},
+}
@@ -3,33 +3,196 @@ package storages
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/ksensehq/eventnative/adapters"
 	"github.com/ksensehq/eventnative/appconfig"
 	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/geo"
+	"github.com/ksensehq/eventnative/privacy"
 	"github.com/ksensehq/eventnative/schema"
+	"github.com/ksensehq/eventnative/timestamp"
+	"github.com/ksensehq/eventnative/useragent"
+	"github.com/ksensehq/eventnative/validation"
 	"github.com/spf13/viper"
 	"log"
+	"os"
+	"time"
 )
 
-const defaultTableName = "events"
+const (
+	defaultTableName = "events"
+
+	defaultStreamingBatchSize       = 500
+	defaultStreamingFlushIntervalMs = 1000
+	defaultStreamingBackoffBaseMs   = 500
+	defaultStreamingBackoffMaxMs    = 30000
+	defaultStreamingMaxAttempts     = 5
+	defaultStreamingShutdownMs      = 10000
+
+	defaultHTTPTimeoutMs  = 10000
+	defaultHTTPMaxRetries = 3
+
+	defaultValidationReloadIntervalSec = 60
+
+	defaultDedupWindowSize = 100000
+)
 
 type DestinationConfig struct {
 	OnlyTokens   []string    `mapstructure:"only_tokens"`
 	Type         string      `mapstructure:"type"`
 	DataLayout   *DataLayout `mapstructure:"data_layout"`
 	BreakOnError bool        `mapstructure:"break_on_error"`
+	//rules in format: /field operator value. Only matched events are stored (see events.FilterConsumer)
+	Filters []string `mapstructure:"filters"`
+	//store only a part of high-volume events (see events.SamplingConsumer)
+	Sampling *Sampling `mapstructure:"sampling"`
+	//skip events with already seen key (e.g. retried HTTP delivery) within in-memory window (see events.DedupConsumer)
+	Dedup *Dedup `mapstructure:"dedup"`
+	//generate ids of events without id (e.g. for dedup and upsert keys) before all other wrappers (see events.IdConsumer)
+	EventId *EventId `mapstructure:"event_id"`
+	//limit events per second of every source (e.g. api key) so one noisy source doesn't flood the queue (see events.RateLimitConsumer)
+	RateLimit *RateLimit `mapstructure:"rate_limit"`
+	//debug mode: stdout or stderr. Consumed events are also written there masked as indented json (see events.TeeConsumer)
+	Tee string `mapstructure:"tee"`
+	//field with client ip e.g. /eventn_ctx/ip. If set geo_country, geo_city, geo_region fields are added (see geo.EnrichmentConsumer)
+	GeoIpField string `mapstructure:"geo_ip_field"`
+	//field with user-agent e.g. /eventn_ctx/user_agent. If set ua_browser, ua_os, ua_device, ua_is_bot fields are added
+	//(see useragent.EnrichmentConsumer)
+	UaField string `mapstructure:"ua_field"`
+	//validate events with JSON Schema per event type. Invalid events are put to the dead-letter queue (streaming destinations only)
+	Validation *Validation `mapstructure:"validation"`
+	//dir for persistent queue files of streaming destinations. log.path by default
+	//Is created if it doesn't exist. Separate dirs keep queues of different storages (or instances) apart
+	FallbackDir string `mapstructure:"fallback_dir"`
+	//route events to per tenant storages (see events.RoutingConsumer). Postgres destinations only
+	Routing *Routing `mapstructure:"routing"`
 
 	DataSource *adapters.DataSourceConfig `mapstructure:"datasource"`
 	S3         *adapters.S3Config         `mapstructure:"s3"`
+	S3Sink     *adapters.S3SinkConfig     `mapstructure:"s3_sink"`
 	Google     *adapters.GoogleConfig     `mapstructure:"google"`
+	ClickHouse *adapters.ClickHouseConfig `mapstructure:"clickhouse"`
+	Snowflake  *adapters.SnowflakeConfig  `mapstructure:"snowflake"`
+	MySQL      *adapters.MySQLConfig      `mapstructure:"mysql"`
+	Kafka      *adapters.KafkaConfig      `mapstructure:"kafka"`
+	HTTP       *adapters.HTTPConfig       `mapstructure:"http"`
+}
+
+type Sampling struct {
+	//event_type -> share of stored events e.g. page_view: 0.01
+	Rates map[string]float64 `mapstructure:"rates"`
+	//optional: events with the same value are either stored or skipped all together
+	KeyField string `mapstructure:"key_field"`
+}
+
+type Dedup struct {
+	//JSON path of event key. /eventn_ctx/event_id by default
+	KeyField string `mapstructure:"key_field"`
+	//max count of kept keys (the least recently seen ones are evicted). 100000 by default
+	WindowSize int `mapstructure:"window_size"`
+	//optional: keys are kept no longer than this count of seconds. 0 (default) - only window_size is applied
+	WindowSec int `mapstructure:"window_sec"`
+}
+
+type EventId struct {
+	//JSON path of event id. Ids are generated only for events without it. /eventn_ctx/event_id by default
+	Field string `mapstructure:"field"`
+	//hash (default): name based UUID of hash_fields values. random: random UUID
+	Strategy string `mapstructure:"strategy"`
+	//JSON paths of stable fields e.g. /user/id, /timestamp. Random UUID is generated if none of them exist in event
+	HashFields []string `mapstructure:"hash_fields"`
+}
+
+type RateLimit struct {
+	//JSON path of source key e.g. /api_key. All events share one limit if not set
+	KeyField string `mapstructure:"key_field"`
+	//events per second of one key
+	Rate float64 `mapstructure:"rate"`
+	//max count of events in a burst above rate. rate by default
+	Burst int `mapstructure:"burst"`
+	//drop (default), block or dead_letter
+	Policy string `mapstructure:"policy"`
+}
+
+type Routing struct {
+	//JSON path of tenant field e.g. /eventn_ctx/tenant_id
+	TenantField string `mapstructure:"tenant_field"`
+	//tenant (lowercase) -> tenant datasource. Tenant storage is created on the first event of the tenant
+	//Events of other tenants and without tenant field are stored in the destination datasource
+	Tenants map[string]*adapters.DataSourceConfig `mapstructure:"tenants"`
+}
+
+type Validation struct {
+	//directory with <event_type>.json JSON Schema files. Events of types without schema aren't validated
+	SchemasDir string `mapstructure:"schemas_dir"`
+	//JSON path of event type field. /event_type by default
+	EventTypeField string `mapstructure:"event_type_field"`
+	//how often schemas dir is checked for changes. 60 by default
+	ReloadIntervalSec int `mapstructure:"reload_interval_sec"`
 }
 
 type DataLayout struct {
 	Mapping           []string `mapstructure:"mapping"`
+	ColumnTypes       []string `mapstructure:"column_types"`
 	TableNameTemplate string   `mapstructure:"table_name_template"`
+	//events with missing table_name_template keys or invalid table names are stored here. Skipped if not set
+	DefaultTableName string `mapstructure:"default_table_name"`
+	//nested objects deeper than this level are stored as json strings. 0 (default) - unlimited
+	MaxFlattenDepth int `mapstructure:"max_flatten_depth"`
+	//convert nested keys to snake_case and replace not allowed characters with '_'
+	SnakeCaseColumns bool `mapstructure:"snake_case_columns"`
+	//longer column names are truncated with hash suffix. Destination identifier limit by default
+	MaxColumnNameLength int `mapstructure:"max_column_name_length"`
+	//put between nested keys of flatten column names. '_' by default
+	FlattenDelimiter string `mapstructure:"flatten_delimiter"`
+	//keep keys case in column names (userId and userid are different columns). postgres only
+	CaseSensitiveColumns bool `mapstructure:"case_sensitive_columns"`
+	//flatten key with timestamp (e.g. _timestamp). If set _partition_date column is filled
+	//and postgres tables are created partitioned by range of it
+	PartitionField string `mapstructure:"partition_field"`
+	//day (default) or month
+	PartitionGranularity string `mapstructure:"partition_granularity"`
+	//subtrees (e.g. /properties) which are stored in one jsonb column without flattening. / - the whole event in _payload column
+	//postgres only
+	JSONBPaths []string `mapstructure:"jsonb_paths"`
+	//store numbers in integer and float columns instead of strings. Columns are widened to strings on mixed values
+	//postgres only: other destinations don't widen existing columns types
+	NumericTypes bool `mapstructure:"numeric_types"`
+	//source paths with numeric precision and scale in format: /amount -> 18,2 (see schema.DecimalValue)
+	DecimalFields []string `mapstructure:"decimal_fields"`
+	//source paths (e.g. /user/email) of fields which get <column>_present columns: 1 if field exists (even null), 0 if absent
+	PresenceFields []string `mapstructure:"presence_fields"`
+	//store homogeneous scalar arrays in array columns (e.g. text[], bigint[]) and other arrays in jsonb columns
+	//instead of json strings. postgres only
+	ArrayTypes bool `mapstructure:"array_types"`
+	//source paths (e.g. /timestamp, /eventn_ctx/utc_time) of event time in priority order. If set the first existing one
+	//is normalized to UTC event_time_column (timestamp by default). It can be used as partition_field
+	EventTimeFields []string `mapstructure:"event_time_fields"`
+	EventTimeColumn string   `mapstructure:"event_time_column"`
+	//Go time layouts or rfc3339, epoch_millis, epoch_seconds. rfc3339, eventnative layout and epoch_millis by default
+	EventTimeFormats []string `mapstructure:"event_time_formats"`
+	//reject (default) - event without parseable event time isn't stored, now - current time is used
+	EventTimeOnMissing string `mapstructure:"event_time_on_missing"`
+	//columns computed by expressions over flatten fields in format: column_name = expression (see schema.ComputedColumns)
+	ComputedColumns []string `mapstructure:"computed_columns"`
+	//ordered operations over flatten columns after mapping: rename, drop, cast, default_value, lowercase (see schema.Transforms)
+	Transforms []schema.TransformConfig `mapstructure:"transforms"`
+	//column (e.g. _raw) with the original not flattened event as jsonb next to flatten columns. postgres only
+	RawColumn string `mapstructure:"raw_column"`
 }
 
-var unknownDestination = errors.New("Unknown destination type")
+var (
+	unknownDestination = errors.New("Unknown destination type")
+
+	//destinations identifiers length limits
+	defaultMaxColumnNameLength = map[string]int{
+		"postgres":  63,
+		"redshift":  127,
+		"bigquery":  300,
+		"snowflake": 255,
+		"mysql":     64,
+	}
+)
 
 //Create event storages(batch) and consumers(streaming) from incoming config
 //Enrich incoming configs with default values if needed
@@ -53,30 +216,110 @@ func CreateStorages(ctx context.Context, destinations *viper.Viper, logEventPath
 		log.Println("Initializing", name, "destination of type:", destination.Type)
 
 		var mapping []string
+		processorConfig := schema.ProcessorConfig{}
 		tableName := defaultTableName
 		if destination.DataLayout != nil {
 			mapping = destination.DataLayout.Mapping
+			processorConfig.ColumnTypes = destination.DataLayout.ColumnTypes
+			processorConfig.MaxFlattenDepth = destination.DataLayout.MaxFlattenDepth
+			processorConfig.DefaultTableName = destination.DataLayout.DefaultTableName
+			processorConfig.ColumnNames.SnakeCase = destination.DataLayout.SnakeCaseColumns
+			processorConfig.ColumnNames.MaxLength = destination.DataLayout.MaxColumnNameLength
+			processorConfig.ColumnNames.Delimiter = destination.DataLayout.FlattenDelimiter
+			processorConfig.ColumnNames.CaseSensitive = destination.DataLayout.CaseSensitiveColumns
+			processorConfig.Partition.Field = destination.DataLayout.PartitionField
+			processorConfig.Partition.Granularity = destination.DataLayout.PartitionGranularity
+			processorConfig.JSONPaths = destination.DataLayout.JSONBPaths
+			processorConfig.NumericTypes = destination.DataLayout.NumericTypes
+			processorConfig.PresencePaths = destination.DataLayout.PresenceFields
+			processorConfig.ArrayTypes = destination.DataLayout.ArrayTypes
+			processorConfig.EventTime.SourcePaths = destination.DataLayout.EventTimeFields
+			processorConfig.EventTime.Column = destination.DataLayout.EventTimeColumn
+			processorConfig.EventTime.Formats = destination.DataLayout.EventTimeFormats
+			processorConfig.EventTime.OnMissing = destination.DataLayout.EventTimeOnMissing
+			processorConfig.ComputedColumns = destination.DataLayout.ComputedColumns
+			processorConfig.Transforms = destination.DataLayout.Transforms
+			processorConfig.RawColumn = destination.DataLayout.RawColumn
+			processorConfig.DecimalFields = destination.DataLayout.DecimalFields
 
 			if destination.DataLayout.TableNameTemplate != "" {
 				tableName = destination.DataLayout.TableNameTemplate
 			}
 		}
 
-		processor, err := schema.NewProcessor(tableName, mapping)
+		if processorConfig.ColumnNames.MaxLength <= 0 {
+			if maxLength, ok := defaultMaxColumnNameLength[destination.Type]; ok {
+				processorConfig.ColumnNames.MaxLength = maxLength
+				log.Printf("name: %s type: %s max_column_name_length wasn't provided. Will be used default one: %d", name, destination.Type, maxLength)
+			}
+		}
+
+		if len(processorConfig.JSONPaths) > 0 && destination.Type != "postgres" {
+			logError(name, destination.Type, errors.New("data_layout jsonb_paths are supported only in postgres destination"))
+			continue
+		}
+		if processorConfig.NumericTypes && destination.Type != "postgres" {
+			logError(name, destination.Type, errors.New("data_layout numeric_types is supported only in postgres destination"))
+			continue
+		}
+
+		if processorConfig.ArrayTypes && destination.Type != "postgres" {
+			logError(name, destination.Type, errors.New("data_layout array_types is supported only in postgres destination"))
+			continue
+		}
+		if processorConfig.RawColumn != "" && destination.Type != "postgres" {
+			logError(name, destination.Type, errors.New("data_layout raw_column is supported only in postgres destination"))
+			continue
+		}
+		//other destinations fold or don't quote identifiers so mixed case columns would never match table schema
+		if processorConfig.ColumnNames.CaseSensitive && destination.Type != "postgres" {
+			logError(name, destination.Type, errors.New("data_layout case_sensitive_columns is supported only in postgres destination"))
+			continue
+		}
+
+		//source paths are used in column comments
+		processorConfig.TrackSourcePaths = destination.Type == "postgres" && postgresColumnComments(destination)
+
+		processor, err := schema.NewProcessor(tableName, mapping, processorConfig)
 		if err != nil {
 			logError(name, destination.Type, err)
 			continue
 		}
 
+		//streaming destinations keep queue files in log.path by default
+		fallbackDir := logEventPath
+		if destination.FallbackDir != "" {
+			fallbackDir = destination.FallbackDir
+		}
+
 		var storage events.Storage
 		var consumer events.Consumer
 		switch destination.Type {
 		case "redshift":
 			storage, err = createRedshift(ctx, name, destination, processor)
 		case "bigquery":
-			storage, err = createBigQuery(ctx, name, destination, processor)
+			if destination.Google != nil && destination.Google.StreamMode() {
+				consumer, err = createBigQueryStreaming(ctx, name, destination, processor, fallbackDir)
+			} else {
+				storage, err = createBigQuery(ctx, name, destination, processor)
+			}
 		case "postgres":
-			consumer, err = createPostgres(ctx, name, destination, processor, logEventPath)
+			consumer, err = createPostgres(ctx, name, destination, processor, fallbackDir)
+			if err == nil && destination.Routing != nil {
+				consumer, err = createPostgresRouting(ctx, name, destination, processor, fallbackDir, consumer)
+			}
+		case "clickhouse":
+			consumer, err = createClickHouse(ctx, name, destination, processor, fallbackDir)
+		case "snowflake":
+			consumer, err = createSnowflake(ctx, name, destination, processor, fallbackDir)
+		case "mysql":
+			consumer, err = createMySQL(ctx, name, destination, processor, fallbackDir)
+		case "kafka":
+			consumer, err = createKafka(name, destination, fallbackDir)
+		case "http":
+			consumer, err = createHTTP(ctx, name, destination, fallbackDir)
+		case "s3":
+			consumer, err = createS3(name, destination, fallbackDir)
 		default:
 			err = unknownDestination
 		}
@@ -86,6 +329,112 @@ func CreateStorages(ctx context.Context, destinations *viper.Viper, logEventPath
 			continue
 		}
 
+		var ok bool
+		//tee wraps storage inside masking: facts are written masked and in the same shape as they are stored
+		if destination.Tee != "" {
+			consumer, ok = wrapConsumer(name, destination.Type, "tee", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				switch destination.Tee {
+				case "stdout":
+					return events.NewTeeConsumer(consumer, os.Stdout), nil
+				case "stderr":
+					return events.NewTeeConsumer(consumer, os.Stderr), nil
+				default:
+					return nil, fmt.Errorf("Unsupported tee: %s. Supported: stdout, stderr", destination.Tee)
+				}
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		//masking wraps storage (with tee) directly: enrichment consumers read not masked values but only masked ones are stored
+		//batch destinations read events from log files which are masked by logger consumers
+		//masking consumer forwards to the storage dead-letter queue (see rate_limit dead_letter policy)
+		var deadLetterer events.DeadLetterer
+		if consumer != nil {
+			masked := privacy.NewMaskingConsumer(consumer, appconfig.Instance.Masker)
+			deadLetterer, _ = masked.(events.DeadLetterer)
+			consumer = masked
+		}
+
+		//validation wraps storage (with masking): invalid events are put to its dead-letter queue
+		if destination.Validation != nil {
+			consumer, ok = wrapConsumer(name, destination.Type, "validation", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return createValidationConsumer(name, destination.Type, consumer, destination.Validation)
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		if len(destination.Filters) > 0 {
+			consumer, ok = wrapConsumer(name, destination.Type, "filters", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return events.NewFilterConsumer(consumer, destination.Filters)
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		if destination.GeoIpField != "" {
+			consumer, ok = wrapConsumer(name, destination.Type, "geo_ip_field", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return geo.NewEnrichmentConsumer(consumer, appconfig.Instance.GeoResolver, destination.GeoIpField), nil
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		if destination.UaField != "" {
+			consumer, ok = wrapConsumer(name, destination.Type, "ua_field", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return useragent.NewEnrichmentConsumer(consumer, appconfig.Instance.UaResolver, destination.UaField), nil
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		if destination.Sampling != nil {
+			consumer, ok = wrapConsumer(name, destination.Type, "sampling", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return events.NewSamplingConsumer(consumer, destination.Sampling.Rates, destination.Sampling.KeyField)
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		//duplicates are skipped before all other wrappers except event id generation and rate limit
+		//(rate limit is checked first so duplicates of noisy sources count against their limit too)
+		if destination.Dedup != nil {
+			consumer, ok = wrapConsumer(name, destination.Type, "dedup", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return CreateDedupConsumer(name, destination.Type, consumer, destination.Dedup)
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		//ids are generated before dedup so retried deliveries of the same event (hash strategy) are skipped
+		if destination.EventId != nil {
+			consumer, ok = wrapConsumer(name, destination.Type, "event_id", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return CreateIdConsumer(name, destination.Type, consumer, destination.EventId)
+			})
+			if !ok {
+				continue
+			}
+		}
+
+		//limits are checked before all other wrappers so events of noisy sources aren't processed
+		if destination.RateLimit != nil {
+			consumer, ok = wrapConsumer(name, destination.Type, "rate_limit", consumer, func(consumer events.Consumer) (events.Consumer, error) {
+				return events.NewRateLimitConsumer(consumer, deadLetterer, destination.RateLimit.KeyField,
+					destination.RateLimit.Rate, destination.RateLimit.Burst, destination.RateLimit.Policy)
+			})
+			if !ok {
+				continue
+			}
+		}
+
 		tokens := destination.OnlyTokens
 		if len(tokens) == 0 {
 			log.Printf("Warn: only_tokens wasn't provided. All tokens will be stored in %s %s destination", name, destination.Type)
@@ -107,6 +456,70 @@ func CreateStorages(ctx context.Context, destinations *viper.Viper, logEventPath
 	return stores, consumers
 }
 
+//Create validation.Consumer over streaming consumer with default parameters if they aren't set
+func createValidationConsumer(name, destinationType string, consumer events.Consumer, config *Validation) (*validation.Consumer, error) {
+	if config.SchemasDir == "" {
+		return nil, errors.New("validation schemas_dir is required parameter")
+	}
+	if config.EventTypeField == "" {
+		config.EventTypeField = validation.DefaultEventTypeField
+		log.Printf("name: %s type: %s validation event_type_field wasn't provided. Will be used default one: %s", name, destinationType, config.EventTypeField)
+	}
+	if config.ReloadIntervalSec <= 0 {
+		config.ReloadIntervalSec = defaultValidationReloadIntervalSec
+		log.Printf("name: %s type: %s validation reload_interval_sec wasn't provided. Will be used default one: %d", name, destinationType, config.ReloadIntervalSec)
+	}
+
+	return validation.NewConsumer(consumer, config.SchemasDir, config.EventTypeField, time.Duration(config.ReloadIntervalSec)*time.Second)
+}
+
+//CreateDedupConsumer return events.DedupConsumer over consumer with default parameters if they aren't set
+func CreateDedupConsumer(name, destinationType string, consumer events.Consumer, config *Dedup) (*events.DedupConsumer, error) {
+	if config.KeyField == "" {
+		config.KeyField = events.DefaultDedupKeyField
+		log.Printf("name: %s type: %s dedup key_field wasn't provided. Will be used default one: %s", name, destinationType, config.KeyField)
+	}
+	if config.WindowSize == 0 {
+		config.WindowSize = defaultDedupWindowSize
+		log.Printf("name: %s type: %s dedup window_size wasn't provided. Will be used default one: %d", name, destinationType, config.WindowSize)
+	}
+
+	return events.NewDedupConsumer(consumer, config.KeyField, config.WindowSize, time.Duration(config.WindowSec)*time.Second)
+}
+
+//CreateIdConsumer return events.IdConsumer over consumer with default parameters if they aren't set
+func CreateIdConsumer(name, destinationType string, consumer events.Consumer, config *EventId) (*events.IdConsumer, error) {
+	if config.Field == "" {
+		config.Field = events.DefaultDedupKeyField
+		log.Printf("name: %s type: %s event_id field wasn't provided. Will be used default one: %s", name, destinationType, config.Field)
+	}
+	if config.Strategy == "" {
+		config.Strategy = events.HashIdStrategy
+		log.Printf("name: %s type: %s event_id strategy wasn't provided. Will be used default one: %s", name, destinationType, config.Strategy)
+	}
+
+	return events.NewIdConsumer(consumer, config.Field, config.Strategy, config.HashFields)
+}
+
+//Wrap streaming destination consumer with configured option wrapper. Options are ignored in batch destinations (nil consumer)
+//If wrapper can't be created consumer is closed, the error is logged and false is returned: the destination must be skipped
+func wrapConsumer(name, destinationType, option string, consumer events.Consumer,
+	wrap func(consumer events.Consumer) (events.Consumer, error)) (events.Consumer, bool) {
+	if consumer == nil {
+		log.Printf("Warn: %s option is supported only in streaming destinations. It will be ignored in %s %s destination", option, name, destinationType)
+		return nil, true
+	}
+
+	wrapped, err := wrap(consumer)
+	if err != nil {
+		consumer.Close()
+		logError(name, destinationType, err)
+		return nil, false
+	}
+
+	return wrapped, true
+}
+
 func logError(destinationName, destinationType string, err error) {
 	log.Printf("Error initializing %s destination of type %s: %v", destinationName, destinationType, err)
 }
@@ -132,7 +545,7 @@ func createRedshift(ctx context.Context, name string, destination DestinationCon
 		log.Printf("name: %s type: redshift schema wasn't provided. Will be used default one: %s", name, redshiftConfig.Schema)
 	}
 
-	return NewAwsRedshift(ctx, s3Config, redshiftConfig, processor, destination.BreakOnError)
+	return NewAwsRedshift(ctx, s3Config, redshiftConfig, processor, destination.BreakOnError, name)
 }
 
 //Create google BigQuery event storage
@@ -148,11 +561,76 @@ func createBigQuery(ctx context.Context, name string, destination DestinationCon
 		log.Printf("name: %s type: bigquery dataset wasn't provided. Will be used default one: %s", name, gConfig.Dataset)
 	}
 
-	return NewBigQuery(ctx, gConfig, processor, destination.BreakOnError)
+	return NewBigQuery(ctx, gConfig, processor, destination.BreakOnError, name)
+}
+
+//Create google BigQuery event consumer (streaming mode)
+func createBigQueryStreaming(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor, fallbackDir string) (*BigQueryStreaming, error) {
+	gConfig := destination.Google
+	if err := gConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	//enrich with default parameters
+	if gConfig.Dataset == "" {
+		gConfig.Dataset = "default"
+		log.Printf("name: %s type: bigquery dataset wasn't provided. Will be used default one: %s", name, gConfig.Dataset)
+	}
+	enrichStreamingConfig(name, destination.Type, &gConfig.StreamingConfig)
+
+	return NewBigQueryStreaming(ctx, gConfig, processor, fallbackDir, name)
+}
+
+//Create events.RoutingConsumer which stores events of configured tenants in their own Postgres storages
+//and other events in destination storage (defaultConsumer)
+func createPostgresRouting(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor,
+	fallbackDir string, defaultConsumer events.Consumer) (events.Consumer, error) {
+	routing := destination.Routing
+	if routing.TenantField == "" {
+		defaultConsumer.Close()
+		return nil, errors.New("routing tenant_field is required")
+	}
+	for tenant, config := range routing.Tenants {
+		if config == nil {
+			defaultConsumer.Close()
+			return nil, fmt.Errorf("routing tenant %s datasource is required", tenant)
+		}
+	}
+
+	//tenant is lowercase (see events.RoutingConsumer)
+	factory := func(tenant string) (events.Consumer, error) {
+		config, ok := routing.Tenants[tenant]
+		if !ok {
+			return nil, nil
+		}
+
+		tenantDestination := destination
+		tenantDestination.DataSource = config
+		//tenant storage has its own queue and metrics labels
+		return createPostgres(ctx, name+"-"+tenant, tenantDestination, processor, fallbackDir)
+	}
+
+	return events.NewRoutingConsumer(routing.TenantField, factory, defaultConsumer), nil
+}
+
+//Return true if column comments are configured in destination datasource or in any routing tenant datasource
+func postgresColumnComments(destination DestinationConfig) bool {
+	if destination.DataSource != nil && destination.DataSource.ColumnCommentTemplate != "" {
+		return true
+	}
+	if destination.Routing != nil {
+		for _, config := range destination.Routing.Tenants {
+			if config != nil && config.ColumnCommentTemplate != "" {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 //Create Postgres event consumer
-func createPostgres(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor, logEventPath string) (*Postgres, error) {
+func createPostgres(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor, fallbackDir string) (*Postgres, error) {
 	config := destination.DataSource
 	if err := config.Validate(); err != nil {
 		return nil, err
@@ -166,6 +644,163 @@ func createPostgres(ctx context.Context, name string, destination DestinationCon
 		config.Schema = "public"
 		log.Printf("name: %s type: postgres schema wasn't provided. Will be used default one: %s", name, config.Schema)
 	}
+	if config.RetentionDays > 0 {
+		if config.RetentionColumn == "" {
+			config.RetentionColumn = timestamp.Key
+			log.Printf("name: %s type: postgres retention_column wasn't provided. Will be used default one: %s", name, config.RetentionColumn)
+		}
+		if config.RetentionBatchSize == 0 {
+			config.RetentionBatchSize = 10000
+			log.Printf("name: %s type: postgres retention_batch_size wasn't provided. Will be used default one: %d", name, config.RetentionBatchSize)
+		}
+		if config.RetentionIntervalSec == 0 {
+			config.RetentionIntervalSec = 3600
+			log.Printf("name: %s type: postgres retention_interval_sec wasn't provided. Will be used default one: %d", name, config.RetentionIntervalSec)
+		}
+	}
+	//unique index on partitioned table must contain partition column so dedup would work only inside one partition
+	if (config.DedupKey != "" || len(config.UpsertKeys) > 0) && processor.Partitioning().Enabled() {
+		return nil, errors.New("dedup_key and upsert_keys can't be used with data_layout partition_field in postgres destination")
+	}
+	enrichStreamingConfig(name, destination.Type, &config.StreamingConfig)
+
+	return NewPostgres(ctx, config, processor, fallbackDir, name)
+}
+
+//Create ClickHouse event consumer
+func createClickHouse(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor, fallbackDir string) (*ClickHouse, error) {
+	config := destination.ClickHouse
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	enrichStreamingConfig(name, destination.Type, &config.StreamingConfig)
+
+	return NewClickHouse(ctx, config, processor, fallbackDir, name)
+}
+
+//Create Snowflake event consumer
+func createSnowflake(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor, fallbackDir string) (*Snowflake, error) {
+	config := destination.Snowflake
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	//enrich with default parameters
+	if config.Schema == "" {
+		config.Schema = "PUBLIC"
+		log.Printf("name: %s type: snowflake schema wasn't provided. Will be used default one: %s", name, config.Schema)
+	}
+	enrichStreamingConfig(name, destination.Type, &config.StreamingConfig)
 
-	return NewPostgres(ctx, config, processor, logEventPath, name)
+	return NewSnowflake(ctx, config, processor, fallbackDir, name)
+}
+
+//Create MySQL event consumer
+func createMySQL(ctx context.Context, name string, destination DestinationConfig, processor *schema.Processor, fallbackDir string) (*MySQL, error) {
+	config := destination.MySQL
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	//enrich with default parameters
+	if config.Port <= 0 {
+		config.Port = 3306
+		log.Printf("name: %s type: mysql port wasn't provided. Will be used default one: %d", name, config.Port)
+	}
+	enrichStreamingConfig(name, destination.Type, &config.StreamingConfig)
+
+	return NewMySQL(ctx, config, processor, fallbackDir, name)
+}
+
+//Create Kafka event consumer. Events are produced as is so data_layout isn't used
+func createKafka(name string, destination DestinationConfig, fallbackDir string) (*Kafka, error) {
+	config := destination.Kafka
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if destination.DataLayout != nil {
+		log.Printf("Warn: data_layout isn't supported in kafka destination. It will be ignored in %s destination", name)
+	}
+	enrichStreamingConfig(name, destination.Type, &config.StreamingConfig)
+
+	return NewKafka(config, fallbackDir, name)
+}
+
+//Create HTTP forwarding event consumer. Events are sent as is so data_layout isn't used
+func createHTTP(ctx context.Context, name string, destination DestinationConfig, fallbackDir string) (*HTTPConsumer, error) {
+	config := destination.HTTP
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if destination.DataLayout != nil {
+		log.Printf("Warn: data_layout isn't supported in http destination. It will be ignored in %s destination", name)
+	}
+	//enrich with default parameters
+	if config.TimeoutMs <= 0 {
+		config.TimeoutMs = defaultHTTPTimeoutMs
+		log.Printf("name: %s type: http timeout_ms wasn't provided. Will be used default one: %d", name, config.TimeoutMs)
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultHTTPMaxRetries
+		log.Printf("name: %s type: http max_retries wasn't provided. Will be used default one: %d", name, config.MaxRetries)
+	}
+	enrichStreamingConfig(name, destination.Type, &config.StreamingConfig)
+
+	return NewHTTPConsumer(ctx, config, fallbackDir, name)
+}
+
+//Create aws s3 raw events archive consumer. Events are stored as is so data_layout isn't used
+func createS3(name string, destination DestinationConfig, fallbackDir string) (*S3, error) {
+	s3Config := destination.S3
+	if err := s3Config.Validate(); err != nil {
+		return nil, err
+	}
+	if destination.DataLayout != nil {
+		log.Printf("Warn: data_layout isn't supported in s3 destination. It will be ignored in %s destination", name)
+	}
+	sinkConfig := destination.S3Sink
+	if sinkConfig == nil {
+		sinkConfig = &adapters.S3SinkConfig{}
+	}
+	enrichStreamingConfig(name, destination.Type, &sinkConfig.StreamingConfig)
+
+	return NewS3(s3Config, sinkConfig, fallbackDir, name)
+}
+
+//Enrich streaming destination config with default parameters
+func enrichStreamingConfig(name, destinationType string, config *adapters.StreamingConfig) {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultStreamingBatchSize
+		log.Printf("name: %s type: %s batch_size wasn't provided. Will be used default one: %d", name, destinationType, config.BatchSize)
+	}
+	if config.FlushIntervalMs <= 0 {
+		config.FlushIntervalMs = defaultStreamingFlushIntervalMs
+		log.Printf("name: %s type: %s flush_interval_ms wasn't provided. Will be used default one: %d", name, destinationType, config.FlushIntervalMs)
+	}
+	if config.BackoffBaseMs <= 0 {
+		config.BackoffBaseMs = defaultStreamingBackoffBaseMs
+		log.Printf("name: %s type: %s backoff_base_ms wasn't provided. Will be used default one: %d", name, destinationType, config.BackoffBaseMs)
+	}
+	if config.BackoffMaxMs <= 0 {
+		config.BackoffMaxMs = defaultStreamingBackoffMaxMs
+		log.Printf("name: %s type: %s backoff_max_ms wasn't provided. Will be used default one: %d", name, destinationType, config.BackoffMaxMs)
+	}
+	if config.MaxProcessingAttempts <= 0 {
+		config.MaxProcessingAttempts = defaultStreamingMaxAttempts
+		log.Printf("name: %s type: %s max_processing_attempts wasn't provided. Will be used default one: %d", name, destinationType, config.MaxProcessingAttempts)
+	}
+	if config.ShutdownTimeoutMs <= 0 {
+		config.ShutdownTimeoutMs = defaultStreamingShutdownMs
+		log.Printf("name: %s type: %s shutdown_timeout_ms wasn't provided. Will be used default one: %d", name, destinationType, config.ShutdownTimeoutMs)
+	}
+	if config.QueueSegmentSize == 0 {
+		config.QueueSegmentSize = defaultEventsPerPersistedFile
+		log.Printf("name: %s type: %s queue_segment_size wasn't provided. Will be used default one: %d", name, destinationType, config.QueueSegmentSize)
+	}
+	if config.QueueLimited() && config.QueueFullPolicy == "" {
+		config.QueueFullPolicy = adapters.RejectQueuePolicy
+		log.Printf("name: %s type: %s queue_full_policy wasn't provided. Will be used default one: %s", name, destinationType, config.QueueFullPolicy)
+	}
+	if config.MaxEventBytes > 0 && config.OversizedEventPolicy == "" {
+		config.OversizedEventPolicy = adapters.DeadLetterOversizedPolicy
+		log.Printf("name: %s type: %s oversized_event_policy wasn't provided. Will be used default one: %s", name, destinationType, config.OversizedEventPolicy)
+	}
 }
@@ -0,0 +1,29 @@
+package storages
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+//Create dir if it doesn't exist and check that files can be written there
+//Is called before opening persistent queues: dque errors on misconfigured dirs aren't clear
+func ensureWritableDir(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("Error fallback dir isn't configured")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating fallback dir %s: %v", dir, err)
+	}
+
+	checkFile, err := ioutil.TempFile(dir, ".write_check")
+	if err != nil {
+		return fmt.Errorf("Error fallback dir %s isn't writable: %v", dir, err)
+	}
+	checkFile.Close()
+	if err := os.Remove(checkFile.Name()); err != nil {
+		return fmt.Errorf("Error removing write check file from fallback dir %s: %v", dir, err)
+	}
+
+	return nil
+}
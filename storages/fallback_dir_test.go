@@ -0,0 +1,47 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnsureWritableDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "fallback_dir_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	notDirFile := filepath.Join(tmpDir, "file")
+	require.NoError(t, ioutil.WriteFile(notDirFile, []byte("content"), 0644))
+
+	tests := []struct {
+		name      string
+		dir       string
+		expectErr bool
+	}{
+		{"existing dir", tmpDir, false},
+		{"missing nested dir is created", filepath.Join(tmpDir, "storage1", "queues"), false},
+		{"empty dir", "", true},
+		{"file instead of dir", notDirFile, true},
+		{"dir inside file", filepath.Join(notDirFile, "queues"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ensureWritableDir(tt.dir)
+			if tt.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			files, err := ioutil.ReadDir(tt.dir)
+			require.NoError(t, err)
+			for _, file := range files {
+				require.False(t, strings.HasPrefix(file.Name(), ".write_check"), "Write check file must be removed")
+			}
+		})
+	}
+}
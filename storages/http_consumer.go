@@ -0,0 +1,156 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"time"
+)
+
+//HTTPConsumer forwards events as is (without flattening) with POST requests to third-party HTTP API
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and send every event (or batch as json array) with retries on 5xx, 429 responses and network errors
+//Retry-After header is honored. Facts which weren't delivered after max retries are put to the dead-letter queue
+type HTTPConsumer struct {
+	*streamingWorker
+
+	adapter    *adapters.HTTP
+	batch      bool
+	maxRetries int
+	retryBase  time.Duration
+	retryMax   time.Duration
+}
+
+func NewHTTPConsumer(ctx context.Context, config *adapters.HTTPConfig, fallbackDir, storageName string) (*HTTPConsumer, error) {
+	hc := &HTTPConsumer{
+		adapter:    adapters.NewHTTP(ctx, config),
+		batch:      config.Batch,
+		maxRetries: config.MaxRetries,
+		retryBase:  time.Duration(config.BackoffBaseMs) * time.Millisecond,
+		retryMax:   time.Duration(config.BackoffMaxMs) * time.Millisecond,
+	}
+
+	//facts aren't processed with schema.Processor
+	var err error
+	hc.streamingWorker, err = newStreamingWorker("http", storageName, fallbackDir, config.StreamingConfig, nil, hc.insert)
+	if err != nil {
+		hc.adapter.Close()
+		return nil, err
+	}
+	hc.start()
+
+	return hc, nil
+}
+
+//insert send objects in one request (batch mode) or one by one
+//Return *adapters.PartialInsertError with indexes of not delivered objects: Rejected ones (not retryable response,
+//exhausted retries or marshaling error) are put to the dead-letter queue and Failed ones (not sent because storage
+//is being closed) are enqueued one more time (see streamingWorker.storePartial)
+func (hc *HTTPConsumer) insert(_ *schema.Table, objects []events.Fact) error {
+	partialErr := &adapters.PartialInsertError{Total: len(objects)}
+	if hc.batch {
+		payload, err := json.Marshal(objects)
+		if err == nil {
+			err = hc.sendWithRetries(payload)
+		} else {
+			err = fmt.Errorf("Error marshalling events facts: %v", err)
+		}
+		if err == nil {
+			return nil
+		}
+		for i := range objects {
+			addNotDelivered(partialErr, i, err)
+		}
+		return partialErr
+	}
+
+	for i, object := range objects {
+		payload, err := json.Marshal(object)
+		if err == nil {
+			err = hc.sendWithRetries(payload)
+		} else {
+			err = fmt.Errorf("Error marshalling events fact: %v", err)
+		}
+		if err != nil {
+			addNotDelivered(partialErr, i, err)
+		}
+	}
+	if len(partialErr.Failed) == 0 && len(partialErr.Rejected) == 0 {
+		return nil
+	}
+
+	return partialErr
+}
+
+//Send payload and retry with exponential backoff (or Retry-After delay capped with backoff max) not more than
+//maxRetries times. If storage is being closed retries don't exceed shutdown deadline
+//Return last error if payload hasn't been delivered or errStorageClosed if it can't be retried before shutdown deadline
+func (hc *HTTPConsumer) sendWithRetries(payload []byte) error {
+	retryBackoff := newBackoff(hc.retryBase, hc.retryMax)
+	for attempt := 0; ; attempt++ {
+		err := hc.adapter.Send(payload)
+		if err == nil {
+			return nil
+		}
+
+		delay := retryBackoff.fail()
+		if httpErr, ok := err.(*adapters.HTTPError); ok {
+			if !httpErr.Retryable() {
+				return err
+			}
+			if httpErr.RetryAfter > 0 {
+				delay = httpErr.RetryAfter
+				if delay > hc.retryMax {
+					delay = hc.retryMax
+				}
+			}
+		}
+		if attempt >= hc.maxRetries {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-hc.closed:
+			if time.Now().Add(delay).After(hc.shutdownDeadline) {
+				timer.Stop()
+				return errStorageClosed
+			}
+			<-timer.C
+		case <-timer.C:
+		}
+	}
+}
+
+//Add index of not delivered object to partialErr: failed if storage is being closed (it will be retried)
+//or rejected otherwise. Err and RejectErr are the first errors
+func addNotDelivered(partialErr *adapters.PartialInsertError, i int, err error) {
+	if err == errStorageClosed {
+		partialErr.Failed = append(partialErr.Failed, i)
+		if partialErr.Err == nil {
+			partialErr.Err = err
+		}
+		return
+	}
+
+	partialErr.Rejected = append(partialErr.Rejected, i)
+	if partialErr.RejectErr == nil {
+		partialErr.RejectErr = err
+	}
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.HTTP
+func (hc *HTTPConsumer) Close() (multiErr error) {
+	if err := hc.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if err := hc.adapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+
+	return
+}
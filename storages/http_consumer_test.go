@@ -0,0 +1,165 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/appconfig"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHTTPConsumer(t *testing.T, url string, config adapters.StreamingConfig) (*HTTPConsumer, func()) {
+	if appconfig.Instance == nil {
+		appconfig.Instance = &appconfig.AppConfig{ServerName: "test"}
+	}
+	dir, err := ioutil.TempDir("", "http_consumer_test")
+	require.NoError(t, err)
+
+	hc, err := NewHTTPConsumer(context.Background(), &adapters.HTTPConfig{URL: url, TimeoutMs: 1000, MaxRetries: 2, StreamingConfig: config},
+		dir, "http_test")
+	require.NoError(t, err)
+
+	return hc, func() { os.RemoveAll(dir) }
+}
+
+func TestHTTPConsumerDelivery(t *testing.T) {
+	var requests int64
+	//id 1 is delivered, id 2 is a bad request, id 3 is never accepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		object := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&object))
+		switch object["id"] {
+		case "1":
+			w.WriteHeader(http.StatusOK)
+		case "2":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	hc, remove := newTestHTTPConsumer(t, server.URL, adapters.StreamingConfig{BatchSize: 10, FlushIntervalMs: 10,
+		BackoffBaseMs: 1, BackoffMaxMs: 1, MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000})
+	defer remove()
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, hc.ConsumeCtx(context.Background(), events.Fact{"id": id}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, hc.Flush(ctx))
+
+	stats := hc.Stats()
+	require.Equal(t, uint64(1), stats.Inserted, "Only delivered events must be counted as inserted")
+	require.Equal(t, uint64(2), stats.DeadLettered)
+	require.Equal(t, 2, hc.DeadLettered())
+	//1 + 1 (not retryable) + 3 (max_retries 2)
+	require.Equal(t, int64(5), atomic.LoadInt64(&requests))
+	require.NoError(t, hc.Close())
+}
+
+func TestHTTPConsumerSendWithRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		responses        []int
+		expectedRequests int64
+		expectedStatus   int
+	}{
+		{"Success", []int{http.StatusOK}, 1, 0},
+		{"Server error is retried", []int{http.StatusServiceUnavailable, http.StatusOK}, 2, 0},
+		{"Too many requests is retried", []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK}, 3, 0},
+		{"Bad request isn't retried", []int{http.StatusBadRequest}, 1, http.StatusBadRequest},
+		{"Not retryable error after retry", []int{http.StatusBadGateway, http.StatusNotFound}, 2, http.StatusNotFound},
+		{"Max retries exceeded", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, 3, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt64(&requests, 1) - 1
+				if i >= int64(len(tt.responses)) {
+					i = int64(len(tt.responses)) - 1
+				}
+				w.WriteHeader(tt.responses[i])
+			}))
+			defer server.Close()
+
+			hc, remove := newTestHTTPConsumer(t, server.URL, adapters.StreamingConfig{BatchSize: 10, FlushIntervalMs: 10,
+				BackoffBaseMs: 1, BackoffMaxMs: 5, MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000})
+			defer remove()
+			defer hc.Close()
+
+			err := hc.sendWithRetries([]byte(`{}`))
+			require.Equal(t, tt.expectedRequests, atomic.LoadInt64(&requests))
+			if tt.expectedStatus == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			httpErr, ok := err.(*adapters.HTTPError)
+			require.True(t, ok, "Error must be HTTPError: %v", err)
+			require.Equal(t, tt.expectedStatus, httpErr.StatusCode)
+		})
+	}
+}
+
+func TestHTTPConsumerRetryAfterIsCapped(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hc, remove := newTestHTTPConsumer(t, server.URL, adapters.StreamingConfig{BatchSize: 10, FlushIntervalMs: 10,
+		BackoffBaseMs: 10, BackoffMaxMs: 50, MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000})
+	defer remove()
+
+	start := time.Now()
+	require.NoError(t, hc.sendWithRetries([]byte(`{}`)))
+	require.True(t, time.Since(start) < time.Second, "Retry-After delay must be capped with backoff_max_ms")
+	require.Equal(t, int64(2), atomic.LoadInt64(&requests))
+	require.NoError(t, hc.Close())
+}
+
+func TestHTTPConsumerCloseDuringRetries(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	hc, remove := newTestHTTPConsumer(t, server.URL, adapters.StreamingConfig{BatchSize: 10, FlushIntervalMs: 10,
+		BackoffBaseMs: 300, BackoffMaxMs: 300, MaxProcessingAttempts: 100, ShutdownTimeoutMs: 100})
+	defer remove()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, hc.ConsumeCtx(context.Background(), events.Fact{"id": i}))
+	}
+	//the first requests are sent
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	require.Error(t, hc.Close(), "Not delivered events must be reported")
+	require.True(t, time.Since(start) < time.Second, "Retries must be bounded by shutdown timeout")
+	require.True(t, atomic.LoadInt64(&requests) < 20, "Not delivered events mustn't be sent in a loop during flush: %d requests", requests)
+
+	stats := hc.Stats()
+	require.Equal(t, uint64(0), stats.Inserted)
+	require.Equal(t, uint64(0), stats.DeadLettered, "Not delivered events must be kept in the queue")
+}
@@ -0,0 +1,53 @@
+package storages
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+)
+
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and produce events as is (without flattening) to Kafka topic in batches
+//Failed batches are produced one more time (at-least-once delivery)
+type Kafka struct {
+	*streamingWorker
+
+	adapter *adapters.Kafka
+}
+
+func NewKafka(config *adapters.KafkaConfig, fallbackDir, storageName string) (*Kafka, error) {
+	adapter, err := adapters.NewKafka(config)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Kafka{adapter: adapter}
+
+	//facts aren't processed with schema.Processor
+	k.streamingWorker, err = newStreamingWorker("kafka", storageName, fallbackDir, config.StreamingConfig, nil, k.insert)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+	k.start()
+
+	return k, nil
+}
+
+//insert produce facts to Kafka. Table schema is empty because facts aren't processed
+func (k *Kafka) insert(_ *schema.Table, objects []events.Fact) error {
+	return k.adapter.Produce(objects)
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.Kafka
+func (k *Kafka) Close() (multiErr error) {
+	if err := k.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if err := k.adapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+
+	return
+}
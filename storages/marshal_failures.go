@@ -0,0 +1,59 @@
+package storages
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const marshalFailuresFileSuffix = ".marshal_failures.log"
+
+//marshalFailureRecord is one line of the marshal failures file
+type marshalFailureRecord struct {
+	FailedAt time.Time `json:"failed_at"`
+	Error    string    `json:"error"`
+	//Go syntax representation (with value types) of the fact which can't be marshaled to json
+	Fact string `json:"fact"`
+}
+
+//marshalFailuresFile keeps facts which can't be marshaled to json (it is a bug) for inspection: one json record per line
+//File is opened on every write because failures are rare. It is kept in the fallback dir next to the queue dirs
+//(not inside them: queue dirs are removed on compaction)
+type marshalFailuresFile struct {
+	mutex sync.Mutex
+	path  string
+}
+
+func newMarshalFailuresFile(fallbackDir, queueName string) *marshalFailuresFile {
+	return &marshalFailuresFile{path: filepath.Join(fallbackDir, queueName+marshalFailuresFileSuffix)}
+}
+
+//Write append fact with marshaling error to the file
+func (mff *marshalFailuresFile) Write(fact events.Fact, reason error) error {
+	record := marshalFailureRecord{FailedAt: time.Now().UTC(), Fact: fmt.Sprintf("%#v", fact)}
+	if reason != nil {
+		record.Error = reason.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Error marshalling marshal failure record: %v", err)
+	}
+
+	mff.mutex.Lock()
+	defer mff.mutex.Unlock()
+
+	file, err := os.OpenFile(mff.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening marshal failures file %s: %v", mff.path, err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("Error writing to marshal failures file %s: %v", mff.path, err)
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,44 @@
+package storages
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMarshalFailuresFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marshal_failures_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mff := newMarshalFailuresFile(dir, "srv-postgres-pg1")
+	fact := events.Fact{"event_type": "purchase", "amount": math.Inf(1)}
+	_, marshalErr := json.Marshal(fact)
+	require.Error(t, marshalErr)
+	require.NoError(t, mff.Write(fact, marshalErr))
+	require.NoError(t, mff.Write(events.Fact{"id": int64(2)}, nil))
+
+	file, err := os.Open(filepath.Join(dir, "srv-postgres-pg1.marshal_failures.log"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []marshalFailureRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record marshalFailureRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Equal(t, 2, len(records))
+	require.Equal(t, marshalErr.Error(), records[0].Error)
+	require.True(t, strings.Contains(records[0].Fact, `"amount":+Inf`), "Offending value must be kept: %s", records[0].Fact)
+	require.False(t, records[0].FailedAt.IsZero())
+	require.Equal(t, `events.Fact{"id":2}`, records[1].Fact)
+}
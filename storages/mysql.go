@@ -0,0 +1,73 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+)
+
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and store events to MySQL in streaming mode with multi-row inserts
+//Keeping tables schema state inmemory and update it according to incoming new data
+//note: Assume that after any outer changes in db we need to recreate this structure
+//for keeping actual db tables schema state
+type MySQL struct {
+	*streamingWorker
+
+	adapter *adapters.MySQL
+	tables  *tablesCache
+}
+
+func NewMySQL(ctx context.Context, config *adapters.MySQLConfig, processor *schema.Processor,
+	fallbackDir, storageName string) (*MySQL, error) {
+	adapter, err := adapters.NewMySQL(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	//create db if doesn't exist
+	err = adapter.CreateDB(config.Db)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+
+	m := &MySQL{
+		adapter: adapter,
+		tables:  newTablesCache(),
+	}
+
+	m.streamingWorker, err = newStreamingWorker("mysql", storageName, fallbackDir, config.StreamingConfig, processor, m.insert)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+	m.start()
+
+	return m, nil
+}
+
+//insert facts in MySQL
+func (m *MySQL) insert(dataSchema *schema.Table, objects []events.Fact) error {
+	dbTableSchema, err := m.tables.ensureTable("mysql", m.adapter, dataSchema, m.adapter.EnsureDedupKey, m.notifySchemaChange)
+	if err != nil {
+		return err
+	}
+
+	return m.adapter.BulkInsert(dbTableSchema, objects)
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.MySQL
+func (m *MySQL) Close() (multiErr error) {
+	if err := m.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if err := m.adapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing mysql datasource: %v", err))
+	}
+
+	return
+}
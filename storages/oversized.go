@@ -0,0 +1,83 @@
+package storages
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const (
+	//is put instead of truncated values
+	TruncatedValueMarker = "__truncated__"
+	//is added to truncated events
+	TruncatedKey = "_truncated"
+)
+
+var (
+	truncatedValueMarkerSize = len(`"` + TruncatedValueMarker + `"`)
+
+	errNotTruncatable = errors.New("Event can't be truncated to max event size")
+)
+
+//Return json bytes of the object copy where the biggest values are replaced with TruncatedValueMarker
+//until it fits maxBytes. Nested objects are truncated recursively: the biggest value of the biggest object
+//is replaced first so small fields (e.g. event_id) are kept. Truncated object has TruncatedKey = true
+//Return errNotTruncatable if object doesn't fit maxBytes even with all values replaced
+func truncateObject(object map[string]interface{}, maxBytes int) ([]byte, error) {
+	truncated := copyObjects(object)
+	truncated[TruncatedKey] = true
+	for {
+		b, err := json.Marshal(truncated)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) <= maxBytes {
+			return b, nil
+		}
+		if !replaceBiggestValue(truncated) {
+			return nil, errNotTruncatable
+		}
+	}
+}
+
+//Replace the biggest value (bigger than marker) of the object or of its biggest nested object with marker
+//Return false if there is nothing to replace
+func replaceBiggestValue(object map[string]interface{}) bool {
+	biggestKey := ""
+	biggestSize := truncatedValueMarkerSize
+	for key, value := range object {
+		if key == TruncatedKey {
+			continue
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		if len(b) > biggestSize {
+			biggestKey = key
+			biggestSize = len(b)
+		}
+	}
+	if biggestKey == "" {
+		return false
+	}
+
+	if nested, ok := object[biggestKey].(map[string]interface{}); ok && replaceBiggestValue(nested) {
+		return true
+	}
+	object[biggestKey] = TruncatedValueMarker
+
+	return true
+}
+
+//Return deep copy of nested objects (other values are copied by reference because they are only replaced)
+func copyObjects(object map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = copyObjects(nested)
+		}
+		copied[key] = value
+	}
+
+	return copied
+}
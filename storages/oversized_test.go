@@ -0,0 +1,59 @@
+package storages
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestTruncateObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    map[string]interface{}
+		maxBytes int
+		expected map[string]interface{}
+	}{
+		{
+			"Top level value",
+			map[string]interface{}{"event_id": "1", "payload": strings.Repeat("a", 100)},
+			60,
+			map[string]interface{}{"event_id": "1", "payload": TruncatedValueMarker, TruncatedKey: true},
+		},
+		{
+			"Nested value",
+			map[string]interface{}{"event_id": "1", "eventn_ctx": map[string]interface{}{"user_id": "abc", "items": []interface{}{strings.Repeat("a", 100)}}},
+			100,
+			map[string]interface{}{"event_id": "1", "eventn_ctx": map[string]interface{}{"user_id": "abc", "items": TruncatedValueMarker}, TruncatedKey: true},
+		},
+		{
+			"The biggest values first",
+			map[string]interface{}{"a": strings.Repeat("a", 50), "b": strings.Repeat("b", 30), "c": strings.Repeat("c", 20)},
+			110,
+			map[string]interface{}{"a": TruncatedValueMarker, "b": strings.Repeat("b", 30), "c": strings.Repeat("c", 20), TruncatedKey: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := truncateObject(tt.input, tt.maxBytes)
+			require.NoError(t, err)
+			require.True(t, len(b) <= tt.maxBytes)
+
+			actual := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(b, &actual))
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTruncateObjectDoesntChangeInput(t *testing.T) {
+	input := map[string]interface{}{"nested": map[string]interface{}{"payload": strings.Repeat("a", 100)}}
+	_, err := truncateObject(input, 50)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"nested": map[string]interface{}{"payload": strings.Repeat("a", 100)}}, input)
+}
+
+func TestTruncateObjectNotTruncatable(t *testing.T) {
+	_, err := truncateObject(map[string]interface{}{"event_id": "1"}, 10)
+	require.Equal(t, errNotTruncatable, err)
+}
@@ -0,0 +1,64 @@
+package storages
+
+import (
+	"context"
+	"sync"
+)
+
+//pendingFacts counts facts which have been put to the queue but haven't been handled yet
+//(inserted, dead-lettered or skipped). Re-enqueued fact is added before it is marked as handled so count doesn't
+//fall to zero while fact is being retried. Waiters are notified when count becomes zero (see wait)
+type pendingFacts struct {
+	mutex sync.Mutex
+	count int
+	//closed and replaced when count becomes zero
+	drained chan struct{}
+}
+
+func newPendingFacts(count int) *pendingFacts {
+	return &pendingFacts{count: count, drained: make(chan struct{})}
+}
+
+//add enqueued facts
+func (pf *pendingFacts) add(n int) {
+	pf.mutex.Lock()
+	pf.count += n
+	pf.mutex.Unlock()
+}
+
+//done mark n facts as handled and notify waiters if there are no pending facts
+func (pf *pendingFacts) done(n int) {
+	if n == 0 {
+		return
+	}
+
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+
+	pf.count -= n
+	if pf.count == 0 {
+		close(pf.drained)
+		pf.drained = make(chan struct{})
+	}
+}
+
+//wait block until there are no pending facts
+//Return ctx.Err() if ctx is done or stopErr if stop channel is closed before
+func (pf *pendingFacts) wait(ctx context.Context, stop <-chan struct{}, stopErr error) error {
+	pf.mutex.Lock()
+	if pf.count == 0 {
+		pf.mutex.Unlock()
+		return nil
+	}
+	drained := pf.drained
+	pf.mutex.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-stop:
+		return stopErr
+	}
+}
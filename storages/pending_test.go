@@ -0,0 +1,50 @@
+package storages
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPendingFactsWait(t *testing.T) {
+	pending := newPendingFacts(2)
+	stop := make(chan struct{})
+	errStopped := errors.New("stopped")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, pending.wait(ctx, stop, errStopped))
+
+	done := make(chan error, 1)
+	go func() { done <- pending.wait(context.Background(), stop, errStopped) }()
+
+	//re-enqueued fact is added before it is marked as handled
+	pending.done(1)
+	pending.add(1)
+	pending.done(1)
+	select {
+	case <-done:
+		require.Fail(t, "Waiter must be blocked while there are pending facts")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	pending.done(1)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "Waiter must be notified when pending facts are handled")
+	}
+	require.NoError(t, pending.wait(context.Background(), stop, errStopped), "Nothing to wait")
+
+	//fact is handled by drain goroutine before enqueue returns
+	pending.add(1)
+	pending.done(1)
+	require.NoError(t, pending.wait(context.Background(), stop, errStopped))
+
+	pending.add(1)
+	close(stop)
+	require.Equal(t, errStopped, pending.wait(context.Background(), stop, errStopped))
+}
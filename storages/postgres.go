@@ -2,186 +2,576 @@ package storages
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/go-multierror"
-	"github.com/joncrlsn/dque"
 	"github.com/ksensehq/eventnative/adapters"
-	"github.com/ksensehq/eventnative/appconfig"
-	"github.com/ksensehq/eventnative/appstatus"
 	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/logging"
 	"github.com/ksensehq/eventnative/schema"
-	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-const eventsPerPersistedFile = 2000
+//declarative partitioning with automatic routing to partitions is used since Postgres 12
+const minPartitioningServerVersionNum = 120000
+
+//longer identifiers are truncated by Postgres
+const postgresMaxIdentifierLength = 63
+
+//postgresAdapter is a part of adapters.Postgres used by Postgres storage
+type postgresAdapter interface {
+	CreateDbSchema(dbSchemaName string) error
+	CreateTable(tableSchema *schema.Table) error
+	CreatePartitionedTable(tableSchema *schema.Table, partitionColumn string) error
+	CreatePartition(dbSchema, tableName, partitionName string, from, to time.Time) error
+	IsPartitioned(dbSchema, tableName string) (bool, error)
+	PatchTableSchema(patchSchema *schema.Table) error
+	GetDbSchemaTable(dbSchema, tableName string) (*schema.Table, error)
+	EnsureUniqueKey(table *schema.Table) error
+	Indexes(tableName string) [][]string
+	CreateIndex(dbSchema, tableName string, columns []string, concurrently bool) error
+	BulkInsert(table *schema.Table, objects []events.Fact) error
+	DeleteOlderThan(ctx context.Context, dbSchema, tableName, timestampColumn string, before time.Time, batchSize int) (int64, error)
+	PartitionsOlderThan(dbSchema, tableName string, before time.Time) ([]string, error)
+	DropPartition(dbSchema, partitionName string) error
+	Health() error
+	Close() error
+}
 
 //Consuming event facts, put them to https://github.com/joncrlsn/dque
 //Dequeuing and store events to Postgres in streaming mode
 //Keeping tables schema state inmemory and update it according to incoming new data
-//note: Assume that after any outer changes in db we need to recreate this structure
-//for keeping actual db tables schema state
+//note: outer changes in db (e.g. ALTER TABLE by DBA) are detected with RefreshSchema (periodically if
+//schema_refresh_interval_sec is configured) and after insert failures: cached schema is re-read from db
+//and the table is patched one more time on the next insert
+//If partitioning is configured tables are created partitioned by range of schema.PartitionColumn
+//and partitions are created on demand
+//If retention_days is configured rows (or whole partitions) outside of retention window are removed periodically
+//Table names from schema.Processor are transformed with configured prefix and suffix (see schema.TableNamesConfig)
+//If schema_column is configured rows are stored in db schemas selected by the column value (see dbSchemaRouter):
+//tables are cached by qualified names (schema.table)
+//Configured secondary indexes are created in background (see ensureIndexes)
 type Postgres struct {
-	adapter         *adapters.Postgres
-	schemaProcessor *schema.Processor
-	tables          map[string]*schema.Table
-	eventQueue      *dque.DQue
-}
-
-type QueuedFact struct {
-	FactBytes []byte
-}
+	*streamingWorker
 
-// FactBuilder creates and returns a new events.Fact.
-// This is used when we load a segment of the queue from disk.
-func QueuedFactBuilder() interface{} {
-	return &QueuedFact{}
+	adapter    postgresAdapter
+	tableNames schema.TableNamesConfig
+	tables     *tablesCache
+	partition  schema.PartitionConfig
+	dbSchemas  *dbSchemaRouter
+	//serializes tables creating, patching, refreshing and partitions creating. Guards partitioned, partitions, createdDbSchemas and indexes
+	ddlMutex sync.Mutex
+	//table qualified name -> is table partitioned
+	partitioned map[string]bool
+	//created (or existing) partition qualified names
+	partitions map[string]bool
+	//created (or existing) db schemas
+	createdDbSchemas map[string]bool
+	//created or being created secondary indexes keys (see indexKey)
+	indexes   map[string]bool
+	retention retentionConfig
+	//nil if unused_columns_window isn't configured
+	columnUsage *schema.ColumnUsage
+	//tables aren't patched: objects which need patching are rejected (see matchSchema)
+	strictSchema bool
 }
 
 func NewPostgres(ctx context.Context, config *adapters.DataSourceConfig, processor *schema.Processor,
 	fallbackDir, storageName string) (*Postgres, error) {
+	tableNames := schema.TableNamesConfig{Prefix: config.TablePrefix, Suffix: config.TableSuffix, MaxLength: postgresMaxIdentifierLength}
+	if err := tableNames.Validate(); err != nil {
+		return nil, err
+	}
+
 	adapter, err := adapters.NewPostgres(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
+	if config.AuditStatements {
+		auditLogger := logging.DefaultLogger().With("destination_type", "postgres", "storage", storageName)
+		adapter.SetHooks(adapters.SQLHooks{
+			OnDDL: func(statement string) { auditLogger.Info("Executing DDL statement", "statement", statement) },
+			OnDML: func(statement string) { auditLogger.Debug("Executing DML statement", "statement", statement) },
+		})
+	}
+
 	//create db schema if doesn't exist
 	err = adapter.CreateDbSchema(config.Schema)
 	if err != nil {
 		return nil, err
 	}
 
-	queueName := fmt.Sprintf("%s-%s", appconfig.Instance.ServerName, storageName)
-	queue, err := dque.NewOrOpen(queueName, fallbackDir, eventsPerPersistedFile, QueuedFactBuilder)
-	if err != nil {
-		return nil, fmt.Errorf("Error opening/creating event queue for postgres: %v", err)
+	p := &Postgres{
+		adapter:          adapter,
+		tableNames:       tableNames,
+		tables:           newTablesCache(),
+		partition:        processor.Partitioning(),
+		dbSchemas:        newDbSchemaRouter(config.SchemaColumn, config.Schema, config.AllowedSchemas, adapters.IsValidDbSchemaName),
+		partitioned:      map[string]bool{},
+		partitions:       map[string]bool{},
+		createdDbSchemas: map[string]bool{config.Schema: true},
+		indexes:          map[string]bool{},
+		retention:        retentionConfig{days: config.RetentionDays, column: config.RetentionColumn, batchSize: config.RetentionBatchSize},
+		strictSchema:     config.StrictSchema,
 	}
 
-	p := &Postgres{
-		adapter:         adapter,
-		schemaProcessor: processor,
-		tables:          map[string]*schema.Table{},
-		eventQueue:      queue,
+	if config.UnusedColumnsWindow > 0 {
+		p.columnUsage = schema.NewColumnUsage(config.UnusedColumnsWindow)
+	}
+
+	if p.partition.Enabled() {
+		versionNum, err := adapter.ServerVersionNum()
+		if err != nil {
+			adapter.Close()
+			return nil, err
+		}
+		if versionNum < minPartitioningServerVersionNum {
+			adapter.Close()
+			return nil, fmt.Errorf("Partitioning requires Postgres 12+. Server version num: %d", versionNum)
+		}
+	}
+
+	p.streamingWorker, err = newStreamingWorker("postgres", storageName, fallbackDir, config.StreamingConfig, processor, p.insert)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+	//insert is safe for concurrent calls. One drain worker (newStreamingWorker default) if not set
+	if config.DrainWorkers > 0 {
+		p.drainWorkers = config.DrainWorkers
 	}
 	p.start()
 
+	if config.SchemaRefreshIntervalSec > 0 {
+		go p.refreshSchemas(time.Duration(config.SchemaRefreshIntervalSec) * time.Second)
+	}
+	if config.RetentionDays > 0 {
+		go p.retentionLoop(time.Duration(config.RetentionIntervalSec) * time.Second)
+	}
+
 	return p, nil
 }
 
-//Consume events.Fact and enqueue it
-func (p *Postgres) Consume(fact events.Fact) {
-	p.enqueue(fact)
+//insert facts in Postgres tables of their db schemas
+//Objects of one batch may be stored in different db schemas: if some of db schemas (or rows with savepoint_rows)
+//fail *adapters.PartialInsertError with indexes of failed objects is returned so inserted objects aren't retried.
+//The whole batch is retried only if nothing has been inserted
+//Safe for concurrent calls: see ensureTable()
+func (p *Postgres) insert(dataSchema *schema.Table, objects []events.Fact) error {
+	//the whole table lifecycle (getting schema, creating, patching, inserting) uses transformed name
+	tableName := p.tableNames.TableName(dataSchema.Name)
+
+	dbSchemas, groups, indexes := p.dbSchemas.group(objects)
+	if len(dbSchemas) == 1 {
+		return p.insertTable(&schema.Table{Name: tableName, Schema: dbSchemas[0], Columns: dataSchema.Columns}, objects)
+	}
+
+	var multiErr, rejectErr error
+	var failed, rejected []int
+	for _, dbSchema := range dbSchemas {
+		table := &schema.Table{Name: tableName, Schema: dbSchema, Columns: dataSchema.Columns}
+		err := p.insertTable(table, groups[dbSchema])
+		if err == nil {
+			continue
+		}
+		if partialErr, ok := err.(*adapters.PartialInsertError); ok {
+			if len(partialErr.Failed) > 0 {
+				multiErr = multierror.Append(multiErr, partialErr.Err)
+			}
+			if len(partialErr.Rejected) > 0 {
+				rejectErr = multierror.Append(rejectErr, partialErr.RejectErr)
+			}
+			for _, i := range partialErr.Failed {
+				failed = append(failed, indexes[dbSchema][i])
+			}
+			for _, i := range partialErr.Rejected {
+				rejected = append(rejected, indexes[dbSchema][i])
+			}
+		} else {
+			multiErr = multierror.Append(multiErr, err)
+			failed = append(failed, indexes[dbSchema]...)
+		}
+	}
+	if len(rejected) == 0 && (multiErr == nil || len(failed) == len(objects)) {
+		return multiErr
+	}
+
+	sort.Ints(failed)
+	sort.Ints(rejected)
+	return &adapters.PartialInsertError{Failed: failed, Total: len(objects), Err: multiErr, Rejected: rejected, RejectErr: rejectErr}
 }
 
-//Marshaling events.Fact to json bytes and put it to persistent queue
-func (p *Postgres) enqueue(fact events.Fact) {
-	factBytes, err := json.Marshal(fact)
+//insert objects in one table of db schema
+//In strict schema mode objects which need table patching are rejected: *adapters.PartialInsertError with their indexes
+//and SchemaMismatchError is returned
+func (p *Postgres) insertTable(dataSchema *schema.Table, objects []events.Fact) error {
+	dbTableSchema, err := p.ensureTable(dataSchema)
 	if err != nil {
-		p.logSkippedEvent(fact, fmt.Errorf("Error marshalling events fact: %v", err))
-		return
+		return err
 	}
-	if err := p.eventQueue.Enqueue(QueuedFact{FactBytes: factBytes}); err != nil {
-		p.logSkippedEvent(fact, fmt.Errorf("Error putting event fact bytes to the postgres queue: %v", err))
-		return
+
+	if p.strictSchema {
+		kept, keptIndexes, rejected, mismatchErr := matchSchema(dbTableSchema, dataSchema, objects)
+		if mismatchErr != nil {
+			return p.insertKept(dbTableSchema, dataSchema, kept, keptIndexes, rejected, mismatchErr, len(objects))
+		}
 	}
+
+	return p.insertMatched(dbTableSchema, dataSchema, objects)
 }
 
-//Run goroutine to:
-//1. read from queue
-//2. insert in postgres
-//3. if error => enqueue one more time
-func (p *Postgres) start() {
-	go func() {
-		for {
-			if appstatus.Instance.Idle {
-				break
-			}
-			iface, err := p.eventQueue.DequeueBlock()
-			if err != nil {
-				log.Println("Error reading event fact from postgres queue", err)
-				continue
-			}
+//insert objects which fit the table and return *adapters.PartialInsertError with rejected indexes and failed ones
+//(mapped to indexes of all objects of the insert)
+func (p *Postgres) insertKept(dbTableSchema, dataSchema *schema.Table, kept []events.Fact, keptIndexes, rejected []int,
+	mismatchErr *SchemaMismatchError, total int) error {
+	partialErr := &adapters.PartialInsertError{Total: total, Rejected: rejected, RejectErr: mismatchErr}
+	if len(kept) == 0 {
+		return partialErr
+	}
 
-			wrappedFact, ok := iface.(QueuedFact)
-			if !ok || len(wrappedFact.FactBytes) == 0 {
-				log.Println("Warn: Dequeued object is not a QueuedFact instance or wrapped events.Fact bytes is empty")
-				continue
-			}
+	err := p.insertMatched(dbTableSchema, dataSchema, kept)
+	if keptErr, ok := err.(*adapters.PartialInsertError); ok {
+		partialErr.Err = keptErr.Err
+		for _, i := range keptErr.Failed {
+			partialErr.Failed = append(partialErr.Failed, keptIndexes[i])
+		}
+	} else if err != nil {
+		partialErr.Err = err
+		partialErr.Failed = keptIndexes
+	}
 
-			fact := events.Fact{}
-			err = json.Unmarshal(wrappedFact.FactBytes, &fact)
-			if err != nil {
-				log.Println("Error unmarshalling events.Fact from bytes", err)
-				continue
-			}
+	return partialErr
+}
 
-			dataSchema, flattenObject, err := p.schemaProcessor.ProcessFact(fact)
-			if err != nil {
-				log.Printf("Unable to process object %v: %v", fact, err)
-				p.enqueue(fact)
-				continue
-			}
+//insert objects in the table which contains all their columns
+func (p *Postgres) insertMatched(dbTableSchema, dataSchema *schema.Table, objects []events.Fact) error {
+	if p.partition.Enabled() {
+		if err := p.ensurePartitions(dbTableSchema, objects); err != nil {
+			return err
+		}
+	}
 
-			//don't process empty object
-			if !dataSchema.Exists() {
-				continue
-			}
+	if err := p.adapter.BulkInsert(dbTableSchema, objects); err != nil {
+		if adapters.IsTableNotFoundError(err) {
+			//the table has been dropped outside after cache check: it will be created on retry
+			p.ddlMutex.Lock()
+			p.logger.Warn("Table has been dropped outside. It will be created on the next insert", "table", dbTableSchema.QualifiedName())
+			p.forgetTable(dbTableSchema)
+			p.ddlMutex.Unlock()
+			return err
+		}
+		//the table may have been changed outside: schema will be re-read and patched on retry
+		p.tables.Delete(dbTableSchema.QualifiedName())
+		return err
+	}
 
-			if err := p.insert(dataSchema, flattenObject); err != nil {
-				log.Printf("Error inserting to postgres table [%s]: %v", dataSchema.Name, err)
-				p.enqueue(fact)
-				continue
-			}
+	if p.columnUsage != nil {
+		if unused := p.columnUsage.Observe(dbTableSchema.Diff(dataSchema), len(objects)); len(unused) > 0 {
+			p.logger.Info("Columns haven't been received in the configured count of the latest events. They can be dropped manually",
+				"table", dbTableSchema.QualifiedName(), "unused_columns", unused)
 		}
-	}()
+	}
+
+	return nil
+}
+
+//UnusedColumns return destination table qualified name (schema.table) -> sorted names of columns which haven't been received
+//in the last unused_columns_window events. Columns are never dropped automatically. Return nil if tracking isn't configured
+func (p *Postgres) UnusedColumns() map[string][]string {
+	if p.columnUsage == nil {
+		return nil
+	}
+
+	return p.columnUsage.Unused()
 }
 
-//insert fact in Postgres
-func (p *Postgres) insert(dataSchema *schema.Table, fact events.Fact) (err error) {
-	dbTableSchema, ok := p.tables[dataSchema.Name]
+//Return cached table schema which contains all dataSchema columns. Get or create table and patch it if needed
+//Cached tables which don't need patching are returned without waiting for DDL of other tables.
+//Tables are created and patched under ddlMutex so concurrent inserts to the same table don't duplicate DDL
+//In strict schema mode existing tables aren't patched: returned table may not contain all dataSchema columns
+func (p *Postgres) ensureTable(dataSchema *schema.Table) (*schema.Table, error) {
+	if cached, ok := p.tables.Get(dataSchema.QualifiedName()); ok && (p.strictSchema || !cached.Diff(dataSchema).NeedsPatch()) {
+		return cached, nil
+	}
+
+	p.ddlMutex.Lock()
+	defer p.ddlMutex.Unlock()
+
+	//the table may have been created or patched by another insert while waiting
+	dbTableSchema, ok := p.tables.Get(dataSchema.QualifiedName())
 	if !ok {
+		if err := p.ensureDbSchema(dataSchema.Schema); err != nil {
+			return nil, err
+		}
+
 		//Get or Create Table
-		dbTableSchema, err = p.adapter.GetTableSchema(dataSchema.Name)
+		var err error
+		dbTableSchema, err = p.adapter.GetDbSchemaTable(dataSchema.Schema, dataSchema.Name)
 		if err != nil {
-			return fmt.Errorf("Error getting table %s schema from postgres: %v", dataSchema.Name, err)
+			return nil, fmt.Errorf("Error getting table %s schema from postgres: %v", dataSchema.QualifiedName(), err)
 		}
 		if !dbTableSchema.Exists() {
-			if err := p.adapter.CreateTable(dataSchema); err != nil {
-				return fmt.Errorf("Error creating table %s in postgres: %v", dataSchema.Name, err)
+			//processor's columns aren't cached: cached schemas are never modified
+			newTable := patchedTable(&schema.Table{Name: dataSchema.Name, Schema: dataSchema.Schema}, dataSchema)
+			if err := p.createTable(newTable); err != nil {
+				return nil, fmt.Errorf("Error creating table %s in postgres: %v", dataSchema.QualifiedName(), err)
+			}
+			dbTableSchema = newTable
+		} else {
+			if err := p.adapter.EnsureUniqueKey(dbTableSchema); err != nil {
+				return nil, err
+			}
+			if p.partition.Enabled() {
+				partitioned, err := p.adapter.IsPartitioned(dbTableSchema.Schema, dbTableSchema.Name)
+				if err != nil {
+					return nil, err
+				}
+				if !partitioned {
+					p.logger.Warn("Table isn't partitioned. Partitions won't be created, only partition column will be filled",
+						"table", dbTableSchema.QualifiedName(), "column", schema.PartitionColumn)
+				}
+				p.partitioned[dbTableSchema.QualifiedName()] = partitioned
 			}
-			dbTableSchema = dataSchema
 		}
 		//Save
-		p.tables[dbTableSchema.Name] = dbTableSchema
+		p.tables.Set(dbTableSchema)
+		p.ensureIndexes(dbTableSchema)
+	}
+
+	if p.strictSchema {
+		return dbTableSchema, nil
 	}
 
 	schemaDiff := dbTableSchema.Diff(dataSchema)
-	//Patch
-	if schemaDiff.Exists() {
+	//Patch (add new columns and widen types of existing ones)
+	if schemaDiff.NeedsPatch() {
 		if err := p.adapter.PatchTableSchema(schemaDiff); err != nil {
-			return fmt.Errorf("Error patching table %s in postgres: %v", schemaDiff.Name, err)
+			return nil, fmt.Errorf("Error patching table %s in postgres: %v", schemaDiff.QualifiedName(), err)
 		}
+		p.notifySchemaChange(schemaDiff)
 		//Save
-		for k, v := range schemaDiff.Columns {
-			dbTableSchema.Columns[k] = v
+		dbTableSchema = patchedTable(dbTableSchema, schemaDiff)
+		p.tables.Set(dbTableSchema)
+		//indexed columns may have been added
+		p.ensureIndexes(dbTableSchema)
+	}
+
+	return dbTableSchema, nil
+}
+
+//RefreshSchema re-read table schema from Postgres and reconcile cached one
+//On drift (table has been dropped or altered outside) cached schema is replaced so the table is created or
+//patched on the next insert. tableName is a destination table qualified name (schema.table with configured prefix and suffix)
+func (p *Postgres) RefreshSchema(tableName string) error {
+	p.ddlMutex.Lock()
+	defer p.ddlMutex.Unlock()
+
+	cached, ok := p.tables.Get(tableName)
+	if !ok {
+		return nil
+	}
+
+	dbTableSchema, err := p.adapter.GetDbSchemaTable(cached.Schema, cached.Name)
+	if err != nil {
+		return fmt.Errorf("Error getting table %s schema from postgres: %v", tableName, err)
+	}
+	if !dbTableSchema.Exists() {
+		p.logger.Warn("Table has been dropped outside. It will be created on the next insert", "table", tableName)
+		p.forgetTable(cached)
+		return nil
+	}
+
+	var missing, changed, added []string
+	for name, column := range cached.Columns {
+		dbColumn, ok := dbTableSchema.Columns[name]
+		if !ok {
+			missing = append(missing, name)
+		} else if dbColumn.Type != column.Type {
+			changed = append(changed, name)
+		}
+	}
+	for name := range dbTableSchema.Columns {
+		if _, ok := cached.Columns[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	if len(missing) > 0 || len(changed) > 0 || len(added) > 0 {
+		p.logger.Warn("Table schema has been changed outside. Cached schema is reloaded", "table", tableName,
+			"missing_columns", missing, "changed_columns", changed, "added_columns", added)
+	}
+	p.tables.Set(dbTableSchema)
+
+	return nil
+}
+
+//Remove all cached state of the dropped table so it is created again on the next insert. Must be called under ddlMutex
+//db schema may have been dropped too: it is created again (if not exists) with the table. Partitions of all tables of
+//the db schema are forgotten as well (partition names may be truncated): they are created again with IF NOT EXISTS
+func (p *Postgres) forgetTable(table *schema.Table) {
+	tableName := table.QualifiedName()
+	p.tables.Delete(tableName)
+	delete(p.partitioned, tableName)
+	delete(p.createdDbSchemas, table.Schema)
+	for partition := range p.partitions {
+		if table.Schema == "" || strings.HasPrefix(partition, table.Schema+".") {
+			delete(p.partitions, partition)
+		}
+	}
+	for key := range p.indexes {
+		if strings.HasPrefix(key, tableName+"(") {
+			delete(p.indexes, key)
+		}
+	}
+	if p.columnUsage != nil {
+		p.columnUsage.Forget(tableName)
+	}
+}
+
+//Start creating configured secondary indexes of the table in background if all their columns exist and they haven't
+//been started yet. Index creation may take long on big tables so it doesn't block ingestion and DDL of other tables.
+//Failed indexes are logged and created again after the table is re-read or patched. Must be called under ddlMutex
+func (p *Postgres) ensureIndexes(table *schema.Table) {
+	for _, columns := range p.adapter.Indexes(table.Name) {
+		key := indexKey(table.QualifiedName(), columns)
+		if p.indexes[key] || !hasColumns(table, columns) {
+			continue
 		}
+		p.indexes[key] = true
+		//CREATE INDEX CONCURRENTLY isn't supported on partitioned tables
+		go p.createIndex(table, key, columns, !p.partitioned[table.QualifiedName()])
+	}
+}
+
+func (p *Postgres) createIndex(table *schema.Table, key string, columns []string, concurrently bool) {
+	if err := p.adapter.CreateIndex(table.Schema, table.Name, columns, concurrently); err != nil {
+		p.logger.Error("Error creating table index. It will be created again after the table is re-read or patched",
+			"table", table.QualifiedName(), "columns", columns, "error", err)
+		p.ddlMutex.Lock()
+		delete(p.indexes, key)
+		p.ddlMutex.Unlock()
+		return
 	}
 
-	return p.adapter.Insert(dbTableSchema, fact)
+	p.logger.Info("Table index has been created", "table", table.QualifiedName(), "columns", columns)
 }
 
-//Close adapters.Postgres and queue
+//Return table qualified name with index columns e.g. public.events(user_id,_timestamp)
+func indexKey(tableName string, columns []string) string {
+	return tableName + "(" + strings.Join(columns, ",") + ")"
+}
+
+//Return true if table has all columns
+func hasColumns(table *schema.Table, columns []string) bool {
+	for _, column := range columns {
+		if _, ok := table.Columns[column]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+//Refresh schemas of all cached tables every interval until storage is closed
+func (p *Postgres) refreshSchemas(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-ticker.C:
+			for _, name := range p.tables.Names() {
+				if err := p.RefreshSchema(name); err != nil {
+					p.logger.Error("Error refreshing table schema", "table", name, "error", err)
+				}
+			}
+		}
+	}
+}
+
+//Create db schema if it hasn't been created (or checked) by this storage yet. Must be called under ddlMutex
+func (p *Postgres) ensureDbSchema(dbSchema string) error {
+	if p.createdDbSchemas[dbSchema] {
+		return nil
+	}
+
+	if err := p.adapter.CreateDbSchema(dbSchema); err != nil {
+		return fmt.Errorf("Error creating db schema %s in postgres: %v", dbSchema, err)
+	}
+	p.createdDbSchemas[dbSchema] = true
+
+	return nil
+}
+
+//Create partitioned table if partitioning is configured or ordinary one
+func (p *Postgres) createTable(dataSchema *schema.Table) error {
+	if !p.partition.Enabled() {
+		return p.adapter.CreateTable(dataSchema)
+	}
+
+	if err := p.adapter.CreatePartitionedTable(dataSchema, schema.PartitionColumn); err != nil {
+		return err
+	}
+	p.partitioned[dataSchema.QualifiedName()] = true
+
+	return nil
+}
+
+//Create partitions for all partition column values of objects if they haven't been created yet
+//Postgres routes inserted rows to partitions itself
+func (p *Postgres) ensurePartitions(table *schema.Table, objects []events.Fact) error {
+	p.ddlMutex.Lock()
+	defer p.ddlMutex.Unlock()
+
+	if !p.partitioned[table.QualifiedName()] {
+		return nil
+	}
+
+	for _, object := range objects {
+		partitionDate, ok := object[schema.PartitionColumn].(string)
+		if !ok {
+			continue
+		}
+
+		partition := &schema.Table{Schema: table.Schema,
+			Name: schema.TableNamesConfig{MaxLength: postgresMaxIdentifierLength}.TableName(table.Name + "_p" + strings.ReplaceAll(partitionDate, "-", ""))}
+		if p.partitions[partition.QualifiedName()] {
+			continue
+		}
+
+		from, to, err := p.partition.Bounds(partitionDate)
+		if err != nil {
+			return err
+		}
+		if err := p.adapter.CreatePartition(table.Schema, table.Name, partition.Name, from, to); err != nil {
+			return err
+		}
+		p.partitions[partition.QualifiedName()] = true
+	}
+
+	return nil
+}
+
+//Health return error if postgres isn't reachable (or connection is being restored) or queue size exceeds health_max_queue_size
+func (p *Postgres) Health() error {
+	if err := p.adapter.Health(); err != nil {
+		return err
+	}
+
+	return p.queueHealth()
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.Postgres
 func (p *Postgres) Close() (multiErr error) {
+	if err := p.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
 	if err := p.adapter.Close(); err != nil {
 		multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing postgres datasource: %v", err))
 	}
-	if err := p.eventQueue.Close(); err != nil {
-		multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing postgres event queue: %v", err))
-	}
 
 	return
 }
-
-func (p *Postgres) logSkippedEvent(fact events.Fact, err error) {
-	log.Printf("Warn: unable to enqueue object %v reason: %v. This object will be skipped", fact, err)
-}
@@ -0,0 +1,84 @@
+package storages
+
+import (
+	"context"
+	"github.com/ksensehq/eventnative/schema"
+	"time"
+)
+
+//Postgres retention settings (see adapters.DataSourceConfig retention_* parameters)
+type retentionConfig struct {
+	days      int
+	column    string
+	batchSize int
+}
+
+//Return retention window start: rows (partitions) before it are removed
+func (rc retentionConfig) cutoff(now time.Time) time.Time {
+	return now.UTC().AddDate(0, 0, -rc.days)
+}
+
+//Enforce retention on all cached tables every interval until storage is closed
+//Only tables which have been written (or reconciled) by this storage are touched
+func (p *Postgres) retentionLoop(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-p.closed
+		cancel()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.closed:
+			return
+		case <-ticker.C:
+			cutoff := p.retention.cutoff(time.Now())
+			for _, name := range p.tables.Names() {
+				table, ok := p.tables.Get(name)
+				if !ok {
+					continue
+				}
+				if err := p.enforceRetention(ctx, table, cutoff); err != nil {
+					p.logger.Error("Error enforcing table retention", "table", name, "error", err)
+				}
+			}
+		}
+	}
+}
+
+//Drop partitions of partitioned table which are entirely before cutoff
+//or delete rows before cutoff by batches from ordinary table
+func (p *Postgres) enforceRetention(ctx context.Context, table *schema.Table, cutoff time.Time) error {
+	p.ddlMutex.Lock()
+	partitioned := p.partitioned[table.QualifiedName()]
+	p.ddlMutex.Unlock()
+
+	if !partitioned {
+		deleted, err := p.adapter.DeleteOlderThan(ctx, table.Schema, table.Name, p.retention.column, cutoff, p.retention.batchSize)
+		if deleted > 0 {
+			p.logger.Info("Rows outside of retention window have been deleted", "table", table.QualifiedName(), "rows", deleted)
+		}
+		return err
+	}
+
+	//serialize with partitions creating: dropped partition must be created again on the next insert
+	p.ddlMutex.Lock()
+	defer p.ddlMutex.Unlock()
+
+	partitions, err := p.adapter.PartitionsOlderThan(table.Schema, table.Name, cutoff)
+	if err != nil {
+		return err
+	}
+	for _, partitionName := range partitions {
+		if err := p.adapter.DropPartition(table.Schema, partitionName); err != nil {
+			return err
+		}
+		delete(p.partitions, (&schema.Table{Schema: table.Schema, Name: partitionName}).QualifiedName())
+		p.logger.Info("Partition outside of retention window has been dropped", "table", table.QualifiedName(), "partition", partitionName)
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/logging"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+//in-memory postgresAdapter which fails duplicated DDL like Postgres does
+type fakePostgresAdapter struct {
+	mutex sync.Mutex
+	//table qualified name -> columns
+	tables map[string]schema.Columns
+	//table qualified name -> count of CREATE TABLE statements
+	creates map[string]int
+	//table qualified name -> count of inserted rows
+	rows map[string]int
+}
+
+func newFakePostgresAdapter() *fakePostgresAdapter {
+	return &fakePostgresAdapter{tables: map[string]schema.Columns{}, creates: map[string]int{}, rows: map[string]int{}}
+}
+
+func (fpa *fakePostgresAdapter) CreateDbSchema(dbSchemaName string) error {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) CreateTable(tableSchema *schema.Table) error {
+	fpa.mutex.Lock()
+	defer fpa.mutex.Unlock()
+
+	name := tableSchema.QualifiedName()
+	fpa.creates[name]++
+	if _, ok := fpa.tables[name]; ok {
+		return fmt.Errorf("relation %s already exists", name)
+	}
+	fpa.tables[name] = schema.Columns{}
+	for column, value := range tableSchema.Columns {
+		fpa.tables[name][column] = value
+	}
+
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) CreatePartitionedTable(tableSchema *schema.Table, partitionColumn string) error {
+	return fpa.CreateTable(tableSchema)
+}
+
+func (fpa *fakePostgresAdapter) CreatePartition(dbSchema, tableName, partitionName string, from, to time.Time) error {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) IsPartitioned(dbSchema, tableName string) (bool, error) {
+	return false, nil
+}
+
+func (fpa *fakePostgresAdapter) PatchTableSchema(patchSchema *schema.Table) error {
+	fpa.mutex.Lock()
+	defer fpa.mutex.Unlock()
+
+	name := patchSchema.QualifiedName()
+	columns, ok := fpa.tables[name]
+	if !ok {
+		return fmt.Errorf("relation %s does not exist", name)
+	}
+	for column, value := range patchSchema.Columns {
+		if _, ok := columns[column]; ok {
+			return fmt.Errorf("column %s of relation %s already exists", column, name)
+		}
+		columns[column] = value
+	}
+
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) GetDbSchemaTable(dbSchema, tableName string) (*schema.Table, error) {
+	fpa.mutex.Lock()
+	defer fpa.mutex.Unlock()
+
+	table := &schema.Table{Schema: dbSchema, Name: tableName, Columns: schema.Columns{}}
+	for column, value := range fpa.tables[table.QualifiedName()] {
+		table.Columns[column] = value
+	}
+
+	return table, nil
+}
+
+func (fpa *fakePostgresAdapter) EnsureUniqueKey(table *schema.Table) error {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) Indexes(tableName string) [][]string {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) CreateIndex(dbSchema, tableName string, columns []string, concurrently bool) error {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) BulkInsert(table *schema.Table, objects []events.Fact) error {
+	fpa.mutex.Lock()
+	defer fpa.mutex.Unlock()
+
+	name := table.QualifiedName()
+	columns, ok := fpa.tables[name]
+	if !ok {
+		return fmt.Errorf("relation %s does not exist", name)
+	}
+	for _, object := range objects {
+		for column := range object {
+			if _, ok := columns[column]; !ok {
+				return fmt.Errorf("column %s of relation %s does not exist", column, name)
+			}
+		}
+	}
+	fpa.rows[name] += len(objects)
+
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) DeleteOlderThan(ctx context.Context, dbSchema, tableName, timestampColumn string, before time.Time, batchSize int) (int64, error) {
+	return 0, nil
+}
+
+func (fpa *fakePostgresAdapter) PartitionsOlderThan(dbSchema, tableName string, before time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func (fpa *fakePostgresAdapter) DropPartition(dbSchema, partitionName string) error {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) Health() error {
+	return nil
+}
+
+func (fpa *fakePostgresAdapter) Close() error {
+	return nil
+}
+
+func newTestPostgres(adapter postgresAdapter) *Postgres {
+	return &Postgres{
+		streamingWorker:  &streamingWorker{destinationType: "postgres", storageName: "pg_test", logger: logging.DefaultLogger()},
+		adapter:          adapter,
+		tables:           newTablesCache(),
+		dbSchemas:        newDbSchemaRouter("", "public", nil, adapters.IsValidDbSchemaName),
+		partitioned:      map[string]bool{},
+		partitions:       map[string]bool{},
+		createdDbSchemas: map[string]bool{"public": true},
+		indexes:          map[string]bool{},
+	}
+}
+
+//Run with -race: concurrent inserts to the same and different tables with new columns
+//create each table once and patch it without duplicated columns
+func TestPostgresConcurrentInsert(t *testing.T) {
+	adapter := newFakePostgresAdapter()
+	p := newTestPostgres(adapter)
+	tableNames := []string{"events", "events", "pages", "clicks"}
+
+	const goroutines = 20
+	const inserts = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*inserts)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < inserts; j++ {
+				name := tableNames[(i+j)%len(tableNames)]
+				column := fmt.Sprintf("field_%d", (i+j)%10)
+				dataSchema := &schema.Table{Name: name, Columns: schema.Columns{
+					"id":   schema.Column{Type: schema.INT64},
+					column: schema.Column{Type: schema.STRING},
+				}}
+				if err := p.insert(dataSchema, []events.Fact{{"id": j, column: "value"}}); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	total := 0
+	for _, name := range []string{"public.events", "public.pages", "public.clicks"} {
+		require.Equal(t, 1, adapter.creates[name], "Table must be created once: %s", name)
+		columns := adapter.tables[name]
+		require.Contains(t, columns, "id")
+		cached, ok := p.tables.Get(name)
+		require.True(t, ok)
+		require.Equal(t, columns, cached.Columns, "Cached schema must match the table: %s", name)
+		total += adapter.rows[name]
+	}
+	require.Equal(t, goroutines*inserts, total)
+	for i := 0; i < 10; i++ {
+		require.Contains(t, adapter.tables["public.events"], fmt.Sprintf("field_%d", i))
+	}
+}
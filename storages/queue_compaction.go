@@ -0,0 +1,120 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/joncrlsn/dque"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+//compactingQueue is a dque.DQue which can be recreated when it is empty: dque deletes only fully dequeued segment
+//files which aren't the last one, so the last segment of a drained backlog stays on disk until it is full
+//Operations are safe for concurrent calls. Compaction waits for running operations and blocks new ones
+type compactingQueue struct {
+	name        string
+	parentDir   string
+	segmentSize int
+
+	mutex  sync.RWMutex
+	queue  *dque.DQue
+	closed bool
+
+	//count of enqueued facts and its value at the last compaction (for skipping compaction of not written queue)
+	enqueued    uint64
+	compactedAt uint64
+}
+
+//Open or create dque parentDir/name
+func openCompactingQueue(name, parentDir string, segmentSize int) (*compactingQueue, error) {
+	queue, err := dque.NewOrOpen(name, parentDir, segmentSize, QueuedFactBuilder)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compactingQueue{name: name, parentDir: parentDir, segmentSize: segmentSize, queue: queue}, nil
+}
+
+func (cq *compactingQueue) Enqueue(obj interface{}) error {
+	cq.mutex.RLock()
+	defer cq.mutex.RUnlock()
+
+	atomic.AddUint64(&cq.enqueued, 1)
+	return cq.queue.Enqueue(obj)
+}
+
+func (cq *compactingQueue) Dequeue() (interface{}, error) {
+	cq.mutex.RLock()
+	defer cq.mutex.RUnlock()
+
+	return cq.queue.Dequeue()
+}
+
+func (cq *compactingQueue) Size() int {
+	cq.mutex.RLock()
+	defer cq.mutex.RUnlock()
+
+	return cq.queue.Size()
+}
+
+//Enqueued return count of facts which have been enqueued since start
+func (cq *compactingQueue) Enqueued() uint64 {
+	return atomic.LoadUint64(&cq.enqueued)
+}
+
+//DiskUsage return size of the queue files
+func (cq *compactingQueue) DiskUsage() (diskBytes int64, err error) {
+	cq.mutex.RLock()
+	defer cq.mutex.RUnlock()
+
+	err = filepath.Walk(filepath.Join(cq.parentDir, cq.name), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			diskBytes += info.Size()
+		}
+		return nil
+	})
+
+	return
+}
+
+//Compact close empty queue, remove its files and create it again
+//Return false if the queue isn't empty, is closed or nothing has been enqueued since the last compaction
+func (cq *compactingQueue) Compact() (bool, error) {
+	cq.mutex.Lock()
+	defer cq.mutex.Unlock()
+
+	enqueued := atomic.LoadUint64(&cq.enqueued)
+	if cq.closed || enqueued == cq.compactedAt || cq.queue.Size() > 0 {
+		return false, nil
+	}
+
+	if err := cq.queue.Close(); err != nil {
+		return false, fmt.Errorf("Error closing queue %s: %v", cq.name, err)
+	}
+	//queue is reopened even if files haven't been removed
+	removeErr := os.RemoveAll(filepath.Join(cq.parentDir, cq.name))
+	queue, err := dque.NewOrOpen(cq.name, cq.parentDir, cq.segmentSize, QueuedFactBuilder)
+	if err != nil {
+		//closed dque returns errors from all operations
+		return false, fmt.Errorf("Error opening queue %s after compaction: %v", cq.name, err)
+	}
+	cq.queue = queue
+	if removeErr != nil {
+		return false, fmt.Errorf("Error removing queue %s files: %v", cq.name, removeErr)
+	}
+	cq.compactedAt = enqueued
+
+	return true, nil
+}
+
+func (cq *compactingQueue) Close() error {
+	cq.mutex.Lock()
+	defer cq.mutex.Unlock()
+
+	cq.closed = true
+	return cq.queue.Close()
+}
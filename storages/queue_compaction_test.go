@@ -0,0 +1,56 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCompactingQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue_compaction_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	queue, err := openCompactingQueue("srv-postgres-pg1", dir, 10)
+	require.NoError(t, err)
+	for i := 0; i < 25; i++ {
+		require.NoError(t, queue.Enqueue(QueuedFact{FactBytes: []byte(`{"id":1}`)}))
+	}
+	compacted, err := queue.Compact()
+	require.NoError(t, err)
+	require.False(t, compacted, "Not empty queue mustn't be compacted")
+
+	for i := 0; i < 25; i++ {
+		_, err := queue.Dequeue()
+		require.NoError(t, err)
+	}
+	before, err := queue.DiskUsage()
+	require.NoError(t, err)
+	require.True(t, before > 0, "Drained queue keeps the last segment file")
+
+	compacted, err = queue.Compact()
+	require.NoError(t, err)
+	require.True(t, compacted)
+	after, err := queue.DiskUsage()
+	require.NoError(t, err)
+	require.True(t, after < before, "Compaction must free disk space")
+
+	compacted, err = queue.Compact()
+	require.NoError(t, err)
+	require.False(t, compacted, "Queue without new facts mustn't be compacted again")
+
+	//compacted queue accepts facts
+	require.NoError(t, queue.Enqueue(QueuedFact{FactBytes: []byte(`{"id":2}`)}))
+	require.Equal(t, 1, queue.Size())
+	iface, err := queue.Dequeue()
+	require.NoError(t, err)
+	fact, ok := iface.(QueuedFact)
+	require.True(t, ok)
+	require.Equal(t, `{"id":2}`, string(fact.FactBytes))
+
+	require.NoError(t, queue.Close())
+	compacted, err = queue.Compact()
+	require.NoError(t, err)
+	require.False(t, compacted, "Closed queue mustn't be compacted")
+}
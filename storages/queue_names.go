@@ -0,0 +1,72 @@
+package storages
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//queue dirs of all created and not closed streaming storages
+var activeQueues = &queueRegistry{storages: map[string]string{}}
+
+//queueRegistry keeps queue dirs in use. Two storages can't share one persistent queue: they would corrupt each other's data
+type queueRegistry struct {
+	mutex sync.Mutex
+	//absolute queue dir -> storage name
+	storages map[string]string
+}
+
+//register return error if queue dir is used by another storage
+func (qr *queueRegistry) register(queueDir, storageName string) error {
+	absDir, err := filepath.Abs(queueDir)
+	if err != nil {
+		return fmt.Errorf("Error getting absolute path of queue dir %s: %v", queueDir, err)
+	}
+
+	qr.mutex.Lock()
+	defer qr.mutex.Unlock()
+
+	if owner, ok := qr.storages[absDir]; ok {
+		return fmt.Errorf("Error queue %s is already used by storage [%s]. Storage names must be unique", absDir, owner)
+	}
+	qr.storages[absDir] = storageName
+
+	return nil
+}
+
+func (qr *queueRegistry) unregister(queueDir string) {
+	absDir, err := filepath.Abs(queueDir)
+	if err != nil {
+		return
+	}
+
+	qr.mutex.Lock()
+	delete(qr.storages, absDir)
+	qr.mutex.Unlock()
+}
+
+//Return persistent queue name: <server name>-<destination type>-<storage name>
+func streamingQueueName(serverName, destinationType, storageName string) string {
+	return fmt.Sprintf("%s-%s-%s", serverName, destinationType, storageName)
+}
+
+//Rename queue dirs (event queue and dead-letter queue) with legacy names <server name>-<storage name>
+//so facts which were queued before upgrade aren't lost. Dirs are renamed only if new ones don't exist
+func migrateLegacyQueue(fallbackDir, legacyName, queueName string) error {
+	for _, suffix := range []string{"", deadLetterQueueSuffix} {
+		legacyDir := filepath.Join(fallbackDir, legacyName+suffix)
+		queueDir := filepath.Join(fallbackDir, queueName+suffix)
+		if _, err := os.Stat(legacyDir); err != nil {
+			continue
+		}
+		if _, err := os.Stat(queueDir); err == nil {
+			continue
+		}
+		if err := os.Rename(legacyDir, queueDir); err != nil {
+			return fmt.Errorf("Error renaming legacy queue dir %s to %s: %v", legacyDir, queueDir, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package storages
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueueRegistry(t *testing.T) {
+	registry := &queueRegistry{storages: map[string]string{}}
+
+	require.NoError(t, registry.register("/tmp/queues/srv-postgres-pg1", "pg1"))
+	require.NoError(t, registry.register("/tmp/queues/srv-clickhouse-pg1", "pg1"))
+	require.Error(t, registry.register("/tmp/queues/srv-postgres-pg1", "pg1"), "Duplicate queue must be rejected")
+	require.Error(t, registry.register("/tmp/queues/../queues/srv-postgres-pg1", "pg1"), "Duplicate queue with not clean path must be rejected")
+
+	registry.unregister("/tmp/queues/srv-postgres-pg1")
+	require.NoError(t, registry.register("/tmp/queues/srv-postgres-pg1", "pg1"), "Queue must be registered again after closing")
+}
+
+func TestMigrateLegacyQueue(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "queue_names_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "srv-pg1"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "srv-pg1", "0000000000001.dque"), []byte("facts"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "srv-pg1"+deadLetterQueueSuffix), 0755))
+
+	require.NoError(t, migrateLegacyQueue(tmpDir, "srv-pg1", "srv-postgres-pg1"))
+
+	content, err := ioutil.ReadFile(filepath.Join(tmpDir, "srv-postgres-pg1", "0000000000001.dque"))
+	require.NoError(t, err)
+	require.Equal(t, "facts", string(content))
+	_, err = os.Stat(filepath.Join(tmpDir, "srv-postgres-pg1"+deadLetterQueueSuffix))
+	require.NoError(t, err, "Dead-letter queue must be renamed")
+	_, err = os.Stat(filepath.Join(tmpDir, "srv-pg1"))
+	require.True(t, os.IsNotExist(err), "Legacy queue dir must be renamed")
+
+	//existing new queue isn't overwritten
+	require.NoError(t, os.Mkdir(filepath.Join(tmpDir, "srv-pg1"), 0755))
+	require.NoError(t, migrateLegacyQueue(tmpDir, "srv-pg1", "srv-postgres-pg1"))
+	_, err = os.Stat(filepath.Join(tmpDir, "srv-pg1"))
+	require.NoError(t, err, "Legacy queue dir must be kept if new one exists")
+
+	//nothing to migrate
+	require.NoError(t, migrateLegacyQueue(tmpDir, "srv-missing", "srv-postgres-missing"))
+}
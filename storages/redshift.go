@@ -24,10 +24,11 @@ type AwsRedshift struct {
 	schemaProcessor *schema.Processor
 	tables          map[string]*schema.Table
 	breakOnError    bool
+	name            string
 }
 
 func NewAwsRedshift(ctx context.Context, s3Config *adapters.S3Config, redshiftConfig *adapters.DataSourceConfig,
-	processor *schema.Processor, breakOnError bool) (*AwsRedshift, error) {
+	processor *schema.Processor, breakOnError bool, storageName string) (*AwsRedshift, error) {
 	s3Adapter, err := adapters.NewAwsS3(s3Config)
 	if err != nil {
 		return nil, err
@@ -50,6 +51,7 @@ func NewAwsRedshift(ctx context.Context, s3Config *adapters.S3Config, redshiftCo
 		schemaProcessor: processor,
 		tables:          map[string]*schema.Table{},
 		breakOnError:    breakOnError,
+		name:            storageName,
 	}
 	ar.start()
 
@@ -63,11 +65,12 @@ func NewAwsRedshift(ctx context.Context, s3Config *adapters.S3Config, redshiftCo
 func (ar *AwsRedshift) start() {
 	go func() {
 		for {
-			if appstatus.Instance.Idle {
-				break
-			}
 			//TODO configurable
-			time.Sleep(1 * time.Minute)
+			select {
+			case <-appstatus.Instance.Done():
+				return
+			case <-time.After(1 * time.Minute):
+			}
 
 			filesKeys, err := ar.s3Adapter.ListBucket(appconfig.Instance.ServerName)
 			if err != nil {
@@ -97,7 +100,11 @@ func (ar *AwsRedshift) start() {
 					continue
 				}
 
-				wrappedTx.Commit()
+				//the file is deleted only after successful COPY: otherwise it will be copied again on the next iteration
+				if err := wrappedTx.Commit(); err != nil {
+					log.Printf("Error committing copy of file [%s] from s3 to redshift. The file will be copied again: %v", fileKey, err)
+					continue
+				}
 				//TODO may be we need to have a journal for collecting already processed files names
 				// if ar.s3Adapter.DeleteObject fails => it will be processed next time => duplicate data
 				if err := ar.s3Adapter.DeleteObject(fileKey); err != nil {
@@ -143,6 +150,7 @@ func (ar *AwsRedshift) Store(fileName string, payload []byte) error {
 			if err := ar.redshiftAdapter.PatchTableSchema(schemaDiff); err != nil {
 				return fmt.Errorf("Error patching table schema %s in redshift: %v", schemaDiff.Name, err)
 			}
+			notifySchemaChange("redshift", ar.name, schemaDiff)
 			//Save
 			for k, v := range schemaDiff.Columns {
 				dbTableSchema.Columns[k] = v
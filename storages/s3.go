@@ -0,0 +1,140 @@
+package storages
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultS3KeyTemplate = "year={{.Year}}/month={{.Month}}/day={{.Day}}/{{uuid}}.json"
+
+//S3 archives raw events (without flattening) to aws s3 as newline-delimited json objects (optionally gzipped)
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and upload one object per batch (batch_size events or flush_interval_ms time window)
+//Facts are removed from the queue only after the object is uploaded (s3 PutObject is atomic)
+type S3 struct {
+	*streamingWorker
+
+	adapter     *adapters.AwsS3
+	keyTemplate *template.Template
+	gzip        bool
+}
+
+//s3KeyData is data for object key template
+type s3KeyData struct {
+	Year  string
+	Month string
+	Day   string
+	Hour  string
+}
+
+func NewS3(s3Config *adapters.S3Config, sinkConfig *adapters.S3SinkConfig, fallbackDir, storageName string) (*S3, error) {
+	keyTemplate, err := parseS3KeyTemplate(sinkConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, err := adapters.NewAwsS3(s3Config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &S3{adapter: adapter, keyTemplate: keyTemplate, gzip: sinkConfig.Gzip}
+
+	//facts aren't processed with schema.Processor
+	s.streamingWorker, err = newStreamingWorker("s3", storageName, fallbackDir, sinkConfig.StreamingConfig, nil, s.insert)
+	if err != nil {
+		return nil, err
+	}
+	s.start()
+
+	return s, nil
+}
+
+//Return configured object key template or default one (with .gz extension if gzip is enabled)
+func parseS3KeyTemplate(sinkConfig *adapters.S3SinkConfig) (*template.Template, error) {
+	keyTemplateExpression := sinkConfig.KeyTemplate
+	if keyTemplateExpression == "" {
+		keyTemplateExpression = defaultS3KeyTemplate
+		if sinkConfig.Gzip {
+			keyTemplateExpression += ".gz"
+		}
+	}
+	keyTemplate, err := template.New("s3 key").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"uuid": func() string { return uuid.New().String() }}).
+		Parse(keyTemplateExpression)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing s3 key template: %v", err)
+	}
+
+	return keyTemplate, nil
+}
+
+//insert upload all facts as one object
+func (s *S3) insert(_ *schema.Table, objects []events.Fact) error {
+	payload, err := s.payload(objects)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.objectKey(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return s.adapter.UploadBytes(key, payload)
+}
+
+//Return newline-delimited json objects (gzipped if configured)
+func (s *S3) payload(objects []events.Fact) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, object := range objects {
+		b, err := json.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("Error marshaling event to json: %v", err)
+		}
+		buf.Write(b)
+		buf.Write([]byte("\n"))
+	}
+
+	if !s.gzip {
+		return buf.Bytes(), nil
+	}
+
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	if _, err := gzipWriter.Write(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("Error compressing s3 object: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("Error compressing s3 object: %v", err)
+	}
+
+	return compressed.Bytes(), nil
+}
+
+//Return object key from key template
+func (s *S3) objectKey(t time.Time) (string, error) {
+	data := s3KeyData{
+		Year:  t.Format("2006"),
+		Month: t.Format("01"),
+		Day:   t.Format("02"),
+		Hour:  t.Format("15"),
+	}
+
+	var key strings.Builder
+	if err := s.keyTemplate.Execute(&key, data); err != nil {
+		return "", fmt.Errorf("Error executing s3 key template: %v", err)
+	}
+
+	return key.String(), nil
+}
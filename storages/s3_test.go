@@ -0,0 +1,107 @@
+package storages
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestS3ObjectKey(t *testing.T) {
+	uploadTime := time.Date(2020, 3, 7, 9, 45, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		sinkConfig    *adapters.S3SinkConfig
+		expectedKey   string
+		expectedError string
+	}{
+		{
+			"Default template",
+			&adapters.S3SinkConfig{},
+			`^year=2020/month=03/day=07/[0-9a-f-]{36}\.json$`,
+			"",
+		},
+		{
+			"Default template with gzip",
+			&adapters.S3SinkConfig{Gzip: true},
+			`^year=2020/month=03/day=07/[0-9a-f-]{36}\.json\.gz$`,
+			"",
+		},
+		{
+			"Configured template isn't changed with gzip",
+			&adapters.S3SinkConfig{KeyTemplate: "raw/{{.Year}}{{.Month}}{{.Day}}/{{.Hour}}/events.ndjson", Gzip: true},
+			`^raw/20200307/09/events\.ndjson$`,
+			"",
+		},
+		{
+			"Malformed template",
+			&adapters.S3SinkConfig{KeyTemplate: "{{.Year"},
+			"",
+			"Error parsing s3 key template",
+		},
+		{
+			"Unknown function",
+			&adapters.S3SinkConfig{KeyTemplate: "{{now}}.json"},
+			"",
+			"Error parsing s3 key template",
+		},
+		{
+			"Unknown field",
+			&adapters.S3SinkConfig{KeyTemplate: "{{.Minute}}.json"},
+			"",
+			"Error executing s3 key template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyTemplate, err := parseS3KeyTemplate(tt.sinkConfig)
+			var key string
+			if err == nil {
+				key, err = (&S3{keyTemplate: keyTemplate}).objectKey(uploadTime)
+			}
+			if tt.expectedError != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, regexp.MustCompile(tt.expectedKey).MatchString(key), "Unexpected key: %s", key)
+		})
+	}
+
+	//every object gets a unique key
+	keyTemplate, err := parseS3KeyTemplate(&adapters.S3SinkConfig{})
+	require.NoError(t, err)
+	s := &S3{keyTemplate: keyTemplate}
+	first, err := s.objectKey(uploadTime)
+	require.NoError(t, err)
+	second, err := s.objectKey(uploadTime)
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+}
+
+func TestS3Payload(t *testing.T) {
+	objects := []events.Fact{{"id": 1, "event_type": "pageview"}, {"id": 2}}
+	expected := "{\"event_type\":\"pageview\",\"id\":1}\n{\"id\":2}\n"
+
+	payload, err := (&S3{}).payload(objects)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(payload))
+
+	compressed, err := (&S3{gzip: true}).payload(objects)
+	require.NoError(t, err)
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decompressed, err := ioutil.ReadAll(gzipReader)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(decompressed))
+
+	_, err = (&S3{}).payload([]events.Fact{{"value": make(chan int)}})
+	require.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package storages
+
+import (
+	"github.com/ksensehq/eventnative/logging"
+	"github.com/ksensehq/eventnative/metrics"
+	"github.com/ksensehq/eventnative/schema"
+	"sync"
+)
+
+//SchemaChangeHook is called after destination table has been patched with new columns
+//addedColumns: column name -> type (configured sql type or schema.DataType name)
+type SchemaChangeHook func(storageName, table string, addedColumns map[string]string)
+
+var schemaChangeHooks = struct {
+	mutex sync.RWMutex
+	hooks []SchemaChangeHook
+}{}
+
+//OnSchemaChange register hook which is called on automatic schema changes in all storages (e.g. for alerting or audit)
+//Hooks are called synchronously from insert goroutines so they must not block
+func OnSchemaChange(hook SchemaChangeHook) {
+	schemaChangeHooks.mutex.Lock()
+	schemaChangeHooks.hooks = append(schemaChangeHooks.hooks, hook)
+	schemaChangeHooks.mutex.Unlock()
+}
+
+//Log applied schema diff, count added columns in metrics and call registered hooks
+//Is called after successful PatchTableSchema
+func notifySchemaChange(destinationType, storageName string, diff *schema.Table) {
+	if len(diff.Columns) == 0 && len(diff.WidenedColumns) == 0 {
+		return
+	}
+
+	added := columnTypes(diff.Columns)
+	logging.DefaultLogger().Info("Table schema has been patched", "destination_type", destinationType, "storage", storageName,
+		"table", diff.Name, "added_columns", added, "widened_columns", columnTypes(diff.WidenedColumns))
+	if len(added) == 0 {
+		return
+	}
+	metrics.AddedColumns(destinationType, storageName, diff.Name).Add(float64(len(added)))
+
+	schemaChangeHooks.mutex.RLock()
+	defer schemaChangeHooks.mutex.RUnlock()
+	for _, hook := range schemaChangeHooks.hooks {
+		hook(storageName, diff.Name, added)
+	}
+}
+
+//Return column name -> configured sql type or schema.DataType name
+func columnTypes(columns schema.Columns) map[string]string {
+	types := map[string]string{}
+	for name, column := range columns {
+		if column.SqlType != "" {
+			types[name] = column.SqlType
+		} else {
+			types[name] = column.Type.String()
+		}
+	}
+
+	return types
+}
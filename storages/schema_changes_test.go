@@ -0,0 +1,30 @@
+package storages
+
+import (
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNotifySchemaChange(t *testing.T) {
+	type change struct {
+		storageName  string
+		table        string
+		addedColumns map[string]string
+	}
+	var changes []change
+	OnSchemaChange(func(storageName, table string, addedColumns map[string]string) {
+		changes = append(changes, change{storageName, table, addedColumns})
+	})
+
+	notifySchemaChange("postgres", "pg1", &schema.Table{Name: "events", Columns: schema.Columns{
+		"utm_source": schema.Column{Type: schema.STRING},
+		"properties": schema.Column{Type: schema.STRING, SqlType: "jsonb"},
+	}})
+	//only widened columns: hooks aren't called
+	notifySchemaChange("postgres", "pg1", &schema.Table{Name: "events", Columns: schema.Columns{},
+		WidenedColumns: schema.Columns{"count": schema.Column{Type: schema.FLOAT64}}})
+	notifySchemaChange("postgres", "pg1", &schema.Table{Name: "events", Columns: schema.Columns{}})
+
+	require.Equal(t, []change{{"pg1", "events", map[string]string{"utm_source": "STRING", "properties": "jsonb"}}}, changes)
+}
@@ -0,0 +1,66 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"sort"
+)
+
+//SchemaMismatchError is a reason of objects rejection in strict schema mode: objects have columns which don't exist
+//in the table or values which don't fit column types. Table isn't patched automatically
+type SchemaMismatchError struct {
+	Table string
+	//sorted names of new and widened columns
+	Columns []string
+}
+
+func (sme *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("Schema mismatch: table %s doesn't have columns %v (or their types are narrower) and automatic schema patching is disabled", sme.Table, sme.Columns)
+}
+
+//matchSchema split objects of dataSchema into ones which fit the table and ones which need table patching
+//Return kept objects with their indexes in objects and indexes of rejected ones with error. Error is nil if all objects are kept
+func matchSchema(table, dataSchema *schema.Table, objects []events.Fact) ([]events.Fact, []int, []int, *SchemaMismatchError) {
+	diff := table.Diff(dataSchema)
+	if !diff.NeedsPatch() {
+		return objects, nil, nil, nil
+	}
+
+	var kept []events.Fact
+	var keptIndexes, rejected []int
+	mismatched := map[string]bool{}
+	for i, object := range objects {
+		fits := true
+		for column, value := range object {
+			if value == nil {
+				continue
+			}
+			if _, ok := diff.Columns[column]; ok {
+				mismatched[column] = true
+				fits = false
+			} else if _, ok := diff.WidenedColumns[column]; ok && schema.ValueType(value).IsWiderThan(table.Columns[column].Type) {
+				mismatched[column] = true
+				fits = false
+			}
+		}
+		if fits {
+			kept = append(kept, object)
+			keptIndexes = append(keptIndexes, i)
+		} else {
+			rejected = append(rejected, i)
+		}
+	}
+
+	if len(rejected) == 0 {
+		return objects, nil, nil, nil
+	}
+
+	columns := make([]string, 0, len(mismatched))
+	for column := range mismatched {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	return kept, keptIndexes, rejected, &SchemaMismatchError{Table: table.QualifiedName(), Columns: columns}
+}
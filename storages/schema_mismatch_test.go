@@ -0,0 +1,70 @@
+package storages
+
+import (
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMatchSchema(t *testing.T) {
+	table := &schema.Table{Schema: "public", Name: "events", Columns: schema.Columns{
+		"id":    schema.Column{Type: schema.STRING},
+		"count": schema.Column{Type: schema.INT64},
+	}}
+
+	tests := []struct {
+		name            string
+		dataSchema      schema.Columns
+		objects         []events.Fact
+		expectedKept    []int
+		expectedRejects []int
+		expectedColumns []string
+	}{
+		{
+			"all objects fit",
+			schema.Columns{"id": schema.Column{Type: schema.STRING}, "count": schema.Column{Type: schema.INT64}},
+			[]events.Fact{{"id": "1", "count": int64(1)}, {"id": "2"}},
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"new column",
+			schema.Columns{"id": schema.Column{Type: schema.STRING}, "utm_source": schema.Column{Type: schema.STRING}},
+			[]events.Fact{{"id": "1"}, {"id": "2", "utm_source": "google"}, {"id": "3", "utm_source": nil}},
+			[]int{0, 2},
+			[]int{1},
+			[]string{"utm_source"},
+		},
+		{
+			"widened column",
+			schema.Columns{"count": schema.Column{Type: schema.FLOAT64}, "source": schema.Column{Type: schema.STRING}},
+			[]events.Fact{{"count": 1.5}, {"count": int64(2)}, {"count": int64(3), "source": "api"}},
+			[]int{1},
+			[]int{0, 2},
+			[]string{"count", "source"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataSchema := &schema.Table{Schema: "public", Name: "events", Columns: tt.dataSchema}
+			kept, keptIndexes, rejected, err := matchSchema(table, dataSchema, tt.objects)
+			require.Equal(t, tt.expectedKept, keptIndexes)
+			require.Equal(t, tt.expectedRejects, rejected)
+			if tt.expectedColumns == nil {
+				require.Nil(t, err)
+				require.Equal(t, tt.objects, kept)
+				return
+			}
+
+			require.NotNil(t, err)
+			require.Equal(t, tt.expectedColumns, err.Columns)
+			require.Equal(t, "public.events", err.Table)
+			require.Equal(t, len(tt.expectedKept), len(kept))
+			for i, index := range keptIndexes {
+				require.Equal(t, tt.objects[index], kept[i])
+			}
+		})
+	}
+}
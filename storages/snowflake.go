@@ -0,0 +1,73 @@
+package storages
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+)
+
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing and store events to Snowflake in streaming mode with batches (every batch is loaded with COPY INTO)
+//Keeping tables schema state inmemory and update it according to incoming new data
+//note: Assume that after any outer changes in db we need to recreate this structure
+//for keeping actual db tables schema state
+type Snowflake struct {
+	*streamingWorker
+
+	adapter *adapters.Snowflake
+	tables  *tablesCache
+}
+
+func NewSnowflake(ctx context.Context, config *adapters.SnowflakeConfig, processor *schema.Processor,
+	fallbackDir, storageName string) (*Snowflake, error) {
+	adapter, err := adapters.NewSnowflake(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	//create db schema if doesn't exist
+	err = adapter.CreateDbSchema(config.Schema)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+
+	s := &Snowflake{
+		adapter: adapter,
+		tables:  newTablesCache(),
+	}
+
+	s.streamingWorker, err = newStreamingWorker("snowflake", storageName, fallbackDir, config.StreamingConfig, processor, s.insert)
+	if err != nil {
+		adapter.Close()
+		return nil, err
+	}
+	s.start()
+
+	return s, nil
+}
+
+//insert facts in Snowflake
+func (s *Snowflake) insert(dataSchema *schema.Table, objects []events.Fact) error {
+	dbTableSchema, err := s.tables.ensureTable("snowflake", s.adapter, dataSchema, nil, s.notifySchemaChange)
+	if err != nil {
+		return err
+	}
+
+	return s.adapter.BulkInsert(dbTableSchema, objects)
+}
+
+//Close flush and close queues (see streamingWorker.Close()) then close adapters.Snowflake
+func (s *Snowflake) Close() (multiErr error) {
+	if err := s.streamingWorker.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	}
+	if err := s.adapter.Close(); err != nil {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing snowflake datasource: %v", err))
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package storages
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//weight of the last insert latency in the rolling average
+const insertLatencyWeight = 0.1
+
+//StorageStats is a snapshot of streaming storage counters since start
+//(see streamingWorker.Stats()). Prometheus metrics (see metrics.Streaming) contain the same counters
+type StorageStats struct {
+	//count of inserted objects
+	Inserted uint64
+	//count of objects which weren't inserted because of insert errors (they are re-enqueued)
+	Failed uint64
+	//count of facts which are enqueued one more time after insert or processing failures
+	Reenqueued uint64
+	//count of facts which haven't been enqueued (e.g. marshaling errors or storage is closed)
+	Skipped uint64
+	//count of facts which have been dropped because the queue is full (reject policy)
+	Rejected uint64
+	//count of facts which have been put to the dead-letter queue
+	DeadLettered uint64
+
+	//last insert error. Empty if there weren't any errors
+	LastError   string
+	LastErrorAt time.Time
+	//time of the last successful insert. Zero if there weren't any inserts
+	LastInsertAt time.Time
+	//exponentially weighted moving average of insert latency (successful and failed inserts)
+	AvgInsertLatency time.Duration
+}
+
+//storageStats accumulates StorageStats. Safe for concurrent use
+type storageStats struct {
+	//counters are updated atomically (must be the first fields for 64-bit alignment on 32-bit platforms)
+	inserted     uint64
+	failed       uint64
+	reenqueued   uint64
+	skipped      uint64
+	rejected     uint64
+	deadLettered uint64
+
+	mutex            sync.RWMutex
+	lastError        string
+	lastErrorAt      time.Time
+	lastInsertAt     time.Time
+	avgInsertLatency time.Duration
+}
+
+func (ss *storageStats) insertSucceeded(objects int, latency time.Duration) {
+	atomic.AddUint64(&ss.inserted, uint64(objects))
+
+	ss.mutex.Lock()
+	ss.lastInsertAt = time.Now()
+	ss.observeLatency(latency)
+	ss.mutex.Unlock()
+}
+
+func (ss *storageStats) insertFailed(objects int, latency time.Duration, err error) {
+	atomic.AddUint64(&ss.failed, uint64(objects))
+
+	ss.mutex.Lock()
+	ss.lastError = err.Error()
+	ss.lastErrorAt = time.Now()
+	ss.observeLatency(latency)
+	ss.mutex.Unlock()
+}
+
+//must be called under mutex
+func (ss *storageStats) observeLatency(latency time.Duration) {
+	if ss.avgInsertLatency == 0 {
+		ss.avgInsertLatency = latency
+		return
+	}
+	ss.avgInsertLatency = time.Duration(insertLatencyWeight*float64(latency) + (1-insertLatencyWeight)*float64(ss.avgInsertLatency))
+}
+
+func (ss *storageStats) snapshot() StorageStats {
+	ss.mutex.RLock()
+	defer ss.mutex.RUnlock()
+
+	return StorageStats{
+		Inserted:         atomic.LoadUint64(&ss.inserted),
+		Failed:           atomic.LoadUint64(&ss.failed),
+		Reenqueued:       atomic.LoadUint64(&ss.reenqueued),
+		Skipped:          atomic.LoadUint64(&ss.skipped),
+		Rejected:         atomic.LoadUint64(&ss.rejected),
+		DeadLettered:     atomic.LoadUint64(&ss.deadLettered),
+		LastError:        ss.lastError,
+		LastErrorAt:      ss.lastErrorAt,
+		LastInsertAt:     ss.lastInsertAt,
+		AvgInsertLatency: ss.avgInsertLatency,
+	}
+}
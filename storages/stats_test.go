@@ -0,0 +1,54 @@
+package storages
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorageStats(t *testing.T) {
+	stats := &storageStats{}
+	require.Equal(t, StorageStats{}, stats.snapshot())
+
+	stats.insertSucceeded(10, 100*time.Millisecond)
+	stats.insertFailed(5, 200*time.Millisecond, errors.New("connection refused"))
+
+	snapshot := stats.snapshot()
+	require.Equal(t, uint64(10), snapshot.Inserted)
+	require.Equal(t, uint64(5), snapshot.Failed)
+	require.Equal(t, "connection refused", snapshot.LastError)
+	require.False(t, snapshot.LastInsertAt.IsZero())
+	require.False(t, snapshot.LastErrorAt.IsZero())
+	require.Equal(t, 110*time.Millisecond, snapshot.AvgInsertLatency)
+}
+
+//Run with -race
+func TestStorageStatsConcurrentAccess(t *testing.T) {
+	stats := &storageStats{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stats.insertSucceeded(1, time.Millisecond)
+				stats.insertFailed(1, time.Millisecond, errors.New("error"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stats.snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	snapshot := stats.snapshot()
+	require.Equal(t, uint64(1000), snapshot.Inserted)
+	require.Equal(t, uint64(1000), snapshot.Failed)
+	require.Equal(t, time.Millisecond, snapshot.AvgInsertLatency)
+}
@@ -0,0 +1,1063 @@
+package storages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/joncrlsn/dque"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/appconfig"
+	"github.com/ksensehq/eventnative/appstatus"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/logging"
+	"github.com/ksensehq/eventnative/metrics"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/ksensehq/eventnative/tracing"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//count of facts per queue segment file if queue_segment_size isn't configured
+	defaultEventsPerPersistedFile = 2000
+	emptyQueuePollInterval        = 10 * time.Millisecond
+	idleQueuePollInterval         = 100 * time.Millisecond
+	deadLetterQueueSuffix         = "-dead-letter"
+	//queue files size is recalculated not more often than this interval (it requires directory walking)
+	queueDiskUsageTTL = time.Second
+)
+
+var (
+	errStorageClosed = errors.New("Storage is closed")
+	errQueueFull     = errors.New("Queue is full")
+	errAppIdle       = errors.New("Application is idle")
+)
+
+//insertFunc store flatten objects of one table in a destination
+type insertFunc func(dataSchema *schema.Table, objects []events.Fact) error
+
+//ProcessingFailureHook is called on every failed fact processing attempt (e.g. for alerting)
+//attempts is a count of failed attempts including the current one
+//fact is put to the dead-letter queue when attempts reaches max_processing_attempts
+type ProcessingFailureHook func(fact events.Fact, err error, attempts int)
+
+//streamingWorker is a common part of streaming storages (e.g. Postgres or ClickHouse):
+//Consuming event facts, put them to https://github.com/joncrlsn/dque
+//Dequeuing them in batches, processing with schema.Processor and passing to insertFunc
+//(or passing facts as is in one batch if processor is nil e.g. in Kafka)
+//Retrying failed facts and putting facts which can't be processed to the dead-letter queue
+type streamingWorker struct {
+	destinationType string
+	storageName     string
+	schemaProcessor *schema.Processor
+	insert          insertFunc
+
+	eventQueue      *compactingQueue
+	eventQueueDir   string
+	deadLetterQueue *compactingQueue
+	//empty queue is compacted after this idle time. 0 - only on Compact() call
+	compactIdle     time.Duration
+	batchSize       int
+	flushInterval   time.Duration
+	insertBackoff   *backoff
+	maxAttempts     int
+	shutdownTimeout time.Duration
+	//end of shutdown timeout. Is set before closed channel is closed
+	shutdownDeadline time.Time
+	//count of drain goroutines. insertFunc must be safe for concurrent calls if it is greater than 1
+	drainWorkers int
+
+	maxQueueSize  int
+	maxQueueBytes int64
+	blockWhenFull bool
+	//max json marshaled fact size. 0 - unlimited
+	maxEventBytes int
+	//truncate oversized facts instead of putting them to the dead-letter queue
+	truncateOversized bool
+	//facts which can't be marshaled to json are written there. nil - they are only logged (skip policy)
+	marshalFailures *marshalFailuresFile
+	//max count of queued facts for healthy state
+	healthMaxQueueSize int
+	//cached queue files size
+	diskUsageMutex     sync.Mutex
+	diskUsage          int64
+	diskUsageCheckedAt time.Time
+	//1 if the queue is full (for logging only transitions)
+	full int32
+
+	//facts which haven't been inserted yet (see Flush)
+	pending *pendingFacts
+
+	metrics *metrics.Streaming
+	//the same counters for programmatic reading (see Stats())
+	stats *storageStats
+	//with destination_type and storage fields
+	logger logging.Logger
+	//per event failures are written at debug level and collapsed into periodic summaries
+	reenqueueSummary         *logging.Summary
+	processingFailureSummary *logging.Summary
+
+	hookMutex           sync.RWMutex
+	onProcessingFailure ProcessingFailureHook
+
+	closed    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//tableBatch is a group of facts which will be inserted in one table
+type tableBatch struct {
+	dataSchema     *schema.Table
+	flattenObjects []events.Fact
+	sourceFacts    []*dequeuedFact
+}
+
+//Return halves of the batch with the same data schema
+func (tb *tableBatch) split() (*tableBatch, *tableBatch) {
+	half := len(tb.sourceFacts) / 2
+	return &tableBatch{dataSchema: tb.dataSchema, flattenObjects: tb.flattenObjects[:half], sourceFacts: tb.sourceFacts[:half]},
+		&tableBatch{dataSchema: tb.dataSchema, flattenObjects: tb.flattenObjects[half:], sourceFacts: tb.sourceFacts[half:]}
+}
+
+//dequeuedFact is unwrapped QueuedFact
+type dequeuedFact struct {
+	fact       events.Fact
+	attempts   int
+	enqueuedAt time.Time
+	//W3C traceparent of the enqueue span (see tracing)
+	traceParent string
+}
+
+//Return context with span context of fact trace
+func (df *dequeuedFact) traceContext() context.Context {
+	ctx := context.Background()
+	if sc, ok := tracing.ParseTraceParent(df.traceParent); ok {
+		ctx = tracing.ContextWithSpanContext(ctx, sc)
+	}
+
+	return ctx
+}
+
+//QueuedFact is a persistent queue record (gob encoded by dque)
+//Records written by previous versions contain only FactBytes: other fields are decoded with zero values
+type QueuedFact struct {
+	FactBytes []byte
+	//count of failed processing attempts
+	Attempts int
+	//time when fact was consumed (is kept when fact is enqueued one more time after failure)
+	//in the dead-letter queue - time when fact was put there
+	EnqueuedAt time.Time
+	//in the dead-letter queue - why fact was put there (e.g. processing or validation error)
+	Reason string
+	//W3C traceparent: trace is continued after dequeue (and restart)
+	TraceParent string
+}
+
+// FactBuilder creates and returns a new events.Fact.
+// This is used when we load a segment of the queue from disk.
+func QueuedFactBuilder() interface{} {
+	return &QueuedFact{}
+}
+
+//Open (or create) persistent queues and return not started worker instance
+func newStreamingWorker(destinationType, storageName, fallbackDir string, config adapters.StreamingConfig,
+	processor *schema.Processor, insert insertFunc) (*streamingWorker, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ensureWritableDir(fallbackDir); err != nil {
+		return nil, err
+	}
+
+	queueName := streamingQueueName(appconfig.Instance.ServerName, destinationType, storageName)
+	queueDir := filepath.Join(fallbackDir, queueName)
+	if err := activeQueues.register(queueDir, storageName); err != nil {
+		return nil, err
+	}
+
+	legacyQueueName := fmt.Sprintf("%s-%s", appconfig.Instance.ServerName, storageName)
+	if err := migrateLegacyQueue(fallbackDir, legacyQueueName, queueName); err != nil {
+		activeQueues.unregister(queueDir)
+		return nil, err
+	}
+
+	eventsPerPersistedFile := config.QueueSegmentSize
+	if eventsPerPersistedFile == 0 {
+		eventsPerPersistedFile = defaultEventsPerPersistedFile
+	}
+	queue, err := openCompactingQueue(queueName, fallbackDir, eventsPerPersistedFile)
+	if err != nil {
+		activeQueues.unregister(queueDir)
+		return nil, fmt.Errorf("Error opening/creating event queue for %s: %v", destinationType, err)
+	}
+
+	deadLetterQueue, err := openCompactingQueue(queueName+deadLetterQueueSuffix, fallbackDir, eventsPerPersistedFile)
+	if err != nil {
+		queue.Close()
+		activeQueues.unregister(queueDir)
+		return nil, fmt.Errorf("Error opening/creating dead-letter queue for %s: %v", destinationType, err)
+	}
+
+	logger := logging.DefaultLogger().With("destination_type", destinationType, "storage", storageName)
+	var marshalFailures *marshalFailuresFile
+	if config.MarshalFailurePolicy == adapters.FileMarshalFailurePolicy {
+		marshalFailures = newMarshalFailuresFile(fallbackDir, queueName)
+	}
+
+	return &streamingWorker{
+		destinationType:          destinationType,
+		storageName:              storageName,
+		schemaProcessor:          processor,
+		insert:                   insert,
+		eventQueue:               queue,
+		eventQueueDir:            queueDir,
+		deadLetterQueue:          deadLetterQueue,
+		compactIdle:              time.Duration(config.QueueCompactIdleSec) * time.Second,
+		batchSize:                config.BatchSize,
+		flushInterval:            time.Duration(config.FlushIntervalMs) * time.Millisecond,
+		insertBackoff:            newBackoff(time.Duration(config.BackoffBaseMs)*time.Millisecond, time.Duration(config.BackoffMaxMs)*time.Millisecond),
+		maxAttempts:              config.MaxProcessingAttempts,
+		shutdownTimeout:          time.Duration(config.ShutdownTimeoutMs) * time.Millisecond,
+		maxQueueSize:             config.MaxQueueSize,
+		maxQueueBytes:            config.MaxQueueBytes,
+		blockWhenFull:            config.QueueFullPolicy == adapters.BlockQueuePolicy,
+		maxEventBytes:            config.MaxEventBytes,
+		truncateOversized:        config.OversizedEventPolicy == adapters.TruncateOversizedPolicy,
+		marshalFailures:          marshalFailures,
+		healthMaxQueueSize:       config.HealthMaxQueueSize,
+		pending:                  newPendingFacts(queue.Size()),
+		metrics:                  metrics.NewStreaming(destinationType, storageName),
+		stats:                    &storageStats{},
+		logger:                   logger,
+		reenqueueSummary:         logging.NewSummary(logger, "Re-enqueued events after insert failures"),
+		processingFailureSummary: logging.NewSummary(logger, "Unable to process events"),
+		drainWorkers:             1,
+		closed:                   make(chan struct{}),
+		done:                     make(chan struct{}),
+	}, nil
+}
+
+//Consume events.Fact and enqueue it (see ConsumeCtx)
+func (sw *streamingWorker) Consume(fact events.Fact) {
+	sw.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx enqueue events.Fact
+//Facts aren't accepted after Close() call
+//If the queue is full: fact is dropped (reject policy) or call is blocked until the queue has free space
+//or ctx is done (block policy)
+//Return error if fact hasn't been enqueued: closed storage, full queue, marshaling or queue error (e.g. disk is full)
+//Not enqueued facts are logged and counted in metrics
+func (sw *streamingWorker) ConsumeCtx(ctx context.Context, fact events.Fact) error {
+	select {
+	case <-sw.closed:
+		sw.logSkippedEvent(fact, errStorageClosed)
+		return errStorageClosed
+	default:
+	}
+
+	if sw.queueFull() {
+		if !sw.blockWhenFull {
+			sw.metrics.Rejected.Inc()
+			atomic.AddUint64(&sw.stats.rejected, 1)
+			return errQueueFull
+		}
+		if err := sw.waitForQueueSpace(ctx); err != nil {
+			sw.logSkippedEvent(fact, err)
+			return err
+		}
+	}
+
+	_, span := tracing.Start(ctx, "enqueue")
+	if err := sw.enqueue(fact, 0, time.Now(), span.SpanContext().TraceParent()); err != nil {
+		span.End(err)
+		return err
+	}
+	span.End(nil)
+	sw.metrics.Enqueued.Inc()
+
+	return nil
+}
+
+//Return true if count of queued facts or queue files size exceeds configured limit
+//Log only transitions between full and not full states
+//Facts which are enqueued one more time after failures aren't limited
+func (sw *streamingWorker) queueFull() bool {
+	full := (sw.maxQueueSize > 0 && sw.eventQueue.Size() >= sw.maxQueueSize) ||
+		(sw.maxQueueBytes > 0 && sw.queueDiskUsage() >= sw.maxQueueBytes)
+
+	if full {
+		if atomic.CompareAndSwapInt32(&sw.full, 0, 1) {
+			sw.logger.Warn("Queue is full. New events are blocked (block policy) or rejected (reject policy)",
+				"max_queue_size", sw.maxQueueSize, "max_queue_bytes", sw.maxQueueBytes)
+		}
+	} else if atomic.CompareAndSwapInt32(&sw.full, 1, 0) {
+		sw.logger.Info("Queue has free space. New events are accepted")
+	}
+
+	return full
+}
+
+//Return cached queue files size (see QueueStats) and recalculate it if cache is expired
+func (sw *streamingWorker) queueDiskUsage() int64 {
+	sw.diskUsageMutex.Lock()
+	defer sw.diskUsageMutex.Unlock()
+
+	if time.Since(sw.diskUsageCheckedAt) >= queueDiskUsageTTL {
+		_, sw.diskUsage = sw.QueueStats()
+		sw.diskUsageCheckedAt = time.Now()
+	}
+
+	return sw.diskUsage
+}
+
+//Block until the queue has free space
+//Return errStorageClosed if storage is closed or ctx.Err() if ctx is done during waiting
+func (sw *streamingWorker) waitForQueueSpace(ctx context.Context) error {
+	for {
+		select {
+		case <-sw.closed:
+			return errStorageClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(idleQueuePollInterval):
+			if !sw.queueFull() {
+				return nil
+			}
+		}
+	}
+}
+
+//Marshaling events.Fact to json bytes and put it to persistent queue
+//Return error if fact has been skipped or dead-lettered (oversized one)
+func (sw *streamingWorker) enqueue(fact events.Fact, attempts int, enqueuedAt time.Time, traceParent string) error {
+	queuedFact, err := sw.queuedFact(fact, attempts, enqueuedAt, traceParent)
+	if err != nil {
+		return err
+	}
+	if err := sw.put(queuedFact); err != nil {
+		sw.logSkippedEvent(fact, err)
+		return err
+	}
+
+	return nil
+}
+
+//Return queue record with json marshaled fact
+//Return error if fact has been skipped (can't be marshaled) or dead-lettered (oversized one)
+func (sw *streamingWorker) queuedFact(fact events.Fact, attempts int, enqueuedAt time.Time, traceParent string) (QueuedFact, error) {
+	factBytes, err := json.Marshal(fact)
+	if err != nil {
+		err = fmt.Errorf("Error marshalling events fact: %v", err)
+		sw.skipUnmarshalable(fact, err)
+		return QueuedFact{}, err
+	}
+	if sw.maxEventBytes > 0 && len(factBytes) > sw.maxEventBytes {
+		if factBytes, err = sw.handleOversized(fact, len(factBytes), attempts); err != nil {
+			return QueuedFact{}, err
+		}
+	}
+
+	return QueuedFact{FactBytes: factBytes, Attempts: attempts, EnqueuedAt: enqueuedAt, TraceParent: traceParent}, nil
+}
+
+//Put record to the event queue
+func (sw *streamingWorker) put(queuedFact QueuedFact) error {
+	//fact is counted before it is put to the queue: drain goroutines may dequeue and handle it before Enqueue returns
+	sw.pending.add(1)
+	if err := sw.eventQueue.Enqueue(queuedFact); err != nil {
+		sw.pending.done(1)
+		return fmt.Errorf("Error putting event fact bytes to the %s queue: %v", sw.destinationType, err)
+	}
+	sw.metrics.QueueDepth.Set(float64(sw.eventQueue.Size()))
+
+	return nil
+}
+
+//Truncate oversized fact (truncate policy) or put it to the dead-letter queue
+//Return truncated fact bytes or error if fact has been dead-lettered
+func (sw *streamingWorker) handleOversized(fact events.Fact, size, attempts int) ([]byte, error) {
+	sw.metrics.Oversized.Inc()
+	reason := fmt.Errorf("Event size %d bytes exceeds max_event_bytes %d", size, sw.maxEventBytes)
+	if sw.truncateOversized {
+		truncated, err := truncateObject(fact, sw.maxEventBytes)
+		if err == nil {
+			sw.logger.Debug("Oversized event has been truncated", "size", size, "max_event_bytes", sw.maxEventBytes)
+			return truncated, nil
+		}
+		reason = fmt.Errorf("%v: %v", reason, err)
+	}
+
+	sw.deadLetter(fact, attempts, reason)
+	return nil, reason
+}
+
+//Enqueue fact one more time after failure
+func (sw *streamingWorker) reenqueue(fact events.Fact, attempts int, enqueuedAt time.Time, traceParent string) {
+	if sw.enqueue(fact, attempts, enqueuedAt, traceParent) == nil {
+		sw.metrics.Reenqueued.Inc()
+		atomic.AddUint64(&sw.stats.reenqueued, 1)
+	}
+}
+
+//OnProcessingFailure set hook which is called on every failed fact processing attempt. nil - remove hook
+//Hook is called from drain goroutines so it shouldn't block
+func (sw *streamingWorker) OnProcessingFailure(hook ProcessingFailureHook) {
+	sw.hookMutex.Lock()
+	sw.onProcessingFailure = hook
+	sw.hookMutex.Unlock()
+}
+
+//Increment processing attempts, notify hook and enqueue fact one more time or
+//put it to the dead-letter queue if max attempts count is exceeded
+func (sw *streamingWorker) retryProcessing(df *dequeuedFact, reason error) {
+	attempts := df.attempts + 1
+	sw.metrics.ProcessingFailures.Inc()
+
+	sw.hookMutex.RLock()
+	hook := sw.onProcessingFailure
+	sw.hookMutex.RUnlock()
+	if hook != nil {
+		hook(df.fact, reason, attempts)
+	}
+
+	if attempts < sw.maxAttempts {
+		sw.reenqueue(df.fact, attempts, df.enqueuedAt, df.traceParent)
+		return
+	}
+
+	sw.deadLetter(df.fact, attempts, reason)
+}
+
+//DeadLetter put rejected fact (e.g. invalid one) to the dead-letter queue without processing attempts
+//implements events.DeadLetterer
+func (sw *streamingWorker) DeadLetter(fact events.Fact, reason error) {
+	sw.deadLetter(fact, 0, reason)
+}
+
+//Put fact to the dead-letter queue
+func (sw *streamingWorker) deadLetter(fact events.Fact, attempts int, reason error) {
+	sw.logger.Warn("Object wasn't processed. It will be put to the dead-letter queue", "object", fact, "attempts", attempts, "error", reason)
+	factBytes, err := json.Marshal(fact)
+	if err != nil {
+		sw.skipUnmarshalable(fact, fmt.Errorf("Error marshalling events fact: %v", err))
+		return
+	}
+	queuedFact := QueuedFact{FactBytes: factBytes, Attempts: attempts, EnqueuedAt: time.Now()}
+	if reason != nil {
+		queuedFact.Reason = reason.Error()
+	}
+	if err := sw.deadLetterQueue.Enqueue(queuedFact); err != nil {
+		sw.logSkippedEvent(fact, fmt.Errorf("Error putting event fact bytes to the %s dead-letter queue: %v", sw.destinationType, err))
+		return
+	}
+	sw.metrics.DeadLettered.Inc()
+	atomic.AddUint64(&sw.stats.deadLettered, 1)
+}
+
+//Put queued record which bytes can't be decoded to the dead-letter queue as is (bytes aren't changed)
+func (sw *streamingWorker) deadLetterCorrupted(queuedFact QueuedFact, reason error) {
+	sw.metrics.Corrupted.Inc()
+	sw.logger.Error("Queued record is corrupted. It will be put to the dead-letter queue", "bytes", string(queuedFact.FactBytes), "error", reason)
+	queuedFact.Reason = reason.Error()
+	if err := sw.deadLetterQueue.Enqueue(queuedFact); err != nil {
+		sw.logger.Error("Corrupted record has been lost: error putting it to the dead-letter queue", "bytes", string(queuedFact.FactBytes), "error", err)
+		sw.metrics.Skipped.Inc()
+		atomic.AddUint64(&sw.stats.skipped, 1)
+		return
+	}
+	sw.metrics.DeadLettered.Inc()
+	atomic.AddUint64(&sw.stats.deadLettered, 1)
+}
+
+//Flush block until all queued facts (including ones which are being inserted by drain goroutines) have been
+//inserted, dead-lettered or skipped. Facts which are consumed during the call are waited too
+//Drain goroutines notify about empty queue: the queue isn't polled. Failed inserts are retried so Flush may wait
+//until destination is available again
+//Return ctx.Err() if ctx is done or errStorageClosed if storage is closed before
+func (sw *streamingWorker) Flush(ctx context.Context) error {
+	return sw.pending.wait(ctx, sw.closed, errStorageClosed)
+}
+
+//Stats return snapshot of storage counters, last error and insert latency
+//It is cheap and is safe for concurrent calls with drain goroutines
+func (sw *streamingWorker) Stats() StorageStats {
+	return sw.stats.snapshot()
+}
+
+//QueueStats return count of queued facts and approximate size of the queue segment files on disk
+//size is best-effort: dque doesn't count facts in segments exactly and cheaply
+//(e.g. facts which have been dequeued but not yet removed from a segment file are still on disk)
+func (sw *streamingWorker) QueueStats() (size int, diskBytes int64) {
+	size = sw.eventQueue.Size()
+
+	diskBytes, err := sw.eventQueue.DiskUsage()
+	if err != nil {
+		sw.logger.Error("Error calculating queue disk usage", "dir", sw.eventQueueDir, "error", err)
+	}
+
+	return
+}
+
+//queueHealth return error if storage is closed or count of queued facts exceeds health threshold
+func (sw *streamingWorker) queueHealth() error {
+	select {
+	case <-sw.closed:
+		return errStorageClosed
+	default:
+	}
+
+	if size := sw.eventQueue.Size(); sw.healthMaxQueueSize > 0 && size > sw.healthMaxQueueSize {
+		return fmt.Errorf("%s queue size %d exceeds health threshold %d", sw.destinationType, size, sw.healthMaxQueueSize)
+	}
+
+	return nil
+}
+
+//DeadLettered return count of facts in the dead-letter queue
+func (sw *streamingWorker) DeadLettered() int {
+	return sw.deadLetterQueue.Size()
+}
+
+//RequeueDeadLettered move all facts from the dead-letter queue back to the main queue with reset attempts counter
+//Should be called after fixing the reason of processing failures (e.g. db schema)
+//Corrupted records (which can't be decoded or are empty) stay in the dead-letter queue. Oversized facts are put
+//to the dead-letter queue again (see max_event_bytes). If a fact can't be put to the main queue it is put back to
+//the dead-letter queue and error is returned
+//Return count of moved facts
+func (sw *streamingWorker) RequeueDeadLettered() (int, error) {
+	requeued := 0
+	//corrupted records are put back to the tail: read only records which were queued before the call
+	for size := sw.deadLetterQueue.Size(); size > 0; size-- {
+		iface, err := sw.deadLetterQueue.Dequeue()
+		if err == dque.ErrEmpty {
+			return requeued, nil
+		}
+		if err != nil {
+			return requeued, fmt.Errorf("Error reading event fact from %s dead-letter queue: %v", sw.destinationType, err)
+		}
+
+		record := toQueuedFact(iface)
+		if len(record.FactBytes) == 0 {
+			sw.deadLetterCorrupted(record, errors.New("Dead-lettered record is empty or isn't a QueuedFact instance"))
+			continue
+		}
+		df, ok := sw.unwrap(iface)
+		if !ok {
+			continue
+		}
+		queuedFact, err := sw.queuedFact(df.fact, 0, time.Now(), df.traceParent)
+		if err != nil {
+			//skipped or dead-lettered again
+			continue
+		}
+		if err := sw.put(queuedFact); err != nil {
+			if restoreErr := sw.deadLetterQueue.Enqueue(record); restoreErr != nil {
+				sw.logSkippedEvent(df.fact, fmt.Errorf("%v. Error putting it back to the dead-letter queue: %v", err, restoreErr))
+			}
+			return requeued, err
+		}
+		sw.metrics.Enqueued.Inc()
+		requeued++
+	}
+
+	return requeued, nil
+}
+
+//Run drainWorkers goroutines. Every goroutine:
+//1. read batch from queue
+//2. insert in destination grouped by tables
+//3. if error => enqueue one more time and sleep with exponential backoff
+//Facts order isn't kept between goroutines. Goroutines exit after Close() call when current batches are processed
+func (sw *streamingWorker) start() {
+	var wg sync.WaitGroup
+	for i := 0; i < sw.drainWorkers; i++ {
+		wg.Add(1)
+		//every goroutine has own backoff: failures of one of them don't delay others
+		workerBackoff := newBackoff(sw.insertBackoff.base, sw.insertBackoff.max)
+		go func() {
+			defer wg.Done()
+			sw.drain(workerBackoff)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(sw.done)
+	}()
+
+	if sw.compactIdle > 0 {
+		go sw.compactWhenIdle()
+	}
+}
+
+//Compact the event queue when it has been empty and nothing has been enqueued during compactIdle (checked on every tick)
+func (sw *streamingWorker) compactWhenIdle() {
+	ticker := time.NewTicker(sw.compactIdle)
+	defer ticker.Stop()
+
+	wasEmpty := false
+	lastEnqueued := sw.eventQueue.Enqueued()
+	for {
+		select {
+		case <-sw.closed:
+			return
+		case <-ticker.C:
+		}
+
+		empty := sw.eventQueue.Size() == 0
+		enqueued := sw.eventQueue.Enqueued()
+		if wasEmpty && empty && enqueued == lastEnqueued {
+			sw.compactQueue(sw.eventQueue)
+		}
+		wasEmpty, lastEnqueued = empty, enqueued
+	}
+}
+
+//Compact remove files of the event queue and the dead-letter queue if they are empty (see compactingQueue)
+//so disk usage is proportional to the actual backlog. Not empty queues are skipped
+func (sw *streamingWorker) Compact() error {
+	var multiErr error
+	for _, queue := range []*compactingQueue{sw.eventQueue, sw.deadLetterQueue} {
+		if err := sw.compactQueue(queue); err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return multiErr
+}
+
+func (sw *streamingWorker) compactQueue(queue *compactingQueue) error {
+	before, _ := queue.DiskUsage()
+	compacted, err := queue.Compact()
+	if err != nil {
+		sw.logger.Error("Error compacting queue", "queue", queue.name, "error", err)
+		return err
+	}
+	if compacted {
+		sw.diskUsageMutex.Lock()
+		sw.diskUsageCheckedAt = time.Time{}
+		sw.diskUsageMutex.Unlock()
+		after, _ := queue.DiskUsage()
+		sw.logger.Info("Empty queue has been compacted", "queue", queue.name, "freed_bytes", before-after)
+	}
+
+	return nil
+}
+
+//Insert batches until Close() call. Queued facts aren't drained after it: they are flushed by Close()
+//during shutdown timeout or remain in the queue
+func (sw *streamingWorker) drain(insertBackoff *backoff) {
+	for {
+		select {
+		case <-sw.closed:
+			return
+		default:
+		}
+		if appstatus.Instance.IsIdle() {
+			return
+		}
+		facts, err := sw.dequeueBatch(true)
+		if err == errStorageClosed || err == errAppIdle || err == dque.ErrQueueClosed {
+			return
+		}
+		if err != nil {
+			sw.logger.Error("Error reading event fact from queue", "error", err)
+			continue
+		}
+		sw.metrics.Dequeued.Add(float64(len(facts)))
+		sw.metrics.QueueDepth.Set(float64(sw.eventQueue.Size()))
+
+		succeeded, failed := sw.storeBatch(facts)
+		//failed facts have been enqueued one more time
+		sw.pending.done(len(facts))
+		if failed > 0 {
+			delay := insertBackoff.fail()
+			sw.logger.Warn("Insert failures", "consecutive_failures", insertBackoff.consecutiveFailures(), "next_attempt_in", delay)
+			select {
+			case <-sw.closed:
+			case <-time.After(delay):
+			}
+		} else if succeeded > 0 {
+			insertBackoff.reset()
+		}
+	}
+}
+
+//Read up to batchSize facts from the queue
+//if wait: block until at least one fact is in the queue (or storage is closed) and
+//read facts until flushInterval elapses
+//otherwise: read facts until the queue is empty
+func (sw *streamingWorker) dequeueBatch(wait bool) ([]*dequeuedFact, error) {
+	var facts []*dequeuedFact
+	if wait {
+		iface, err := sw.dequeueWait()
+		if err != nil {
+			return nil, err
+		}
+
+		if fact, ok := sw.unwrap(iface); ok {
+			facts = append(facts, fact)
+		} else {
+			sw.pending.done(1)
+		}
+	}
+
+	deadline := time.Now().Add(sw.flushInterval)
+	for len(facts) < sw.batchSize && (!wait || time.Now().Before(deadline)) {
+		iface, err := sw.eventQueue.Dequeue()
+		if err == dque.ErrEmpty {
+			if !wait {
+				break
+			}
+			time.Sleep(emptyQueuePollInterval)
+			continue
+		}
+		if err != nil {
+			if err != dque.ErrQueueClosed {
+				sw.logger.Error("Error reading event fact from queue", "error", err)
+			}
+			break
+		}
+
+		if fact, ok := sw.unwrap(iface); ok {
+			facts = append(facts, fact)
+		} else {
+			sw.pending.done(1)
+		}
+	}
+
+	return facts, nil
+}
+
+//Poll the queue until a fact is available, storage is closed or application becomes idle
+//(so drain goroutines exit without waiting for the next event)
+func (sw *streamingWorker) dequeueWait() (interface{}, error) {
+	for {
+		iface, err := sw.eventQueue.Dequeue()
+		if err != dque.ErrEmpty {
+			return iface, err
+		}
+
+		select {
+		case <-sw.closed:
+			return nil, errStorageClosed
+		case <-appstatus.Instance.Done():
+			return nil, errAppIdle
+		case <-time.After(idleQueuePollInterval):
+		}
+	}
+}
+
+//Unwrap dequeued object into events.Fact with processing attempts count and enqueue time
+func (sw *streamingWorker) unwrap(iface interface{}) (*dequeuedFact, bool) {
+	wrappedFact := toQueuedFact(iface)
+	if len(wrappedFact.FactBytes) == 0 {
+		sw.logger.Warn("Dequeued object is not a QueuedFact instance or wrapped events.Fact bytes is empty")
+		return nil, false
+	}
+	//records written before enqueue time was introduced
+	if wrappedFact.EnqueuedAt.IsZero() {
+		wrappedFact.EnqueuedAt = time.Now()
+	}
+
+	fact := events.Fact{}
+	if err := events.DecodeJSON(wrappedFact.FactBytes, &fact); err != nil {
+		//bytes have been marshaled by eventnative: corrupted file or incompatible version. Keep them for investigation
+		sw.deadLetterCorrupted(wrappedFact, fmt.Errorf("Error unmarshalling events.Fact from bytes: %v", err))
+		return nil, false
+	}
+
+	return &dequeuedFact{fact: fact, attempts: wrappedFact.Attempts, enqueuedAt: wrappedFact.EnqueuedAt, traceParent: wrappedFact.TraceParent}, true
+}
+
+//Return dequeued object as QueuedFact. Empty record if object isn't a QueuedFact instance
+//dque returns objects enqueued in this process as is (QueuedFact) and objects loaded from disk as QueuedFactBuilder
+//results (*QueuedFact)
+func toQueuedFact(iface interface{}) QueuedFact {
+	switch v := iface.(type) {
+	case QueuedFact:
+		return v
+	case *QueuedFact:
+		if v != nil {
+			return *v
+		}
+	}
+
+	return QueuedFact{}
+}
+
+//Process facts, group them by table name and insert every group with one insertFunc call
+//if processing error => enqueue fact one more time or put it to the dead-letter queue
+//if insert error => bisect the group to isolate bad rows (see storeFailed)
+//Return count of succeeded and failed inserted groups. Group is failed if nothing has been inserted from it
+func (sw *streamingWorker) storeBatch(facts []*dequeuedFact) (succeeded, failed int) {
+	batches := sw.groupByTable(facts)
+	for tableName, batch := range batches {
+		inserted, latency, err := sw.tryInsert(tableName, batch)
+		if err != nil {
+			sw.logger.Debug("Error inserting objects", "table", tableName, "objects", len(batch.flattenObjects), "error", err)
+			inserted = sw.storeFailed(tableName, batch, err, latency, false)
+		}
+		if inserted {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+
+	return
+}
+
+//Insert batch with one insertFunc call. Inserted and partially inserted batches are handled here
+//Return true if at least one object has been inserted and insert latency
+//Return insert error (not partial one) if the whole batch has failed: its facts haven't been handled
+func (sw *streamingWorker) tryInsert(tableName string, batch *tableBatch) (bool, time.Duration, error) {
+	start := time.Now()
+	err := sw.insert(batch.dataSchema, batch.flattenObjects)
+	latency := time.Since(start)
+	sw.metrics.InsertLatency.Observe(latency.Seconds())
+	if partialErr, ok := err.(*adapters.PartialInsertError); ok {
+		sw.storePartial(tableName, batch, partialErr, start, latency)
+		return len(partialErr.Failed)+len(partialErr.Rejected) < len(batch.flattenObjects), latency, nil
+	}
+	if err != nil {
+		return false, latency, err
+	}
+
+	for _, df := range batch.sourceFacts {
+		_, span := tracing.StartAt(df.traceContext(), "insert", start)
+		span.End(nil)
+	}
+	sw.metrics.Inserted.Add(float64(len(batch.flattenObjects)))
+	sw.stats.insertSucceeded(len(batch.flattenObjects), latency)
+
+	return true, latency, nil
+}
+
+//Handle batch which has failed as a whole: a single bad row (e.g. with a value which can't be cast to the column type)
+//fails every batch it is in. The batch is split in halves which are inserted separately, failed halves are split
+//further until bad rows are isolated. If both halves fail and nothing has been inserted (destination is unavailable)
+//or a single row fails, facts are retried as processing failures: they are put to the dead-letter queue after
+//max_processing_attempts
+//available is true if other objects of the group have been inserted
+//Return true if at least one object has been inserted
+func (sw *streamingWorker) storeFailed(tableName string, batch *tableBatch, err error, latency time.Duration, available bool) bool {
+	if len(batch.sourceFacts) > 1 {
+		left, right := batch.split()
+		leftInserted, leftLatency, leftErr := sw.tryInsert(tableName, left)
+		rightInserted, rightLatency, rightErr := sw.tryInsert(tableName, right)
+		if available || leftErr == nil || rightErr == nil {
+			if leftErr != nil {
+				leftInserted = sw.storeFailed(tableName, left, leftErr, leftLatency, true)
+			}
+			if rightErr != nil {
+				rightInserted = sw.storeFailed(tableName, right, rightErr, rightLatency, true)
+			}
+			return leftInserted || rightInserted
+		}
+		err = leftErr
+		latency = leftLatency
+	}
+
+	start := time.Now().Add(-latency)
+	for _, df := range batch.sourceFacts {
+		_, span := tracing.StartAt(df.traceContext(), "insert", start)
+		span.End(err)
+	}
+	sw.stats.insertFailed(len(batch.flattenObjects), latency, err)
+	for _, df := range batch.sourceFacts {
+		sw.logger.Debug("Object will be retried", "object", df.fact, "table", tableName, "attempt", df.attempts+1)
+		sw.retryProcessing(df, err)
+	}
+	sw.reenqueueSummary.Add(err.Error(), len(batch.sourceFacts))
+
+	return false
+}
+
+//Handle batch which has been inserted partially (e.g. with savepoints): other rows have been committed so only failed
+//ones are retried. Failures of single rows are caused by their data: they are counted as processing attempts
+//and failed facts are put to the dead-letter queue after max_processing_attempts. Rejected rows (e.g. with new columns
+//in strict schema mode) can't be inserted on retry: they are put to the dead-letter queue right away
+func (sw *streamingWorker) storePartial(tableName string, batch *tableBatch, partialErr *adapters.PartialInsertError, start time.Time, latency time.Duration) {
+	failed := map[int]bool{}
+	for _, i := range partialErr.Failed {
+		failed[i] = true
+	}
+	rejected := map[int]bool{}
+	for _, i := range partialErr.Rejected {
+		rejected[i] = true
+	}
+	for i, df := range batch.sourceFacts {
+		_, span := tracing.StartAt(df.traceContext(), "insert", start)
+		if failed[i] {
+			span.End(partialErr.Err)
+		} else if rejected[i] {
+			span.End(partialErr.RejectErr)
+		} else {
+			span.End(nil)
+		}
+	}
+
+	inserted := len(batch.flattenObjects) - len(failed) - len(rejected)
+	sw.metrics.Inserted.Add(float64(inserted))
+	sw.stats.insertSucceeded(inserted, latency)
+
+	for _, i := range partialErr.Rejected {
+		df := batch.sourceFacts[i]
+		sw.deadLetter(df.fact, df.attempts+1, partialErr.RejectErr)
+	}
+
+	if len(failed) == 0 {
+		return
+	}
+	sw.stats.insertFailed(len(failed), latency, partialErr)
+	sw.logger.Debug("Objects haven't been inserted", "table", tableName, "objects", len(failed), "error", partialErr.Err)
+	for _, i := range partialErr.Failed {
+		df := batch.sourceFacts[i]
+		sw.logger.Debug("Object will be retried", "object", df.fact, "table", tableName, "attempt", df.attempts+1)
+		sw.retryProcessing(df, partialErr.Err)
+	}
+	sw.reenqueueSummary.Add(partialErr.Err.Error(), len(failed))
+}
+
+//Return facts processed with schema.Processor and grouped by table name
+//or all facts as is in one group with empty table schema if processor isn't configured
+func (sw *streamingWorker) groupByTable(facts []*dequeuedFact) map[string]*tableBatch {
+	batches := map[string]*tableBatch{}
+	if sw.schemaProcessor == nil {
+		if len(facts) > 0 {
+			batch := &tableBatch{dataSchema: &schema.Table{Columns: schema.Columns{}}}
+			for _, df := range facts {
+				batch.flattenObjects = append(batch.flattenObjects, df.fact)
+				batch.sourceFacts = append(batch.sourceFacts, df)
+			}
+			batches[""] = batch
+		}
+		return batches
+	}
+
+	for _, df := range facts {
+		ctx := df.traceContext()
+		//time in the queue (including previous failed attempts)
+		_, queueSpan := tracing.StartAt(ctx, "queue", df.enqueuedAt)
+		queueSpan.End(nil)
+
+		_, processSpan := tracing.Start(ctx, "process")
+		dataSchema, flattenObject, err := sw.schemaProcessor.ProcessFact(df.fact)
+		processSpan.End(err)
+		if err != nil {
+			sw.logger.Debug("Unable to process object", "object", df.fact, "attempt", df.attempts+1, "error", err)
+			sw.processingFailureSummary.Add(err.Error(), 1)
+			sw.retryProcessing(df, err)
+			continue
+		}
+
+		//don't process empty object
+		if !dataSchema.Exists() {
+			continue
+		}
+
+		batch, ok := batches[dataSchema.Name]
+		if !ok {
+			batch = &tableBatch{dataSchema: dataSchema}
+			batches[dataSchema.Name] = batch
+		} else {
+			batch.dataSchema.Columns.Merge(dataSchema.Columns)
+		}
+		batch.flattenObjects = append(batch.flattenObjects, flattenObject)
+		batch.sourceFacts = append(batch.sourceFacts, df)
+	}
+
+	return batches
+}
+
+//Close stop accepting new facts, wait for all drain goroutines (they finish their current batches) and flush queued
+//facts to the destination during shutdown timeout. Then close queues: they are never closed while facts are being inserted
+//so dequeued facts can be re-enqueued. Not flushed facts remain in the persistent queue and will be processed after restart
+func (sw *streamingWorker) Close() (multiErr error) {
+	sw.closeOnce.Do(func() {
+		deadline := time.Now().Add(sw.shutdownTimeout)
+		sw.shutdownDeadline = deadline
+		close(sw.closed)
+
+		select {
+		case <-sw.done:
+		case <-time.After(sw.shutdownTimeout):
+			sw.logger.Warn("Drain goroutines haven't finished their current batches during shutdown timeout. Waiting for them",
+				"shutdown_timeout", sw.shutdownTimeout)
+			<-sw.done
+		}
+		sw.flush(deadline)
+
+		sw.reenqueueSummary.Flush()
+		sw.processingFailureSummary.Flush()
+
+		if notFlushed := sw.eventQueue.Size(); notFlushed > 0 {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("%d events weren't flushed to %s during shutdown timeout %v", notFlushed, sw.destinationType, sw.shutdownTimeout))
+		}
+
+		if err := sw.eventQueue.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing %s event queue: %v", sw.destinationType, err))
+		}
+		if err := sw.deadLetterQueue.Close(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("Error closing %s dead-letter queue: %v", sw.destinationType, err))
+		}
+		activeQueues.unregister(sw.eventQueueDir)
+	})
+
+	return
+}
+
+//Notify about patched table schema (see notifySchemaChange)
+func (sw *streamingWorker) notifySchemaChange(diff *schema.Table) {
+	notifySchemaChange(sw.destinationType, sw.storageName, diff)
+}
+
+//Insert all queued facts until the queue is empty or deadline is reached
+func (sw *streamingWorker) flush(deadline time.Time) {
+	for time.Now().Before(deadline) {
+		facts, err := sw.dequeueBatch(false)
+		if err != nil {
+			sw.logger.Error("Error reading event fact from queue", "error", err)
+			return
+		}
+		if len(facts) == 0 {
+			return
+		}
+		sw.metrics.Dequeued.Add(float64(len(facts)))
+
+		_, failed := sw.storeBatch(facts)
+		sw.pending.done(len(facts))
+		if failed > 0 {
+			delay := sw.insertBackoff.fail()
+			if left := time.Until(deadline); delay > left {
+				delay = left
+			}
+			time.Sleep(delay)
+		}
+	}
+}
+
+//Count and skip fact which can't be marshaled to json. Write it to the marshal failures file if file policy is configured
+func (sw *streamingWorker) skipUnmarshalable(fact events.Fact, err error) {
+	sw.metrics.MarshalFailures.Inc()
+	if sw.marshalFailures != nil {
+		if writeErr := sw.marshalFailures.Write(fact, err); writeErr != nil {
+			sw.logger.Error("Error writing object to marshal failures file", "error", writeErr)
+		} else {
+			sw.logger.Warn("Object can't be marshaled. It has been written to marshal failures file", "path", sw.marshalFailures.path)
+		}
+	}
+	sw.logSkippedEvent(fact, err)
+}
+
+func (sw *streamingWorker) logSkippedEvent(fact events.Fact, err error) {
+	sw.metrics.Skipped.Inc()
+	atomic.AddUint64(&sw.stats.skipped, 1)
+	sw.logger.Warn("Unable to enqueue object. This object will be skipped", "object", fact, "error", err)
+}
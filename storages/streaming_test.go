@@ -0,0 +1,288 @@
+package storages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ksensehq/eventnative/adapters"
+	"github.com/ksensehq/eventnative/appconfig"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//fakeInserter records inserted objects by table. The first failures inserts fail (destination is unavailable),
+//batches with poison objects always fail (bad row)
+type fakeInserter struct {
+	mutex    sync.Mutex
+	failures int
+	inserts  int
+	batches  []int
+	inserted map[string][]events.Fact
+}
+
+func newFakeInserter(failures int) *fakeInserter {
+	return &fakeInserter{failures: failures, inserted: map[string][]events.Fact{}}
+}
+
+func (fi *fakeInserter) insert(dataSchema *schema.Table, objects []events.Fact) error {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+
+	fi.inserts++
+	if fi.failures > 0 {
+		fi.failures--
+		return errors.New("connection refused")
+	}
+	for _, object := range objects {
+		if _, ok := object["poison"]; ok {
+			return errors.New("invalid input syntax for type integer")
+		}
+	}
+	fi.batches = append(fi.batches, len(objects))
+	fi.inserted[dataSchema.Name] = append(fi.inserted[dataSchema.Name], objects...)
+
+	return nil
+}
+
+func (fi *fakeInserter) setFailures(failures int) {
+	fi.mutex.Lock()
+	fi.failures = failures
+	fi.mutex.Unlock()
+}
+
+func newTestStreamingWorker(t *testing.T, storageName string, config adapters.StreamingConfig, processor *schema.Processor,
+	insert insertFunc) (*streamingWorker, func()) {
+	if appconfig.Instance == nil {
+		appconfig.Instance = &appconfig.AppConfig{ServerName: "test"}
+	}
+	dir, err := ioutil.TempDir("", "streaming_test")
+	require.NoError(t, err)
+
+	sw, err := newStreamingWorker("postgres", storageName, dir, config, processor, insert)
+	require.NoError(t, err)
+
+	return sw, func() {
+		sw.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func flushStreamingWorker(t *testing.T, sw *streamingWorker) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, sw.Flush(ctx))
+}
+
+func TestCloseWaitsForDrainWorkers(t *testing.T) {
+	if appconfig.Instance == nil {
+		appconfig.Instance = &appconfig.AppConfig{ServerName: "test"}
+	}
+	dir, err := ioutil.TempDir("", "streaming_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	const drainWorkers = 3
+	var running, started int64
+	startedCh := make(chan struct{}, drainWorkers)
+	//slow failing destination: all facts are re-enqueued
+	insert := func(dataSchema *schema.Table, objects []events.Fact) error {
+		atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		if atomic.AddInt64(&started, 1) <= drainWorkers {
+			startedCh <- struct{}{}
+		}
+		time.Sleep(200 * time.Millisecond)
+		return errors.New("connection refused")
+	}
+	config := adapters.StreamingConfig{BatchSize: 1, FlushIntervalMs: 10, BackoffBaseMs: 1000, BackoffMaxMs: 1000,
+		MaxProcessingAttempts: 5, ShutdownTimeoutMs: 10}
+	sw, err := newStreamingWorker("postgres", "pg_close", dir, config, nil, insert)
+	require.NoError(t, err)
+	sw.drainWorkers = drainWorkers
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": i}))
+	}
+	sw.start()
+	for i := 0; i < drainWorkers; i++ {
+		select {
+		case <-startedCh:
+		case <-time.After(time.Second):
+			require.Fail(t, "All drain goroutines must insert concurrently")
+		}
+	}
+
+	//shutdown timeout is exceeded by current inserts
+	require.Error(t, sw.Close(), "Not flushed events must be reported")
+	require.Equal(t, int64(0), atomic.LoadInt64(&running), "Close must wait for current inserts")
+	require.Equal(t, int64(drainWorkers), atomic.LoadInt64(&started), "Queued events mustn't be drained after Close")
+	require.Equal(t, uint64(0), sw.Stats().Skipped, "Failed events must be re-enqueued before queues are closed")
+
+	queue, err := openCompactingQueue(streamingQueueName(appconfig.Instance.ServerName, "postgres", "pg_close"), dir, defaultEventsPerPersistedFile)
+	require.NoError(t, err)
+	defer queue.Close()
+	require.Equal(t, 10, queue.Size(), "Not flushed events must remain in the queue")
+}
+
+func TestStreamingBatching(t *testing.T) {
+	processor, err := schema.NewProcessor("{{.event_type}}", []string{}, schema.ProcessorConfig{})
+	require.NoError(t, err)
+	inserter := newFakeInserter(0)
+	sw, cleanup := newTestStreamingWorker(t, "pg_batching", adapters.StreamingConfig{BatchSize: 4, FlushIntervalMs: 10,
+		BackoffBaseMs: 1, BackoffMaxMs: 1, MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000}, processor, inserter.insert)
+	defer cleanup()
+
+	for i := 0; i < 10; i++ {
+		eventType := "pageview"
+		if i%3 == 0 {
+			eventType = "click"
+		}
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"_timestamp": "2020-08-02T18:23:58.057807Z", "event_type": eventType, "id": i}))
+	}
+	sw.start()
+	flushStreamingWorker(t, sw)
+
+	require.Len(t, inserter.inserted["click"], 4)
+	require.Len(t, inserter.inserted["pageview"], 6)
+	//3 read batches (4, 4, 2 facts): every one is grouped in at most 2 tables
+	require.True(t, len(inserter.batches) >= 3 && len(inserter.batches) <= 6, "Facts must be inserted by batches: %v", inserter.batches)
+	for _, batch := range inserter.batches {
+		require.True(t, batch <= 4, "Batch mustn't exceed batch_size: %v", inserter.batches)
+	}
+	require.Equal(t, uint64(10), sw.Stats().Inserted)
+}
+
+func TestStreamingRetries(t *testing.T) {
+	tests := []struct {
+		name                 string
+		failures             int
+		expectedInserts      int
+		expectedInserted     int
+		expectedDeadLettered int
+	}{
+		{"Inserted after transient failures", 2, 3, 1, 0},
+		{"Dead-lettered after max_processing_attempts", 100, 3, 0, 1},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inserter := newFakeInserter(tt.failures)
+			sw, cleanup := newTestStreamingWorker(t, fmt.Sprintf("pg_retries_%d", i), adapters.StreamingConfig{BatchSize: 1, FlushIntervalMs: 10,
+				BackoffBaseMs: 1, BackoffMaxMs: 1, MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000}, nil, inserter.insert)
+			defer cleanup()
+
+			require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": 1}))
+			sw.start()
+			flushStreamingWorker(t, sw)
+
+			require.Equal(t, tt.expectedInserts, inserter.inserts)
+			require.Len(t, inserter.inserted[""], tt.expectedInserted)
+			require.Equal(t, tt.expectedDeadLettered, sw.DeadLettered())
+			require.Equal(t, uint64(tt.expectedDeadLettered), sw.Stats().DeadLettered)
+		})
+	}
+}
+
+func TestStreamingPoisonRow(t *testing.T) {
+	inserter := newFakeInserter(0)
+	sw, cleanup := newTestStreamingWorker(t, "pg_poison", adapters.StreamingConfig{BatchSize: 8, FlushIntervalMs: 50,
+		BackoffBaseMs: 1, BackoffMaxMs: 1, MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000}, nil, inserter.insert)
+	defer cleanup()
+
+	for i := 0; i < 8; i++ {
+		fact := events.Fact{"id": i}
+		if i == 5 {
+			fact["poison"] = "not a number"
+		}
+		require.NoError(t, sw.ConsumeCtx(context.Background(), fact))
+	}
+	sw.start()
+	flushStreamingWorker(t, sw)
+
+	require.Len(t, inserter.inserted[""], 7, "Bad row mustn't fail other rows")
+	for _, object := range inserter.inserted[""] {
+		require.NotEqual(t, 5, object["id"])
+	}
+	require.Equal(t, 1, sw.DeadLettered(), "Bad row must be dead-lettered after max_processing_attempts")
+	require.Equal(t, uint64(7), sw.Stats().Inserted)
+}
+
+func TestStreamingRequeueDeadLettered(t *testing.T) {
+	inserter := newFakeInserter(100)
+	sw, cleanup := newTestStreamingWorker(t, "pg_requeue", adapters.StreamingConfig{BatchSize: 10, FlushIntervalMs: 10,
+		BackoffBaseMs: 1, BackoffMaxMs: 1, MaxProcessingAttempts: 1, ShutdownTimeoutMs: 1000}, nil, inserter.insert)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": i}))
+	}
+	sw.start()
+	flushStreamingWorker(t, sw)
+	require.Equal(t, 3, sw.DeadLettered())
+	//corrupted record stays in the dead-letter queue
+	require.NoError(t, sw.deadLetterQueue.Enqueue(QueuedFact{}))
+
+	//destination is fixed
+	inserter.setFailures(0)
+	requeued, err := sw.RequeueDeadLettered()
+	require.NoError(t, err)
+	require.Equal(t, 3, requeued)
+	require.Equal(t, 1, sw.DeadLettered(), "Corrupted record must stay in the dead-letter queue")
+	flushStreamingWorker(t, sw)
+
+	require.Len(t, inserter.inserted[""], 3)
+	require.Equal(t, 1, sw.DeadLettered())
+}
+
+func TestStreamingQueueFull(t *testing.T) {
+	config := adapters.StreamingConfig{BatchSize: 10, FlushIntervalMs: 10, BackoffBaseMs: 1, BackoffMaxMs: 1,
+		MaxProcessingAttempts: 3, ShutdownTimeoutMs: 1000, MaxQueueSize: 2}
+
+	t.Run("Reject", func(t *testing.T) {
+		config.QueueFullPolicy = adapters.RejectQueuePolicy
+		sw, cleanup := newTestStreamingWorker(t, "pg_queue_reject", config, nil, newFakeInserter(0).insert)
+		defer cleanup()
+
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": 1}))
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": 2}))
+		require.Equal(t, errQueueFull, sw.ConsumeCtx(context.Background(), events.Fact{"id": 3}))
+		require.Equal(t, uint64(1), sw.Stats().Rejected)
+		require.Equal(t, 2, sw.eventQueue.Size())
+		sw.start()
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		config.QueueFullPolicy = adapters.BlockQueuePolicy
+		inserter := newFakeInserter(0)
+		sw, cleanup := newTestStreamingWorker(t, "pg_queue_block", config, nil, inserter.insert)
+		defer cleanup()
+
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": 1}))
+		require.NoError(t, sw.ConsumeCtx(context.Background(), events.Fact{"id": 2}))
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+		require.Equal(t, context.DeadlineExceeded, sw.ConsumeCtx(ctx, events.Fact{"id": 3}), "Consuming must be blocked until ctx is done")
+
+		//space is freed by drain goroutine
+		consumed := make(chan error, 1)
+		go func() {
+			consumed <- sw.ConsumeCtx(context.Background(), events.Fact{"id": 4})
+		}()
+		sw.start()
+		select {
+		case err := <-consumed:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "Blocked consuming must continue when the queue has free space")
+		}
+		flushStreamingWorker(t, sw)
+		require.Len(t, inserter.inserted[""], 3)
+		require.Equal(t, uint64(0), sw.Stats().Rejected)
+	})
+}
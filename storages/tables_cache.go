@@ -0,0 +1,133 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/schema"
+	"sync"
+)
+
+//tableManager gets, creates and patches tables of destinations without db schemas (see tablesCache.ensureTable)
+type tableManager interface {
+	GetTableSchema(tableName string) (*schema.Table, error)
+	CreateTable(tableSchema *schema.Table) error
+	PatchTableSchema(patchSchema *schema.Table) error
+}
+
+//tablesCache is a thread-safe map of destination table qualified name (schema.table, see schema.Table.QualifiedName) -> table schema
+//Cached schemas are never modified: patched schema is put as a new copy so readers can use it without locks
+type tablesCache struct {
+	mutex  sync.RWMutex
+	tables map[string]*schema.Table
+	//tables are created and patched under this mutex (see ensureTable)
+	ddlMutex sync.Mutex
+}
+
+func newTablesCache() *tablesCache {
+	return &tablesCache{tables: map[string]*schema.Table{}}
+}
+
+//Get return cached table schema by qualified name
+func (tc *tablesCache) Get(name string) (*schema.Table, bool) {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+
+	table, ok := tc.tables[name]
+	return table, ok
+}
+
+//Set put table schema to the cache. Table mustn't be modified after that
+func (tc *tablesCache) Set(table *schema.Table) {
+	tc.mutex.Lock()
+	tc.tables[table.QualifiedName()] = table
+	tc.mutex.Unlock()
+}
+
+//Delete remove table schema from the cache by qualified name
+func (tc *tablesCache) Delete(name string) {
+	tc.mutex.Lock()
+	delete(tc.tables, name)
+	tc.mutex.Unlock()
+}
+
+//Names return all cached table qualified names
+func (tc *tablesCache) Names() []string {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+
+	names := make([]string, 0, len(tc.tables))
+	for name := range tc.tables {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+//ensureTable return cached table schema which contains all dataSchema columns. Get or create table with manager
+//and add new columns if needed. Cached tables which don't need patching are returned without waiting for DDL of
+//other tables. Tables are created and patched under ddlMutex so concurrent inserts (drain_workers > 1) to the same
+//table don't duplicate DDL. onExisting (optional) is called with schema of existing table before it is cached,
+//onPatch is called with added columns. Postgres has its own ensureTable (db schemas, partitions, types widening)
+func (tc *tablesCache) ensureTable(destinationType string, manager tableManager, dataSchema *schema.Table,
+	onExisting func(table *schema.Table) error, onPatch func(diff *schema.Table)) (*schema.Table, error) {
+	if cached, ok := tc.Get(dataSchema.QualifiedName()); ok && !cached.Diff(dataSchema).Exists() {
+		return cached, nil
+	}
+
+	tc.ddlMutex.Lock()
+	defer tc.ddlMutex.Unlock()
+
+	//the table may have been created or patched by another insert while waiting
+	dbTableSchema, ok := tc.Get(dataSchema.QualifiedName())
+	if !ok {
+		//Get or Create Table
+		var err error
+		dbTableSchema, err = manager.GetTableSchema(dataSchema.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting table %s schema from %s: %v", dataSchema.Name, destinationType, err)
+		}
+		if !dbTableSchema.Exists() {
+			//processor's columns aren't cached: cached schemas are never modified
+			newTable := patchedTable(&schema.Table{Name: dataSchema.Name, Schema: dataSchema.Schema}, dataSchema)
+			if err := manager.CreateTable(newTable); err != nil {
+				return nil, fmt.Errorf("Error creating table %s in %s: %v", dataSchema.Name, destinationType, err)
+			}
+			dbTableSchema = newTable
+		} else if onExisting != nil {
+			if err := onExisting(dbTableSchema); err != nil {
+				return nil, err
+			}
+		}
+		//Save
+		tc.Set(dbTableSchema)
+	}
+
+	schemaDiff := dbTableSchema.Diff(dataSchema)
+	//Patch (only new columns are added)
+	if schemaDiff.Exists() {
+		if err := manager.PatchTableSchema(schemaDiff); err != nil {
+			return nil, fmt.Errorf("Error patching table %s in %s: %v", schemaDiff.Name, destinationType, err)
+		}
+		onPatch(schemaDiff)
+		//Save
+		dbTableSchema = patchedTable(dbTableSchema, &schema.Table{Columns: schemaDiff.Columns})
+		tc.Set(dbTableSchema)
+	}
+
+	return dbTableSchema, nil
+}
+
+//Return copy of table with diff columns (new and widened ones)
+func patchedTable(table, diff *schema.Table) *schema.Table {
+	patched := &schema.Table{Name: table.Name, Schema: table.Schema, Columns: make(schema.Columns, len(table.Columns)+len(diff.Columns))}
+	for k, v := range table.Columns {
+		patched.Columns[k] = v
+	}
+	for k, v := range diff.Columns {
+		patched.Columns[k] = v
+	}
+	for k, v := range diff.WidenedColumns {
+		patched.Columns[k] = v
+	}
+
+	return patched
+}
@@ -0,0 +1,170 @@
+package storages
+
+import (
+	"fmt"
+	"github.com/ksensehq/eventnative/schema"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+//Run with -race: concurrent gets, sets and deletes of the same and different tables (see TestPostgresConcurrentInsert)
+func TestTablesCacheConcurrentAccess(t *testing.T) {
+	cache := newTablesCache()
+	tableNames := []string{"events", "events", "pages", "clicks"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				name := tableNames[(i+j)%len(tableNames)]
+				dataSchema := &schema.Table{Name: name, Columns: schema.Columns{fmt.Sprintf("field_%d", j%10): schema.Column{Type: schema.STRING}}}
+
+				cached, ok := cache.Get(name)
+				if !ok {
+					cached = &schema.Table{Name: name, Columns: schema.Columns{}}
+				}
+				if diff := cached.Diff(dataSchema); diff.NeedsPatch() {
+					cache.Set(patchedTable(cached, diff))
+				}
+
+				if j%17 == 0 {
+					cache.Delete(name)
+				}
+				cache.Names()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, name := range cache.Names() {
+		require.Contains(t, tableNames, name)
+		cached, ok := cache.Get(name)
+		require.True(t, ok)
+		require.Equal(t, name, cached.Name)
+	}
+}
+
+func TestPatchedTable(t *testing.T) {
+	table := &schema.Table{Name: "events", Columns: schema.Columns{"a": schema.Column{Type: schema.INT64}}}
+	diff := &schema.Table{Name: "events", Columns: schema.Columns{"b": schema.Column{Type: schema.STRING}},
+		WidenedColumns: schema.Columns{"a": schema.Column{Type: schema.FLOAT64}}}
+
+	patched := patchedTable(table, diff)
+
+	require.Equal(t, schema.Columns{"a": schema.Column{Type: schema.FLOAT64}, "b": schema.Column{Type: schema.STRING}}, patched.Columns)
+	require.Equal(t, schema.Columns{"a": schema.Column{Type: schema.INT64}}, table.Columns, "source table mustn't be modified")
+}
+
+//in-memory tableManager which fails duplicated DDL like SQL databases do
+type fakeTableManager struct {
+	mutex   sync.Mutex
+	tables  map[string]schema.Columns
+	creates int
+	patches int
+}
+
+func (ftm *fakeTableManager) GetTableSchema(tableName string) (*schema.Table, error) {
+	ftm.mutex.Lock()
+	defer ftm.mutex.Unlock()
+
+	table := &schema.Table{Name: tableName, Columns: schema.Columns{}}
+	for column, value := range ftm.tables[tableName] {
+		table.Columns[column] = value
+	}
+
+	return table, nil
+}
+
+func (ftm *fakeTableManager) CreateTable(tableSchema *schema.Table) error {
+	ftm.mutex.Lock()
+	defer ftm.mutex.Unlock()
+
+	ftm.creates++
+	if _, ok := ftm.tables[tableSchema.Name]; ok {
+		return fmt.Errorf("table %s already exists", tableSchema.Name)
+	}
+	ftm.tables[tableSchema.Name] = schema.Columns{}
+	for column, value := range tableSchema.Columns {
+		ftm.tables[tableSchema.Name][column] = value
+	}
+
+	return nil
+}
+
+func (ftm *fakeTableManager) PatchTableSchema(patchSchema *schema.Table) error {
+	ftm.mutex.Lock()
+	defer ftm.mutex.Unlock()
+
+	ftm.patches++
+	for column, value := range patchSchema.Columns {
+		if _, ok := ftm.tables[patchSchema.Name][column]; ok {
+			return fmt.Errorf("column %s of table %s already exists", column, patchSchema.Name)
+		}
+		ftm.tables[patchSchema.Name][column] = value
+	}
+
+	return nil
+}
+
+//Run with -race: concurrent inserts (drain_workers > 1) create the table once and add every column once
+func TestTablesCacheEnsureTable(t *testing.T) {
+	manager := &fakeTableManager{tables: map[string]schema.Columns{"existing": {"id": schema.Column{Type: schema.INT64}}}}
+	cache := newTablesCache()
+	var existing []string
+	var patchedColumns int64
+	var callbacksMutex sync.Mutex
+	onExisting := func(table *schema.Table) error {
+		callbacksMutex.Lock()
+		existing = append(existing, table.Name)
+		callbacksMutex.Unlock()
+		return nil
+	}
+	onPatch := func(diff *schema.Table) {
+		callbacksMutex.Lock()
+		patchedColumns += int64(len(diff.Columns))
+		callbacksMutex.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20*50)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				dataSchema := &schema.Table{Name: []string{"events", "existing"}[j%2], Columns: schema.Columns{
+					"id":                          schema.Column{Type: schema.INT64},
+					fmt.Sprintf("field_%d", i%10): schema.Column{Type: schema.STRING},
+				}}
+				table, err := cache.ensureTable("test", manager, dataSchema, onExisting, onPatch)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				for column := range dataSchema.Columns {
+					if _, ok := table.Columns[column]; !ok {
+						errs <- fmt.Errorf("column %s isn't in table %s", column, table.Name)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, manager.creates, "Table must be created once")
+	require.Equal(t, []string{"existing"}, existing, "Existing table must be handled once when it is cached")
+	//events: 10 fields are added after creation with the first one, existing: 10 fields
+	require.Equal(t, int64(19), patchedColumns)
+	for _, name := range []string{"events", "existing"} {
+		cached, ok := cache.Get(name)
+		require.True(t, ok)
+		require.Equal(t, manager.tables[name], cached.Columns, "Cached schema must match the table: %s", name)
+	}
+}
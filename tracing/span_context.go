@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+//TraceParentHeader is W3C Trace Context header (https://www.w3.org/TR/trace-context/)
+const TraceParentHeader = "traceparent"
+
+type contextKey struct{}
+
+//SpanContext identifies span of W3C trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	//W3C trace flags (01 - sampled)
+	Flags byte
+}
+
+//IsValid return true if trace id and span id aren't zero
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+//TraceParent return traceparent header value e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//or "" if span context isn't valid
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.Flags)
+}
+
+//ParseTraceParent return SpanContext from traceparent header value. Return false if value is malformed
+func ParseTraceParent(traceParent string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	//future versions may have more parts
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+
+	return sc, sc.IsValid()
+}
+
+//ContextWithSpanContext return ctx with span context which is used as parent of spans started with ctx
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+//SpanContextFromContext return span context of ctx or zero (not valid) one
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+//Return child span context: the same trace (or a new one if parent isn't valid) with a new span id
+func childSpanContext(parent SpanContext) SpanContext {
+	child := parent
+	if !parent.IsValid() {
+		rand.Read(child.TraceID[:])
+		child.Flags = 1
+	}
+	rand.Read(child.SpanID[:])
+
+	return child
+}
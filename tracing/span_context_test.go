@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceParent string
+		expectedOk  bool
+	}{
+		{"Valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"Not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"Future version with extra part", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"Empty", "", false},
+		{"Invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"Extra part in version 00", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"Zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"Short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", false},
+		{"Not hex", "00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceParent(tt.traceParent)
+			require.Equal(t, tt.expectedOk, ok)
+			if ok && tt.traceParent[:2] == "00" {
+				require.Equal(t, tt.traceParent, sc.TraceParent())
+			}
+		})
+	}
+}
+
+func TestLogTracer(t *testing.T) {
+	parent, ok := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+
+	tracer := NewLogTracer(nil, time.Hour)
+	ctx, span := tracer.Start(ContextWithSpanContext(context.Background(), parent), "enqueue", time.Now())
+	child := span.SpanContext()
+	require.Equal(t, parent.TraceID, child.TraceID, "Child span must be in parent trace")
+	require.NotEqual(t, parent.SpanID, child.SpanID)
+	require.Equal(t, child, SpanContextFromContext(ctx))
+	//shorter than threshold: isn't logged (nil logger isn't called)
+	span.End(nil)
+
+	_, root := tracer.Start(context.Background(), "consume", time.Now())
+	require.True(t, root.SpanContext().IsValid(), "New trace must be started without parent")
+}
+
+func TestNoopTracerPropagation(t *testing.T) {
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := Start(ContextWithSpanContext(context.Background(), parent), "enqueue")
+	require.Equal(t, parent, span.SpanContext())
+	span.End(nil)
+}
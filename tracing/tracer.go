@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"github.com/ksensehq/eventnative/logging"
+	"sync/atomic"
+	"time"
+)
+
+//Span is a timed pipeline stage of one event (e.g. enqueue, process, insert)
+type Span interface {
+	SpanContext() SpanContext
+	//End finish span. err is recorded as span error (nil - span succeeded)
+	End(err error)
+}
+
+//Tracer starts spans. OpenTelemetry (or any other) SDK is plugged with SetTracer:
+//parent span context is taken from ctx (see ContextWithSpanContext)
+type Tracer interface {
+	//Start return span which started at start time and ctx with its span context
+	Start(ctx context.Context, name string, start time.Time) (context.Context, Span)
+}
+
+type tracerHolder struct {
+	tracer Tracer
+}
+
+var globalTracer atomic.Value
+
+func init() {
+	globalTracer.Store(tracerHolder{tracer: noopTracer{}})
+}
+
+//SetTracer set global tracer. Spans aren't recorded by default (trace context is only propagated)
+func SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+	globalTracer.Store(tracerHolder{tracer: tracer})
+}
+
+//Start span with global tracer which starts now
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	return StartAt(ctx, name, time.Now())
+}
+
+//StartAt start span with global tracer which started at start time (e.g. queue waiting span)
+func StartAt(ctx context.Context, name string, start time.Time) (context.Context, Span) {
+	return globalTracer.Load().(tracerHolder).tracer.Start(ctx, name, start)
+}
+
+//noopTracer doesn't record spans but keeps incoming span context: trace is propagated through the pipeline
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string, start time.Time) (context.Context, Span) {
+	return ctx, noopSpan{spanContext: SpanContextFromContext(ctx)}
+}
+
+type noopSpan struct {
+	spanContext SpanContext
+}
+
+func (ns noopSpan) SpanContext() SpanContext { return ns.spanContext }
+func (ns noopSpan) End(err error)            {}
+
+//LogTracer writes spans which are longer than threshold (or failed) to the logger with trace and span ids
+type LogTracer struct {
+	logger    logging.Logger
+	threshold time.Duration
+}
+
+func NewLogTracer(logger logging.Logger, threshold time.Duration) *LogTracer {
+	return &LogTracer{logger: logger, threshold: threshold}
+}
+
+func (lt *LogTracer) Start(ctx context.Context, name string, start time.Time) (context.Context, Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &logSpan{
+		tracer:      lt,
+		name:        name,
+		start:       start,
+		parent:      parent,
+		spanContext: childSpanContext(parent),
+	}
+
+	return ContextWithSpanContext(ctx, span.spanContext), span
+}
+
+type logSpan struct {
+	tracer      *LogTracer
+	name        string
+	start       time.Time
+	parent      SpanContext
+	spanContext SpanContext
+}
+
+func (ls *logSpan) SpanContext() SpanContext {
+	return ls.spanContext
+}
+
+func (ls *logSpan) End(err error) {
+	duration := time.Since(ls.start)
+	if err == nil && duration < ls.tracer.threshold {
+		return
+	}
+
+	keysAndValues := []interface{}{"span", ls.name, "trace_parent", ls.spanContext.TraceParent(), "duration", duration}
+	if ls.parent.IsValid() {
+		keysAndValues = append(keysAndValues, "parent", ls.parent.TraceParent())
+	}
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err)
+	}
+	ls.tracer.logger.Info("Trace span", keysAndValues...)
+}
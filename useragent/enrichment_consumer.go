@@ -0,0 +1,69 @@
+package useragent
+
+import (
+	"context"
+	"github.com/ksensehq/eventnative/events"
+)
+
+const (
+	BrowserKey = "ua_browser"
+	OsKey      = "ua_os"
+	DeviceKey  = "ua_device"
+	IsBotKey   = "ua_is_bot"
+)
+
+//EnrichmentConsumer adds ua_browser, ua_os, ua_device and ua_is_bot fields (parsed from user-agent field) to facts
+//and passes them to underlying consumer
+type EnrichmentConsumer struct {
+	consumer events.Consumer
+	resolver *Resolver
+	uaField  string
+}
+
+//NewEnrichmentConsumer return EnrichmentConsumer which reads user-agent from field path e.g. /eventn_ctx/user_agent
+func NewEnrichmentConsumer(consumer events.Consumer, resolver *Resolver, uaField string) *EnrichmentConsumer {
+	return &EnrichmentConsumer{consumer: consumer, resolver: resolver, uaField: uaField}
+}
+
+//Consume enrich fact with parsed user-agent and pass it to underlying consumer
+func (ec *EnrichmentConsumer) Consume(fact events.Fact) {
+	ec.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx enrich fact with parsed user-agent and pass it with ctx to underlying consumer (see events.ConsumeCtx)
+//Fact is copied because the same fact is passed to other consumers
+func (ec *EnrichmentConsumer) ConsumeCtx(ctx context.Context, fact events.Fact) error {
+	ua, _ := fact.Get(ec.uaField).(string)
+	if ua == "" {
+		return events.ConsumeCtx(ctx, ec.consumer, fact)
+	}
+
+	enriched := events.Fact{}
+	for k, v := range fact {
+		enriched[k] = v
+	}
+	enriched[IsBotKey] = ec.resolver.IsBot(ua)
+	if resolved := ec.resolver.Resolve(ua); resolved != nil {
+		if resolved.UaFamily != "" {
+			enriched[BrowserKey] = resolved.UaFamily
+		}
+		if resolved.OsFamily != "" {
+			enriched[OsKey] = resolved.OsFamily
+		}
+		if resolved.DeviceFamily != "" {
+			enriched[DeviceKey] = resolved.DeviceFamily
+		}
+	}
+
+	return events.ConsumeCtx(ctx, ec.consumer, enriched)
+}
+
+//Health return underlying consumer health
+func (ec *EnrichmentConsumer) Health() error {
+	return events.CheckHealth(ec.consumer)
+}
+
+//Close underlying consumer
+func (ec *EnrichmentConsumer) Close() error {
+	return ec.consumer.Close()
+}
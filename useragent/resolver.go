@@ -4,10 +4,17 @@ import (
 	"fmt"
 	"github.com/ua-parser/uap-go/uaparser"
 	"log"
+	"regexp"
 )
 
 const ParsedUaKey = "parsed_ua"
 
+//ua-parser device family of crawlers
+const spiderDeviceFamily = "Spider"
+
+//common bots user-agent markers which ua-parser doesn't recognize as Spider
+var botRegexp = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|scrapy|headless|lighthouse|facebookexternalhit|curl|wget|python-requests|go-http-client`)
+
 type Resolver struct {
 	parser *uaparser.Parser
 }
@@ -82,3 +89,16 @@ func (r Resolver) Resolve(ua string) *ResolvedUa {
 
 	return resolved
 }
+
+//IsBot return true if user-agent belongs to crawler, bot or http library
+func (r Resolver) IsBot(ua string) bool {
+	if ua == "" {
+		return false
+	}
+	if botRegexp.MatchString(ua) {
+		return true
+	}
+
+	device := r.parser.ParseDevice(ua)
+	return device != nil && device.Family == spiderDeviceFamily
+}
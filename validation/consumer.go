@@ -0,0 +1,126 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/xeipuuv/gojsonschema"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultEventTypeField = "/event_type"
+
+//Consumer validates facts with JSON Schema of their event type and passes only valid ones to underlying consumer
+//Invalid facts are put to the dead-letter queue of underlying consumer (if it has one, see events.DeadLetterer)
+//with validation errors as a reason or skipped. Facts of event types without schema are passed as is
+//Schemas are reloaded from directory every reload interval
+type Consumer struct {
+	consumer       events.Consumer
+	schemas        *SchemaSet
+	eventTypeField string
+	invalid        uint64
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+//NewConsumer return Consumer with schemas loaded from schemasDir and started reloading goroutine
+//eventTypeField is a JSON path e.g. /event_type
+func NewConsumer(consumer events.Consumer, schemasDir, eventTypeField string, reloadInterval time.Duration) (*Consumer, error) {
+	schemas, err := NewSchemaSet(schemasDir)
+	if err != nil {
+		return nil, err
+	}
+
+	vc := &Consumer{consumer: consumer, schemas: schemas, eventTypeField: eventTypeField, closed: make(chan struct{})}
+	if reloadInterval > 0 {
+		go vc.reload(reloadInterval)
+	}
+
+	return vc, nil
+}
+
+func (vc *Consumer) reload(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-vc.closed:
+			return
+		case <-ticker.C:
+			if _, err := vc.schemas.Reload(); err != nil {
+				log.Printf("Error reloading json schemas: %v", err)
+			}
+		}
+	}
+}
+
+//Consume pass fact to underlying consumer if it is valid
+func (vc *Consumer) Consume(fact events.Fact) {
+	vc.ConsumeCtx(context.Background(), fact)
+}
+
+//ConsumeCtx pass fact with ctx to underlying consumer if it is valid (see events.ConsumeCtx)
+//Invalid facts are dead-lettered or skipped without error
+func (vc *Consumer) ConsumeCtx(ctx context.Context, fact events.Fact) error {
+	err := vc.validate(fact)
+	if err == nil {
+		return events.ConsumeCtx(ctx, vc.consumer, fact)
+	}
+
+	atomic.AddUint64(&vc.invalid, 1)
+	if dl, ok := vc.consumer.(events.DeadLetterer); ok {
+		dl.DeadLetter(fact, err)
+		return nil
+	}
+	log.Printf("Warn: event %v is invalid: %v. This event will be skipped", fact, err)
+	return nil
+}
+
+//Return error with all validation errors or nil if fact is valid or its event type doesn't have a schema
+func (vc *Consumer) validate(fact events.Fact) error {
+	eventType := fact.Get(vc.eventTypeField)
+	if eventType == nil {
+		return nil
+	}
+	schema := vc.schemas.Get(fmt.Sprint(eventType))
+	if schema == nil {
+		return nil
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(map[string]interface{}(fact)))
+	if err != nil {
+		return fmt.Errorf("Error validating event: %v", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	var validationErrors []string
+	for _, resultErr := range result.Errors() {
+		validationErrors = append(validationErrors, resultErr.String())
+	}
+
+	return errors.New("JSON schema validation errors: " + strings.Join(validationErrors, "; "))
+}
+
+//Invalid return count of facts which haven't passed validation
+func (vc *Consumer) Invalid() uint64 {
+	return atomic.LoadUint64(&vc.invalid)
+}
+
+//Health return underlying consumer health
+func (vc *Consumer) Health() error {
+	return events.CheckHealth(vc.consumer)
+}
+
+//Close stop schemas reloading and close underlying consumer
+func (vc *Consumer) Close() error {
+	vc.closeOnce.Do(func() { close(vc.closed) })
+	return vc.consumer.Close()
+}
@@ -0,0 +1,230 @@
+package validation
+
+import (
+	"context"
+	"github.com/ksensehq/eventnative/events"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const pageviewSchema = `{
+  "type": "object",
+  "properties": {
+    "user_id": {"type": "string"},
+    "duration": {"type": "integer", "minimum": 0}
+  },
+  "required": ["user_id"]
+}`
+
+type recordingConsumer struct {
+	mutex sync.Mutex
+	facts []events.Fact
+}
+
+func (rc *recordingConsumer) Consume(fact events.Fact) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	rc.facts = append(rc.facts, fact)
+}
+
+func (rc *recordingConsumer) Facts() []events.Fact {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	return append([]events.Fact{}, rc.facts...)
+}
+
+func (rc *recordingConsumer) Close() error {
+	return nil
+}
+
+//recordingConsumer with dead-letter queue
+type deadLetterConsumer struct {
+	recordingConsumer
+	deadLettered []events.Fact
+	reasons      []error
+}
+
+func (dlc *deadLetterConsumer) DeadLetter(fact events.Fact, reason error) {
+	dlc.mutex.Lock()
+	defer dlc.mutex.Unlock()
+
+	dlc.deadLettered = append(dlc.deadLettered, fact)
+	dlc.reasons = append(dlc.reasons, reason)
+}
+
+func writeSchema(t *testing.T, dir, eventType, content string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, eventType+schemaFileExtension), []byte(content), 0644))
+}
+
+func newTestSchemasDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "validation_test")
+	require.NoError(t, err)
+	writeSchema(t, dir, "pageview", pageviewSchema)
+	//not a schema file
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("schemas"), 0644))
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestConsumerValidation(t *testing.T) {
+	dir, remove := newTestSchemasDir(t)
+	defer remove()
+
+	tests := []struct {
+		name          string
+		fact          events.Fact
+		expectedValid bool
+		expectedError string
+	}{
+		{
+			"Valid event",
+			events.Fact{"event_type": "pageview", "user_id": "user_1", "duration": 10},
+			true,
+			"",
+		},
+		{
+			"Missing required field",
+			events.Fact{"event_type": "pageview", "duration": 10},
+			false,
+			"user_id is required",
+		},
+		{
+			"Wrong type",
+			events.Fact{"event_type": "pageview", "user_id": 1},
+			false,
+			"user_id: Invalid type",
+		},
+		{
+			"Value out of range",
+			events.Fact{"event_type": "pageview", "user_id": "user_1", "duration": -1},
+			false,
+			"duration: Must be greater than or equal to 0",
+		},
+		{
+			"Event type without schema",
+			events.Fact{"event_type": "click"},
+			true,
+			"",
+		},
+		{
+			"Event without event type",
+			events.Fact{"user_id": 1},
+			true,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := &deadLetterConsumer{}
+			vc, err := NewConsumer(consumer, dir, DefaultEventTypeField, 0)
+			require.NoError(t, err)
+			defer vc.Close()
+
+			require.NoError(t, vc.ConsumeCtx(context.Background(), tt.fact))
+			if tt.expectedValid {
+				require.Equal(t, []events.Fact{tt.fact}, consumer.Facts())
+				require.Empty(t, consumer.deadLettered)
+				require.Equal(t, uint64(0), vc.Invalid())
+				return
+			}
+
+			require.Empty(t, consumer.Facts(), "Invalid event mustn't be passed")
+			require.Equal(t, []events.Fact{tt.fact}, consumer.deadLettered, "Invalid event must be dead-lettered")
+			require.Contains(t, consumer.reasons[0].Error(), tt.expectedError)
+			require.Equal(t, uint64(1), vc.Invalid())
+		})
+	}
+}
+
+func TestConsumerWithoutDeadLetter(t *testing.T) {
+	dir, remove := newTestSchemasDir(t)
+	defer remove()
+
+	consumer := &recordingConsumer{}
+	vc, err := NewConsumer(consumer, dir, DefaultEventTypeField, 0)
+	require.NoError(t, err)
+	defer vc.Close()
+
+	valid := events.Fact{"event_type": "pageview", "user_id": "user_1"}
+	vc.Consume(events.Fact{"event_type": "pageview"})
+	vc.Consume(valid)
+
+	require.Equal(t, []events.Fact{valid}, consumer.Facts(), "Invalid event must be skipped")
+	require.Equal(t, uint64(1), vc.Invalid())
+}
+
+func TestConsumerReload(t *testing.T) {
+	dir, remove := newTestSchemasDir(t)
+	defer remove()
+
+	consumer := &deadLetterConsumer{}
+	vc, err := NewConsumer(consumer, dir, DefaultEventTypeField, 10*time.Millisecond)
+	require.NoError(t, err)
+	defer vc.Close()
+
+	click := events.Fact{"event_type": "click"}
+	require.NoError(t, vc.ConsumeCtx(context.Background(), click))
+	require.Len(t, consumer.Facts(), 1, "Event type without schema must be passed")
+
+	//a new schema is picked up by the reloading goroutine
+	writeSchema(t, dir, "click", `{"type": "object", "required": ["target"]}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for vc.schemas.Get("click") == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, vc.ConsumeCtx(context.Background(), click))
+	require.Len(t, consumer.Facts(), 1)
+	require.Equal(t, uint64(1), vc.Invalid(), "Event must be validated with the new schema")
+}
+
+func TestSchemaSetReload(t *testing.T) {
+	dir, remove := newTestSchemasDir(t)
+	defer remove()
+
+	ss, err := NewSchemaSet(dir)
+	require.NoError(t, err)
+	require.NotNil(t, ss.Get("pageview"))
+	require.Nil(t, ss.Get("README"))
+
+	reloaded, err := ss.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded, "Schemas mustn't be reloaded without changes")
+
+	//changed schema replaces the previous one
+	writeSchema(t, dir, "pageview", strings.Replace(pageviewSchema, `["user_id"]`, `["user_id", "duration"]`, 1))
+	reloaded, err = ss.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	result, err := ss.Get("pageview").Validate(gojsonschema.NewGoLoader(map[string]interface{}{"user_id": "user_1"}))
+	require.NoError(t, err)
+	require.False(t, result.Valid(), "Changed schema must be used")
+
+	//malformed schema keeps the previous version
+	previous := ss.Get("pageview")
+	writeSchema(t, dir, "pageview", `{"type": `)
+	writeSchema(t, dir, "click", `{"type": "object"}`)
+	reloaded, err = ss.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Equal(t, previous, ss.Get("pageview"), "Previous version of malformed schema must be kept")
+	require.NotNil(t, ss.Get("click"))
+
+	//removed schema is removed from the set
+	require.NoError(t, os.Remove(filepath.Join(dir, "click"+schemaFileExtension)))
+	reloaded, err = ss.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.Nil(t, ss.Get("click"))
+
+	_, err = NewSchemaSet(filepath.Join(dir, "not_existing"))
+	require.Error(t, err)
+}
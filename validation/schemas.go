@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/xeipuuv/gojsonschema"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const schemaFileExtension = ".json"
+
+//SchemaSet is a set of JSON Schemas per event type loaded from directory: <dir>/<event_type>.json
+//Is thread-safe: Reload() may be called concurrently with Get()
+type SchemaSet struct {
+	dir string
+
+	mutex   sync.RWMutex
+	schemas map[string]*gojsonschema.Schema
+	//file names with modification times and sizes of the last reload
+	signature string
+}
+
+//NewSchemaSet return SchemaSet with loaded schemas or error if directory can't be read
+func NewSchemaSet(dir string) (*SchemaSet, error) {
+	ss := &SchemaSet{dir: dir, schemas: map[string]*gojsonschema.Schema{}}
+	if _, err := ss.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+//Get return schema of event type or nil if it isn't configured
+func (ss *SchemaSet) Get(eventType string) *gojsonschema.Schema {
+	ss.mutex.RLock()
+	defer ss.mutex.RUnlock()
+
+	return ss.schemas[eventType]
+}
+
+//Reload read schema files if they have been changed since the last reload
+//Malformed schema files are logged and previous versions of these schemas are kept
+//Return true if schemas have been reloaded
+func (ss *SchemaSet) Reload() (bool, error) {
+	files, err := ioutil.ReadDir(ss.dir)
+	if err != nil {
+		return false, fmt.Errorf("Error reading json schemas dir %s: %v", ss.dir, err)
+	}
+
+	var schemaFiles []os.FileInfo
+	var signature []string
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != schemaFileExtension {
+			continue
+		}
+		schemaFiles = append(schemaFiles, file)
+		signature = append(signature, fmt.Sprintf("%s:%d:%d", file.Name(), file.ModTime().UnixNano(), file.Size()))
+	}
+	sort.Strings(signature)
+
+	ss.mutex.RLock()
+	unchanged := ss.signature == strings.Join(signature, ",")
+	ss.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	schemas := map[string]*gojsonschema.Schema{}
+	for _, file := range schemaFiles {
+		eventType := strings.TrimSuffix(file.Name(), schemaFileExtension)
+		filePath := filepath.Join(ss.dir, file.Name())
+		schema, err := loadSchema(filePath)
+		if err != nil {
+			log.Printf("Error loading json schema of %s event type from %s: %v. Previous version will be used", eventType, filePath, err)
+			if previous := ss.Get(eventType); previous != nil {
+				schemas[eventType] = previous
+			}
+			continue
+		}
+		schemas[eventType] = schema
+	}
+
+	ss.mutex.Lock()
+	ss.schemas = schemas
+	ss.signature = strings.Join(signature, ",")
+	ss.mutex.Unlock()
+
+	log.Printf("Loaded %d json schemas from %s", len(schemas), ss.dir)
+
+	return true, nil
+}
+
+func loadSchema(filePath string) (*gojsonschema.Schema, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return gojsonschema.NewSchema(gojsonschema.NewBytesLoader(b))
+}